@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/dig"
+	"log"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	"strconv"
+	"time"
+)
+
+func accountCommand(ctx context.Context, dpd *dig.Container, args []string) error {
+	if len(args) != 2 || args[0] != "freeze" {
+		return errors.New("usage: app account freeze ID")
+	}
+	id, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("freeze takes an account id, got %q", args[1])
+	}
+
+	return dpd.Invoke(func(store db.Store, recorder audit.Recorder) error {
+		account, err := freezeAccount(ctx, store, recorder, id)
+		if err != nil {
+			return err
+		}
+		log.Printf("account %d of %s is frozen since %s", account.ID, account.Owner, account.FrozenAt.Format(time.RFC3339))
+		return nil
+	})
+}
+
+// freezeAccount stops transfers from and to the account. Freezing a frozen
+// account keeps the time it was first frozen.
+func freezeAccount(ctx context.Context, store db.Store, recorder audit.Recorder, id int64) (db.Account, error) {
+	account, err := store.GetAccount(ctx, id)
+	if err != nil {
+		return db.Account{}, fmt.Errorf("account %d: %w", id, err)
+	}
+	if !account.FrozenAt.IsZero() {
+		return account, nil
+	}
+
+	if account, err = store.FreezeAccount(ctx, id); err != nil {
+		return db.Account{}, err
+	}
+
+	err = recorder.Record(ctx, audit.Event{
+		Actor:      cliActor,
+		Action:     "account.freeze",
+		TargetType: "account",
+		TargetID:   strconv.FormatInt(id, 10),
+		Outcome:    audit.OutcomeSuccess,
+	})
+	return account, err
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"log"
+	"log/slog"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/config"
+	"simple-bank/internal/db"
+	memdb "simple-bank/internal/db/memory"
+	"simple-bank/internal/security"
+	"simple-bank/internal/utils"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testHashParams keeps argon2 cheap, the tests hash a handful of passwords.
+var testHashParams = security.PasswordParams{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func TestSeed(t *testing.T) {
+	ctx := context.Background()
+	store := memdb.NewStore()
+
+	result, err := seed(ctx, store, testHashParams, seedParams{Users: 3, Transfers: 10, Password: "seed-password"})
+	require.NoError(t, err)
+	require.Len(t, result.Users, 3)
+	require.Len(t, result.Accounts, 3*len(utils.SupportedCurrencies))
+	require.Len(t, result.Transfers, 10)
+
+	for _, user := range result.Users {
+		require.False(t, user.EmailVerifiedAt.IsZero())
+		_, err = security.ComparePasswordAndHash("seed-password", user.HashedPassword, testHashParams)
+		require.NoError(t, err)
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, verifyLedger(ctx, store, &out))
+	require.Equal(t, "ledger is consistent\n", out.String())
+}
+
+func TestVerifyLedger_Mismatch(t *testing.T) {
+	ctx := context.Background()
+	store := memdb.NewStore()
+
+	result, err := seed(ctx, store, testHashParams, seedParams{Users: 2, Password: "seed-password"})
+	require.NoError(t, err)
+	account := result.Accounts[0]
+	_, err = store.CreateEntry(ctx, db.CreateEntryParams{AccountID: account.ID, Amount: 7})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.ErrorContains(t, verifyLedger(ctx, store, &out), "1 inconsistent accounts")
+	require.Equal(t, "account "+strconv.FormatInt(account.ID, 10)+": entries total 7, transfers total 0\n", out.String())
+}
+
+func TestCreateAdmin(t *testing.T) {
+	ctx := context.Background()
+	store := memdb.NewStore()
+	recorder := audit.NewStoreRecorder(store)
+	policy := security.PasswordPolicy{MinLength: 8}
+	params := createAdminParams{Username: "root", FullName: "Root", Email: "root@example.com", Password: "correct horse"}
+
+	user, err := createAdmin(ctx, store, policy, testHashParams, recorder, params)
+	require.NoError(t, err)
+	require.Equal(t, db.UserRoleAdmin, user.Role)
+	require.False(t, user.EmailVerifiedAt.IsZero())
+
+	events, err := store.ListAuditEvents(ctx, db.ListAuditEventsParams{
+		TargetID: pgtype.Text{String: "root", Valid: true},
+		RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, cliActor, events[0].Actor)
+	require.Equal(t, "user.create_admin", events[0].Action)
+
+	params.Username, params.Email, params.Password = "weak", "weak@example.com", "short"
+	_, err = createAdmin(ctx, store, policy, testHashParams, recorder, params)
+	require.ErrorIs(t, err, security.ErrPasswordTooShort)
+	_, err = store.GetUser(ctx, "weak")
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestReadPassword(t *testing.T) {
+	password, err := readPassword(strings.NewReader("correct horse\r\nignored\n"))
+	require.NoError(t, err)
+	require.Equal(t, "correct horse", password)
+
+	_, err = readPassword(strings.NewReader(""))
+	require.Error(t, err)
+}
+
+func TestFreezeAccount(t *testing.T) {
+	ctx := context.Background()
+	store := memdb.NewStore()
+	recorder := audit.NewStoreRecorder(store)
+
+	result, err := seed(ctx, store, testHashParams, seedParams{Users: 1, Password: "seed-password"})
+	require.NoError(t, err)
+	id := result.Accounts[0].ID
+
+	frozen, err := freezeAccount(ctx, store, recorder, id)
+	require.NoError(t, err)
+	require.False(t, frozen.FrozenAt.IsZero())
+
+	again, err := freezeAccount(ctx, store, recorder, id)
+	require.NoError(t, err)
+	require.Equal(t, frozen.FrozenAt, again.FrozenAt)
+
+	events, err := store.ListAuditEvents(ctx, db.ListAuditEventsParams{
+		Action:   pgtype.Text{String: "account.freeze", Valid: true},
+		RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1, "freezing a frozen account is not recorded")
+
+	_, err = freezeAccount(ctx, store, recorder, -1)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestSetupLogging_Level(t *testing.T) {
+	var out bytes.Buffer
+	logger := newLogger(&out, config.Fixed(&config.Config{LogLevel: "error"}))
+
+	defaultLogger := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	slog.Info("routine line")
+	log.Println("log package line")
+	slog.Error("webhook sender failed", "err", errors.New("boom"))
+
+	printed := out.String()
+	require.NotContains(t, printed, "routine line")
+	require.NotContains(t, printed, "log package line")
+	require.Contains(t, printed, `level=ERROR msg="webhook sender failed" err=boom`)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/dig"
+	"os"
+	"simple-bank/internal/config"
+)
+
+// configCommand reads the configuration itself rather than through the
+// container, an invalid one is still printed, followed by what is wrong.
+func configCommand(_ context.Context, _ *dig.Container, args []string) error {
+	if len(args) != 1 || args[0] != "print" {
+		return errors.New("usage: app config print")
+	}
+
+	cfg, err := config.Read("./")
+	if err != nil {
+		return err
+	}
+	if err = cfg.Print(os.Stdout); err != nil {
+		return err
+	}
+	if err = cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/dig"
+	"io"
+	"os"
+	"simple-bank/internal/db"
+)
+
+func ledgerCommand(ctx context.Context, dpd *dig.Container, args []string) error {
+	if len(args) != 1 || args[0] != "verify" {
+		return errors.New("usage: app ledger verify")
+	}
+
+	return dpd.Invoke(func(store db.Store) error {
+		return verifyLedger(ctx, store, os.Stdout)
+	})
+}
+
+// verifyLedger checks that the entries of every account add up to its
+// transfers and prints the accounts that do not. Balances are not checked,
+// the opening balance of an account has no entry.
+func verifyLedger(ctx context.Context, store db.Store, w io.Writer) error {
+	mismatches, err := store.ListLedgerMismatches(ctx)
+	if err != nil {
+		return err
+	}
+	if len(mismatches) == 0 {
+		_, err = fmt.Fprintln(w, "ledger is consistent")
+		return err
+	}
+
+	for _, mismatch := range mismatches {
+		_, err = fmt.Fprintf(w, "account %d: entries total %d, transfers total %d\n", mismatch.AccountID, mismatch.EntriesTotal, mismatch.TransfersTotal)
+		if err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("ledger has %d inconsistent accounts", len(mismatches))
+}
@@ -1,21 +1,53 @@
 package main
 
 import (
-	_ "github.com/lib/pq"
-	"simple-bank/internal/api"
-	"simple-bank/internal/config"
+	"context"
+	"fmt"
+	"go.uber.org/dig"
+	"log"
+	"os"
 	"simple-bank/internal/dependency"
-	"simple-bank/internal/utils"
 )
 
+const usage = `usage: app COMMAND [ARGS]
+
+commands:
+  serve                                  start the HTTP and gRPC servers, the default
+  migrate up | down [N] | version | force VERSION
+  seed [-users N] [-transfers N] [-password P]
+  user create-admin -username U -email E [-full-name F], password on stdin
+  account freeze ID
+  ledger verify
+  config print                           show the effective configuration, secrets masked`
+
+// command runs a subcommand, dependencies are resolved through dpd so a
+// command only builds what it uses.
+type command func(ctx context.Context, dpd *dig.Container, args []string) error
+
+var commands = map[string]command{
+	"serve":   serveCommand,
+	"migrate": migrateCommand,
+	"seed":    seedCommand,
+	"user":    userCommand,
+	"account": accountCommand,
+	"ledger":  ledgerCommand,
+	"config":  configCommand,
+}
+
 func main() {
-	dpd := dependency.NewDependency()
+	if err := run(context.Background(), dependency.NewDependency(), os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
 
-	utils.NoError(dpd.Invoke(func(server *api.Server, cfg *config.Config) {
-		go func() {
-			utils.NoError(server.Start(cfg.ServerAddress))
-		}()
-	}))
+func run(ctx context.Context, dpd *dig.Container, args []string) error {
+	if len(args) == 0 {
+		return serveCommand(ctx, dpd, nil)
+	}
 
-	select {}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+	return cmd(ctx, dpd, args[1:])
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/dig"
+	"log"
+	"simple-bank/internal/config"
+	"simple-bank/internal/migrate"
+	"strconv"
+)
+
+const migrateUsage = "usage: app migrate up | down [N] | version | force VERSION"
+
+func migrateCommand(ctx context.Context, dpd *dig.Container, args []string) error {
+	return dpd.Invoke(func(migrator *migrate.Migrator) error {
+		return runMigrate(ctx, migrator, args)
+	})
+}
+
+// runMigrate is the migrate subcommand, down reverts one migration unless
+// told how many.
+func runMigrate(ctx context.Context, migrator *migrate.Migrator, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch command, args := args[0], args[1:]; command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			log.Printf("applied %d_%s", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			log.Printf("no change, the schema is at version %d", migrator.Latest())
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n < 1 {
+				return fmt.Errorf("down takes a number of migrations, got %q", args[0])
+			}
+			steps = n
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, migration := range reverted {
+			log.Printf("reverted %d_%s", migration.Version, migration.Name)
+		}
+		return err
+	case "version":
+		version, dirty, err := migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			fmt.Printf("%d (dirty)\n", version)
+		} else {
+			fmt.Println(version)
+		}
+		return nil
+	case "force":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("force takes a version, got %q", args[0])
+		}
+		return migrator.Force(ctx, uint(version))
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// prepareSchema migrates the database when AUTO_MIGRATE is set, the server
+// does not start on a schema older than the binary.
+func prepareSchema(ctx context.Context, cfg *config.Config, migrator *migrate.Migrator) error {
+	if cfg.AutoMigrate {
+		if err := runMigrate(ctx, migrator, []string{"up"}); err != nil {
+			return err
+		}
+	}
+
+	if err := migrator.Check(ctx); err != nil {
+		return fmt.Errorf("refusing to serve: %w, run app migrate up", err)
+	}
+	return nil
+}
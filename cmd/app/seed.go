@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go.uber.org/dig"
+	"log"
+	"simple-bank/internal/config"
+	"simple-bank/internal/db"
+	"simple-bank/internal/random"
+	"simple-bank/internal/security"
+	"simple-bank/internal/utils"
+)
+
+type seedParams struct {
+	Users     int
+	Transfers int
+	Password  string
+}
+
+type seedResult struct {
+	Users     []db.User
+	Accounts  []db.Account
+	Transfers []db.Transfer
+}
+
+func seedCommand(ctx context.Context, dpd *dig.Container, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	params := seedParams{}
+	flags.IntVar(&params.Users, "users", 10, "users to create, each with an account per currency")
+	flags.IntVar(&params.Transfers, "transfers", 50, "transfers to make between the new accounts")
+	flags.StringVar(&params.Password, "password", "seed-password", "password of the new users")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	return dpd.Invoke(func(cfg *config.Config, store db.Store) error {
+		result, err := seed(ctx, store, passwordParams(cfg), params)
+		log.Printf("seeded %d users, %d accounts and %d transfers", len(result.Users), len(result.Accounts), len(result.Transfers))
+		return err
+	})
+}
+
+// seed fills the database with random users, accounts and transfers. The
+// users have a verified email so they can log in and transfer right away.
+// Transfers go through TransferTx, the ledger stays consistent.
+func seed(ctx context.Context, store db.Store, hashParams security.PasswordParams, params seedParams) (seedResult, error) {
+	var result seedResult
+
+	// one hash for every user, hashing is slow on purpose
+	hashedPassword, err := security.HashPassword(params.Password, hashParams)
+	if err != nil {
+		return result, err
+	}
+
+	byCurrency := make(map[string][]db.Account)
+	for range params.Users {
+		user, err := store.CreateUser(ctx, db.CreateUserParams{
+			Username:       random.Username(),
+			HashedPassword: hashedPassword,
+			FullName:       random.String(6) + " " + random.String(8),
+			Email:          random.UserEmail(),
+		})
+		if err != nil {
+			return result, fmt.Errorf("create user: %w", err)
+		}
+		verified, err := store.VerifyUserEmail(ctx, user.Username)
+		if err != nil {
+			return result, fmt.Errorf("verify user %s: %w", user.Username, err)
+		}
+		result.Users = append(result.Users, verified)
+
+		for _, currency := range utils.SupportedCurrencies {
+			account, err := store.CreateAccountTx(ctx, db.CreateAccountParams{
+				Owner:    user.Username,
+				Balance:  random.AccountBalance(),
+				Currency: currency,
+			})
+			if err != nil {
+				return result, fmt.Errorf("create account of %s: %w", user.Username, err)
+			}
+			result.Accounts = append(result.Accounts, account)
+			byCurrency[currency] = append(byCurrency[currency], account)
+		}
+	}
+
+	if params.Users < 2 {
+		return result, nil
+	}
+	for range params.Transfers {
+		accounts := byCurrency[random.AccountCurrency()]
+		from := random.Int(0, len(accounts))
+		to := (from + random.Int(1, len(accounts))) % len(accounts)
+
+		transfer, err := store.TransferTx(ctx, db.TransferTxParams{
+			FromAccountID: accounts[from].ID,
+			ToAccountID:   accounts[to].ID,
+			Amount:        random.Int64(1, 100),
+		})
+		if err != nil {
+			return result, fmt.Errorf("transfer: %w", err)
+		}
+		result.Transfers = append(result.Transfers, transfer.Transfer)
+	}
+	return result, nil
+}
+
+func passwordParams(cfg *config.Config) security.PasswordParams {
+	return security.PasswordParams{
+		Memory:      cfg.PasswordHashMemory,
+		Iterations:  cfg.PasswordHashIterations,
+		Parallelism: cfg.PasswordHashParallelism,
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"go.uber.org/dig"
+	"io"
+	"log/slog"
+	"os"
+	"simple-bank/internal/api"
+	"simple-bank/internal/config"
+	"simple-bank/internal/gapi"
+	"simple-bank/internal/migrate"
+	"simple-bank/internal/outbox"
+	"simple-bank/internal/stream"
+	"simple-bank/internal/utils"
+	"simple-bank/internal/webhook"
+)
+
+// serveCommand starts the servers and the background workers, it only
+// returns when the schema is not ready.
+func serveCommand(ctx context.Context, dpd *dig.Container, _ []string) error {
+	err := dpd.Invoke(func(configs *config.Watcher, migrator *migrate.Migrator) error {
+		setupLogging(configs)
+		return prepareSchema(ctx, configs.Current(), migrator)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = dpd.Invoke(func(server *api.Server, grpcServer *gapi.Server, relay *outbox.Relay, webhooks *webhook.Sender, accountEvents stream.Listener, cfg *config.Config) {
+		go func() {
+			utils.NoError(server.Start(cfg.ServerAddress))
+		}()
+		go func() {
+			utils.NoError(grpcServer.Start(cfg.GRPCServerAddress))
+		}()
+		go func() {
+			_ = relay.Run(context.Background())
+		}()
+		go func() {
+			_ = webhooks.Run(context.Background())
+		}()
+		go func() {
+			utils.NoError(accountEvents.Run(context.Background()))
+		}()
+	})
+	if err != nil {
+		return err
+	}
+
+	select {}
+}
+
+// setupLogging routes logs through slog at LOG_LEVEL and follows its
+// reloads. Lines of the log package are logged at info.
+func setupLogging(configs *config.Watcher) {
+	slog.SetDefault(newLogger(os.Stderr, configs))
+}
+
+func newLogger(w io.Writer, configs *config.Watcher) *slog.Logger {
+	level := &slog.LevelVar{}
+	setLevel := func(cfg *config.Config) {
+		if l, err := cfg.SlogLevel(); err == nil {
+			level.Set(l)
+		}
+	}
+	setLevel(configs.Current())
+	configs.Subscribe(setLevel)
+
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go.uber.org/dig"
+	"io"
+	"log"
+	"os"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/config"
+	"simple-bank/internal/db"
+	"simple-bank/internal/security"
+	"strings"
+)
+
+// cliActor is who the audit log attributes operator commands to.
+const cliActor = "cli"
+
+type createAdminParams struct {
+	Username string
+	FullName string
+	Email    string
+	Password string
+}
+
+func userCommand(ctx context.Context, dpd *dig.Container, args []string) error {
+	if len(args) == 0 || args[0] != "create-admin" {
+		return errors.New("usage: app user create-admin -username U -email E [-full-name F], password on stdin")
+	}
+
+	flags := flag.NewFlagSet("user create-admin", flag.ContinueOnError)
+	params := createAdminParams{}
+	flags.StringVar(&params.Username, "username", "", "username of the administrator")
+	flags.StringVar(&params.Email, "email", "", "email address of the administrator")
+	flags.StringVar(&params.FullName, "full-name", "Administrator", "full name of the administrator")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if params.Username == "" || params.Email == "" {
+		return errors.New("-username and -email are required")
+	}
+
+	// read from stdin so the password stays out of the shell history
+	password, err := readPassword(os.Stdin)
+	if err != nil {
+		return err
+	}
+	params.Password = password
+
+	return dpd.Invoke(func(cfg *config.Config, store db.Store, policy security.PasswordPolicy, recorder audit.Recorder) error {
+		user, err := createAdmin(ctx, store, policy, passwordParams(cfg), recorder, params)
+		if err != nil {
+			return err
+		}
+		log.Printf("created administrator %s", user.Username)
+		return nil
+	})
+}
+
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password on stdin")
+	}
+	return password, nil
+}
+
+// createAdmin creates a user with the admin role. The operator vouches for
+// the email address, it is verified right away.
+func createAdmin(ctx context.Context, store db.Store, policy security.PasswordPolicy, hashParams security.PasswordParams, recorder audit.Recorder, params createAdminParams) (db.User, error) {
+	if err := policy.Check(params.Password); err != nil {
+		return db.User{}, err
+	}
+	hashedPassword, err := security.HashPassword(params.Password, hashParams)
+	if err != nil {
+		return db.User{}, err
+	}
+
+	user, err := store.CreateUser(ctx, db.CreateUserParams{
+		Username:       params.Username,
+		HashedPassword: hashedPassword,
+		FullName:       params.FullName,
+		Email:          params.Email,
+	})
+	if err != nil {
+		return db.User{}, fmt.Errorf("create user: %w", err)
+	}
+	if _, err = store.VerifyUserEmail(ctx, user.Username); err != nil {
+		return db.User{}, fmt.Errorf("verify user: %w", err)
+	}
+	user, err = store.UpdateUserRole(ctx, db.UpdateUserRoleParams{Username: user.Username, Role: db.UserRoleAdmin})
+	if err != nil {
+		return db.User{}, fmt.Errorf("grant admin role: %w", err)
+	}
+
+	err = recorder.Record(ctx, audit.Event{
+		Actor:      cliActor,
+		Action:     "user.create_admin",
+		TargetType: "user",
+		TargetID:   user.Username,
+		Outcome:    audit.OutcomeSuccess,
+		Diff:       map[string]any{"role": db.UserRoleAdmin},
+	})
+	return user, err
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS login_attempts;
+
+ALTER TABLE IF EXISTS users DROP COLUMN IF EXISTS locked_until;
+
+ALTER TABLE IF EXISTS users DROP COLUMN IF EXISTS failed_login_attempts;
\ No newline at end of file
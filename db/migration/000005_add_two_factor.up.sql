@@ -0,0 +1,21 @@
+CREATE TABLE "user_totp" (
+                             "username" varchar PRIMARY KEY,
+                             "secret" varchar NOT NULL,
+                             "last_used_step" bigint NOT NULL DEFAULT 0,
+                             "confirmed_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z',
+                             "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "recovery_codes" (
+                                  "id" bigserial PRIMARY KEY,
+                                  "username" varchar NOT NULL,
+                                  "hashed_code" varchar NOT NULL,
+                                  "used_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z',
+                                  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "recovery_codes" ("username");
+
+ALTER TABLE "user_totp" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
@@ -0,0 +1,15 @@
+ALTER TABLE "users" ADD COLUMN "email_verified_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z';
+
+CREATE TABLE "user_tokens" (
+                               "id" bigserial PRIMARY KEY,
+                               "username" varchar NOT NULL,
+                               "purpose" varchar NOT NULL,
+                               "hashed_token" varchar UNIQUE NOT NULL,
+                               "expires_at" timestamptz NOT NULL,
+                               "used_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z',
+                               "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "user_tokens" ("username", "purpose");
+
+ALTER TABLE "user_tokens" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
@@ -0,0 +1,16 @@
+CREATE TABLE "api_keys" (
+                            "id" uuid PRIMARY KEY,
+                            "username" varchar NOT NULL,
+                            "name" varchar NOT NULL,
+                            "prefix" varchar UNIQUE NOT NULL,
+                            "hashed_secret" varchar NOT NULL,
+                            "scopes" varchar[] NOT NULL,
+                            "expires_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z',
+                            "last_used_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z',
+                            "revoked_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z',
+                            "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "api_keys" ("username");
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
@@ -0,0 +1,30 @@
+CREATE TABLE "oauth_clients" (
+                                 "id" varchar PRIMARY KEY,
+                                 "owner" varchar NOT NULL,
+                                 "name" varchar NOT NULL,
+                                 "hashed_secret" varchar NOT NULL,
+                                 "redirect_uris" varchar[] NOT NULL,
+                                 "scopes" varchar[] NOT NULL,
+                                 "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "oauth_authorization_codes" (
+                                             "id" bigserial PRIMARY KEY,
+                                             "hashed_code" varchar UNIQUE NOT NULL,
+                                             "client_id" varchar NOT NULL,
+                                             "username" varchar NOT NULL,
+                                             "redirect_uri" varchar NOT NULL,
+                                             "scopes" varchar[] NOT NULL,
+                                             "code_challenge" varchar NOT NULL,
+                                             "expires_at" timestamptz NOT NULL,
+                                             "used_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z',
+                                             "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "oauth_clients" ("owner");
+
+ALTER TABLE "oauth_clients" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "oauth_authorization_codes" ADD FOREIGN KEY ("client_id") REFERENCES "oauth_clients" ("id");
+
+ALTER TABLE "oauth_authorization_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
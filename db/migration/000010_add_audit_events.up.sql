@@ -0,0 +1,39 @@
+CREATE TABLE "audit_events" (
+                                "id" bigserial PRIMARY KEY,
+                                "actor" varchar NOT NULL,
+                                "action" varchar NOT NULL,
+                                "target_type" varchar NOT NULL,
+                                "target_id" varchar NOT NULL,
+                                "request_id" varchar NOT NULL,
+                                "ip" varchar NOT NULL,
+                                "outcome" varchar NOT NULL,
+                                "diff" jsonb NOT NULL,
+                                "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "audit_events" ("actor");
+
+CREATE INDEX ON "audit_events" ("action");
+
+CREATE INDEX ON "audit_events" ("target_type", "target_id");
+
+CREATE INDEX ON "audit_events" ("created_at");
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update_or_delete
+    BEFORE UPDATE OR DELETE
+    ON "audit_events"
+    FOR EACH ROW
+EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE
+    ON "audit_events"
+    FOR EACH STATEMENT
+EXECUTE FUNCTION audit_events_append_only();
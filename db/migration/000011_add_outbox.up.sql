@@ -0,0 +1,14 @@
+CREATE TABLE "outbox" (
+                          "id" bigserial PRIMARY KEY,
+                          "aggregate_type" varchar NOT NULL,
+                          "aggregate_id" varchar NOT NULL,
+                          "event_type" varchar NOT NULL,
+                          "payload" jsonb NOT NULL,
+                          "attempts" integer NOT NULL DEFAULT 0,
+                          "last_error" varchar NOT NULL DEFAULT '',
+                          "next_attempt_at" timestamptz NOT NULL DEFAULT (now()),
+                          "published_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z',
+                          "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "outbox" ("aggregate_type", "aggregate_id", "id") WHERE "published_at" = '0001-01-01 00:00:00Z';
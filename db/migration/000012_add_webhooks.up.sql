@@ -0,0 +1,38 @@
+CREATE TABLE "webhook_subscriptions" (
+                                         "id" bigserial PRIMARY KEY,
+                                         "owner" varchar NOT NULL,
+                                         "url" varchar NOT NULL,
+                                         "secret" varchar NOT NULL,
+                                         "event_types" varchar[] NOT NULL,
+                                         "consecutive_failures" integer NOT NULL DEFAULT 0,
+                                         "disabled_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z',
+                                         "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "webhook_deliveries" (
+                                      "id" bigserial PRIMARY KEY,
+                                      "subscription_id" bigint NOT NULL,
+                                      "event_id" bigint NOT NULL,
+                                      "event_type" varchar NOT NULL,
+                                      "payload" jsonb NOT NULL,
+                                      "replay_of" bigint NOT NULL DEFAULT 0,
+                                      "status" varchar NOT NULL DEFAULT 'pending',
+                                      "attempts" integer NOT NULL DEFAULT 0,
+                                      "response_status" integer NOT NULL DEFAULT 0,
+                                      "last_error" varchar NOT NULL DEFAULT '',
+                                      "next_attempt_at" timestamptz NOT NULL DEFAULT (now()),
+                                      "delivered_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z',
+                                      "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "webhook_subscriptions" ("owner");
+
+CREATE INDEX ON "webhook_deliveries" ("subscription_id", "id");
+
+CREATE UNIQUE INDEX ON "webhook_deliveries" ("subscription_id", "event_id") WHERE "replay_of" = 0;
+
+CREATE INDEX ON "webhook_deliveries" ("next_attempt_at") WHERE "status" = 'pending';
+
+ALTER TABLE "webhook_subscriptions" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "webhook_deliveries" ADD FOREIGN KEY ("subscription_id") REFERENCES "webhook_subscriptions" ("id") ON DELETE CASCADE;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS outbox_aggregate_type_aggregate_id_id_idx;
+
+CREATE INDEX ON "outbox" ("aggregate_type", "aggregate_id", "id") WHERE "published_at" = '0001-01-01 00:00:00Z';
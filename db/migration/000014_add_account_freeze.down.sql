@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS accounts DROP COLUMN IF EXISTS frozen_at;
@@ -0,0 +1 @@
+ALTER TABLE "accounts" ADD COLUMN "frozen_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z';
@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS recovery_codes DROP COLUMN IF EXISTS lookup;
@@ -0,0 +1,5 @@
+-- lookup is a keyed HMAC of the code, so a code is found without hashing
+-- every code of the user. Codes issued before it have none.
+ALTER TABLE "recovery_codes" ADD COLUMN "lookup" varchar NOT NULL DEFAULT '';
+
+CREATE INDEX ON "recovery_codes" ("username", "lookup");
@@ -0,0 +1,8 @@
+// Package migration embeds the schema migrations so the binary can apply
+// them itself, see internal/migrate.
+package migration
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
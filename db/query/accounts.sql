@@ -25,10 +25,19 @@ INSERT INTO accounts (
 RETURNING *;
 
 -- name: AddAccountBalance :one
-UPDATE accounts SET balance = balance + sqlc.arg(amount)
-WHERE id = sqlc.arg(id)
+-- AddAccountBalance leaves frozen accounts alone, a freeze committed while
+-- a transfer waits for the row lock is seen by the update.
+UPDATE accounts SET balance = balance + sqlc.arg(amount),
+                    version = version + 1
+WHERE id = sqlc.arg(id) AND frozen_at = '0001-01-01 00:00:00Z'
 RETURNING *;
 
 -- name: DeleteAccount :exec
 DELETE FROM accounts
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: FreezeAccount :one
+UPDATE accounts SET frozen_at = now(),
+                    version = version + 1
+WHERE id = $1
+RETURNING *;
\ No newline at end of file
@@ -0,0 +1,42 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id,
+                      username,
+                      name,
+                      prefix,
+                      hashed_secret,
+                      scopes,
+                      expires_at)
+VALUES ($1,
+        $2,
+        $3,
+        $4,
+        $5,
+        $6,
+        $7)
+RETURNING *;
+
+-- name: GetAPIKeyByPrefix :one
+SELECT *
+FROM api_keys
+WHERE prefix = $1
+LIMIT 1;
+
+-- name: ListAPIKeys :many
+SELECT *
+FROM api_keys
+WHERE username = $1
+  AND revoked_at = '0001-01-01 00:00:00Z'
+ORDER BY created_at;
+
+-- name: RevokeAPIKey :execrows
+UPDATE api_keys
+SET revoked_at = now()
+WHERE id = $1
+  AND username = $2
+  AND revoked_at = '0001-01-01 00:00:00Z';
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = $1
+  AND last_used_at < now() - interval '1 minute';
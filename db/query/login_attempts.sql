@@ -0,0 +1,15 @@
+-- name: CreateLoginAttempt :one
+INSERT INTO login_attempts (username,
+                            client_ip,
+                            succeeded)
+VALUES ($1,
+        $2,
+        $3)
+RETURNING *;
+
+-- name: ListLoginAttempts :many
+SELECT *
+FROM login_attempts
+WHERE username = $1
+ORDER BY id DESC
+LIMIT $2;
@@ -0,0 +1,56 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id,
+                           owner,
+                           name,
+                           hashed_secret,
+                           redirect_uris,
+                           scopes)
+VALUES ($1,
+        $2,
+        $3,
+        $4,
+        $5,
+        $6)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT *
+FROM oauth_clients
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListOAuthClients :many
+SELECT *
+FROM oauth_clients
+WHERE owner = $1
+ORDER BY created_at;
+
+-- name: CreateOAuthAuthorizationCode :one
+INSERT INTO oauth_authorization_codes (hashed_code,
+                                       client_id,
+                                       username,
+                                       redirect_uri,
+                                       scopes,
+                                       code_challenge,
+                                       expires_at)
+VALUES ($1,
+        $2,
+        $3,
+        $4,
+        $5,
+        $6,
+        $7)
+RETURNING *;
+
+-- name: GetOAuthAuthorizationCode :one
+SELECT *
+FROM oauth_authorization_codes
+WHERE hashed_code = $1
+LIMIT 1;
+
+-- name: UseOAuthAuthorizationCode :execrows
+UPDATE oauth_authorization_codes
+SET used_at = now()
+WHERE id = $1
+  AND used_at = '0001-01-01 00:00:00Z'
+  AND expires_at > now();
@@ -0,0 +1,68 @@
+-- name: CreateOutboxEvent :one
+INSERT INTO outbox (aggregate_type,
+                    aggregate_id,
+                    event_type,
+                    payload)
+VALUES ($1,
+        $2,
+        $3,
+        $4)
+RETURNING *;
+
+-- name: ClaimOutboxEvents :many
+WITH claimed AS (
+    UPDATE outbox
+        SET attempts = attempts + 1,
+            next_attempt_at = sqlc.arg(lease_until)
+        WHERE id IN (SELECT pending.id
+                     FROM outbox pending
+                     WHERE pending.published_at = '0001-01-01 00:00:00Z'
+                       AND pending.next_attempt_at <= now()
+                       AND NOT EXISTS (SELECT 1
+                                       FROM outbox earlier
+                                       WHERE earlier.aggregate_type = pending.aggregate_type
+                                         AND earlier.aggregate_id = pending.aggregate_id
+                                         AND earlier.published_at = '0001-01-01 00:00:00Z'
+                                         AND earlier.id < pending.id)
+                     ORDER BY pending.id
+                     LIMIT sqlc.arg(row_limit) FOR UPDATE SKIP LOCKED)
+        RETURNING *)
+SELECT *
+FROM claimed
+ORDER BY id;
+
+-- name: MarkOutboxEventPublished :exec
+UPDATE outbox
+SET published_at = now(),
+    last_error   = ''
+WHERE id = $1;
+
+-- name: MarkOutboxEventFailed :exec
+UPDATE outbox
+SET last_error      = $2,
+    next_attempt_at = $3
+WHERE id = $1;
+
+-- name: DeletePublishedOutboxEvents :exec
+DELETE
+FROM outbox
+WHERE published_at != '0001-01-01 00:00:00Z'
+  AND published_at < $1;
+
+-- name: GetLatestAccountEventID :one
+SELECT COALESCE(MAX(outbox.id), 0)::bigint AS latest_id
+FROM outbox
+         JOIN accounts ON outbox.aggregate_type = 'account' AND outbox.aggregate_id = accounts.id::varchar
+WHERE accounts.owner = sqlc.arg(owner);
+
+-- name: ListAccountEvents :many
+SELECT outbox.*
+FROM outbox
+         JOIN accounts ON outbox.aggregate_type = 'account' AND outbox.aggregate_id = accounts.id::varchar
+WHERE accounts.owner = sqlc.arg(owner)
+  AND outbox.id > sqlc.arg(after_id)
+ORDER BY outbox.id
+LIMIT sqlc.arg(row_limit);
+
+-- name: NotifyAccountEvents :exec
+SELECT pg_notify('account_events', sqlc.arg(owner)::text);
@@ -0,0 +1,21 @@
+-- name: RefillRateLimitBucket :one
+INSERT INTO rate_limit_buckets AS b (key,
+                                     tokens)
+VALUES (sqlc.arg(key),
+        sqlc.arg(burst)::float8)
+ON CONFLICT (key) DO UPDATE
+    SET tokens     = LEAST(sqlc.arg(burst)::float8,
+                           b.tokens + GREATEST(EXTRACT(EPOCH FROM (now() - b.updated_at)), 0) * sqlc.arg(rate)::float8),
+        updated_at = now()
+RETURNING tokens;
+
+-- name: TakeRateLimitToken :one
+UPDATE rate_limit_buckets
+SET tokens = tokens - 1
+WHERE key = $1
+  AND tokens >= 1
+RETURNING tokens;
+
+-- name: DeleteStaleRateLimitBuckets :exec
+DELETE FROM rate_limit_buckets
+WHERE updated_at < $1;
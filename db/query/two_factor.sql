@@ -0,0 +1,64 @@
+-- name: UpsertUserTOTP :one
+INSERT INTO user_totp (username,
+                       secret)
+VALUES ($1,
+        $2)
+ON CONFLICT (username) DO UPDATE
+    SET secret         = EXCLUDED.secret,
+        last_used_step = 0,
+        confirmed_at   = '0001-01-01 00:00:00Z',
+        created_at     = now()
+RETURNING *;
+
+-- name: GetUserTOTP :one
+SELECT *
+FROM user_totp
+WHERE username = $1
+LIMIT 1;
+
+-- name: ConfirmUserTOTP :one
+UPDATE user_totp
+SET confirmed_at   = now(),
+    last_used_step = sqlc.arg(last_used_step)
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: UseTOTPStep :execrows
+UPDATE user_totp
+SET last_used_step = sqlc.arg(step)
+WHERE username = sqlc.arg(username)
+  AND last_used_step < sqlc.arg(step);
+
+-- name: CreateRecoveryCode :one
+INSERT INTO recovery_codes (username,
+                            hashed_code,
+                            lookup)
+VALUES ($1,
+        $2,
+        $3)
+RETURNING *;
+
+-- name: GetUnusedRecoveryCode :one
+SELECT *
+FROM recovery_codes
+WHERE username = $1
+  AND lookup = $2
+  AND used_at = '0001-01-01 00:00:00Z'
+LIMIT 1;
+
+-- name: ListUnusedRecoveryCodes :many
+SELECT *
+FROM recovery_codes
+WHERE username = $1
+  AND used_at = '0001-01-01 00:00:00Z'
+ORDER BY id;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = now()
+WHERE id = $1
+  AND used_at = '0001-01-01 00:00:00Z';
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE username = $1;
@@ -0,0 +1,31 @@
+-- name: CreateUserToken :one
+INSERT INTO user_tokens (username,
+                         purpose,
+                         hashed_token,
+                         expires_at)
+VALUES ($1,
+        $2,
+        $3,
+        $4)
+RETURNING *;
+
+-- name: GetUserToken :one
+SELECT *
+FROM user_tokens
+WHERE hashed_token = $1
+  AND purpose = $2
+LIMIT 1;
+
+-- name: UseUserToken :execrows
+UPDATE user_tokens
+SET used_at = now()
+WHERE id = $1
+  AND used_at = '0001-01-01 00:00:00Z'
+  AND expires_at > now();
+
+-- name: RevokeUserTokens :exec
+UPDATE user_tokens
+SET used_at = now()
+WHERE username = $1
+  AND purpose = $2
+  AND used_at = '0001-01-01 00:00:00Z';
@@ -0,0 +1,140 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (owner,
+                                   url,
+                                   secret,
+                                   event_types)
+VALUES ($1,
+        $2,
+        $3,
+        $4)
+RETURNING *;
+
+-- name: GetWebhookSubscription :one
+SELECT *
+FROM webhook_subscriptions
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListWebhookSubscriptions :many
+SELECT *
+FROM webhook_subscriptions
+WHERE owner = $1
+ORDER BY id;
+
+-- name: ListWebhookSubscriptionsForEvent :many
+SELECT *
+FROM webhook_subscriptions
+WHERE owner = ANY (sqlc.arg(owners)::varchar[])
+  AND disabled_at = '0001-01-01 00:00:00Z'
+  AND (cardinality(event_types) = 0 OR sqlc.arg(event_type)::varchar = ANY (event_types))
+ORDER BY id;
+
+-- name: UpdateWebhookSubscription :one
+UPDATE webhook_subscriptions
+SET url                  = $2,
+    event_types          = $3,
+    disabled_at          = $4,
+    consecutive_failures = 0
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE
+FROM webhook_subscriptions
+WHERE id = $1
+  AND owner = $2;
+
+-- name: RecordWebhookSubscriptionFailure :one
+UPDATE webhook_subscriptions
+SET consecutive_failures = consecutive_failures + 1,
+    disabled_at          = CASE
+                               WHEN consecutive_failures + 1 >= sqlc.arg(disable_after)::integer
+                                   AND disabled_at = '0001-01-01 00:00:00Z' THEN now()
+                               ELSE disabled_at END
+WHERE id = $1
+RETURNING *;
+
+-- name: ResetWebhookSubscriptionFailures :exec
+UPDATE webhook_subscriptions
+SET consecutive_failures = 0
+WHERE id = $1
+  AND consecutive_failures > 0;
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (subscription_id,
+                                event_id,
+                                event_type,
+                                payload)
+VALUES ($1,
+        $2,
+        $3,
+        $4)
+ON CONFLICT (subscription_id, event_id) WHERE replay_of = 0 DO NOTHING;
+
+-- name: ReplayWebhookDelivery :one
+INSERT INTO webhook_deliveries (subscription_id,
+                                event_id,
+                                event_type,
+                                payload,
+                                replay_of)
+SELECT subscription_id,
+       event_id,
+       event_type,
+       payload,
+       id
+FROM webhook_deliveries
+WHERE webhook_deliveries.id = $1
+RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT *
+FROM webhook_deliveries
+WHERE id = $1
+LIMIT 1;
+
+-- name: ListWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE subscription_id = sqlc.arg(subscription_id)
+  AND (sqlc.narg(status)::varchar IS NULL OR status = sqlc.narg(status))
+  AND (sqlc.narg(before_id)::bigint IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(row_limit);
+
+-- name: ClaimWebhookDeliveries :many
+WITH claimed AS (
+    UPDATE webhook_deliveries
+        SET attempts = attempts + 1,
+            next_attempt_at = sqlc.arg(lease_until)
+        WHERE webhook_deliveries.id IN (SELECT pending.id
+                                        FROM webhook_deliveries pending
+                                                 JOIN webhook_subscriptions subscription
+                                                      ON subscription.id = pending.subscription_id
+                                        WHERE pending.status = 'pending'
+                                          AND pending.next_attempt_at <= now()
+                                          AND subscription.disabled_at = '0001-01-01 00:00:00Z'
+                                        ORDER BY pending.id
+                                        LIMIT sqlc.arg(row_limit) FOR UPDATE OF pending SKIP LOCKED)
+        RETURNING *)
+SELECT claimed.*,
+       webhook_subscriptions.url,
+       webhook_subscriptions.secret
+FROM claimed
+         JOIN webhook_subscriptions ON webhook_subscriptions.id = claimed.subscription_id
+ORDER BY claimed.id;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status          = 'succeeded',
+    response_status = $2,
+    last_error      = '',
+    delivered_at    = now()
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status          = $2,
+    response_status = $3,
+    last_error      = $4,
+    next_attempt_at = $5
+WHERE id = $1;
@@ -4,7 +4,12 @@ CREATE TABLE "users" (
                          "full_name" varchar NOT NULL,
                          "email" varchar UNIQUE NOT NULL,
                          "password_changed_at" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
-                         "created_at" timestamptz NOT NULL DEFAULT (now())
+                         "created_at" timestamptz NOT NULL DEFAULT (now()),
+                         "failed_login_attempts" integer NOT NULL DEFAULT 0,
+                         "locked_until" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
+                         "email_verified_at" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
+                         "role" varchar NOT NULL DEFAULT 'customer',
+                         "version" bigint NOT NULL DEFAULT 1
 );
 
 CREATE TABLE "accounts" (
@@ -12,7 +17,9 @@ CREATE TABLE "accounts" (
                             "owner" varchar NOT NULL,
                             "balance" bigint NOT NULL,
                             "currency" varchar NOT NULL,
-                            "created_at" timestamptz NOT NULL DEFAULT (now())
+                            "created_at" timestamptz NOT NULL DEFAULT (now()),
+                            "frozen_at" timestamptz NOT NULL DEFAULT '0001-01-01 00:00:00Z',
+                            "version" bigint NOT NULL DEFAULT 1
 );
 
 CREATE TABLE "entries" (
@@ -52,4 +59,201 @@ ALTER TABLE "entries" ADD FOREIGN KEY ("account_id") REFERENCES "accounts" ("id"
 
 ALTER TABLE "transfers" ADD FOREIGN KEY ("from_account_id") REFERENCES "accounts" ("id");
 
-ALTER TABLE "transfers" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
\ No newline at end of file
+ALTER TABLE "transfers" ADD FOREIGN KEY ("to_account_id") REFERENCES "accounts" ("id");
+
+CREATE TABLE "rate_limit_buckets" (
+                                      "key" varchar PRIMARY KEY,
+                                      "tokens" double precision NOT NULL,
+                                      "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "login_attempts" (
+                                  "id" bigserial PRIMARY KEY,
+                                  "username" varchar NOT NULL,
+                                  "client_ip" varchar NOT NULL,
+                                  "succeeded" boolean NOT NULL,
+                                  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "login_attempts" ("username", "created_at");
+
+CREATE TABLE "user_totp" (
+                             "username" varchar PRIMARY KEY,
+                             "secret" varchar NOT NULL,
+                             "last_used_step" bigint NOT NULL DEFAULT 0,
+                             "confirmed_at" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
+                             "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "recovery_codes" (
+                                  "id" bigserial PRIMARY KEY,
+                                  "username" varchar NOT NULL,
+                                  "hashed_code" varchar NOT NULL,
+                                  "used_at" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
+                                  "created_at" timestamptz NOT NULL DEFAULT (now()),
+                                  "lookup" varchar NOT NULL DEFAULT ''
+);
+
+CREATE INDEX ON "recovery_codes" ("username");
+
+CREATE INDEX ON "recovery_codes" ("username", "lookup");
+
+ALTER TABLE "user_totp" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE TABLE "user_tokens" (
+                               "id" bigserial PRIMARY KEY,
+                               "username" varchar NOT NULL,
+                               "purpose" varchar NOT NULL,
+                               "hashed_token" varchar UNIQUE NOT NULL,
+                               "expires_at" timestamptz NOT NULL,
+                               "used_at" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
+                               "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "user_tokens" ("username", "purpose");
+
+ALTER TABLE "user_tokens" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE TABLE "api_keys" (
+                            "id" uuid PRIMARY KEY,
+                            "username" varchar NOT NULL,
+                            "name" varchar NOT NULL,
+                            "prefix" varchar UNIQUE NOT NULL,
+                            "hashed_secret" varchar NOT NULL,
+                            "scopes" varchar[] NOT NULL,
+                            "expires_at" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
+                            "last_used_at" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
+                            "revoked_at" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
+                            "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "api_keys" ("username");
+
+ALTER TABLE "api_keys" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE TABLE "oauth_clients" (
+                                 "id" varchar PRIMARY KEY,
+                                 "owner" varchar NOT NULL,
+                                 "name" varchar NOT NULL,
+                                 "hashed_secret" varchar NOT NULL,
+                                 "redirect_uris" varchar[] NOT NULL,
+                                 "scopes" varchar[] NOT NULL,
+                                 "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "oauth_authorization_codes" (
+                                             "id" bigserial PRIMARY KEY,
+                                             "hashed_code" varchar UNIQUE NOT NULL,
+                                             "client_id" varchar NOT NULL,
+                                             "username" varchar NOT NULL,
+                                             "redirect_uri" varchar NOT NULL,
+                                             "scopes" varchar[] NOT NULL,
+                                             "code_challenge" varchar NOT NULL,
+                                             "expires_at" timestamptz NOT NULL,
+                                             "used_at" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
+                                             "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "oauth_clients" ("owner");
+
+ALTER TABLE "oauth_clients" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "oauth_authorization_codes" ADD FOREIGN KEY ("client_id") REFERENCES "oauth_clients" ("id");
+
+ALTER TABLE "oauth_authorization_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE TABLE "audit_events" (
+                                "id" bigserial PRIMARY KEY,
+                                "actor" varchar NOT NULL,
+                                "action" varchar NOT NULL,
+                                "target_type" varchar NOT NULL,
+                                "target_id" varchar NOT NULL,
+                                "request_id" varchar NOT NULL,
+                                "ip" varchar NOT NULL,
+                                "outcome" varchar NOT NULL,
+                                "diff" jsonb NOT NULL,
+                                "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "audit_events" ("actor");
+
+CREATE INDEX ON "audit_events" ("action");
+
+CREATE INDEX ON "audit_events" ("target_type", "target_id");
+
+CREATE INDEX ON "audit_events" ("created_at");
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS
+$$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update_or_delete
+    BEFORE UPDATE OR DELETE
+    ON "audit_events"
+    FOR EACH ROW
+EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE
+    ON "audit_events"
+    FOR EACH STATEMENT
+EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TABLE "outbox" (
+                          "id" bigserial PRIMARY KEY,
+                          "aggregate_type" varchar NOT NULL,
+                          "aggregate_id" varchar NOT NULL,
+                          "event_type" varchar NOT NULL,
+                          "payload" jsonb NOT NULL,
+                          "attempts" integer NOT NULL DEFAULT 0,
+                          "last_error" varchar NOT NULL DEFAULT '',
+                          "next_attempt_at" timestamptz NOT NULL DEFAULT (now()),
+                          "published_at" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
+                          "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "outbox" ("aggregate_type", "aggregate_id", "id");
+
+CREATE TABLE "webhook_subscriptions" (
+                                         "id" bigserial PRIMARY KEY,
+                                         "owner" varchar NOT NULL,
+                                         "url" varchar NOT NULL,
+                                         "secret" varchar NOT NULL,
+                                         "event_types" varchar[] NOT NULL,
+                                         "consecutive_failures" integer NOT NULL DEFAULT 0,
+                                         "disabled_at" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
+                                         "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "webhook_deliveries" (
+                                      "id" bigserial PRIMARY KEY,
+                                      "subscription_id" bigint NOT NULL,
+                                      "event_id" bigint NOT NULL,
+                                      "event_type" varchar NOT NULL,
+                                      "payload" jsonb NOT NULL,
+                                      "replay_of" bigint NOT NULL DEFAULT 0,
+                                      "status" varchar NOT NULL DEFAULT 'pending',
+                                      "attempts" integer NOT NULL DEFAULT 0,
+                                      "response_status" integer NOT NULL DEFAULT 0,
+                                      "last_error" varchar NOT NULL DEFAULT '',
+                                      "next_attempt_at" timestamptz NOT NULL DEFAULT (now()),
+                                      "delivered_at" timestamptz NOT NULL DEFAULT "0001-01-01 00:00:00Z",
+                                      "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "webhook_subscriptions" ("owner");
+
+CREATE INDEX ON "webhook_deliveries" ("subscription_id", "id");
+
+CREATE UNIQUE INDEX ON "webhook_deliveries" ("subscription_id", "event_id") WHERE "replay_of" = 0;
+
+CREATE INDEX ON "webhook_deliveries" ("next_attempt_at") WHERE "status" = 'pending';
+
+ALTER TABLE "webhook_subscriptions" ADD FOREIGN KEY ("owner") REFERENCES "users" ("username");
+
+ALTER TABLE "webhook_deliveries" ADD FOREIGN KEY ("subscription_id") REFERENCES "webhook_subscriptions" ("id") ON DELETE CASCADE;
@@ -0,0 +1,31 @@
+DROP TABLE IF EXISTS "webhook_deliveries";
+
+DROP TABLE IF EXISTS "webhook_subscriptions";
+
+DROP TABLE IF EXISTS "outbox";
+
+DROP TABLE IF EXISTS "audit_events";
+
+DROP TABLE IF EXISTS "oauth_authorization_codes";
+
+DROP TABLE IF EXISTS "oauth_clients";
+
+DROP TABLE IF EXISTS "api_keys";
+
+DROP TABLE IF EXISTS "user_tokens";
+
+DROP TABLE IF EXISTS "recovery_codes";
+
+DROP TABLE IF EXISTS "user_totp";
+
+DROP TABLE IF EXISTS "login_attempts";
+
+DROP TABLE IF EXISTS "rate_limit_buckets";
+
+DROP TABLE IF EXISTS "transfers";
+
+DROP TABLE IF EXISTS "entries";
+
+DROP TABLE IF EXISTS "accounts";
+
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,388 @@
+-- SQLite has no names for its constraints, the errors of the store name the
+-- Postgres constraint instead. Unique constraints are recognized by their
+-- columns, foreign keys on insert by the triggers named after them below.
+-- Timestamps are UTC text in the format go-sqlite3 binds time.Time with,
+-- jsonb columns are blobs checked to hold JSON.
+
+CREATE TABLE "users"
+(
+    "username" varchar PRIMARY KEY,
+    "hashed_password" varchar NOT NULL,
+    "full_name" varchar NOT NULL,
+    "email" varchar UNIQUE NOT NULL,
+    "password_changed_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    "failed_login_attempts" integer NOT NULL DEFAULT 0,
+    "locked_until" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "email_verified_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "role" varchar NOT NULL DEFAULT 'customer'
+);
+
+CREATE TABLE "accounts"
+(
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "owner" varchar NOT NULL REFERENCES "users" ("username"),
+    "balance" bigint NOT NULL,
+    "currency" varchar NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    "frozen_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00'
+);
+
+CREATE INDEX "accounts_owner_idx" ON "accounts" ("owner");
+
+CREATE UNIQUE INDEX "owner_currency_key" ON "accounts" ("owner", "currency");
+
+CREATE TABLE "entries"
+(
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "account_id" bigint NOT NULL REFERENCES "accounts" ("id"),
+    "amount" bigint NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX "entries_account_id_idx" ON "entries" ("account_id");
+
+CREATE TABLE "transfers"
+(
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "from_account_id" bigint NOT NULL REFERENCES "accounts" ("id"),
+    "to_account_id" bigint NOT NULL REFERENCES "accounts" ("id"),
+    "amount" bigint NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX "transfers_from_account_id_idx" ON "transfers" ("from_account_id");
+
+CREATE INDEX "transfers_to_account_id_idx" ON "transfers" ("to_account_id");
+
+CREATE INDEX "transfers_from_account_id_to_account_id_idx" ON "transfers" ("from_account_id", "to_account_id");
+
+CREATE TABLE "rate_limit_buckets"
+(
+    "key" varchar PRIMARY KEY,
+    "tokens" double precision NOT NULL,
+    "updated_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE "login_attempts"
+(
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "username" varchar NOT NULL,
+    "client_ip" varchar NOT NULL,
+    "succeeded" boolean NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX "login_attempts_username_created_at_idx" ON "login_attempts" ("username", "created_at");
+
+CREATE TABLE "user_totp"
+(
+    "username" varchar PRIMARY KEY REFERENCES "users" ("username"),
+    "secret" varchar NOT NULL,
+    "last_used_step" bigint NOT NULL DEFAULT 0,
+    "confirmed_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE "recovery_codes"
+(
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "username" varchar NOT NULL REFERENCES "users" ("username"),
+    "hashed_code" varchar NOT NULL,
+    "used_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX "recovery_codes_username_idx" ON "recovery_codes" ("username");
+
+CREATE TABLE "user_tokens"
+(
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "username" varchar NOT NULL REFERENCES "users" ("username"),
+    "purpose" varchar NOT NULL,
+    "hashed_token" varchar UNIQUE NOT NULL,
+    "expires_at" timestamp NOT NULL,
+    "used_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX "user_tokens_username_purpose_idx" ON "user_tokens" ("username", "purpose");
+
+CREATE TABLE "api_keys"
+(
+    "id" varchar PRIMARY KEY,
+    "username" varchar NOT NULL REFERENCES "users" ("username"),
+    "name" varchar NOT NULL,
+    "prefix" varchar UNIQUE NOT NULL,
+    "hashed_secret" varchar NOT NULL,
+    "scopes" json NOT NULL,
+    "expires_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "last_used_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "revoked_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX "api_keys_username_idx" ON "api_keys" ("username");
+
+CREATE TABLE "oauth_clients"
+(
+    "id" varchar PRIMARY KEY,
+    "owner" varchar NOT NULL REFERENCES "users" ("username"),
+    "name" varchar NOT NULL,
+    "hashed_secret" varchar NOT NULL,
+    "redirect_uris" json NOT NULL,
+    "scopes" json NOT NULL,
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX "oauth_clients_owner_idx" ON "oauth_clients" ("owner");
+
+CREATE TABLE "oauth_authorization_codes"
+(
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "hashed_code" varchar UNIQUE NOT NULL,
+    "client_id" varchar NOT NULL REFERENCES "oauth_clients" ("id"),
+    "username" varchar NOT NULL REFERENCES "users" ("username"),
+    "redirect_uri" varchar NOT NULL,
+    "scopes" json NOT NULL,
+    "code_challenge" varchar NOT NULL,
+    "expires_at" timestamp NOT NULL,
+    "used_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE "audit_events"
+(
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "actor" varchar NOT NULL,
+    "action" varchar NOT NULL,
+    "target_type" varchar NOT NULL,
+    "target_id" varchar NOT NULL,
+    "request_id" varchar NOT NULL,
+    "ip" varchar NOT NULL,
+    "outcome" varchar NOT NULL,
+    "diff" blob NOT NULL CONSTRAINT "audit_events_diff_check" CHECK (json_valid(CAST("diff" AS TEXT))),
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX "audit_events_actor_idx" ON "audit_events" ("actor");
+
+CREATE INDEX "audit_events_action_idx" ON "audit_events" ("action");
+
+CREATE INDEX "audit_events_target_type_target_id_idx" ON "audit_events" ("target_type", "target_id");
+
+CREATE INDEX "audit_events_created_at_idx" ON "audit_events" ("created_at");
+
+CREATE TRIGGER "audit_events_no_update"
+    BEFORE UPDATE
+    ON "audit_events"
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER "audit_events_no_delete"
+    BEFORE DELETE
+    ON "audit_events"
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TABLE "outbox"
+(
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "aggregate_type" varchar NOT NULL,
+    "aggregate_id" varchar NOT NULL,
+    "event_type" varchar NOT NULL,
+    "payload" blob NOT NULL CONSTRAINT "outbox_payload_check" CHECK (json_valid(CAST("payload" AS TEXT))),
+    "attempts" integer NOT NULL DEFAULT 0,
+    "last_error" varchar NOT NULL DEFAULT '',
+    "next_attempt_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    "published_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX "outbox_aggregate_type_aggregate_id_id_idx" ON "outbox" ("aggregate_type", "aggregate_id", "id");
+
+CREATE TABLE "webhook_subscriptions"
+(
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "owner" varchar NOT NULL REFERENCES "users" ("username"),
+    "url" varchar NOT NULL,
+    "secret" varchar NOT NULL,
+    "event_types" json NOT NULL,
+    "consecutive_failures" integer NOT NULL DEFAULT 0,
+    "disabled_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX "webhook_subscriptions_owner_idx" ON "webhook_subscriptions" ("owner");
+
+CREATE TABLE "webhook_deliveries"
+(
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "subscription_id" bigint NOT NULL REFERENCES "webhook_subscriptions" ("id") ON DELETE CASCADE,
+    "event_id" bigint NOT NULL,
+    "event_type" varchar NOT NULL,
+    "payload" blob NOT NULL CONSTRAINT "webhook_deliveries_payload_check" CHECK (json_valid(CAST("payload" AS TEXT))),
+    "replay_of" bigint NOT NULL DEFAULT 0,
+    "status" varchar NOT NULL DEFAULT 'pending',
+    "attempts" integer NOT NULL DEFAULT 0,
+    "response_status" integer NOT NULL DEFAULT 0,
+    "last_error" varchar NOT NULL DEFAULT '',
+    "next_attempt_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+    "delivered_at" timestamp NOT NULL DEFAULT '0001-01-01 00:00:00+00:00',
+    "created_at" timestamp NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX "webhook_deliveries_subscription_id_id_idx" ON "webhook_deliveries" ("subscription_id", "id");
+
+CREATE UNIQUE INDEX "webhook_deliveries_subscription_id_event_id_idx" ON "webhook_deliveries" ("subscription_id", "event_id") WHERE "replay_of" = 0;
+
+CREATE INDEX "webhook_deliveries_next_attempt_at_idx" ON "webhook_deliveries" ("next_attempt_at") WHERE "status" = 'pending';
+
+-- The REFERENCES clauses above still guard updates and deletes that no
+-- trigger covers, their violations come without a constraint name.
+
+CREATE TRIGGER "accounts_owner_fkey"
+    BEFORE INSERT
+    ON "accounts"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "users" WHERE "username" = NEW."owner")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "accounts" violates foreign key constraint "accounts_owner_fkey"');
+END;
+
+CREATE TRIGGER "entries_account_id_fkey"
+    BEFORE INSERT
+    ON "entries"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "accounts" WHERE "id" = NEW."account_id")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "entries" violates foreign key constraint "entries_account_id_fkey"');
+END;
+
+CREATE TRIGGER "transfers_from_account_id_fkey"
+    BEFORE INSERT
+    ON "transfers"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "accounts" WHERE "id" = NEW."from_account_id")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "transfers" violates foreign key constraint "transfers_from_account_id_fkey"');
+END;
+
+CREATE TRIGGER "transfers_to_account_id_fkey"
+    BEFORE INSERT
+    ON "transfers"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "accounts" WHERE "id" = NEW."to_account_id")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "transfers" violates foreign key constraint "transfers_to_account_id_fkey"');
+END;
+
+CREATE TRIGGER "user_totp_username_fkey"
+    BEFORE INSERT
+    ON "user_totp"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "users" WHERE "username" = NEW."username")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "user_totp" violates foreign key constraint "user_totp_username_fkey"');
+END;
+
+CREATE TRIGGER "recovery_codes_username_fkey"
+    BEFORE INSERT
+    ON "recovery_codes"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "users" WHERE "username" = NEW."username")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "recovery_codes" violates foreign key constraint "recovery_codes_username_fkey"');
+END;
+
+CREATE TRIGGER "user_tokens_username_fkey"
+    BEFORE INSERT
+    ON "user_tokens"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "users" WHERE "username" = NEW."username")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "user_tokens" violates foreign key constraint "user_tokens_username_fkey"');
+END;
+
+CREATE TRIGGER "api_keys_username_fkey"
+    BEFORE INSERT
+    ON "api_keys"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "users" WHERE "username" = NEW."username")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "api_keys" violates foreign key constraint "api_keys_username_fkey"');
+END;
+
+CREATE TRIGGER "oauth_clients_owner_fkey"
+    BEFORE INSERT
+    ON "oauth_clients"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "users" WHERE "username" = NEW."owner")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "oauth_clients" violates foreign key constraint "oauth_clients_owner_fkey"');
+END;
+
+CREATE TRIGGER "oauth_authorization_codes_client_id_fkey"
+    BEFORE INSERT
+    ON "oauth_authorization_codes"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "oauth_clients" WHERE "id" = NEW."client_id")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "oauth_authorization_codes" violates foreign key constraint "oauth_authorization_codes_client_id_fkey"');
+END;
+
+CREATE TRIGGER "oauth_authorization_codes_username_fkey"
+    BEFORE INSERT
+    ON "oauth_authorization_codes"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "users" WHERE "username" = NEW."username")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "oauth_authorization_codes" violates foreign key constraint "oauth_authorization_codes_username_fkey"');
+END;
+
+CREATE TRIGGER "webhook_subscriptions_owner_fkey"
+    BEFORE INSERT
+    ON "webhook_subscriptions"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "users" WHERE "username" = NEW."owner")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "webhook_subscriptions" violates foreign key constraint "webhook_subscriptions_owner_fkey"');
+END;
+
+CREATE TRIGGER "webhook_deliveries_subscription_id_fkey"
+    BEFORE INSERT
+    ON "webhook_deliveries"
+    FOR EACH ROW
+    WHEN NOT EXISTS (SELECT 1 FROM "webhook_subscriptions" WHERE "id" = NEW."subscription_id")
+BEGIN
+    SELECT RAISE(ABORT, 'insert or update on table "webhook_deliveries" violates foreign key constraint "webhook_deliveries_subscription_id_fkey"');
+END;
+
+CREATE TRIGGER "entries_account_id_fkey_on_delete"
+    BEFORE DELETE
+    ON "accounts"
+    FOR EACH ROW
+    WHEN EXISTS (SELECT 1 FROM "entries" WHERE "account_id" = OLD."id")
+BEGIN
+    SELECT RAISE(ABORT, 'update or delete on table "accounts" violates foreign key constraint "entries_account_id_fkey" on table "entries"');
+END;
+
+CREATE TRIGGER "transfers_from_account_id_fkey_on_delete"
+    BEFORE DELETE
+    ON "accounts"
+    FOR EACH ROW
+    WHEN EXISTS (SELECT 1 FROM "transfers" WHERE "from_account_id" = OLD."id")
+BEGIN
+    SELECT RAISE(ABORT, 'update or delete on table "accounts" violates foreign key constraint "transfers_from_account_id_fkey" on table "transfers"');
+END;
+
+CREATE TRIGGER "transfers_to_account_id_fkey_on_delete"
+    BEFORE DELETE
+    ON "accounts"
+    FOR EACH ROW
+    WHEN EXISTS (SELECT 1 FROM "transfers" WHERE "to_account_id" = OLD."id")
+BEGIN
+    SELECT RAISE(ABORT, 'update or delete on table "accounts" violates foreign key constraint "transfers_to_account_id_fkey" on table "transfers"');
+END;
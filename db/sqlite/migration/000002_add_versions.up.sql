@@ -0,0 +1,3 @@
+ALTER TABLE "accounts" ADD COLUMN "version" integer NOT NULL DEFAULT 1;
+
+ALTER TABLE "users" ADD COLUMN "version" integer NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+DROP INDEX "recovery_codes_username_lookup_idx";
+
+ALTER TABLE "recovery_codes" DROP COLUMN "lookup";
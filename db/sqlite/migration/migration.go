@@ -0,0 +1,9 @@
+// Package migration embeds the SQLite schema migrations. They track
+// db/migration from the schema it had reached when SQLite support was
+// added, every later Postgres migration needs its SQLite counterpart.
+package migration
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,43 @@
+-- name: GetAccount :one
+SELECT * FROM accounts WHERE id = ? LIMIT 1;
+
+-- name: GetAccountForUpdate :one
+-- GetAccountForUpdate is GetAccount, transactions take the write lock as
+-- they begin and there are no row locks to ask for.
+SELECT * FROM accounts WHERE id = ? LIMIT 1;
+
+-- name: ListAccounts :many
+SELECT * FROM accounts
+WHERE owner = ?
+ORDER BY id
+LIMIT ?
+OFFSET ?;
+
+-- name: CreateAccount :one
+INSERT INTO accounts (
+    owner,
+    balance,
+    currency
+    ) VALUES (
+    ?,
+    ?,
+    ?
+)
+RETURNING *;
+
+-- name: AddAccountBalance :one
+-- AddAccountBalance leaves frozen accounts alone.
+UPDATE accounts SET balance = balance + sqlc.arg(amount),
+                    version = version + 1
+WHERE id = sqlc.arg(id) AND frozen_at = '0001-01-01 00:00:00+00:00'
+RETURNING *;
+
+-- name: DeleteAccount :exec
+DELETE FROM accounts
+WHERE id = ?;
+
+-- name: FreezeAccount :one
+UPDATE accounts SET frozen_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+                    version   = version + 1
+WHERE id = ?
+RETURNING *;
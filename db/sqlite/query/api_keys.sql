@@ -0,0 +1,42 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id,
+                      username,
+                      name,
+                      prefix,
+                      hashed_secret,
+                      scopes,
+                      expires_at)
+VALUES (?,
+        ?,
+        ?,
+        ?,
+        ?,
+        ?,
+        ?)
+RETURNING *;
+
+-- name: GetAPIKeyByPrefix :one
+SELECT *
+FROM api_keys
+WHERE prefix = ?
+LIMIT 1;
+
+-- name: ListAPIKeys :many
+SELECT *
+FROM api_keys
+WHERE username = ?
+  AND revoked_at = '0001-01-01 00:00:00+00:00'
+ORDER BY created_at, id;
+
+-- name: RevokeAPIKey :execrows
+UPDATE api_keys
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?
+  AND username = ?
+  AND revoked_at = '0001-01-01 00:00:00+00:00';
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?
+  AND last_used_at < strftime('%Y-%m-%d %H:%M:%f+00:00', 'now', '-1 minute');
@@ -0,0 +1,32 @@
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (actor,
+                          action,
+                          target_type,
+                          target_id,
+                          request_id,
+                          ip,
+                          outcome,
+                          diff)
+VALUES (?,
+        ?,
+        ?,
+        ?,
+        ?,
+        ?,
+        ?,
+        ?)
+RETURNING *;
+
+-- name: ListAuditEvents :many
+SELECT *
+FROM audit_events
+WHERE (sqlc.narg(actor) IS NULL OR actor = sqlc.narg(actor))
+  AND (sqlc.narg(action) IS NULL OR action = sqlc.narg(action))
+  AND (sqlc.narg(target_type) IS NULL OR target_type = sqlc.narg(target_type))
+  AND (sqlc.narg(target_id) IS NULL OR target_id = sqlc.narg(target_id))
+  AND (sqlc.narg(outcome) IS NULL OR outcome = sqlc.narg(outcome))
+  AND (sqlc.narg(since) IS NULL OR created_at >= sqlc.narg(since))
+  AND (sqlc.narg(until) IS NULL OR created_at < sqlc.narg(until))
+  AND (sqlc.narg(before_id) IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(row_limit);
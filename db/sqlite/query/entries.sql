@@ -0,0 +1,13 @@
+-- name: CreateEntry :one
+INSERT INTO entries (
+    account_id,
+    amount
+)
+VALUES (
+    ?,
+    ?
+)
+RETURNING *;
+
+-- name: GetEntry :one
+SELECT * FROM entries WHERE id = ? LIMIT 1;
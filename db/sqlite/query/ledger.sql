@@ -0,0 +1,19 @@
+-- name: ListLedgerMismatches :many
+-- ListLedgerMismatches returns the accounts whose entries do not add up to
+-- the transfers in and out of them.
+SELECT accounts.id                                 AS account_id,
+       CAST(COALESCE(entries.total, 0) AS INTEGER)   AS entries_total,
+       CAST(COALESCE(transfers.total, 0) AS INTEGER) AS transfers_total
+FROM accounts
+         LEFT JOIN (SELECT account_id, SUM(amount) AS total
+                    FROM entries
+                    GROUP BY account_id) entries ON entries.account_id = accounts.id
+         LEFT JOIN (SELECT account_id, SUM(amount) AS total
+                    FROM (SELECT to_account_id AS account_id, amount
+                          FROM transfers
+                          UNION ALL
+                          SELECT from_account_id, -amount
+                          FROM transfers) moves
+                    GROUP BY account_id) transfers ON transfers.account_id = accounts.id
+WHERE COALESCE(entries.total, 0) != COALESCE(transfers.total, 0)
+ORDER BY accounts.id;
@@ -0,0 +1,15 @@
+-- name: CreateLoginAttempt :one
+INSERT INTO login_attempts (username,
+                            client_ip,
+                            succeeded)
+VALUES (?,
+        ?,
+        ?)
+RETURNING *;
+
+-- name: ListLoginAttempts :many
+SELECT *
+FROM login_attempts
+WHERE username = ?
+ORDER BY id DESC
+LIMIT ?;
@@ -0,0 +1,56 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id,
+                           owner,
+                           name,
+                           hashed_secret,
+                           redirect_uris,
+                           scopes)
+VALUES (?,
+        ?,
+        ?,
+        ?,
+        ?,
+        ?)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT *
+FROM oauth_clients
+WHERE id = ?
+LIMIT 1;
+
+-- name: ListOAuthClients :many
+SELECT *
+FROM oauth_clients
+WHERE owner = ?
+ORDER BY created_at;
+
+-- name: CreateOAuthAuthorizationCode :one
+INSERT INTO oauth_authorization_codes (hashed_code,
+                                       client_id,
+                                       username,
+                                       redirect_uri,
+                                       scopes,
+                                       code_challenge,
+                                       expires_at)
+VALUES (?,
+        ?,
+        ?,
+        ?,
+        ?,
+        ?,
+        ?)
+RETURNING *;
+
+-- name: GetOAuthAuthorizationCode :one
+SELECT *
+FROM oauth_authorization_codes
+WHERE hashed_code = ?
+LIMIT 1;
+
+-- name: UseOAuthAuthorizationCode :execrows
+UPDATE oauth_authorization_codes
+SET used_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?
+  AND used_at = '0001-01-01 00:00:00+00:00'
+  AND expires_at > strftime('%Y-%m-%d %H:%M:%f+00:00', 'now');
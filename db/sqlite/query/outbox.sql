@@ -0,0 +1,63 @@
+-- name: CreateOutboxEvent :one
+INSERT INTO outbox (aggregate_type,
+                    aggregate_id,
+                    event_type,
+                    payload)
+VALUES (?,
+        ?,
+        ?,
+        ?)
+RETURNING *;
+
+-- name: ClaimOutboxEvents :many
+-- ClaimOutboxEvents needs no row locks, writers are serialized. RETURNING
+-- comes in no particular order, the store sorts the claimed events by id.
+UPDATE outbox
+SET attempts        = attempts + 1,
+    next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (SELECT pending.id
+             FROM outbox pending
+             WHERE pending.published_at = '0001-01-01 00:00:00+00:00'
+               AND pending.next_attempt_at <= strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+               AND NOT EXISTS (SELECT 1
+                               FROM outbox earlier
+                               WHERE earlier.aggregate_type = pending.aggregate_type
+                                 AND earlier.aggregate_id = pending.aggregate_id
+                                 AND earlier.published_at = '0001-01-01 00:00:00+00:00'
+                                 AND earlier.id < pending.id)
+             ORDER BY pending.id
+             LIMIT sqlc.arg(row_limit))
+RETURNING *;
+
+-- name: MarkOutboxEventPublished :exec
+UPDATE outbox
+SET published_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+    last_error   = ''
+WHERE id = ?;
+
+-- name: MarkOutboxEventFailed :exec
+UPDATE outbox
+SET last_error      = ?2,
+    next_attempt_at = ?3
+WHERE id = ?1;
+
+-- name: DeletePublishedOutboxEvents :exec
+DELETE
+FROM outbox
+WHERE published_at != '0001-01-01 00:00:00+00:00'
+  AND published_at < ?;
+
+-- name: GetLatestAccountEventID :one
+SELECT CAST(COALESCE(MAX(outbox.id), 0) AS INTEGER) AS latest_id
+FROM outbox
+         JOIN accounts ON outbox.aggregate_type = 'account' AND outbox.aggregate_id = CAST(accounts.id AS TEXT)
+WHERE accounts.owner = sqlc.arg(owner);
+
+-- name: ListAccountEvents :many
+SELECT outbox.*
+FROM outbox
+         JOIN accounts ON outbox.aggregate_type = 'account' AND outbox.aggregate_id = CAST(accounts.id AS TEXT)
+WHERE accounts.owner = sqlc.arg(owner)
+  AND outbox.id > sqlc.arg(after_id)
+ORDER BY outbox.id
+LIMIT sqlc.arg(row_limit);
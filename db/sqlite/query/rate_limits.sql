@@ -0,0 +1,21 @@
+-- name: RefillRateLimitBucket :one
+INSERT INTO rate_limit_buckets (key,
+                                tokens)
+VALUES (sqlc.arg(key),
+        sqlc.arg(burst))
+ON CONFLICT (key) DO UPDATE
+    SET tokens     = MIN(sqlc.arg(burst),
+                         tokens + MAX((julianday('now') - julianday(updated_at)) * 86400, 0) * CAST(sqlc.arg(rate) AS REAL)),
+        updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+RETURNING tokens;
+
+-- name: TakeRateLimitToken :one
+UPDATE rate_limit_buckets
+SET tokens = tokens - 1
+WHERE key = ?
+  AND tokens >= 1
+RETURNING tokens;
+
+-- name: DeleteStaleRateLimitBuckets :exec
+DELETE FROM rate_limit_buckets
+WHERE updated_at < ?;
@@ -0,0 +1,12 @@
+-- name: CreateTransfer :one
+INSERT INTO transfers (
+    from_account_id,
+    to_account_id,
+    amount
+    ) VALUES (?,
+              ?,
+              ?)
+RETURNING *;
+
+-- name: GetTransfer :one
+SELECT * FROM transfers WHERE id = ? LIMIT 1;
@@ -0,0 +1,64 @@
+-- name: UpsertUserTOTP :one
+INSERT INTO user_totp (username,
+                       secret)
+VALUES (?,
+        ?)
+ON CONFLICT (username) DO UPDATE
+    SET secret         = excluded.secret,
+        last_used_step = 0,
+        confirmed_at   = '0001-01-01 00:00:00+00:00',
+        created_at     = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+RETURNING *;
+
+-- name: GetUserTOTP :one
+SELECT *
+FROM user_totp
+WHERE username = ?
+LIMIT 1;
+
+-- name: ConfirmUserTOTP :one
+UPDATE user_totp
+SET confirmed_at   = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+    last_used_step = sqlc.arg(last_used_step)
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: UseTOTPStep :execrows
+UPDATE user_totp
+SET last_used_step = sqlc.arg(step)
+WHERE username = sqlc.arg(username)
+  AND last_used_step < sqlc.arg(step);
+
+-- name: CreateRecoveryCode :one
+INSERT INTO recovery_codes (username,
+                            hashed_code,
+                            lookup)
+VALUES (?,
+        ?,
+        ?)
+RETURNING *;
+
+-- name: GetUnusedRecoveryCode :one
+SELECT *
+FROM recovery_codes
+WHERE username = ?
+  AND lookup = ?
+  AND used_at = '0001-01-01 00:00:00+00:00'
+LIMIT 1;
+
+-- name: ListUnusedRecoveryCodes :many
+SELECT *
+FROM recovery_codes
+WHERE username = ?
+  AND used_at = '0001-01-01 00:00:00+00:00'
+ORDER BY id;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?
+  AND used_at = '0001-01-01 00:00:00+00:00';
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE username = ?;
@@ -0,0 +1,31 @@
+-- name: CreateUserToken :one
+INSERT INTO user_tokens (username,
+                         purpose,
+                         hashed_token,
+                         expires_at)
+VALUES (?,
+        ?,
+        ?,
+        ?)
+RETURNING *;
+
+-- name: GetUserToken :one
+SELECT *
+FROM user_tokens
+WHERE hashed_token = ?
+  AND purpose = ?
+LIMIT 1;
+
+-- name: UseUserToken :execrows
+UPDATE user_tokens
+SET used_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?
+  AND used_at = '0001-01-01 00:00:00+00:00'
+  AND expires_at > strftime('%Y-%m-%d %H:%M:%f+00:00', 'now');
+
+-- name: RevokeUserTokens :exec
+UPDATE user_tokens
+SET used_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE username = ?
+  AND purpose = ?
+  AND used_at = '0001-01-01 00:00:00+00:00';
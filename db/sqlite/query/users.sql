@@ -0,0 +1,87 @@
+-- name: CreateUser :one
+INSERT INTO users (username,
+                   hashed_password,
+                   full_name,
+                   email)
+VALUES (?,
+        ?,
+        ?,
+        ?)
+RETURNING *;
+
+-- name: GetUser :one
+SELECT *
+FROM users
+WHERE username = ?
+LIMIT 1;
+
+-- name: GetUserByEmail :one
+SELECT *
+FROM users
+WHERE email = ?
+LIMIT 1;
+
+-- name: RegisterFailedLogin :one
+-- RegisterFailedLogin, unlike the other user updates, does not bump the
+-- version: the lockout state is not part of the user clients see, so a
+-- wrong password must not break their ETag or If-Match.
+UPDATE users
+SET failed_login_attempts = failed_login_attempts + 1
+WHERE username = ?
+RETURNING *;
+
+-- name: LockUser :exec
+-- LockUser does not bump the version, like RegisterFailedLogin.
+UPDATE users
+SET locked_until = ?2
+WHERE username = ?1;
+
+-- name: ResetFailedLogins :exec
+-- ResetFailedLogins does not bump the version, like RegisterFailedLogin.
+UPDATE users
+SET failed_login_attempts = 0,
+    locked_until          = '0001-01-01 00:00:00+00:00'
+WHERE username = ?;
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET email_verified_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'),
+    version           = version + 1
+WHERE username = ?
+RETURNING *;
+
+-- name: UpdateUserRole :one
+UPDATE users
+SET role    = ?2,
+    version = version + 1
+WHERE username = ?1
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password     = ?2,
+    password_changed_at = ?3,
+    version             = version + 1
+WHERE username = ?1
+RETURNING *;
+
+-- name: UpdateUser :one
+UPDATE users
+SET full_name         = COALESCE(sqlc.narg(full_name), full_name),
+    email             = COALESCE(sqlc.narg(email), email),
+    email_verified_at = CASE
+                            WHEN sqlc.narg(email) IS NULL OR sqlc.narg(email) = email THEN email_verified_at
+                            ELSE '0001-01-01 00:00:00+00:00'
+        END,
+    version           = version + 1
+WHERE username = sqlc.arg(username)
+  AND (sqlc.narg(expected_version) IS NULL OR version = sqlc.narg(expected_version))
+RETURNING *;
+
+-- name: UpgradeUserPasswordHash :exec
+-- UpgradeUserPasswordHash does not bump the version either, the hash is
+-- never shown and the rehash happens on login.
+UPDATE users
+SET hashed_password = sqlc.arg(new_hashed_password)
+WHERE username = sqlc.arg(username)
+  AND hashed_password = sqlc.arg(old_hashed_password);
@@ -0,0 +1,139 @@
+-- name: CreateWebhookSubscription :one
+INSERT INTO webhook_subscriptions (owner,
+                                   url,
+                                   secret,
+                                   event_types)
+VALUES (?,
+        ?,
+        ?,
+        ?)
+RETURNING *;
+
+-- name: GetWebhookSubscription :one
+SELECT *
+FROM webhook_subscriptions
+WHERE id = ?
+LIMIT 1;
+
+-- name: ListWebhookSubscriptions :many
+SELECT *
+FROM webhook_subscriptions
+WHERE owner = ?
+ORDER BY id;
+
+-- name: ListWebhookSubscriptionsForEvent :many
+SELECT *
+FROM webhook_subscriptions
+WHERE owner IN (SELECT value FROM json_each(sqlc.arg(owners)))
+  AND disabled_at = '0001-01-01 00:00:00+00:00'
+  AND (json_array_length(event_types) = 0 OR
+       EXISTS (SELECT 1 FROM json_each(event_types) WHERE value = CAST(sqlc.arg(event_type) AS TEXT)))
+ORDER BY id;
+
+-- name: UpdateWebhookSubscription :one
+UPDATE webhook_subscriptions
+SET url                  = ?2,
+    event_types          = ?3,
+    disabled_at          = ?4,
+    consecutive_failures = 0
+WHERE id = ?1
+RETURNING *;
+
+-- name: DeleteWebhookSubscription :execrows
+DELETE
+FROM webhook_subscriptions
+WHERE id = ?
+  AND owner = ?;
+
+-- name: RecordWebhookSubscriptionFailure :one
+UPDATE webhook_subscriptions
+SET consecutive_failures = consecutive_failures + 1,
+    disabled_at          = CASE
+                               WHEN consecutive_failures + 1 >= CAST(sqlc.arg(disable_after) AS INTEGER)
+                                   AND disabled_at = '0001-01-01 00:00:00+00:00'
+                                   THEN strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+                               ELSE disabled_at END
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: ResetWebhookSubscriptionFailures :exec
+UPDATE webhook_subscriptions
+SET consecutive_failures = 0
+WHERE id = ?
+  AND consecutive_failures > 0;
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (subscription_id,
+                                event_id,
+                                event_type,
+                                payload)
+VALUES (?,
+        ?,
+        ?,
+        ?)
+ON CONFLICT (subscription_id, event_id) WHERE replay_of = 0 DO NOTHING;
+
+-- name: ReplayWebhookDelivery :one
+INSERT INTO webhook_deliveries (subscription_id,
+                                event_id,
+                                event_type,
+                                payload,
+                                replay_of)
+SELECT subscription_id,
+       event_id,
+       event_type,
+       payload,
+       id
+FROM webhook_deliveries
+WHERE webhook_deliveries.id = ?
+RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT *
+FROM webhook_deliveries
+WHERE id = ?
+LIMIT 1;
+
+-- name: ListWebhookDeliveries :many
+SELECT *
+FROM webhook_deliveries
+WHERE subscription_id = sqlc.arg(subscription_id)
+  AND (sqlc.narg(status) IS NULL OR status = sqlc.narg(status))
+  AND (sqlc.narg(before_id) IS NULL OR id < sqlc.narg(before_id))
+ORDER BY id DESC
+LIMIT sqlc.arg(row_limit);
+
+-- name: ClaimWebhookDeliveries :many
+-- ClaimWebhookDeliveries returns the deliveries in no particular order, like
+-- ClaimOutboxEvents.
+UPDATE webhook_deliveries
+SET attempts        = attempts + 1,
+    next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (SELECT pending.id
+             FROM webhook_deliveries pending
+                      JOIN webhook_subscriptions subscription
+                           ON subscription.id = pending.subscription_id
+             WHERE pending.status = 'pending'
+               AND pending.next_attempt_at <= strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+               AND subscription.disabled_at = '0001-01-01 00:00:00+00:00'
+             ORDER BY pending.id
+             LIMIT sqlc.arg(row_limit))
+RETURNING *,
+    (SELECT url FROM webhook_subscriptions WHERE webhook_subscriptions.id = subscription_id) AS url,
+    (SELECT secret FROM webhook_subscriptions WHERE webhook_subscriptions.id = subscription_id) AS secret;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status          = 'succeeded',
+    response_status = ?2,
+    last_error      = '',
+    delivered_at    = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE id = ?1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status          = ?2,
+    response_status = ?3,
+    last_error      = ?4,
+    next_attempt_at = ?5
+WHERE id = ?1;
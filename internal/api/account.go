@@ -1,23 +1,40 @@
 package api
 
 import (
-	"database/sql"
-	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
-	"log"
 	"net/http"
+	"simple-bank/internal/audit"
 	"simple-bank/internal/db"
+	"strconv"
+	"time"
 )
 
 type createAccountRequest struct {
 	Currency string `json:"currency" binding:"required,currency"`
 }
 
+type accountResponse struct {
+	ID        int64     `json:"id"`
+	Owner     string    `json:"owner"`
+	Balance   int64     `json:"balance"`
+	Currency  string    `json:"currency"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newAccountResponse(account db.Account) accountResponse {
+	return accountResponse{
+		ID:        account.ID,
+		Owner:     account.Owner,
+		Balance:   account.Balance,
+		Currency:  account.Currency,
+		CreatedAt: account.CreatedAt,
+	}
+}
+
 func (s *Server) createAccount(c *gin.Context) {
 	var request createAccountRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(c, bindingError(err))
 		return
 	}
 
@@ -29,22 +46,21 @@ func (s *Server) createAccount(c *gin.Context) {
 		Currency: request.Currency,
 	}
 
-	account, err := s.store.CreateAccount(c, arg)
+	account, err := s.store.CreateAccountTx(c, arg)
 	if err != nil {
-		if pqErr, ok := err.(*pq.Error); ok {
-			log.Println(pqErr.Code.Name(), pqErr.Message)
-
-			switch pqErr.Code.Name() {
-			case "foreign_key_violation", "unique_violation":
-				c.JSON(http.StatusForbidden, errorResponse(err))
-			}
-		} else {
-			c.JSON(http.StatusInternalServerError, errorResponse(err))
-		}
+		abortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, account)
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionAccountCreate,
+		TargetType: auditTargetAccount,
+		TargetID:   strconv.FormatInt(account.ID, 10),
+		Outcome:    audit.OutcomeSuccess,
+		Diff:       map[string]any{"currency": account.Currency},
+	})
+
+	c.JSON(http.StatusOK, present(c).account(account))
 }
 
 type getAccountParams struct {
@@ -54,28 +70,28 @@ type getAccountParams struct {
 func (s *Server) getAccount(c *gin.Context) {
 	var request getAccountParams
 	if err := c.ShouldBindUri(&request); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(c, bindingError(err))
 		return
 	}
 
 	account, err := s.store.GetAccount(c, request.ID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			c.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(c, err)
 		return
 	}
 
 	authPayload := getPayloadFromGinCtx(c)
 
 	if authPayload.Subject != account.Owner {
-		c.JSON(http.StatusForbidden, errorResponse(errors.New("you do not own this account")))
+		abortWithError(c, errForbidden.withDetail("you do not own this account"))
+		return
+	}
+
+	if notModified(c, account.Version) {
 		return
 	}
 
-	c.JSON(http.StatusOK, account)
+	c.JSON(http.StatusOK, present(c).account(account))
 }
 
 type ListAccountParams struct {
@@ -86,7 +102,7 @@ type ListAccountParams struct {
 func (s *Server) listAccounts(c *gin.Context) {
 	var request ListAccountParams
 	if err := c.ShouldBindQuery(&request); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(c, bindingError(err))
 		return
 	}
 
@@ -100,9 +116,9 @@ func (s *Server) listAccounts(c *gin.Context) {
 
 	accounts, err := s.store.ListAccounts(c, arg)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, accounts)
+	c.JSON(http.StatusOK, present(c).accounts(accounts))
 }
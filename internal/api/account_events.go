@@ -0,0 +1,219 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"simple-bank/internal/db"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lastEventIDHeader      = "Last-Event-ID"
+	accountEventsBatch     = 100
+	accountEventsHeartbeat = 15 * time.Second
+	// accountEventsGrace is how long an event id may stay uncommitted: ids
+	// are taken when the row is inserted, a transaction that started first
+	// can commit a lower id after a higher one has been streamed.
+	accountEventsGrace = time.Minute
+)
+
+type streamAccountEventsRequest struct {
+	// LastEventID is for clients that can't set the Last-Event-ID header,
+	// the header wins when both are sent.
+	LastEventID string `form:"last_event_id"`
+}
+
+// streamAccountEvents streams the events of the caller's accounts as
+// Server-Sent Events: account.created and account.balance_changed, the
+// latter carrying the new entry. The id of every event is a cursor,
+// "<event id>-<floor>": every event up to the floor has been streamed, the
+// ones above it are scanned again until the grace window has passed, so an
+// event that commits late is still delivered. A client reconnecting with
+// Last-Event-ID gets what it missed first, events above the floor may come
+// twice and are told apart by their event id. Without it the stream starts
+// with the events that come next.
+func (s *Server) streamAccountEvents(c *gin.Context) {
+	var request streamAccountEventsRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	owner := getPayloadFromGinCtx(c).Subject
+	lastEventID := request.LastEventID
+	if header := c.GetHeader(lastEventIDHeader); header != "" {
+		lastEventID = header
+	}
+
+	var floor int64
+	if lastEventID != "" {
+		var ok bool
+		if floor, ok = parseAccountEventCursor(lastEventID); !ok {
+			abortWithError(c, errInvalidRequest.withDetail("%s must be an event id", lastEventIDHeader))
+			return
+		}
+	}
+
+	// subscribe before reading, an event committed in between still wakes
+	// the stream up
+	wake, unsubscribe := s.accountEvents.Subscribe(owner)
+	defer unsubscribe()
+
+	if lastEventID == "" {
+		latest, err := s.store.GetLatestAccountEventID(c, owner)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		floor = latest
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(accountEventsHeartbeat)
+	defer heartbeat.Stop()
+
+	window := newAccountEventWindow(floor)
+	for {
+		afterID := window.floor
+		for {
+			events, err := s.store.ListAccountEvents(c, db.ListAccountEventsParams{
+				Owner:    owner,
+				AfterID:  afterID,
+				RowLimit: accountEventsBatch,
+			})
+			if err != nil {
+				// the client reconnects with the last id it got
+				return
+			}
+
+			for _, event := range events {
+				afterID = event.ID
+				if !window.add(event.ID) {
+					continue
+				}
+
+				data, ok := accountEventData(event, owner)
+				if !ok {
+					continue
+				}
+				if _, err = fmt.Fprintf(c.Writer, "id: %d-%d\nevent: %s\ndata: %s\n\n", event.ID, window.floor, event.EventType, data); err != nil {
+					return
+				}
+			}
+			c.Writer.Flush()
+
+			if len(events) < accountEventsBatch {
+				break
+			}
+		}
+		window.settle(time.Now())
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-wake:
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// accountEventData renders event the way the API shows it to owner, false
+// for an event that has nothing for owner.
+func accountEventData(event db.Outbox, owner string) ([]byte, bool) {
+	data, err := EventData(event.EventType, event.Payload)
+	if err != nil {
+		slog.Error("render account event", "event_id", event.ID, "err", err)
+		return nil, false
+	}
+
+	item, ok := data[owner]
+	if !ok {
+		return nil, false
+	}
+
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		slog.Error("render account event", "event_id", event.ID, "err", err)
+		return nil, false
+	}
+	return encoded, true
+}
+
+// parseAccountEventCursor returns the floor of an event cursor. A plain
+// event id is its own floor.
+func parseAccountEventCursor(cursor string) (int64, bool) {
+	if _, floor, found := strings.Cut(cursor, "-"); found {
+		cursor = floor
+	}
+	floor, err := strconv.ParseInt(cursor, 10, 64)
+	return floor, err == nil && floor >= 0
+}
+
+// accountEventWindow tracks the events streamed above the floor. The floor
+// moves up to the highest id streamed once the grace window has passed
+// since it was, whatever commits below it later is not waited for.
+type accountEventWindow struct {
+	floor int64
+	last  int64
+	sent  map[int64]bool
+	marks []accountEventMark
+}
+
+type accountEventMark struct {
+	at   time.Time
+	last int64
+}
+
+func newAccountEventWindow(floor int64) *accountEventWindow {
+	return &accountEventWindow{
+		floor: floor,
+		last:  floor,
+		sent:  make(map[int64]bool),
+	}
+}
+
+// add reports whether the event id has yet to be streamed, and records it.
+func (w *accountEventWindow) add(id int64) bool {
+	if id <= w.floor || w.sent[id] {
+		return false
+	}
+	w.sent[id] = true
+	w.last = max(w.last, id)
+	return true
+}
+
+// settle is called after each scan, it raises the floor past the marks older
+// than the grace window.
+func (w *accountEventWindow) settle(now time.Time) {
+	if n := len(w.marks); n == 0 || w.marks[n-1].last < w.last {
+		w.marks = append(w.marks, accountEventMark{at: now, last: w.last})
+	}
+
+	settled := 0
+	for settled < len(w.marks) && now.Sub(w.marks[settled].at) >= accountEventsGrace {
+		w.floor = max(w.floor, w.marks[settled].last)
+		settled++
+	}
+	w.marks = w.marks[settled:]
+
+	for id := range w.sent {
+		if id <= w.floor {
+			delete(w.sent, id)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/stream"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type accountEventsTestCase struct {
+	name          string
+	lastEventID   string
+	query         string
+	buildStubs    func(store *mockdb.MockStore, events *accountEventLog)
+	checkResponse func(t *testing.T, response *http.Response, broker *stream.Broker, events *accountEventLog)
+}
+
+func TestServer_streamAccountEvents(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	testCases := []accountEventsTestCase{
+		{
+			name:        "resume",
+			lastEventID: "5",
+			buildStubs: func(store *mockdb.MockStore, events *accountEventLog) {
+				events.append(randomAccountEvent(account, 4), randomAccountEvent(account, 6), randomAccountEvent(account, 7))
+			},
+			checkResponse: func(t *testing.T, response *http.Response, broker *stream.Broker, events *accountEventLog) {
+				require.Equal(t, http.StatusOK, response.StatusCode)
+				require.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+				reader := bufio.NewReader(response.Body)
+				requireAccountEvent(t, reader, account, "6-5")
+				requireAccountEvent(t, reader, account, "7-5")
+
+				events.append(randomAccountEvent(account, 8))
+				broker.Notify(user.Username)
+				requireAccountEvent(t, reader, account, "8-5")
+			},
+		},
+		{
+			name:        "resume_cursor",
+			lastEventID: "9-7",
+			buildStubs: func(store *mockdb.MockStore, events *accountEventLog) {
+				events.append(randomAccountEvent(account, 7), randomAccountEvent(account, 8))
+			},
+			checkResponse: func(t *testing.T, response *http.Response, _ *stream.Broker, _ *accountEventLog) {
+				require.Equal(t, http.StatusOK, response.StatusCode)
+				requireAccountEvent(t, bufio.NewReader(response.Body), account, "8-7")
+			},
+		},
+		{
+			name:  "resume_query",
+			query: "?last_event_id=7",
+			buildStubs: func(store *mockdb.MockStore, events *accountEventLog) {
+				events.append(randomAccountEvent(account, 8))
+			},
+			checkResponse: func(t *testing.T, response *http.Response, _ *stream.Broker, _ *accountEventLog) {
+				require.Equal(t, http.StatusOK, response.StatusCode)
+				requireAccountEvent(t, bufio.NewReader(response.Body), account, "8-7")
+			},
+		},
+		{
+			name: "new_events_only",
+			buildStubs: func(store *mockdb.MockStore, events *accountEventLog) {
+				events.append(randomAccountEvent(account, 3))
+				store.EXPECT().
+					GetLatestAccountEventID(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(int64(3), nil)
+			},
+			checkResponse: func(t *testing.T, response *http.Response, broker *stream.Broker, events *accountEventLog) {
+				require.Equal(t, http.StatusOK, response.StatusCode)
+
+				// event 3 was already there and isn't sent
+				events.append(randomAccountEvent(account, 4))
+				broker.Notify(user.Username)
+				requireAccountEvent(t, bufio.NewReader(response.Body), account, "4-3")
+			},
+		},
+		{
+			name:        "late_event",
+			lastEventID: "5",
+			buildStubs: func(store *mockdb.MockStore, events *accountEventLog) {
+				events.append(randomAccountEvent(account, 6), randomAccountEvent(account, 8))
+			},
+			checkResponse: func(t *testing.T, response *http.Response, broker *stream.Broker, events *accountEventLog) {
+				require.Equal(t, http.StatusOK, response.StatusCode)
+
+				reader := bufio.NewReader(response.Body)
+				requireAccountEvent(t, reader, account, "6-5")
+				requireAccountEvent(t, reader, account, "8-5")
+
+				// 7 commits after 8 was streamed, it is sent and 6 and 8
+				// aren't sent again
+				events.append(randomAccountEvent(account, 7))
+				broker.Notify(user.Username)
+				requireAccountEvent(t, reader, account, "7-5")
+			},
+		},
+		{
+			name:        "invalid_last_event_id",
+			lastEventID: "latest",
+			buildStubs: func(store *mockdb.MockStore, _ *accountEventLog) {
+				store.EXPECT().
+					ListAccountEvents(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, response *http.Response, _ *stream.Broker, _ *accountEventLog) {
+				require.Equal(t, http.StatusBadRequest, response.StatusCode)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, user)
+			events := &accountEventLog{}
+			tc.buildStubs(store, events)
+			store.EXPECT().
+				ListAccountEvents(gomock.Any(), gomock.Any()).
+				AnyTimes().
+				DoAndReturn(events.list)
+
+			testContainer := newTestContainer(t, store)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server, broker *stream.Broker) {
+				httpServer := httptest.NewServer(server.engine)
+				defer httpServer.Close()
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+
+				request, err := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"/accounts/events"+tc.query, nil)
+				require.NoError(t, err)
+				if tc.lastEventID != "" {
+					request.Header.Set(lastEventIDHeader, tc.lastEventID)
+				}
+				addAuthorization(t, request, server.tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+
+				response, err := http.DefaultClient.Do(request)
+				require.NoError(t, err)
+				defer response.Body.Close()
+
+				tc.checkResponse(t, response, broker, events)
+			}))
+		})
+	}
+}
+
+// accountEventLog is the outbox of a stream test, events are appended as
+// they commit, in any id order.
+type accountEventLog struct {
+	mu     sync.Mutex
+	events []db.Outbox
+}
+
+func (l *accountEventLog) append(events ...db.Outbox) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, events...)
+}
+
+func (l *accountEventLog) list(_ context.Context, arg db.ListAccountEventsParams) ([]db.Outbox, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events := []db.Outbox{}
+	for _, event := range l.events {
+		if event.ID > arg.AfterID {
+			events = append(events, event)
+		}
+	}
+	slices.SortFunc(events, func(a, b db.Outbox) int { return cmp.Compare(a.ID, b.ID) })
+	return events[:min(len(events), int(arg.RowLimit))], nil
+}
+
+func randomAccountEvent(account db.Account, id int64) db.Outbox {
+	payload, _ := json.Marshal(db.BalanceChangedEvent{Account: account})
+	return db.Outbox{
+		ID:            id,
+		AggregateType: db.OutboxAggregateAccount,
+		AggregateID:   fmt.Sprint(account.ID),
+		EventType:     db.EventAccountBalanceChanged,
+		Payload:       payload,
+		CreatedAt:     time.Now(),
+	}
+}
+
+func requireAccountEvent(t *testing.T, reader *bufio.Reader, account db.Account, cursor string) {
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	require.Len(t, lines, 3)
+	require.Equal(t, "id: "+cursor, lines[0])
+	require.Equal(t, "event: "+db.EventAccountBalanceChanged, lines[1])
+
+	var event balanceChangedEventResponse
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &event))
+	require.Equal(t, account.ID, event.Account.ID)
+}
+
+func TestAccountEventWindow(t *testing.T) {
+	now := time.Now()
+	window := newAccountEventWindow(5)
+
+	require.False(t, window.add(5), "events up to the floor were streamed")
+	require.True(t, window.add(8))
+	require.False(t, window.add(8), "an event is streamed once")
+	window.settle(now)
+	require.Equal(t, int64(5), window.floor)
+
+	require.True(t, window.add(7), "a late event within the grace window is streamed")
+	window.settle(now.Add(accountEventsGrace / 2))
+	require.Equal(t, int64(5), window.floor)
+
+	window.settle(now.Add(accountEventsGrace))
+	require.Equal(t, int64(8), window.floor)
+	require.Empty(t, window.sent)
+	require.False(t, window.add(6), "the floor passed the grace window")
+}
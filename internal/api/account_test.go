@@ -5,7 +5,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	"io"
@@ -51,7 +52,7 @@ func TestServer_CreateAccount(t *testing.T) {
 					Balance:  0,
 				}
 				store.EXPECT().
-					CreateAccount(gomock.Any(), gomock.Eq(dbRequest)).
+					CreateAccountTx(gomock.Any(), gomock.Eq(dbRequest)).
 					Times(1).
 					Return(account, nil)
 			},
@@ -76,7 +77,7 @@ func TestServer_CreateAccount(t *testing.T) {
 			},
 			buildStubs: func(store *mockdb.MockStore) {
 				store.EXPECT().
-					CreateAccount(gomock.Any(), gomock.Any()).
+					CreateAccountTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -104,9 +105,9 @@ func TestServer_CreateAccount(t *testing.T) {
 					Balance:  0,
 				}
 				store.EXPECT().
-					CreateAccount(gomock.Any(), gomock.Eq(dbRequest)).
+					CreateAccountTx(gomock.Any(), gomock.Eq(dbRequest)).
 					Times(1).
-					Return(db.Account{}, &pq.Error{Code: "23503"})
+					Return(db.Account{}, &pgconn.PgError{Code: "23503"})
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusForbidden, recorder.Code)
@@ -133,9 +134,9 @@ func TestServer_CreateAccount(t *testing.T) {
 					Balance:  0,
 				}
 				store.EXPECT().
-					CreateAccount(gomock.Any(), gomock.Eq(dbRequest)).
+					CreateAccountTx(gomock.Any(), gomock.Eq(dbRequest)).
 					Times(1).
-					Return(db.Account{}, &pq.Error{Code: "23505"})
+					Return(db.Account{}, &pgconn.PgError{Code: "23505"})
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusForbidden, recorder.Code)
@@ -149,6 +150,7 @@ func TestServer_CreateAccount(t *testing.T) {
 			defer ctrl.Finish()
 
 			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store)
 			tc.buildStubs(store)
 
 			testContainer := newTestContainer(t, store)
@@ -180,6 +182,7 @@ func TestServer_GetAccount(t *testing.T) {
 	testCases := []struct {
 		name          string
 		accountId     int64
+		ifNoneMatch   string
 		setupAuth     func(t *testing.T, request *http.Request, tokensManager tokens2.Manager)
 		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
@@ -204,9 +207,83 @@ func TestServer_GetAccount(t *testing.T) {
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, versionETag(account.Version), recorder.Header().Get(etagHeader))
 				requireBodyMatchAccount(t, recorder.Body, account)
 			},
 		},
+		{
+			name:        "NotModified",
+			accountId:   account.ID,
+			ifNoneMatch: `"stale", W/` + versionETag(account.Version),
+			setupAuth: func(t *testing.T, request *http.Request, tokensManager tokens2.Manager) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, tokens2.PayloadCreationParams{
+					Subject:   user.Username,
+					Audience:  "test",
+					Issuer:    "test",
+					NotBefore: time.Now(),
+					Duration:  time.Minute,
+				})
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotModified, recorder.Code)
+				require.Equal(t, versionETag(account.Version), recorder.Header().Get(etagHeader))
+				require.Empty(t, recorder.Body.Bytes())
+			},
+		},
+		{
+			name:        "Modified",
+			accountId:   account.ID,
+			ifNoneMatch: versionETag(account.Version - 1),
+			setupAuth: func(t *testing.T, request *http.Request, tokensManager tokens2.Manager) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, tokens2.PayloadCreationParams{
+					Subject:   user.Username,
+					Audience:  "test",
+					Issuer:    "test",
+					NotBefore: time.Now(),
+					Duration:  time.Minute,
+				})
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccount(t, recorder.Body, account)
+			},
+		},
+		{
+			name:        "NotModified_WrongUser",
+			accountId:   account.ID,
+			ifNoneMatch: versionETag(account.Version),
+			setupAuth: func(t *testing.T, request *http.Request, tokensManager tokens2.Manager) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, tokens2.PayloadCreationParams{
+					Subject:   "wrong_user",
+					Audience:  "test",
+					Issuer:    "test",
+					NotBefore: time.Now(),
+					Duration:  time.Minute,
+				})
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Empty(t, recorder.Header().Get(etagHeader))
+			},
+		},
 		{
 			name:      "NotFound",
 			accountId: account.ID,
@@ -223,7 +300,7 @@ func TestServer_GetAccount(t *testing.T) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
 					Times(1).
-					Return(db.Account{}, sql.ErrNoRows)
+					Return(db.Account{}, pgx.ErrNoRows)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusNotFound, recorder.Code)
@@ -302,6 +379,7 @@ func TestServer_GetAccount(t *testing.T) {
 			defer ctrl.Finish()
 
 			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store)
 			tc.buildStubs(store)
 
 			testContainer := newTestContainer(t, store)
@@ -310,6 +388,9 @@ func TestServer_GetAccount(t *testing.T) {
 			url := fmt.Sprintf("/accounts/%d", tc.accountId)
 			request, err := http.NewRequest(http.MethodGet, url, nil)
 			require.NoError(t, err)
+			if tc.ifNoneMatch != "" {
+				request.Header.Set(ifNoneMatchHeader, tc.ifNoneMatch)
+			}
 
 			require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
 				tc.setupAuth(t, request, tokensManager)
@@ -479,6 +560,7 @@ func TestServer_ListAccounts(t *testing.T) {
 			defer ctrl.Finish()
 
 			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store)
 			tc.buildStubs(store)
 
 			testContainer := newTestContainer(t, store)
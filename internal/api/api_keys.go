@@ -0,0 +1,149 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	"simple-bank/internal/security"
+	"simple-bank/internal/tokens"
+	"time"
+)
+
+const apiKeyAudience = tokens.AudienceAPIKey
+
+type createAPIKeyRequest struct {
+	Name      string    `json:"name" binding:"required,max=64"`
+	Scopes    []string  `json:"scopes" binding:"required,min=1,dive,scope"`
+	ExpiresAt time.Time `json:"expires_at" binding:"omitempty,gt"`
+}
+
+type apiKeyResponse struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	Scopes     []string  `json:"scopes"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newAPIKeyResponse(apiKey db.ApiKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		Scopes:     apiKey.Scopes,
+		ExpiresAt:  apiKey.ExpiresAt,
+		LastUsedAt: apiKey.LastUsedAt,
+		CreatedAt:  apiKey.CreatedAt,
+	}
+}
+
+type createAPIKeyResponse struct {
+	apiKeyResponse
+	// Key is only ever returned here, the server keeps its hash.
+	Key string `json:"key"`
+}
+
+func (s *Server) createAPIKey(c *gin.Context) {
+	var request createAPIKeyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	key, prefix, err := security.GenerateAPIKey()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	apiKey, err := s.store.CreateAPIKey(c, db.CreateAPIKeyParams{
+		ID:           id,
+		Username:     getPayloadFromGinCtx(c).Subject,
+		Name:         request.Name,
+		Prefix:       prefix,
+		HashedSecret: security.HashOpaqueToken(key),
+		Scopes:       request.Scopes,
+		ExpiresAt:    request.ExpiresAt,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionAPIKeyCreate,
+		TargetType: auditTargetAPIKey,
+		TargetID:   apiKey.ID.String(),
+		Outcome:    audit.OutcomeSuccess,
+		Diff: map[string]any{
+			"name":       apiKey.Name,
+			"prefix":     apiKey.Prefix,
+			"scopes":     apiKey.Scopes,
+			"expires_at": apiKey.ExpiresAt,
+		},
+	})
+
+	c.JSON(http.StatusCreated, createAPIKeyResponse{
+		apiKeyResponse: newAPIKeyResponse(apiKey),
+		Key:            key,
+	})
+}
+
+func (s *Server) listAPIKeys(c *gin.Context) {
+	apiKeys, err := s.store.ListAPIKeys(c, getPayloadFromGinCtx(c).Subject)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	response := make([]apiKeyResponse, len(apiKeys))
+	for i, apiKey := range apiKeys {
+		response[i] = newAPIKeyResponse(apiKey)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+type revokeAPIKeyParams struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+func (s *Server) revokeAPIKey(c *gin.Context) {
+	var request revokeAPIKeyParams
+	if err := c.ShouldBindUri(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	revoked, err := s.store.RevokeAPIKey(c, db.RevokeAPIKeyParams{
+		ID:       uuid.MustParse(request.ID),
+		Username: getPayloadFromGinCtx(c).Subject,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if revoked == 0 {
+		abortWithError(c, errNotFound)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionAPIKeyRevoke,
+		TargetType: auditTargetAPIKey,
+		TargetID:   request.ID,
+		Outcome:    audit.OutcomeSuccess,
+	})
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,545 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/security"
+	tokens2 "simple-bank/internal/tokens"
+	"testing"
+	"time"
+)
+
+func TestAuthMiddleware_APIKey(t *testing.T) {
+	user, _ := randomUser(t)
+	key, apiKey := randomAPIKey(t, user.Username, scopeAccountsRead)
+
+	testCases := []struct {
+		name          string
+		key           string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "success",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					TouchAPIKey(gomock.Any(), gomock.Eq(apiKey.ID)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var payload tokens2.Payload
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &payload))
+				require.Equal(t, apiKey.ID, payload.ID)
+				require.Equal(t, user.Username, payload.Subject)
+				require.Equal(t, apiKeyAudience, payload.Audience)
+				require.Equal(t, []string{scopeAccountsRead}, payload.Scopes)
+			},
+		},
+		{
+			name: "touch_failure_is_ignored",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					TouchAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "malformed_key",
+			key:  "not-a-key",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTokenInvalid)
+			},
+		},
+		{
+			name: "unknown_prefix",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ApiKey{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTokenInvalid)
+			},
+		},
+		{
+			name: "wrong_secret",
+			key:  apiKey.Prefix + "_wrong",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					TouchAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTokenInvalid)
+			},
+		},
+		{
+			name: "revoked",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				revoked := apiKey
+				revoked.RevokedAt = time.Now()
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(revoked, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTokenRevoked)
+			},
+		},
+		{
+			name: "expired",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				expired := apiKey
+				expired.ExpiresAt = time.Now().Add(-time.Minute)
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(expired, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTokenExpired)
+			},
+		},
+		{
+			name: "survives_password_change",
+			key:  key,
+			buildStubs: func(store *mockdb.MockStore) {
+				changed := user
+				changed.PasswordChangedAt = time.Now()
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(changed, nil)
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					TouchAPIKey(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthUsers(store, user)
+
+			testContainer := newTestContainer(t, store)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				authPath := "/auth"
+				server.engine.GET(
+					authPath,
+					authMiddleware(server.bank),
+					func(c *gin.Context) {
+						c.JSON(http.StatusOK, getPayloadFromGinCtx(c))
+					},
+				)
+
+				recorder := httptest.NewRecorder()
+				request, err := http.NewRequest(http.MethodGet, authPath, nil)
+				require.NoError(t, err)
+
+				addAPIKeyAuthorization(request, tc.key)
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	user, _ := randomUser(t)
+	key, apiKey := randomAPIKey(t, user.Username, scopeAccountsRead)
+
+	testCases := []struct {
+		name          string
+		method        string
+		url           string
+		body          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "granted_scope",
+			method: http.MethodGet,
+			url:    "/accounts?page_id=1&page_size=5",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Eq(db.ListAccountsParams{Owner: user.Username, Limit: 5})).
+					Times(1).
+					Return([]db.Account{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "missing_scope",
+			method: http.MethodPost,
+			url:    "/accounts",
+			body:   `{"currency": "USD"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAccountTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errInsufficientScope)
+			},
+		},
+		{
+			name:   "session_only_route",
+			method: http.MethodPost,
+			url:    "/users/me/api-keys",
+			body:   `{"name": "escalation", "scopes": ["transfers:write"]}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errInsufficientScope)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, user)
+			store.EXPECT().
+				GetAPIKeyByPrefix(gomock.Any(), gomock.Eq(apiKey.Prefix)).
+				AnyTimes().
+				Return(apiKey, nil)
+			store.EXPECT().
+				TouchAPIKey(gomock.Any(), gomock.Any()).
+				AnyTimes()
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(tc.method, tc.url, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			addAPIKeyAuthorization(request, key)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestServer_createAPIKey(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "success",
+			body: `{"name": "reporting", "scopes": ["accounts:read", "transfers:write"]}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Cond[db.CreateAPIKeyParams](func(x db.CreateAPIKeyParams) bool {
+						return x.Username == user.Username &&
+							x.Name == "reporting" &&
+							x.ID != uuid.Nil &&
+							x.Prefix != "" &&
+							x.HashedSecret != "" &&
+							x.ExpiresAt.IsZero() &&
+							len(x.Scopes) == 2
+					})).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+						return db.ApiKey{
+							ID:           arg.ID,
+							Username:     arg.Username,
+							Name:         arg.Name,
+							Prefix:       arg.Prefix,
+							HashedSecret: arg.HashedSecret,
+							Scopes:       arg.Scopes,
+							CreatedAt:    time.Now(),
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var result struct {
+					Key    string `json:"key"`
+					Prefix string `json:"prefix"`
+					// the hash never leaves the server
+					HashedSecret string `json:"hashed_secret"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+				require.Empty(t, result.HashedSecret)
+
+				prefix, ok := security.ParseAPIKey(result.Key)
+				require.True(t, ok)
+				require.Equal(t, result.Prefix, prefix)
+			},
+		},
+		{
+			name: "with_expiry",
+			body: fmt.Sprintf(`{"name": "reporting", "scopes": ["accounts:read"], "expires_at": %q}`,
+				time.Now().Add(24*time.Hour).Format(time.RFC3339)),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Cond[db.CreateAPIKeyParams](func(x db.CreateAPIKeyParams) bool {
+						return time.Until(x.ExpiresAt) > 23*time.Hour
+					})).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name: "past_expiry",
+			body: fmt.Sprintf(`{"name": "reporting", "scopes": ["accounts:read"], "expires_at": %q}`,
+				time.Now().Add(-time.Hour).Format(time.RFC3339)),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+		{
+			name: "unknown_scope",
+			body: `{"name": "reporting", "scopes": ["users:admin"]}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+		{
+			name: "no_scopes",
+			body: `{"name": "reporting", "scopes": []}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, user)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/me/api-keys", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestServer_listAPIKeys(t *testing.T) {
+	user, _ := randomUser(t)
+	_, apiKey1 := randomAPIKey(t, user.Username, scopeAccountsRead)
+	_, apiKey2 := randomAPIKey(t, user.Username, scopeTransfersWrite)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	stubAuthUsers(store, user)
+	store.EXPECT().
+		ListAPIKeys(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return([]db.ApiKey{apiKey1, apiKey2}, nil)
+
+	testContainer := newTestContainer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/users/me/api-keys", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+		addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+		server.engine.ServeHTTP(recorder, request)
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.NotContains(t, recorder.Body.String(), apiKey1.HashedSecret)
+
+		var result []apiKeyResponse
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+		require.Len(t, result, 2)
+		require.Equal(t, apiKey1.ID, result[0].ID)
+		require.Equal(t, apiKey2.Prefix, result[1].Prefix)
+	}))
+}
+
+func TestServer_revokeAPIKey(t *testing.T) {
+	user, _ := randomUser(t)
+	_, apiKey := randomAPIKey(t, user.Username, scopeAccountsRead)
+
+	testCases := []struct {
+		name          string
+		id            string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "success",
+			id:   apiKey.ID.String(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeAPIKey(gomock.Any(), gomock.Eq(db.RevokeAPIKeyParams{ID: apiKey.ID, Username: user.Username})).
+					Times(1).
+					Return(int64(1), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name: "not_found_or_not_owned",
+			id:   apiKey.ID.String(),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeAPIKey(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(0), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errNotFound)
+			},
+		},
+		{
+			name: "invalid_id",
+			id:   "123",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeAPIKey(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, user)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodDelete, "/users/me/api-keys/"+tc.id, nil)
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func randomAPIKey(t *testing.T, username string, scopes ...string) (string, db.ApiKey) {
+	key, prefix, err := security.GenerateAPIKey()
+	require.NoError(t, err)
+
+	return key, db.ApiKey{
+		ID:           uuid.New(),
+		Username:     username,
+		Name:         "test",
+		Prefix:       prefix,
+		HashedSecret: security.HashOpaqueToken(key),
+		Scopes:       scopes,
+		CreatedAt:    time.Now().Add(-time.Hour),
+	}
+}
+
+func addAPIKeyAuthorization(request *http.Request, key string) {
+	request.Header.Add(authorizationHeader, "ApiKey "+key)
+}
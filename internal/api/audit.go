@@ -0,0 +1,216 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"log/slog"
+	"net/http"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	tokens2 "simple-bank/internal/tokens"
+	"time"
+)
+
+const (
+	auditActionUserCreate         = "user.create"
+	auditActionUserUpdate         = "user.update"
+	auditActionUserLogin          = "user.login"
+	auditActionUserVerifyEmail    = "user.verify_email"
+	auditActionUserPasswordChange = "user.password_change"
+	auditActionUserPasswordReset  = "user.password_reset"
+	auditActionUserTOTPEnable     = "user.totp_enable"
+	auditActionAPIKeyCreate       = "api_key.create"
+	auditActionAPIKeyRevoke       = "api_key.revoke"
+	auditActionOAuthClientCreate  = "oauth_client.create"
+	auditActionOAuthConsent       = "oauth_client.consent"
+	auditActionAccountCreate      = "account.create"
+	auditActionTransferCreate     = "transfer.create"
+	auditActionWebhookCreate      = "webhook.create"
+	auditActionWebhookUpdate      = "webhook.update"
+	auditActionWebhookDelete      = "webhook.delete"
+	auditActionWebhookReplay      = "webhook.replay"
+	auditActionAdminAccess        = "admin.access"
+	auditActionAuditEventsQuery   = "audit_events.query"
+	auditActionAuditEventsExport  = "audit_events.export"
+	auditTargetUser               = "user"
+	auditTargetAPIKey             = "api_key"
+	auditTargetOAuthClient        = "oauth_client"
+	auditTargetAccount            = "account"
+	auditTargetTransfer           = "transfer"
+	auditTargetWebhook            = "webhook"
+	auditTargetAuditEvents        = "audit_events"
+	auditTargetRoute              = "route"
+	// auditReasonAccountLocked tells a locked user apart in the audit log,
+	// the client gets invalid_credentials
+	auditReasonAccountLocked = "account_locked"
+)
+
+const (
+	auditEventsPageSize    int32 = 50
+	auditEventsExportBatch int32 = 500
+)
+
+// recordAudit fills in what is known about the request and appends event to
+// the audit log. A failure is logged, it never fails the request: the action
+// it describes has already happened.
+func recordAudit(c *gin.Context, recorder audit.Recorder, event audit.Event) {
+	if event.Actor == "" {
+		if payload, ok := c.Get(authorizationPayloadKey); ok {
+			event.Actor = payload.(*tokens2.Payload).Subject
+		}
+	}
+	event.RequestID = c.GetString(requestIDKey)
+	event.IP = c.ClientIP()
+
+	if err := recorder.Record(c, event); err != nil {
+		slog.Error("record audit event", "action", event.Action, "actor", event.Actor, "err", err)
+	}
+}
+
+func (s *Server) recordAudit(c *gin.Context, event audit.Event) {
+	recordAudit(c, s.auditRecorder, event)
+}
+
+type listAuditEventsRequest struct {
+	Actor      string    `form:"actor"`
+	Action     string    `form:"action"`
+	TargetType string    `form:"target_type"`
+	TargetID   string    `form:"target_id"`
+	Outcome    string    `form:"outcome" binding:"omitempty,oneof=success failure denied"`
+	Since      time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until      time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	BeforeID   int64     `form:"before_id" binding:"omitempty,min=1"`
+	PageSize   int32     `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+func (r listAuditEventsRequest) params(limit int32) db.ListAuditEventsParams {
+	return db.ListAuditEventsParams{
+		Actor:      optionalString(r.Actor),
+		Action:     optionalString(r.Action),
+		TargetType: optionalString(r.TargetType),
+		TargetID:   optionalString(r.TargetID),
+		Outcome:    optionalString(r.Outcome),
+		Since:      pgtype.Timestamptz{Time: r.Since, Valid: !r.Since.IsZero()},
+		Until:      pgtype.Timestamptz{Time: r.Until, Valid: !r.Until.IsZero()},
+		BeforeID:   pgtype.Int8{Int64: r.BeforeID, Valid: r.BeforeID > 0},
+		RowLimit:   limit,
+	}
+}
+
+// filters is the Diff of the audit event the query itself is recorded with.
+func (r listAuditEventsRequest) filters() map[string]any {
+	filters := map[string]any{}
+	for name, value := range map[string]string{
+		"actor":       r.Actor,
+		"action":      r.Action,
+		"target_type": r.TargetType,
+		"target_id":   r.TargetID,
+		"outcome":     r.Outcome,
+	} {
+		if value != "" {
+			filters[name] = value
+		}
+	}
+	if !r.Since.IsZero() {
+		filters["since"] = r.Since
+	}
+	if !r.Until.IsZero() {
+		filters["until"] = r.Until
+	}
+	return filters
+}
+
+type listAuditEventsResponse struct {
+	Events []db.AuditEvent `json:"events"`
+	// NextBeforeID is the before_id of the next page, it's omitted on the
+	// last one.
+	NextBeforeID int64 `json:"next_before_id,omitempty"`
+}
+
+// listAuditEvents pages through the audit log, newest first.
+func (s *Server) listAuditEvents(c *gin.Context) {
+	var request listAuditEventsRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	pageSize := request.PageSize
+	if pageSize == 0 {
+		pageSize = auditEventsPageSize
+	}
+
+	events, err := s.store.ListAuditEvents(c, request.params(pageSize))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionAuditEventsQuery,
+		TargetType: auditTargetAuditEvents,
+		Outcome:    audit.OutcomeSuccess,
+		Diff:       request.filters(),
+	})
+
+	response := listAuditEventsResponse{Events: events}
+	if len(events) == int(pageSize) {
+		response.NextBeforeID = events[len(events)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// exportAuditEvents streams every event matching the filters as
+// newline-delimited JSON, newest first. Once the first line is written the
+// status can't change anymore, so a later failure just ends the stream.
+func (s *Server) exportAuditEvents(c *gin.Context) {
+	var request listAuditEventsRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	events, err := s.store.ListAuditEvents(c, request.params(auditEventsExportBatch))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionAuditEventsExport,
+		TargetType: auditTargetAuditEvents,
+		Outcome:    audit.OutcomeSuccess,
+		Diff:       request.filters(),
+	})
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="audit-events.ndjson"`)
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	for {
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				slog.Error("export audit events", "err", err)
+				return
+			}
+		}
+		c.Writer.Flush()
+
+		if len(events) < int(auditEventsExportBatch) {
+			return
+		}
+
+		request.BeforeID = events[len(events)-1].ID
+		events, err = s.store.ListAuditEvents(c, request.params(auditEventsExportBatch))
+		if err != nil {
+			slog.Error("export audit events", "err", err)
+			return
+		}
+	}
+}
+
+func optionalString(value string) pgtype.Text {
+	return pgtype.Text{String: value, Valid: value != ""}
+}
@@ -0,0 +1,449 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/random"
+	"simple-bank/internal/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	testCases := []struct {
+		name      string
+		requestID string
+		check     func(t *testing.T, requestID string)
+	}{
+		{
+			name: "generated",
+			check: func(t *testing.T, requestID string) {
+				require.Len(t, requestID, 36)
+			},
+		},
+		{
+			name:      "kept_from_proxy",
+			requestID: "edge-1234.abc_DEF",
+			check: func(t *testing.T, requestID string) {
+				require.Equal(t, "edge-1234.abc_DEF", requestID)
+			},
+		},
+		{
+			name:      "invalid_replaced",
+			requestID: "<script>",
+			check: func(t *testing.T, requestID string) {
+				require.Len(t, requestID, 36)
+			},
+		},
+		{
+			name:      "too_long_replaced",
+			requestID: strings.Repeat("a", maxRequestIDLength+1),
+			check: func(t *testing.T, requestID string) {
+				require.Len(t, requestID, 36)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			testContainer := newTestContainer(t, mockdb.NewMockStore(ctrl))
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/login", nil)
+			require.NoError(t, err)
+			if tc.requestID != "" {
+				request.Header.Set(requestIDHeader, tc.requestID)
+			}
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				server.engine.ServeHTTP(recorder, request)
+				tc.check(t, recorder.Header().Get(requestIDHeader))
+			}))
+		})
+	}
+}
+
+func TestServer_listAuditEvents(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = db.UserRoleAdmin
+	customer, _ := randomUser(t)
+	key, apiKey := randomAPIKey(t, admin.Username, scopeAccountsRead)
+	events := []db.AuditEvent{randomAuditEvent(customer.Username), randomAuditEvent(customer.Username)}
+
+	testCases := []struct {
+		name          string
+		query         string
+		setupAuth     func(t *testing.T, request *http.Request, server *Server)
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server)
+	}{
+		{
+			name:  "filters",
+			query: fmt.Sprintf("actor=%s&outcome=failure&since=2026-01-02T15:04:05Z&before_id=100&page_size=2", customer.Username),
+			setupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.tokensManager, authorizationTypeBearer, accessTokenParams(admin.Username))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Eq(db.ListAuditEventsParams{
+						Actor:    pgtype.Text{String: customer.Username, Valid: true},
+						Outcome:  pgtype.Text{String: "failure", Valid: true},
+						Since:    pgtype.Timestamptz{Time: time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC), Valid: true},
+						BeforeID: pgtype.Int8{Int64: 100, Valid: true},
+						RowLimit: 2,
+					})).
+					Times(1).
+					Return(events, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var response listAuditEventsResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Len(t, response.Events, 2)
+				require.Equal(t, events[1].ID, response.NextBeforeID)
+
+				recorded := auditEvents(t, server.auditRecorder)
+				require.Len(t, recorded, 1)
+				require.Equal(t, auditActionAuditEventsQuery, recorded[0].Action)
+				require.Equal(t, admin.Username, recorded[0].Actor)
+				require.Equal(t, customer.Username, recorded[0].Diff["actor"])
+			},
+		},
+		{
+			name:  "last_page",
+			query: "",
+			setupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.tokensManager, authorizationTypeBearer, accessTokenParams(admin.Username))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Eq(db.ListAuditEventsParams{RowLimit: auditEventsPageSize})).
+					Times(1).
+					Return(events, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var response listAuditEventsResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Zero(t, response.NextBeforeID)
+			},
+		},
+		{
+			name:  "invalid_outcome",
+			query: "outcome=maybe",
+			setupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.tokensManager, authorizationTypeBearer, accessTokenParams(admin.Username))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+		{
+			name: "not_admin",
+			setupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAuthorization(t, request, server.tokensManager, authorizationTypeBearer, accessTokenParams(customer.Username))
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireProblemCode(t, recorder, errForbidden)
+
+				recorded := auditEvents(t, server.auditRecorder)
+				require.Len(t, recorded, 1)
+				require.Equal(t, auditActionAdminAccess, recorded[0].Action)
+				require.Equal(t, customer.Username, recorded[0].Actor)
+				require.Equal(t, audit.OutcomeDenied, recorded[0].Outcome)
+				require.Equal(t, "GET /admin/audit-events", recorded[0].TargetID)
+			},
+		},
+		{
+			name: "api_key",
+			setupAuth: func(t *testing.T, request *http.Request, server *Server) {
+				addAPIKeyAuthorization(request, key)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(apiKey, nil)
+				store.EXPECT().
+					TouchAPIKey(gomock.Any(), gomock.Any()).
+					AnyTimes()
+				store.EXPECT().
+					ListAuditEvents(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireProblemCode(t, recorder, errInsufficientScope)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, admin, customer)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/admin/audit-events?"+tc.query, nil)
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				tc.setupAuth(t, request, server)
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder, server)
+			}))
+		})
+	}
+}
+
+func TestServer_exportAuditEvents(t *testing.T) {
+	admin, _ := randomUser(t)
+	admin.Role = db.UserRoleAdmin
+
+	firstBatch := make([]db.AuditEvent, auditEventsExportBatch)
+	for i := range firstBatch {
+		firstBatch[i] = randomAuditEvent(admin.Username)
+		firstBatch[i].ID = int64(1000 - i)
+	}
+	lastBatch := []db.AuditEvent{randomAuditEvent(admin.Username)}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	stubAuthUsers(store, admin)
+	gomock.InOrder(
+		store.EXPECT().
+			ListAuditEvents(gomock.Any(), gomock.Eq(db.ListAuditEventsParams{
+				Action:   pgtype.Text{String: auditActionUserLogin, Valid: true},
+				RowLimit: auditEventsExportBatch,
+			})).
+			Times(1).
+			Return(firstBatch, nil),
+		store.EXPECT().
+			ListAuditEvents(gomock.Any(), gomock.Eq(db.ListAuditEventsParams{
+				Action:   pgtype.Text{String: auditActionUserLogin, Valid: true},
+				BeforeID: pgtype.Int8{Int64: firstBatch[len(firstBatch)-1].ID, Valid: true},
+				RowLimit: auditEventsExportBatch,
+			})).
+			Times(1).
+			Return(lastBatch, nil),
+	)
+
+	testContainer := newTestContainer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/admin/audit-events/export?action="+auditActionUserLogin, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, testContainer.Invoke(func(server *Server) {
+		addAuthorization(t, request, server.tokensManager, authorizationTypeBearer, accessTokenParams(admin.Username))
+		server.engine.ServeHTTP(recorder, request)
+
+		recorded := auditEvents(t, server.auditRecorder)
+		require.Len(t, recorded, 1)
+		require.Equal(t, auditActionAuditEventsExport, recorded[0].Action)
+	}))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "application/x-ndjson", recorder.Header().Get("Content-Type"))
+
+	lines := 0
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		var event db.AuditEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		lines++
+	}
+	require.NoError(t, scanner.Err())
+	require.Equal(t, len(firstBatch)+len(lastBatch), lines)
+}
+
+func TestAuditTrail(t *testing.T) {
+	user, _ := randomUser(t)
+	user.EmailVerifiedAt = time.Now()
+	other, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	account.Currency = utils.CurrencyUSD
+	otherAccount := randomAccount(other.Username)
+	otherAccount.Currency = utils.CurrencyUSD
+
+	testCases := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		auth       bool
+		buildStubs func(store *mockdb.MockStore)
+		check      func(t *testing.T, event audit.Event)
+	}{
+		{
+			name:   "failed_login",
+			method: http.MethodPost,
+			url:    "/users/login",
+			body:   `{"username": "nobody", "password": "secret123"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq("nobody")).
+					Times(1).
+					Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			check: func(t *testing.T, event audit.Event) {
+				require.Equal(t, auditActionUserLogin, event.Action)
+				require.Equal(t, "nobody", event.Actor)
+				require.Equal(t, audit.OutcomeFailure, event.Outcome)
+				require.Equal(t, errInvalidCredentials.Code, event.Diff["reason"])
+			},
+		},
+		{
+			name:   "profile_update",
+			method: http.MethodPatch,
+			url:    "/users/me",
+			body:   `{"full_name": "Somebody Else"}`,
+			auth:   true,
+			buildStubs: func(store *mockdb.MockStore) {
+				updated := user
+				updated.FullName = "Somebody Else"
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(updated, nil)
+			},
+			check: func(t *testing.T, event audit.Event) {
+				require.Equal(t, auditActionUserUpdate, event.Action)
+				require.Equal(t, user.Username, event.Actor)
+				require.Equal(t, audit.Change{From: user.FullName, To: "Somebody Else"}, event.Diff["full_name"])
+				require.NotContains(t, event.Diff, "email")
+			},
+		},
+		{
+			name:   "transfer_from_foreign_account",
+			method: http.MethodPost,
+			url:    "/transfers",
+			body:   fmt.Sprintf(`{"from_account_id": %d, "to_account_id": %d, "amount": 10, "currency": "USD"}`, otherAccount.ID, account.ID),
+			auth:   true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(otherAccount.ID)).
+					Times(1).
+					Return(otherAccount, nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			check: func(t *testing.T, event audit.Event) {
+				require.Equal(t, auditActionTransferCreate, event.Action)
+				require.Equal(t, audit.OutcomeDenied, event.Outcome)
+				require.Equal(t, errForbidden.Code, event.Diff["reason"])
+				require.Equal(t, otherAccount.ID, event.Diff["from_account_id"])
+			},
+		},
+		{
+			name:   "transfer",
+			method: http.MethodPost,
+			url:    "/transfers",
+			body:   fmt.Sprintf(`{"from_account_id": %d, "to_account_id": %d, "amount": 10, "currency": "USD"}`, account.ID, otherAccount.ID),
+			auth:   true,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account.ID)).
+					Times(1).
+					Return(account, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(otherAccount.ID)).
+					Times(1).
+					Return(otherAccount, nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.TransferTxResult{Transfer: db.Transfer{ID: 42}}, nil)
+			},
+			check: func(t *testing.T, event audit.Event) {
+				require.Equal(t, auditActionTransferCreate, event.Action)
+				require.Equal(t, audit.OutcomeSuccess, event.Outcome)
+				require.Equal(t, "42", event.TargetID)
+				require.Equal(t, int64(10), event.Diff["amount"])
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			stubAuthUsers(store, user)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(tc.method, tc.url, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			request.Header.Set(requestIDHeader, "test-request")
+			request.RemoteAddr = "192.0.2.1:51234"
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				if tc.auth {
+					addAuthorization(t, request, server.tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				}
+				server.engine.ServeHTTP(recorder, request)
+
+				recorded := auditEvents(t, server.auditRecorder)
+				require.Len(t, recorded, 1)
+				require.Equal(t, "test-request", recorded[0].RequestID)
+				require.Equal(t, "192.0.2.1", recorded[0].IP)
+				tc.check(t, recorded[0])
+			}))
+		})
+	}
+}
+
+func randomAuditEvent(actor string) db.AuditEvent {
+	return db.AuditEvent{
+		ID:         random.Int64(1, 1000),
+		Actor:      actor,
+		Action:     auditActionUserLogin,
+		TargetType: auditTargetUser,
+		TargetID:   actor,
+		RequestID:  random.String(16),
+		Ip:         "192.0.2.1",
+		Outcome:    string(audit.OutcomeSuccess),
+		Diff:       json.RawMessage(`{}`),
+		CreatedAt:  time.Now(),
+	}
+}
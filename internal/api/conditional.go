@@ -0,0 +1,65 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	etagHeader        = "ETag"
+	ifMatchHeader     = "If-Match"
+	ifNoneMatchHeader = "If-None-Match"
+)
+
+// versionETag is the entity tag of a row at version. It does not tell the
+// API versions apart, their paths already do.
+func versionETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// etagsMatch reports whether the If-Match or If-None-Match list contains
+// etag or is "*". If-None-Match compares weakly and lets a W/ tag match,
+// If-Match compares strongly.
+func etagsMatch(list string, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag of a resource at version and answers 304 when
+// If-None-Match says the client already has it.
+func notModified(c *gin.Context, version int64) bool {
+	etag := versionETag(version)
+	c.Header(etagHeader, etag)
+
+	if list := c.GetHeader(ifNoneMatchHeader); list != "" && etagsMatch(list, etag, true) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// expectedVersion checks If-Match against the version of the resource as
+// the request found it. The write still has to be conditioned on the
+// returned version, another one can land between the read and the write.
+// Without If-Match the write is unconditional.
+func expectedVersion(c *gin.Context, version int64) (pgtype.Int8, error) {
+	list := c.GetHeader(ifMatchHeader)
+	if list == "" {
+		return pgtype.Int8{}, nil
+	}
+	if !etagsMatch(list, versionETag(version), false) {
+		return pgtype.Int8{}, errPreconditionFailed.withDetail("current ETag is %s", versionETag(version))
+	}
+	return pgtype.Int8{Int64: version, Valid: true}, nil
+}
@@ -0,0 +1,281 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"simple-bank/internal/security"
+	"simple-bank/internal/service"
+	"simple-bank/internal/tokens"
+	"strings"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypePrefix  = "/problems/"
+)
+
+// apiError is an entry of the error catalog. Code is stable and meant to be
+// matched by clients, Title and Detail are for humans only.
+type apiError struct {
+	Code   string
+	Status int
+	Title  string
+	Detail string
+	Fields []fieldError
+}
+
+func (e *apiError) Error() string {
+	if e.Detail == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *apiError) Is(target error) bool {
+	t, ok := target.(*apiError)
+	return ok && t.Code == e.Code
+}
+
+func (e *apiError) withDetail(format string, args ...any) *apiError {
+	cp := *e
+	cp.Detail = fmt.Sprintf(format, args...)
+	return &cp
+}
+
+func (e *apiError) withFields(fields []fieldError) *apiError {
+	cp := *e
+	cp.Fields = fields
+	return &cp
+}
+
+var (
+	errInvalidRequest          = &apiError{Code: "invalid_request", Status: http.StatusBadRequest, Title: "Request is malformed"}
+	errValidationFailed        = &apiError{Code: "validation_failed", Status: http.StatusBadRequest, Title: "Request validation failed"}
+	errWeakPassword            = &apiError{Code: "weak_password", Status: http.StatusBadRequest, Title: "Password does not meet the password policy"}
+	errUserTokenInvalid        = &apiError{Code: "user_token_invalid", Status: http.StatusBadRequest, Title: "Link is invalid or has expired"}
+	errCurrencyMismatch        = &apiError{Code: "currency_mismatch", Status: http.StatusBadRequest, Title: "Account currency does not match"}
+	errUnauthorized            = &apiError{Code: "unauthorized", Status: http.StatusUnauthorized, Title: "Authentication is required"}
+	errTokenInvalid            = &apiError{Code: "token_invalid", Status: http.StatusUnauthorized, Title: "Access token is invalid"}
+	errTokenExpired            = &apiError{Code: "token_expired", Status: http.StatusUnauthorized, Title: "Access token has expired"}
+	errTokenRevoked            = &apiError{Code: "token_revoked", Status: http.StatusUnauthorized, Title: "Access token has been revoked"}
+	errTokenNotValidYet        = &apiError{Code: "token_not_valid_yet", Status: http.StatusUnauthorized, Title: "Access token is not valid yet"}
+	errInvalidCredentials      = &apiError{Code: "invalid_credentials", Status: http.StatusUnauthorized, Title: "Invalid credentials"}
+	errAccountFrozen           = &apiError{Code: "account_frozen", Status: http.StatusForbidden, Title: "Account is frozen"}
+	errEmailNotVerified        = &apiError{Code: "email_not_verified", Status: http.StatusForbidden, Title: "Email address is not verified"}
+	errTwoFactorRequired       = &apiError{Code: "two_factor_required", Status: http.StatusForbidden, Title: "Two-factor verification is required"}
+	errTwoFactorCodeInvalid    = &apiError{Code: "two_factor_code_invalid", Status: http.StatusForbidden, Title: "Two-factor code is invalid"}
+	errTwoFactorAlreadyEnabled = &apiError{Code: "two_factor_already_enabled", Status: http.StatusForbidden, Title: "Two-factor authentication is already enabled"}
+	errInsufficientScope       = &apiError{Code: "insufficient_scope", Status: http.StatusForbidden, Title: "Credentials lack the required scope"}
+	errForbidden               = &apiError{Code: "forbidden", Status: http.StatusForbidden, Title: "Access to the resource is forbidden"}
+	errAlreadyExists           = &apiError{Code: "already_exists", Status: http.StatusForbidden, Title: "Resource already exists"}
+	errReferenceViolation      = &apiError{Code: "reference_violation", Status: http.StatusForbidden, Title: "Referenced resource does not exist"}
+	errNotFound                = &apiError{Code: "not_found", Status: http.StatusNotFound, Title: "Resource not found"}
+	errPreconditionFailed      = &apiError{Code: "precondition_failed", Status: http.StatusPreconditionFailed, Title: "Resource has changed"}
+	errRateLimited             = &apiError{Code: "rate_limited", Status: http.StatusTooManyRequests, Title: "Too many requests"}
+	errInternal                = &apiError{Code: "internal_error", Status: http.StatusInternalServerError, Title: "Internal server error"}
+)
+
+type fieldError struct {
+	Field  string `json:"field"`
+	Rule   string `json:"rule"`
+	Param  string `json:"param,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type problemDetails struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Code     string       `json:"code"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []fieldError `json:"errors,omitempty"`
+}
+
+// abortWithError writes err as an RFC 7807 problem and aborts the chain.
+// Errors that are not part of the catalog are logged and never exposed.
+func abortWithError(c *gin.Context, err error) {
+	apiErr := toAPIError(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		slog.Error("request failed", "method", c.Request.Method, "path", c.Request.URL.Path, "err", err)
+	}
+
+	c.Header("Content-Type", problemContentType)
+	c.AbortWithStatusJSON(apiErr.Status, problemDetails{
+		Type:     problemTypePrefix + apiErr.Code,
+		Title:    apiErr.Title,
+		Status:   apiErr.Status,
+		Code:     apiErr.Code,
+		Detail:   apiErr.Detail,
+		Instance: c.Request.URL.Path,
+		Errors:   apiErr.Fields,
+	})
+}
+
+func toAPIError(err error) *apiError {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	if apiErr := serviceError(err); apiErr != nil {
+		return apiErr
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgerrcode.UniqueViolation:
+			return errAlreadyExists
+		case pgerrcode.ForeignKeyViolation:
+			return errReferenceViolation
+		}
+		return errInternal
+	}
+
+	switch {
+	case errors.Is(err, tokens.ErrTokenExpired):
+		return errTokenExpired
+	case errors.Is(err, tokens.ErrTokenNotValidYet):
+		return errTokenNotValidYet
+	case errors.Is(err, tokens.ErrTokenInvalid):
+		return errTokenInvalid
+	case errors.Is(err, security.ErrPasswordNotMatched):
+		return errInvalidCredentials
+	}
+
+	return errInternal
+}
+
+// serviceErrors maps the refusals of the service package to the catalog.
+var serviceErrors = map[error]*apiError{
+	service.ErrUnauthorized:         errUnauthorized,
+	service.ErrTokenRevoked:         errTokenRevoked,
+	service.ErrWeakPassword:         errWeakPassword,
+	service.ErrInvalidCredentials:   errInvalidCredentials,
+	service.ErrEmailNotVerified:     errEmailNotVerified,
+	service.ErrTwoFactorRequired:    errTwoFactorRequired,
+	service.ErrTwoFactorCodeInvalid: errTwoFactorCodeInvalid,
+	service.ErrForbidden:            errForbidden,
+	service.ErrCurrencyMismatch:     errCurrencyMismatch,
+	service.ErrAccountFrozen:        errAccountFrozen,
+}
+
+// serviceError converts a refusal of the service package, keeping its
+// detail. It returns nil for any other error.
+func serviceError(err error) *apiError {
+	for serviceErr, apiErr := range serviceErrors {
+		if !errors.Is(err, serviceErr) {
+			continue
+		}
+		var refusal *service.Error
+		if errors.As(err, &refusal) && refusal.Detail != "" {
+			return apiErr.withDetail("%s", refusal.Detail)
+		}
+		return apiErr
+	}
+	return nil
+}
+
+// bindingError converts an error returned by gin's ShouldBind* helpers.
+// Whatever the reason, it is the client's fault.
+func bindingError(err error) *apiError {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return errValidationFailed.withFields(newFieldErrors(validationErrs))
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return errInvalidRequest.withDetail("body is not valid JSON")
+	case errors.As(err, &typeErr):
+		return errInvalidRequest.withFields([]fieldError{{
+			Field:  typeErr.Field,
+			Rule:   "type",
+			Param:  typeErr.Type.String(),
+			Reason: fmt.Sprintf("must be of type %s", typeErr.Type.String()),
+		}})
+	}
+
+	return errInvalidRequest
+}
+
+func newFieldErrors(validationErrs validator.ValidationErrors) []fieldError {
+	fields := make([]fieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		fields = append(fields, fieldError{
+			Field:  fe.Field(),
+			Rule:   fe.Tag(),
+			Param:  fe.Param(),
+			Reason: validationReason(fe),
+		})
+	}
+	return fields
+}
+
+func validationReason(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "gt":
+		if fe.Param() == "" {
+			return "must be in the future"
+		}
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of %s", fe.Param())
+	case "eq":
+		return fmt.Sprintf("must be %s", fe.Param())
+	case "len":
+		return fmt.Sprintf("must be %s characters long", fe.Param())
+	case "url":
+		return "must be a valid URL"
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "email":
+		return "must be a valid email address"
+	case "currency":
+		return "must be a supported currency"
+	case "scope":
+		return "must be a supported scope"
+	case "webhook_url":
+		return "must be an https URL of a public host"
+	default:
+		return fmt.Sprintf("failed on the %q rule", fe.Tag())
+	}
+}
+
+// fieldName makes validation errors report the name the client sent
+// (json, form or uri tag) instead of the Go struct field name.
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "uri"} {
+		name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/o1egl/paseto"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/security"
+	"simple-bank/internal/service"
+	tokens2 "simple-bank/internal/tokens"
+	"testing"
+	"time"
+)
+
+func TestToAPIError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected *apiError
+	}{
+		{name: "catalog_error", err: errForbidden.withDetail("detail"), expected: errForbidden},
+		{name: "wrapped_catalog_error", err: fmt.Errorf("wrapped: %w", errCurrencyMismatch), expected: errCurrencyMismatch},
+		{name: "no_rows", err: pgx.ErrNoRows, expected: errNotFound},
+		{name: "unique_violation", err: &pgconn.PgError{Code: "23505"}, expected: errAlreadyExists},
+		{name: "foreign_key_violation", err: &pgconn.PgError{Code: "23503"}, expected: errReferenceViolation},
+		{name: "other_pq_error", err: &pgconn.PgError{Code: "42P01", Message: "relation does not exist"}, expected: errInternal},
+		{name: "token_expired", err: tokens2.ErrTokenExpired, expected: errTokenExpired},
+		{name: "token_not_valid_yet", err: tokens2.ErrTokenNotValidYet, expected: errTokenNotValidYet},
+		{name: "token_invalid", err: tokens2.ErrTokenInvalid, expected: errTokenInvalid},
+		{name: "token_library_error", err: service.TokenError(paseto.ErrInvalidTokenAuth), expected: errTokenInvalid},
+		{name: "password_not_matched", err: security.ErrPasswordNotMatched, expected: errInvalidCredentials},
+		{name: "unknown", err: errors.New("connection reset by peer"), expected: errInternal},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			apiErr := toAPIError(tc.err)
+			require.Equal(t, tc.expected.Code, apiErr.Code)
+			require.Equal(t, tc.expected.Status, apiErr.Status)
+		})
+	}
+}
+
+func TestProblemResponse(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          []byte
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, problem problemDetails)
+	}{
+		{
+			name: "validation_failed",
+			body: []byte(`{"from_account_id": 1, "amount": 0, "currency": "XYZ"}`),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, problem problemDetails) {
+				require.Equal(t, http.StatusBadRequest, problem.Status)
+				require.Equal(t, errValidationFailed.Code, problem.Code)
+				require.Equal(t, "/problems/validation_failed", problem.Type)
+
+				fields := make(map[string]string)
+				for _, field := range problem.Errors {
+					fields[field.Field] = field.Rule
+				}
+				require.Equal(t, map[string]string{
+					"to_account_id": "required",
+					"amount":        "required",
+					"currency":      "currency",
+				}, fields)
+			},
+		},
+		{
+			name: "malformed_json",
+			body: []byte(`{"from_account_id": `),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, problem problemDetails) {
+				require.Equal(t, http.StatusBadRequest, problem.Status)
+				require.Equal(t, errInvalidRequest.Code, problem.Code)
+			},
+		},
+		{
+			name: "wrong_type",
+			body: []byte(`{"from_account_id": "one", "to_account_id": 2, "amount": 10, "currency": "USD"}`),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, problem problemDetails) {
+				require.Equal(t, http.StatusBadRequest, problem.Status)
+				require.Equal(t, errInvalidRequest.Code, problem.Code)
+				require.Len(t, problem.Errors, 1)
+				require.Equal(t, "from_account_id", problem.Errors[0].Field)
+			},
+		},
+		{
+			name: "internal_error_is_not_leaked",
+			body: []byte(`{"from_account_id": 1, "to_account_id": 2, "amount": 10, "currency": "USD"}`),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(randomAccount(user.Username), &pgconn.PgError{Code: "42P01", Message: "relation \"accounts\" does not exist"})
+			},
+			checkResponse: func(t *testing.T, problem problemDetails) {
+				require.Equal(t, http.StatusInternalServerError, problem.Status)
+				require.Equal(t, errInternal.Code, problem.Code)
+				require.Empty(t, problem.Detail)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(tc.body))
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, tokens2.PayloadCreationParams{
+					Subject:   user.Username,
+					Audience:  "test",
+					Issuer:    "test",
+					NotBefore: time.Now(),
+					Duration:  time.Minute,
+				})
+				server.engine.ServeHTTP(recorder, request)
+
+				require.Equal(t, problemContentType, recorder.Header().Get("Content-Type"))
+				var problem problemDetails
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+				require.Equal(t, recorder.Code, problem.Status)
+				require.Equal(t, "/transfers", problem.Instance)
+				tc.checkResponse(t, problem)
+			}))
+		})
+	}
+}
+
+func requireProblemCode(t *testing.T, recorder *httptest.ResponseRecorder, expected *apiError) {
+	var problem problemDetails
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	require.Equal(t, expected.Status, recorder.Code)
+	require.Equal(t, expected.Code, problem.Code)
+}
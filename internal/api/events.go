@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"simple-bank/internal/db"
+)
+
+// balanceChangedEventResponse is the data of an account.balance_changed
+// event.
+type balanceChangedEventResponse struct {
+	Account accountResponse `json:"account"`
+	Entry   entryResponse   `json:"entry"`
+}
+
+// transferEventResponse is the data of a transfer.completed event as one
+// side of the transfer sees it, the account and entry of the other side are
+// left out. A transfer between two accounts of a user has both.
+type transferEventResponse struct {
+	Transfer    transferResponse `json:"transfer"`
+	FromAccount *accountResponse `json:"from_account,omitempty"`
+	ToAccount   *accountResponse `json:"to_account,omitempty"`
+	FromEntry   *entryResponse   `json:"from_entry,omitempty"`
+	ToEntry     *entryResponse   `json:"to_entry,omitempty"`
+}
+
+// EventData renders the payload of an outbox event for every user it is
+// about, keyed by username. Events that aren't exposed to customers are
+// about no one. Webhooks and the account event stream both go through it,
+// the outbox payload is internal.
+func EventData(eventType string, payload []byte) (map[string]any, error) {
+	switch eventType {
+	case db.EventAccountCreated:
+		var account db.Account
+		if err := json.Unmarshal(payload, &account); err != nil {
+			return nil, err
+		}
+		return map[string]any{account.Owner: newAccountResponse(account)}, nil
+	case db.EventAccountBalanceChanged:
+		var changed db.BalanceChangedEvent
+		if err := json.Unmarshal(payload, &changed); err != nil {
+			return nil, err
+		}
+		return map[string]any{changed.Account.Owner: balanceChangedEventResponse{
+			Account: newAccountResponse(changed.Account),
+			Entry:   newEntryResponse(changed.Entry),
+		}}, nil
+	case db.EventTransferCompleted:
+		var result db.TransferTxResult
+		if err := json.Unmarshal(payload, &result); err != nil {
+			return nil, err
+		}
+		return transferEventData(result), nil
+	default:
+		return map[string]any{}, nil
+	}
+}
+
+func transferEventData(result db.TransferTxResult) map[string]any {
+	transfer := newTransferTxResponse(result)
+	sides := make(map[string]*transferEventResponse)
+	side := func(owner string) *transferEventResponse {
+		if sides[owner] == nil {
+			sides[owner] = &transferEventResponse{Transfer: transfer.Transfer}
+		}
+		return sides[owner]
+	}
+
+	from := side(result.FromAccount.Owner)
+	from.FromAccount, from.FromEntry = &transfer.FromAccount, &transfer.FromEntry
+	to := side(result.ToAccount.Owner)
+	to.ToAccount, to.ToEntry = &transfer.ToAccount, &transfer.ToEntry
+
+	data := make(map[string]any, len(sides))
+	for owner, response := range sides {
+		data[owner] = response
+	}
+	return data
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/db"
+	"testing"
+)
+
+func TestEventData(t *testing.T) {
+	transfer := db.TransferTxResult{
+		Transfer:    db.Transfer{ID: 5, FromAccountID: 1, ToAccountID: 2, Amount: 10},
+		FromAccount: db.Account{ID: 1, Owner: "bob", Balance: 90},
+		ToAccount:   db.Account{ID: 2, Owner: "alice", Balance: 1000},
+		FromEntry:   db.Entry{ID: 11, AccountID: 1, Amount: -10},
+		ToEntry:     db.Entry{ID: 12, AccountID: 2, Amount: 10},
+	}
+	ownTransfer := transfer
+	ownTransfer.ToAccount.Owner = "bob"
+
+	testCases := []struct {
+		name      string
+		eventType string
+		payload   any
+		expected  map[string]any
+	}{
+		{
+			name:      "transfer",
+			eventType: db.EventTransferCompleted,
+			payload:   transfer,
+			expected: map[string]any{
+				"bob": &transferEventResponse{
+					Transfer:    newTransferTxResponse(transfer).Transfer,
+					FromAccount: &accountResponse{ID: 1, Owner: "bob", Balance: 90},
+					FromEntry:   &entryResponse{ID: 11, AccountID: 1, Amount: -10},
+				},
+				"alice": &transferEventResponse{
+					Transfer:  newTransferTxResponse(transfer).Transfer,
+					ToAccount: &accountResponse{ID: 2, Owner: "alice", Balance: 1000},
+					ToEntry:   &entryResponse{ID: 12, AccountID: 2, Amount: 10},
+				},
+			},
+		},
+		{
+			name:      "transfer between own accounts",
+			eventType: db.EventTransferCompleted,
+			payload:   ownTransfer,
+			expected: map[string]any{
+				"bob": &transferEventResponse{
+					Transfer:    newTransferTxResponse(ownTransfer).Transfer,
+					FromAccount: &accountResponse{ID: 1, Owner: "bob", Balance: 90},
+					ToAccount:   &accountResponse{ID: 2, Owner: "bob", Balance: 1000},
+					FromEntry:   &entryResponse{ID: 11, AccountID: 1, Amount: -10},
+					ToEntry:     &entryResponse{ID: 12, AccountID: 2, Amount: 10},
+				},
+			},
+		},
+		{
+			name:      "balance changed",
+			eventType: db.EventAccountBalanceChanged,
+			payload:   db.BalanceChangedEvent{Account: transfer.ToAccount, Entry: transfer.ToEntry},
+			expected: map[string]any{
+				"alice": balanceChangedEventResponse{
+					Account: accountResponse{ID: 2, Owner: "alice", Balance: 1000},
+					Entry:   entryResponse{ID: 12, AccountID: 2, Amount: 10},
+				},
+			},
+		},
+		{
+			name:      "not exposed",
+			eventType: "user.created",
+			payload:   map[string]string{},
+			expected:  map[string]any{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload, err := json.Marshal(tc.payload)
+			require.NoError(t, err)
+
+			data, err := EventData(tc.eventType, payload)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, data)
+		})
+	}
+}
@@ -1,14 +1,22 @@
 package api
 
 import (
+	"context"
+	"crypto/sha1"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/dig"
 	"os"
+	"simple-bank/internal/audit"
 	"simple-bank/internal/config"
 	"simple-bank/internal/db"
+	"simple-bank/internal/mail"
 	"simple-bank/internal/random"
+	"simple-bank/internal/ratelimit"
+	"simple-bank/internal/security"
+	"simple-bank/internal/stream"
 	"simple-bank/internal/tokens"
+	"sync"
 	"testing"
 	"time"
 )
@@ -17,8 +25,14 @@ func newTestContainer(t *testing.T, store db.Store) *dig.Container {
 	container := dig.New()
 
 	require.NoError(t, container.Provide(getFakeConfig))
+	require.NoError(t, container.Provide(config.Fixed))
 	require.NoError(t, container.Provide(wrapDatabase(store)))
 	require.NoError(t, container.Provide(getPasetoManager))
+	require.NoError(t, container.Provide(getRateLimitStorage))
+	require.NoError(t, container.Provide(getFileMailer(t)))
+	require.NoError(t, container.Provide(getPasswordPolicy))
+	require.NoError(t, container.Provide(getAuditRecorder))
+	require.NoError(t, container.Provide(stream.NewBroker))
 	require.NoError(t, container.Provide(NewServer))
 
 	return container
@@ -26,8 +40,18 @@ func newTestContainer(t *testing.T, store db.Store) *dig.Container {
 
 func getFakeConfig() *config.Config {
 	return &config.Config{
-		TokenPrivateKey:     random.String(32),
-		AccessTokenDuration: time.Minute * 15,
+		TokenPrivateKey:            random.String(32),
+		RecoveryCodeKey:            random.String(32),
+		AccessTokenDuration:        time.Minute * 15,
+		LoginMaxAttempts:           5,
+		LoginLockoutDuration:       time.Minute,
+		TwoFactorChallengeDuration: 5 * time.Minute,
+		TransferStepUpThreshold:    1000,
+		AppBaseURL:                 "http://localhost:8080",
+		EmailVerificationDuration:  time.Hour,
+		PasswordResetDuration:      time.Hour,
+		PasswordMinLength:          6,
+		OAuthCodeDuration:          5 * time.Minute,
 	}
 }
 
@@ -42,6 +66,66 @@ func getPasetoManager(cfg *config.Config) (tokens.Manager, error) {
 	return tokens.NewPasetoManager(cfg.TokenPrivateKey)
 }
 
+func getRateLimitStorage() ratelimit.Storage {
+	return ratelimit.NewMemoryStorage()
+}
+
+// getFileMailer keeps sent emails in a per-test outbox, they can be read back
+// with outboxMessages.
+func getFileMailer(t *testing.T) func() (mail.Mailer, error) {
+	return func() (mail.Mailer, error) {
+		return mail.NewFileMailer(t.TempDir(), "test@simple-bank.local")
+	}
+}
+
+// testBreachedPassword is the only entry of the breached list in tests.
+const testBreachedPassword = "password123"
+
+func getPasswordPolicy(cfg *config.Config) security.PasswordPolicy {
+	return security.PasswordPolicy{
+		MinLength: cfg.PasswordMinLength,
+		Breached: security.BreachedPasswords{
+			sha1.Sum([]byte(testBreachedPassword)): {},
+		},
+	}
+}
+
+func outboxMessages(t *testing.T, mailer mail.Mailer) []mail.Message {
+	fileMailer, ok := mailer.(*mail.FileMailer)
+	require.True(t, ok)
+
+	messages, err := fileMailer.Messages()
+	require.NoError(t, err)
+	return messages
+}
+
+// testAuditRecorder keeps audit events in memory, they can be read back with
+// auditEvents.
+type testAuditRecorder struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (r *testAuditRecorder) Record(_ context.Context, event audit.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func getAuditRecorder() audit.Recorder {
+	return &testAuditRecorder{}
+}
+
+func auditEvents(t *testing.T, recorder audit.Recorder) []audit.Event {
+	testRecorder, ok := recorder.(*testAuditRecorder)
+	require.True(t, ok)
+
+	testRecorder.mu.Lock()
+	defer testRecorder.mu.Unlock()
+	return append([]audit.Event(nil), testRecorder.events...)
+}
+
 func TestMain(m *testing.M) {
 	gin.SetMode(gin.TestMode)
 	os.Exit(m.Run())
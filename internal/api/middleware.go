@@ -1,52 +1,180 @@
 package api
 
 import (
-	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
-	"net/http"
+	"github.com/google/uuid"
+	"log/slog"
+	"math"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	"simple-bank/internal/ratelimit"
+	"simple-bank/internal/service"
 	tokens2 "simple-bank/internal/tokens"
+	"strconv"
 	"strings"
 )
 
 const (
-	authorizationHeader     = "Authorization"
-	authorizationTypeBearer = "bearer"
-	authorizationPayloadKey = "payload"
+	authorizationHeader      = "Authorization"
+	authorizationTypeBearer  = service.AuthorizationTypeBearer
+	authorizationTypeAPIKey  = service.AuthorizationTypeAPIKey
+	authorizationPayloadKey  = "payload"
+	authorizationUserKey     = "user"
+	retryAfterHeader         = "Retry-After"
+	rateLimitRemainingHeader = "X-RateLimit-Remaining"
+	requestIDHeader          = "X-Request-ID"
+	requestIDKey             = "request_id"
+	maxRequestIDLength       = 64
 )
 
 func getPayloadFromGinCtx(c *gin.Context) *tokens2.Payload {
 	return c.MustGet(authorizationPayloadKey).(*tokens2.Payload)
 }
 
-func authMiddleware(tokensManager tokens2.Manager) gin.HandlerFunc {
+// getUserFromGinCtx returns the user loaded by authMiddleware, handlers
+// should prefer it over querying the store again.
+func getUserFromGinCtx(c *gin.Context) db.User {
+	return c.MustGet(authorizationUserKey).(db.User)
+}
+
+// authMiddleware accepts either a bearer access token or an API key. Both
+// end up as a tokens.Payload and the user it belongs to in the context.
+func authMiddleware(bank *service.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authorizationToken := c.Request.Header.Get(authorizationHeader)
 		if authorizationToken == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errors.New("authorization header is missing")))
+			abortWithError(c, errUnauthorized.withDetail("authorization header is missing"))
 			return
 		}
 
 		fields := strings.Fields(authorizationToken)
 		if len(fields) < 2 {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errors.New("authorization header is invalid")))
+			abortWithError(c, errUnauthorized.withDetail("authorization header is invalid"))
 			return
 		}
 
-		authorizationType := strings.ToLower(fields[0])
-		if authorizationType != authorizationTypeBearer {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(fmt.Errorf("unsupported authorization type: %s", authorizationType)))
+		payload, user, err := bank.Authenticate(c, fields[0], fields[1])
+		if err != nil {
+			abortWithError(c, err)
 			return
 		}
 
-		accessToken := fields[1]
-		payload, err := tokensManager.VerifyToken(accessToken)
+		c.Set(authorizationPayloadKey, payload)
+		c.Set(authorizationUserKey, user)
+		c.Next()
+	}
+}
+
+// requireScope lets through callers whose payload grants scope. It must be
+// registered after authMiddleware.
+func requireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !getPayloadFromGinCtx(c).HasScope(scope) {
+			abortWithError(c, errInsufficientScope.withDetail("%s scope is required", scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireSession keeps the routes that manage the user itself, including
+// its credentials, out of reach of API keys.
+func requireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if getPayloadFromGinCtx(c).Audience != accessTokenAudience {
+			abortWithError(c, errInsufficientScope.withDetail("only available with an access token"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireAdmin lets through administrators, everybody else is turned away
+// and the attempt ends up in the audit log. It must be registered after
+// authMiddleware.
+func requireAdmin(recorder audit.Recorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if getUserFromGinCtx(c).Role != db.UserRoleAdmin {
+			recordAudit(c, recorder, audit.Event{
+				Action:     auditActionAdminAccess,
+				TargetType: auditTargetRoute,
+				TargetID:   c.Request.Method + " " + c.FullPath(),
+				Outcome:    audit.OutcomeDenied,
+			})
+			abortWithError(c, errForbidden.withDetail("administrator role is required"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// requestIDMiddleware tags every request with an ID that is echoed in the
+// response and stored with audit events. An ID set by a proxy in front of the
+// service is kept when it looks sane.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(requestIDKey, requestID)
+		c.Header(requestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// rateLimitMiddleware throttles requests of a route group. Authenticated
+// callers are keyed by their subject, everybody else by the client IP, so it
+// must be registered after authMiddleware on protected routes. The limit is
+// read on every request, it follows configuration reloads.
+func rateLimitMiddleware(storage ratelimit.Storage, group string, currentLimit func() ratelimit.Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := currentLimit()
+		if limit.IsUnlimited() {
+			c.Next()
+			return
+		}
+
+		result, err := storage.Take(c, rateLimitKey(c, group), limit)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(fmt.Errorf("invalid token: %s", err.Error())))
+			// an unavailable storage must not take the whole API down
+			slog.Error("rate limit storage failed", "err", err)
+			c.Next()
+			return
+		}
+
+		c.Header(rateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			c.Header(retryAfterHeader, strconv.Itoa(max(retryAfter, 1)))
+			abortWithError(c, errRateLimited)
 			return
 		}
 
-		c.Set(authorizationPayloadKey, payload)
 		c.Next()
 	}
 }
+
+func rateLimitKey(c *gin.Context, group string) string {
+	if value, exists := c.Get(authorizationPayloadKey); exists {
+		if payload, ok := value.(*tokens2.Payload); ok {
+			return group + ":user:" + payload.Subject
+		}
+	}
+	return group + ":ip:" + c.ClientIP()
+}
@@ -1,11 +1,17 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 	"net/http"
 	"net/http/httptest"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/ratelimit"
 	tokens2 "simple-bank/internal/tokens"
 	"testing"
 	"time"
@@ -25,10 +31,30 @@ func addAuthorization(
 	request.Header.Add(authorizationHeader, authorizationToken)
 }
 
+// stubAuthUsers lets authMiddleware load the given users, any other subject
+// resolves to a bare user of that name.
+func stubAuthUsers(store *mockdb.MockStore, users ...db.User) {
+	for _, user := range users {
+		store.EXPECT().
+			GetUser(gomock.Any(), gomock.Eq(user.Username)).
+			AnyTimes().
+			Return(user, nil)
+	}
+	store.EXPECT().
+		GetUser(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		DoAndReturn(func(_ context.Context, username string) (db.User, error) {
+			return db.User{Username: username}, nil
+		})
+}
+
 func TestAuthMiddleware(t *testing.T) {
+	user := db.User{Username: "user"}
+
 	testCases := []struct {
 		name          string
 		setupAuth     func(t *testing.T, request *http.Request, tokenManager tokens2.Manager)
+		buildStubs    func(store *mockdb.MockStore)
 		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
 		{
@@ -115,17 +141,71 @@ func TestAuthMiddleware(t *testing.T) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
 		},
+		{
+			name: "password_changed_after_issue",
+			setupAuth: func(t *testing.T, request *http.Request, tokenManager tokens2.Manager) {
+				addAuthorization(t, request, tokenManager, authorizationTypeBearer, tokens2.PayloadCreationParams{
+					Subject:   "user",
+					Audience:  "test",
+					Issuer:    "test",
+					NotBefore: time.Now(),
+					Duration:  time.Minute,
+				})
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				changed := user
+				changed.PasswordChangedAt = time.Now().Add(time.Second)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(changed, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTokenRevoked)
+			},
+		},
+		{
+			name: "user_not_found",
+			setupAuth: func(t *testing.T, request *http.Request, tokenManager tokens2.Manager) {
+				addAuthorization(t, request, tokenManager, authorizationTypeBearer, tokens2.PayloadCreationParams{
+					Subject:   "user",
+					Audience:  "test",
+					Issuer:    "test",
+					NotBefore: time.Now(),
+					Duration:  time.Minute,
+				})
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.User{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTokenInvalid)
+			},
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			testContainer := newTestContainer(t, nil)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			if tc.buildStubs != nil {
+				tc.buildStubs(store)
+			} else {
+				stubAuthUsers(store, user)
+			}
+
+			testContainer := newTestContainer(t, store)
 
 			require.NoError(t, testContainer.Invoke(func(server *Server) {
 				authPath := "/auth"
 				server.engine.GET(
 					authPath,
-					authMiddleware(server.tokensManager),
+					authMiddleware(server.bank),
 					func(c *gin.Context) {
 						c.JSON(http.StatusOK, gin.H{})
 					},
@@ -142,3 +222,97 @@ func TestAuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimitMiddleware(t *testing.T) {
+	limit, err := ratelimit.ParseLimit("2/1m")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		setupRequest  func(t *testing.T, i int, request *http.Request, tokenManager tokens2.Manager)
+		checkResponse func(t *testing.T, recorders []*httptest.ResponseRecorder)
+	}{
+		{
+			name: "same_ip",
+			setupRequest: func(t *testing.T, i int, request *http.Request, tokenManager tokens2.Manager) {
+				request.RemoteAddr = "10.0.0.1:1234"
+			},
+			checkResponse: func(t *testing.T, recorders []*httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorders[0].Code)
+				require.Equal(t, "1", recorders[0].Header().Get(rateLimitRemainingHeader))
+				require.Equal(t, http.StatusOK, recorders[1].Code)
+				require.Equal(t, http.StatusTooManyRequests, recorders[2].Code)
+				require.Equal(t, "30", recorders[2].Header().Get(retryAfterHeader))
+			},
+		},
+		{
+			name: "different_ips",
+			setupRequest: func(t *testing.T, i int, request *http.Request, tokenManager tokens2.Manager) {
+				request.RemoteAddr = fmt.Sprintf("10.0.0.%d:1234", i+1)
+			},
+			checkResponse: func(t *testing.T, recorders []*httptest.ResponseRecorder) {
+				for _, recorder := range recorders {
+					require.Equal(t, http.StatusOK, recorder.Code)
+				}
+			},
+		},
+		{
+			name: "same_user_different_ips",
+			setupRequest: func(t *testing.T, i int, request *http.Request, tokenManager tokens2.Manager) {
+				request.RemoteAddr = fmt.Sprintf("10.0.0.%d:1234", i+1)
+				addAuthorization(t, request, tokenManager, authorizationTypeBearer, tokens2.PayloadCreationParams{
+					Subject:   "user",
+					Audience:  "test",
+					Issuer:    "test",
+					NotBefore: time.Now(),
+					Duration:  time.Minute,
+				})
+			},
+			checkResponse: func(t *testing.T, recorders []*httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorders[0].Code)
+				require.Equal(t, http.StatusOK, recorders[1].Code)
+				require.Equal(t, http.StatusTooManyRequests, recorders[2].Code)
+				require.NotEmpty(t, recorders[2].Header().Get(retryAfterHeader))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store)
+
+			testContainer := newTestContainer(t, store)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				limitedPath := "/limited"
+				server.engine.GET(
+					limitedPath,
+					func(c *gin.Context) {
+						if c.GetHeader(authorizationHeader) != "" {
+							authMiddleware(server.bank)(c)
+						}
+					},
+					rateLimitMiddleware(ratelimit.NewMemoryStorage(), "test", func() ratelimit.Limit { return limit }),
+					func(c *gin.Context) {
+						c.JSON(http.StatusOK, gin.H{})
+					},
+				)
+
+				recorders := make([]*httptest.ResponseRecorder, 3)
+				for i := range recorders {
+					recorders[i] = httptest.NewRecorder()
+					request, err := http.NewRequest(http.MethodGet, limitedPath, nil)
+					require.NoError(t, err)
+
+					tc.setupRequest(t, i, request, server.tokensManager)
+					server.engine.ServeHTTP(recorders[i], request)
+				}
+				tc.checkResponse(t, recorders)
+			}))
+		})
+	}
+}
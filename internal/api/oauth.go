@@ -0,0 +1,594 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"net/http"
+	"net/url"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	"simple-bank/internal/security"
+	"simple-bank/internal/service"
+	"simple-bank/internal/tokens"
+	"slices"
+	"time"
+)
+
+const (
+	oauthAudience              = tokens.AudienceOAuth
+	oauthTokenType             = "Bearer"
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeClientCredentials = "client_credentials"
+)
+
+// oauthError is an error of the token and introspection endpoints. Those are
+// called by OAuth client libraries, so they answer in the RFC 6749 format
+// instead of problem details.
+type oauthError struct {
+	Status      int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *oauthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+func (e *oauthError) withDescription(format string, args ...any) *oauthError {
+	cp := *e
+	cp.Description = fmt.Sprintf(format, args...)
+	return &cp
+}
+
+var (
+	errOAuthInvalidRequest       = &oauthError{Status: http.StatusBadRequest, Code: "invalid_request"}
+	errOAuthInvalidClient        = &oauthError{Status: http.StatusUnauthorized, Code: "invalid_client"}
+	errOAuthInvalidGrant         = &oauthError{Status: http.StatusBadRequest, Code: "invalid_grant"}
+	errOAuthUnauthorizedClient   = &oauthError{Status: http.StatusBadRequest, Code: "unauthorized_client"}
+	errOAuthUnsupportedGrantType = &oauthError{Status: http.StatusBadRequest, Code: "unsupported_grant_type"}
+	errOAuthInvalidScope         = &oauthError{Status: http.StatusBadRequest, Code: "invalid_scope"}
+)
+
+// abortWithOAuthError writes an oauthError, anything else is handed over to
+// abortWithError.
+func abortWithOAuthError(c *gin.Context, err error) {
+	var oauthErr *oauthError
+	if !errors.As(err, &oauthErr) {
+		abortWithError(c, err)
+		return
+	}
+
+	if oauthErr.Status == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.AbortWithStatusJSON(oauthErr.Status, oauthErr)
+}
+
+type createOAuthClientRequest struct {
+	Name         string   `json:"name" binding:"required,max=64"`
+	RedirectURIs []string `json:"redirect_uris" binding:"omitempty,dive,url"`
+	Scopes       []string `json:"scopes" binding:"required,min=1,dive,scope"`
+	// Confidential clients get a secret and may use the client credentials
+	// grant, public ones (mobile and browser apps) rely on PKCE alone.
+	Confidential bool `json:"confidential"`
+}
+
+type oauthClientResponse struct {
+	ClientID     string    `json:"client_id"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func newOAuthClientResponse(client db.OauthClient) oauthClientResponse {
+	return oauthClientResponse{
+		ClientID:     client.ID,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		Confidential: client.HashedSecret != "",
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+type createOAuthClientResponse struct {
+	oauthClientResponse
+	// ClientSecret is only ever returned here, the server keeps its hash.
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+func (s *Server) createOAuthClient(c *gin.Context) {
+	var request createOAuthClientRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	if !request.Confidential && len(request.RedirectURIs) == 0 {
+		abortWithError(c, errValidationFailed.withFields([]fieldError{{
+			Field:  "redirect_uris",
+			Rule:   "required",
+			Reason: "is required for public clients",
+		}}))
+		return
+	}
+
+	var secret, hashedSecret string
+	if request.Confidential {
+		var err error
+		secret, err = security.GenerateOpaqueToken()
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		hashedSecret = security.HashOpaqueToken(secret)
+	}
+
+	redirectURIs := request.RedirectURIs
+	if redirectURIs == nil {
+		redirectURIs = []string{}
+	}
+
+	client, err := s.store.CreateOAuthClient(c, db.CreateOAuthClientParams{
+		ID:           uuid.NewString(),
+		Owner:        getPayloadFromGinCtx(c).Subject,
+		Name:         request.Name,
+		HashedSecret: hashedSecret,
+		RedirectUris: redirectURIs,
+		Scopes:       request.Scopes,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionOAuthClientCreate,
+		TargetType: auditTargetOAuthClient,
+		TargetID:   client.ID,
+		Outcome:    audit.OutcomeSuccess,
+		Diff: map[string]any{
+			"name":          client.Name,
+			"redirect_uris": client.RedirectUris,
+			"scopes":        client.Scopes,
+			"confidential":  request.Confidential,
+		},
+	})
+
+	c.JSON(http.StatusCreated, createOAuthClientResponse{
+		oauthClientResponse: newOAuthClientResponse(client),
+		ClientSecret:        secret,
+	})
+}
+
+func (s *Server) listOAuthClients(c *gin.Context) {
+	clients, err := s.store.ListOAuthClients(c, getPayloadFromGinCtx(c).Subject)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	response := make([]oauthClientResponse, len(clients))
+	for i, client := range clients {
+		response[i] = newOAuthClientResponse(client)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+type oauthAuthorizeRequest struct {
+	ResponseType        string `json:"response_type" form:"response_type" binding:"required,eq=code"`
+	ClientID            string `json:"client_id" form:"client_id" binding:"required"`
+	RedirectURI         string `json:"redirect_uri" form:"redirect_uri" binding:"required"`
+	Scope               string `json:"scope" form:"scope"`
+	State               string `json:"state" form:"state" binding:"max=512"`
+	CodeChallenge       string `json:"code_challenge" form:"code_challenge" binding:"required,len=43"`
+	CodeChallengeMethod string `json:"code_challenge_method" form:"code_challenge_method" binding:"required,eq=S256"`
+}
+
+type oauthConsentResponse struct {
+	ClientID    string   `json:"client_id"`
+	ClientName  string   `json:"client_name"`
+	RedirectURI string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes"`
+}
+
+// getOAuthConsent is the first half of the authorization endpoint. The
+// frontend calls it with the query the client redirected the user with and
+// shows the returned details on the consent screen.
+func (s *Server) getOAuthConsent(c *gin.Context) {
+	var request oauthAuthorizeRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	client, scopes, err := s.resolveAuthorization(c, request)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, oauthConsentResponse{
+		ClientID:    client.ID,
+		ClientName:  client.Name,
+		RedirectURI: request.RedirectURI,
+		Scopes:      scopes,
+	})
+}
+
+type oauthConsentRequest struct {
+	oauthAuthorizeRequest
+	Approved bool `json:"approved"`
+}
+
+type oauthRedirectResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+// approveOAuthConsent records the decision of the user. The answer is the
+// URL to send the browser to, the API itself never redirects since it is
+// called with a bearer token rather than a browser session.
+func (s *Server) approveOAuthConsent(c *gin.Context) {
+	var request oauthConsentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	client, scopes, err := s.resolveAuthorization(c, request.oauthAuthorizeRequest)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	params := url.Values{}
+	if request.State != "" {
+		params.Set("state", request.State)
+	}
+
+	if !request.Approved {
+		s.recordConsent(c, client, scopes, audit.OutcomeDenied)
+		params.Set("error", "access_denied")
+		c.JSON(http.StatusOK, oauthRedirectResponse{RedirectTo: redirectWithParams(request.RedirectURI, params)})
+		return
+	}
+
+	code, err := security.GenerateOpaqueToken()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	_, err = s.store.CreateOAuthAuthorizationCode(c, db.CreateOAuthAuthorizationCodeParams{
+		HashedCode:    security.HashOpaqueToken(code),
+		ClientID:      client.ID,
+		Username:      getPayloadFromGinCtx(c).Subject,
+		RedirectUri:   request.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: request.CodeChallenge,
+		ExpiresAt:     time.Now().Add(s.config().OAuthCodeDuration),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	s.recordConsent(c, client, scopes, audit.OutcomeSuccess)
+
+	params.Set("code", code)
+	c.JSON(http.StatusOK, oauthRedirectResponse{RedirectTo: redirectWithParams(request.RedirectURI, params)})
+}
+
+func (s *Server) recordConsent(c *gin.Context, client db.OauthClient, scopes []string, outcome audit.Outcome) {
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionOAuthConsent,
+		TargetType: auditTargetOAuthClient,
+		TargetID:   client.ID,
+		Outcome:    outcome,
+		Diff:       map[string]any{"scopes": scopes},
+	})
+}
+
+// resolveAuthorization validates an authorization request against the
+// registered client and returns the scopes it asks for. Errors are never
+// sent to the redirect URI, since it isn't trusted until checked here.
+func (s *Server) resolveAuthorization(c *gin.Context, request oauthAuthorizeRequest) (db.OauthClient, []string, error) {
+	client, err := s.store.GetOAuthClient(c, request.ClientID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return client, nil, errInvalidRequest.withDetail("client_id is unknown")
+		}
+		return client, nil, err
+	}
+
+	if !slices.Contains(client.RedirectUris, request.RedirectURI) {
+		return client, nil, errInvalidRequest.withDetail("redirect_uri is not registered for the client")
+	}
+
+	scopes, ok := requestedScopes(client, request.Scope)
+	if !ok {
+		return client, nil, errInvalidRequest.withDetail("scope exceeds what the client is allowed")
+	}
+
+	return client, scopes, nil
+}
+
+// requestedScopes checks a scope parameter against the scopes of client. An
+// empty parameter asks for all of them.
+func requestedScopes(client db.OauthClient, scope string) ([]string, bool) {
+	scopes := parseScopes(scope)
+	if len(scopes) == 0 {
+		return client.Scopes, true
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return nil, false
+		}
+	}
+	return scopes, true
+}
+
+func redirectWithParams(redirectURI string, params url.Values) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		// registered URIs are validated on client registration
+		return redirectURI
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+type oauthTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+func (s *Server) oauthToken(c *gin.Context) {
+	var request oauthTokenRequest
+	if err := c.ShouldBindWith(&request, binding.Form); err != nil {
+		abortWithOAuthError(c, errOAuthInvalidRequest)
+		return
+	}
+
+	client, err := s.authenticateOAuthClient(c, request.ClientID, request.ClientSecret)
+	if err != nil {
+		abortWithOAuthError(c, err)
+		return
+	}
+
+	var subject string
+	var scopes []string
+	switch request.GrantType {
+	case grantTypeAuthorizationCode:
+		subject, scopes, err = s.redeemAuthorizationCode(c, client, request)
+	case grantTypeClientCredentials:
+		subject, scopes, err = clientCredentialsGrant(client, request)
+	case "":
+		err = errOAuthInvalidRequest.withDescription("grant_type is required")
+	default:
+		err = errOAuthUnsupportedGrantType
+	}
+	if err != nil {
+		abortWithOAuthError(c, err)
+		return
+	}
+
+	duration := s.config().AccessTokenDuration
+	accessToken, err := s.tokensManager.CreateToken(tokens.PayloadCreationParams{
+		Subject:   subject,
+		Audience:  oauthAudience,
+		Issuer:    tokenIssuer,
+		NotBefore: time.Now(),
+		Duration:  duration,
+		Scopes:    scopes,
+		ClientID:  client.ID,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, oauthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   oauthTokenType,
+		ExpiresIn:   int64(duration.Seconds()),
+		Scope:       formatScopes(scopes),
+	})
+}
+
+// authenticateOAuthClient accepts the credentials either with HTTP Basic or
+// in the form. Public clients only have to name themselves.
+func (s *Server) authenticateOAuthClient(c *gin.Context, clientID, clientSecret string) (db.OauthClient, error) {
+	if username, password, ok := c.Request.BasicAuth(); ok {
+		// RFC 6749 form-encodes the credentials before the base64 step
+		var err error
+		if clientID, err = url.QueryUnescape(username); err != nil {
+			return db.OauthClient{}, errOAuthInvalidClient
+		}
+		if clientSecret, err = url.QueryUnescape(password); err != nil {
+			return db.OauthClient{}, errOAuthInvalidClient
+		}
+	}
+
+	if clientID == "" {
+		return db.OauthClient{}, errOAuthInvalidClient.withDescription("client authentication is required")
+	}
+
+	client, err := s.store.GetOAuthClient(c, clientID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return client, errOAuthInvalidClient
+		}
+		return client, err
+	}
+
+	if client.HashedSecret != "" && !security.CompareOpaqueToken(clientSecret, client.HashedSecret) {
+		return client, errOAuthInvalidClient
+	}
+
+	return client, nil
+}
+
+func (s *Server) redeemAuthorizationCode(c *gin.Context, client db.OauthClient, request oauthTokenRequest) (string, []string, error) {
+	if request.Code == "" || request.CodeVerifier == "" || request.RedirectURI == "" {
+		return "", nil, errOAuthInvalidRequest.withDescription("code, code_verifier and redirect_uri are required")
+	}
+
+	code, err := s.store.GetOAuthAuthorizationCode(c, security.HashOpaqueToken(request.Code))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", nil, errOAuthInvalidGrant
+		}
+		return "", nil, err
+	}
+
+	switch {
+	case code.ClientID != client.ID,
+		code.RedirectUri != request.RedirectURI,
+		!code.UsedAt.IsZero(),
+		time.Now().After(code.ExpiresAt):
+		return "", nil, errOAuthInvalidGrant
+	case !security.VerifyPKCE(request.CodeVerifier, code.CodeChallenge):
+		return "", nil, errOAuthInvalidGrant.withDescription("code_verifier does not match the challenge")
+	}
+
+	used, err := s.store.UseOAuthAuthorizationCode(c, code.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	if used == 0 {
+		return "", nil, errOAuthInvalidGrant
+	}
+
+	return code.Username, code.Scopes, nil
+}
+
+// clientCredentialsGrant lets a confidential client act as the user that
+// registered it, within the scopes of the client.
+func clientCredentialsGrant(client db.OauthClient, request oauthTokenRequest) (string, []string, error) {
+	if client.HashedSecret == "" {
+		return "", nil, errOAuthUnauthorizedClient.withDescription("public clients can't use the client credentials grant")
+	}
+
+	scopes, ok := requestedScopes(client, request.Scope)
+	if !ok {
+		return "", nil, errOAuthInvalidScope
+	}
+
+	return client.Owner, scopes, nil
+}
+
+type oauthIntrospectRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+	ClientID      string `form:"client_id"`
+	ClientSecret  string `form:"client_secret"`
+}
+
+type oauthIntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Nbf       int64  `json:"nbf,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Aud       string `json:"aud,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
+
+// oauthIntrospect implements RFC 7662. A client only learns about tokens
+// issued to itself, anything else is reported as inactive.
+func (s *Server) oauthIntrospect(c *gin.Context) {
+	var request oauthIntrospectRequest
+	if err := c.ShouldBindWith(&request, binding.Form); err != nil {
+		abortWithOAuthError(c, errOAuthInvalidRequest)
+		return
+	}
+
+	client, err := s.authenticateOAuthClient(c, request.ClientID, request.ClientSecret)
+	if err != nil {
+		abortWithOAuthError(c, err)
+		return
+	}
+	if client.HashedSecret == "" {
+		abortWithOAuthError(c, errOAuthUnauthorizedClient.withDescription("public clients can't introspect tokens"))
+		return
+	}
+
+	if request.Token == "" {
+		abortWithOAuthError(c, errOAuthInvalidRequest.withDescription("token is required"))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+
+	payload, err := s.tokensManager.VerifyToken(request.Token)
+	if err != nil || payload.Audience != oauthAudience || payload.ClientID != client.ID {
+		c.JSON(http.StatusOK, oauthIntrospectionResponse{Active: false})
+		return
+	}
+
+	user, err := s.store.GetUser(c, payload.Subject)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			c.JSON(http.StatusOK, oauthIntrospectionResponse{Active: false})
+			return
+		}
+		abortWithError(c, err)
+		return
+	}
+	if service.TokenRevoked(payload, user) {
+		c.JSON(http.StatusOK, oauthIntrospectionResponse{Active: false})
+		return
+	}
+
+	c.JSON(http.StatusOK, oauthIntrospectionResponse{
+		Active:    true,
+		Scope:     formatScopes(payload.Scopes),
+		ClientID:  payload.ClientID,
+		Username:  payload.Subject,
+		TokenType: oauthTokenType,
+		Exp:       payload.ExpiredAt.Unix(),
+		Iat:       payload.IssuedAt.Unix(),
+		Nbf:       payload.NotBefore.Unix(),
+		Sub:       payload.Subject,
+		Aud:       payload.Audience,
+		Iss:       payload.Issuer,
+		Jti:       payload.ID.String(),
+	})
+}
@@ -0,0 +1,1077 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/random"
+	"simple-bank/internal/security"
+	tokens2 "simple-bank/internal/tokens"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRedirectURI = "https://client.example.com/callback"
+
+func TestServer_createOAuthClient(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		body          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "confidential",
+			body: `{"name": "reporting", "scopes": ["accounts:read"], "confidential": true}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateOAuthClient(gomock.Any(), gomock.Cond[db.CreateOAuthClientParams](func(x db.CreateOAuthClientParams) bool {
+						return x.Owner == user.Username && x.HashedSecret != "" && len(x.RedirectUris) == 0
+					})).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateOAuthClientParams) (db.OauthClient, error) {
+						return db.OauthClient{
+							ID:           arg.ID,
+							Owner:        arg.Owner,
+							Name:         arg.Name,
+							HashedSecret: arg.HashedSecret,
+							RedirectUris: arg.RedirectUris,
+							Scopes:       arg.Scopes,
+							CreatedAt:    time.Now(),
+						}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				var response createOAuthClientResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.NotEmpty(t, response.ClientID)
+				require.NotEmpty(t, response.ClientSecret)
+				require.True(t, response.Confidential)
+				require.Equal(t, []string{scopeAccountsRead}, response.Scopes)
+			},
+		},
+		{
+			name: "public",
+			body: fmt.Sprintf(`{"name": "mobile", "redirect_uris": [%q], "scopes": ["accounts:read"]}`, testRedirectURI),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateOAuthClient(gomock.Any(), gomock.Cond[db.CreateOAuthClientParams](func(x db.CreateOAuthClientParams) bool {
+						return x.HashedSecret == ""
+					})).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateOAuthClientParams) (db.OauthClient, error) {
+						return db.OauthClient{ID: arg.ID, RedirectUris: arg.RedirectUris, Scopes: arg.Scopes}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				var response createOAuthClientResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Empty(t, response.ClientSecret)
+				require.False(t, response.Confidential)
+			},
+		},
+		{
+			name: "public_without_redirect_uris",
+			body: `{"name": "mobile", "scopes": ["accounts:read"]}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateOAuthClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+		{
+			name: "invalid_redirect_uri",
+			body: `{"name": "mobile", "redirect_uris": ["not a url"], "scopes": ["accounts:read"]}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateOAuthClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+		{
+			name: "unknown_scope",
+			body: `{"name": "reporting", "scopes": ["admin"], "confidential": true}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateOAuthClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, user)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/oauth/clients", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				addAuthorization(t, request, server.tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestServer_getOAuthConsent(t *testing.T) {
+	user, _ := randomUser(t)
+	client, _ := randomOAuthClient(t, user.Username, false)
+	verifier := random.String(64)
+
+	query := func(change func(values url.Values)) string {
+		values := url.Values{
+			"response_type":         {"code"},
+			"client_id":             {client.ID},
+			"redirect_uri":          {testRedirectURI},
+			"scope":                 {scopeAccountsRead},
+			"state":                 {"xyz"},
+			"code_challenge":        {security.PKCEChallenge(verifier)},
+			"code_challenge_method": {"S256"},
+		}
+		if change != nil {
+			change(values)
+		}
+		return values.Encode()
+	}
+
+	testCases := []struct {
+		name          string
+		query         string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "success",
+			query: query(nil),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var response oauthConsentResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, client.Name, response.ClientName)
+				require.Equal(t, []string{scopeAccountsRead}, response.Scopes)
+			},
+		},
+		{
+			name: "all_client_scopes_by_default",
+			query: query(func(values url.Values) {
+				values.Del("scope")
+			}),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var response oauthConsentResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, client.Scopes, response.Scopes)
+			},
+		},
+		{
+			name: "unknown_client",
+			query: query(func(values url.Values) {
+				values.Set("client_id", uuid.NewString())
+			}),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.OauthClient{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errInvalidRequest)
+			},
+		},
+		{
+			name: "unregistered_redirect_uri",
+			query: query(func(values url.Values) {
+				values.Set("redirect_uri", "https://attacker.example.com/callback")
+			}),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errInvalidRequest)
+			},
+		},
+		{
+			name: "scope_not_allowed",
+			query: query(func(values url.Values) {
+				values.Set("scope", scopeAccountsRead+" "+scopeTransfersWrite)
+			}),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errInvalidRequest)
+			},
+		},
+		{
+			name: "plain_challenge_method",
+			query: query(func(values url.Values) {
+				values.Set("code_challenge_method", "plain")
+			}),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+		{
+			name: "missing_challenge",
+			query: query(func(values url.Values) {
+				values.Del("code_challenge")
+			}),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, user)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/oauth/authorize?"+tc.query, nil)
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				addAuthorization(t, request, server.tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestServer_approveOAuthConsent(t *testing.T) {
+	user, _ := randomUser(t)
+	client, _ := randomOAuthClient(t, user.Username, false)
+	challenge := security.PKCEChallenge(random.String(64))
+
+	body := func(approved bool) string {
+		return fmt.Sprintf(`{
+			"response_type": "code",
+			"client_id": %q,
+			"redirect_uri": %q,
+			"scope": "accounts:read",
+			"state": "xyz",
+			"code_challenge": %q,
+			"code_challenge_method": "S256",
+			"approved": %t
+		}`, client.ID, testRedirectURI, challenge, approved)
+	}
+
+	testCases := []struct {
+		name          string
+		body          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "approved",
+			body: body(true),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().
+					CreateOAuthAuthorizationCode(gomock.Any(), gomock.Cond[db.CreateOAuthAuthorizationCodeParams](func(x db.CreateOAuthAuthorizationCodeParams) bool {
+						return x.ClientID == client.ID &&
+							x.Username == user.Username &&
+							x.RedirectUri == testRedirectURI &&
+							x.CodeChallenge == challenge &&
+							len(x.Scopes) == 1 && x.Scopes[0] == scopeAccountsRead &&
+							x.ExpiresAt.After(time.Now())
+					})).
+					Times(1).
+					Return(db.OauthAuthorizationCode{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				redirect := requireOAuthRedirect(t, recorder)
+				require.Equal(t, "xyz", redirect.Query().Get("state"))
+				require.NotEmpty(t, redirect.Query().Get("code"))
+				require.Empty(t, redirect.Query().Get("error"))
+			},
+		},
+		{
+			name: "denied",
+			body: body(false),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().
+					CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				redirect := requireOAuthRedirect(t, recorder)
+				require.Equal(t, "access_denied", redirect.Query().Get("error"))
+				require.Equal(t, "xyz", redirect.Query().Get("state"))
+				require.Empty(t, redirect.Query().Get("code"))
+			},
+		},
+		{
+			name: "internal_error",
+			body: body(true),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().
+					CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.OauthAuthorizationCode{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errInternal)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, user)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/oauth/authorize", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				addAuthorization(t, request, server.tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestServer_oauthToken(t *testing.T) {
+	user, _ := randomUser(t)
+	publicClient, _ := randomOAuthClient(t, user.Username, false)
+	confidentialClient, secret := randomOAuthClient(t, user.Username, true)
+
+	verifier := random.String(64)
+	code, authorizationCode := randomOAuthAuthorizationCode(t, publicClient, user.Username, verifier)
+
+	authorizationCodeForm := func(change func(values url.Values)) url.Values {
+		values := url.Values{
+			"grant_type":    {grantTypeAuthorizationCode},
+			"client_id":     {publicClient.ID},
+			"code":          {code},
+			"redirect_uri":  {testRedirectURI},
+			"code_verifier": {verifier},
+		}
+		if change != nil {
+			change(values)
+		}
+		return values
+	}
+
+	testCases := []struct {
+		name          string
+		form          url.Values
+		basicAuth     [2]string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "authorization_code",
+			form: authorizationCodeForm(nil),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(publicClient.ID)).
+					Times(1).
+					Return(publicClient, nil)
+				store.EXPECT().
+					GetOAuthAuthorizationCode(gomock.Any(), gomock.Eq(security.HashOpaqueToken(code))).
+					Times(1).
+					Return(authorizationCode, nil)
+				store.EXPECT().
+					UseOAuthAuthorizationCode(gomock.Any(), gomock.Eq(authorizationCode.ID)).
+					Times(1).
+					Return(int64(1), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+				var response oauthTokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.NotEmpty(t, response.AccessToken)
+				require.Equal(t, oauthTokenType, response.TokenType)
+				require.Equal(t, scopeAccountsRead, response.Scope)
+				require.Equal(t, int64((15 * time.Minute).Seconds()), response.ExpiresIn)
+			},
+		},
+		{
+			name: "code_verifier_mismatch",
+			form: authorizationCodeForm(func(values url.Values) {
+				values.Set("code_verifier", random.String(64))
+			}),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(publicClient, nil)
+				store.EXPECT().
+					GetOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(authorizationCode, nil)
+				store.EXPECT().
+					UseOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthInvalidGrant)
+			},
+		},
+		{
+			name: "redirect_uri_mismatch",
+			form: authorizationCodeForm(func(values url.Values) {
+				values.Set("redirect_uri", "https://client.example.com/other")
+			}),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(publicClient, nil)
+				store.EXPECT().
+					GetOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(authorizationCode, nil)
+				store.EXPECT().
+					UseOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthInvalidGrant)
+			},
+		},
+		{
+			name: "code_of_other_client",
+			form: authorizationCodeForm(func(values url.Values) {
+				values.Set("client_id", confidentialClient.ID)
+				values.Set("client_secret", secret)
+			}),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(confidentialClient.ID)).
+					Times(1).
+					Return(confidentialClient, nil)
+				store.EXPECT().
+					GetOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(authorizationCode, nil)
+				store.EXPECT().
+					UseOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthInvalidGrant)
+			},
+		},
+		{
+			name: "code_already_used",
+			form: authorizationCodeForm(nil),
+			buildStubs: func(store *mockdb.MockStore) {
+				used := authorizationCode
+				used.UsedAt = time.Now()
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(publicClient, nil)
+				store.EXPECT().
+					GetOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(used, nil)
+				store.EXPECT().
+					UseOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthInvalidGrant)
+			},
+		},
+		{
+			name: "code_used_concurrently",
+			form: authorizationCodeForm(nil),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(publicClient, nil)
+				store.EXPECT().
+					GetOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(authorizationCode, nil)
+				store.EXPECT().
+					UseOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(0), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthInvalidGrant)
+			},
+		},
+		{
+			name: "code_expired",
+			form: authorizationCodeForm(nil),
+			buildStubs: func(store *mockdb.MockStore) {
+				expired := authorizationCode
+				expired.ExpiresAt = time.Now().Add(-time.Second)
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(publicClient, nil)
+				store.EXPECT().
+					GetOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(expired, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthInvalidGrant)
+			},
+		},
+		{
+			name: "unknown_code",
+			form: authorizationCodeForm(nil),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(publicClient, nil)
+				store.EXPECT().
+					GetOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.OauthAuthorizationCode{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthInvalidGrant)
+			},
+		},
+		{
+			name: "client_credentials_basic_auth",
+			form: url.Values{
+				"grant_type": {grantTypeClientCredentials},
+				"scope":      {scopeAccountsRead},
+			},
+			basicAuth: [2]string{confidentialClient.ID, secret},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(confidentialClient.ID)).
+					Times(1).
+					Return(confidentialClient, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var response oauthTokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, scopeAccountsRead, response.Scope)
+			},
+		},
+		{
+			name: "client_credentials_all_scopes",
+			form: url.Values{
+				"grant_type":    {grantTypeClientCredentials},
+				"client_id":     {confidentialClient.ID},
+				"client_secret": {secret},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(confidentialClient, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var response oauthTokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, formatScopes(confidentialClient.Scopes), response.Scope)
+			},
+		},
+		{
+			name: "client_credentials_scope_not_allowed",
+			form: url.Values{
+				"grant_type": {grantTypeClientCredentials},
+				"scope":      {scopeTransfersWrite},
+			},
+			basicAuth: [2]string{confidentialClient.ID, secret},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(confidentialClient, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthInvalidScope)
+			},
+		},
+		{
+			name: "client_credentials_public_client",
+			form: url.Values{
+				"grant_type": {grantTypeClientCredentials},
+				"client_id":  {publicClient.ID},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(publicClient, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthUnauthorizedClient)
+			},
+		},
+		{
+			name: "wrong_client_secret",
+			form: url.Values{
+				"grant_type": {grantTypeClientCredentials},
+			},
+			basicAuth: [2]string{confidentialClient.ID, "wrong"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(confidentialClient, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthInvalidClient)
+				require.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name: "unknown_client",
+			form: authorizationCodeForm(nil),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.OauthClient{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthInvalidClient)
+			},
+		},
+		{
+			name: "no_client",
+			form: url.Values{
+				"grant_type": {grantTypeClientCredentials},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthInvalidClient)
+			},
+		},
+		{
+			name: "unsupported_grant_type",
+			form: url.Values{
+				"grant_type": {"password"},
+				"client_id":  {publicClient.ID},
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(publicClient, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthUnsupportedGrantType)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request := newOAuthFormRequest(t, "/oauth/token", tc.form)
+			if tc.basicAuth[0] != "" {
+				request.SetBasicAuth(url.QueryEscape(tc.basicAuth[0]), url.QueryEscape(tc.basicAuth[1]))
+			}
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestServer_oauthIntrospect(t *testing.T) {
+	user, _ := randomUser(t)
+	client, secret := randomOAuthClient(t, user.Username, true)
+	publicClient, _ := randomOAuthClient(t, user.Username, false)
+
+	oauthTokenParams := func(clientID string) tokens2.PayloadCreationParams {
+		params := accessTokenParams(user.Username)
+		params.Audience = oauthAudience
+		params.Scopes = []string{scopeAccountsRead}
+		params.ClientID = clientID
+		return params
+	}
+
+	testCases := []struct {
+		name          string
+		tokenParams   tokens2.PayloadCreationParams
+		clientID      string
+		clientSecret  string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:         "active",
+			tokenParams:  oauthTokenParams(client.ID),
+			clientID:     client.ID,
+			clientSecret: secret,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				response := requireIntrospection(t, recorder)
+				require.True(t, response.Active)
+				require.Equal(t, scopeAccountsRead, response.Scope)
+				require.Equal(t, client.ID, response.ClientID)
+				require.Equal(t, user.Username, response.Username)
+				require.Equal(t, oauthAudience, response.Aud)
+			},
+		},
+		{
+			name:         "token_of_other_client",
+			tokenParams:  oauthTokenParams(publicClient.ID),
+			clientID:     client.ID,
+			clientSecret: secret,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.False(t, requireIntrospection(t, recorder).Active)
+			},
+		},
+		{
+			name:         "session_token",
+			tokenParams:  accessTokenParams(user.Username),
+			clientID:     client.ID,
+			clientSecret: secret,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.False(t, requireIntrospection(t, recorder).Active)
+			},
+		},
+		{
+			name:         "revoked_by_password_change",
+			tokenParams:  oauthTokenParams(client.ID),
+			clientID:     client.ID,
+			clientSecret: secret,
+			buildStubs: func(store *mockdb.MockStore) {
+				changed := user
+				changed.PasswordChangedAt = time.Now().Add(time.Minute)
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(changed, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.False(t, requireIntrospection(t, recorder).Active)
+			},
+		},
+		{
+			name:        "public_client",
+			tokenParams: oauthTokenParams(publicClient.ID),
+			clientID:    publicClient.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetOAuthClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(publicClient, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireOAuthErrorCode(t, recorder, errOAuthUnauthorizedClient)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				token, err := server.tokensManager.CreateToken(tc.tokenParams)
+				require.NoError(t, err)
+
+				request := newOAuthFormRequest(t, "/oauth/introspect", url.Values{
+					"token":         {token},
+					"client_id":     {tc.clientID},
+					"client_secret": {tc.clientSecret},
+				})
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestOAuthTokenScopes(t *testing.T) {
+	user, _ := randomUser(t)
+	client, _ := randomOAuthClient(t, user.Username, false)
+
+	testCases := []struct {
+		name          string
+		method        string
+		url           string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "granted_scope",
+			method: http.MethodGet,
+			url:    "/accounts?page_id=1&page_size=5",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListAccounts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.Account{}, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "session_only_route",
+			method: http.MethodGet,
+			url:    "/users/me",
+			buildStubs: func(store *mockdb.MockStore) {
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errInsufficientScope)
+			},
+		},
+		{
+			name:   "client_registration",
+			method: http.MethodGet,
+			url:    "/oauth/clients",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListOAuthClients(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errInsufficientScope)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, user)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(tc.method, tc.url, nil)
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				params := accessTokenParams(user.Username)
+				params.Audience = oauthAudience
+				params.Scopes = []string{scopeAccountsRead}
+				params.ClientID = client.ID
+				addAuthorization(t, request, server.tokensManager, authorizationTypeBearer, params)
+
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+// randomOAuthClient returns a client owned by owner that may read accounts,
+// along with its secret when it is confidential.
+func randomOAuthClient(t *testing.T, owner string, confidential bool) (db.OauthClient, string) {
+	client := db.OauthClient{
+		ID:           uuid.NewString(),
+		Owner:        owner,
+		Name:         random.String(8),
+		RedirectUris: []string{testRedirectURI},
+		Scopes:       []string{scopeAccountsRead, scopeAccountsWrite},
+		CreatedAt:    time.Now().Add(-time.Hour),
+	}
+	if !confidential {
+		return client, ""
+	}
+
+	secret, err := security.GenerateOpaqueToken()
+	require.NoError(t, err)
+	client.HashedSecret = security.HashOpaqueToken(secret)
+
+	return client, secret
+}
+
+func randomOAuthAuthorizationCode(t *testing.T, client db.OauthClient, username, verifier string) (string, db.OauthAuthorizationCode) {
+	code, err := security.GenerateOpaqueToken()
+	require.NoError(t, err)
+
+	return code, db.OauthAuthorizationCode{
+		ID:            random.Int64(1, 1000),
+		HashedCode:    security.HashOpaqueToken(code),
+		ClientID:      client.ID,
+		Username:      username,
+		RedirectUri:   testRedirectURI,
+		Scopes:        []string{scopeAccountsRead},
+		CodeChallenge: security.PKCEChallenge(verifier),
+		ExpiresAt:     time.Now().Add(time.Minute),
+		CreatedAt:     time.Now(),
+	}
+}
+
+func newOAuthFormRequest(t *testing.T, path string, form url.Values) *http.Request {
+	request, err := http.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return request
+}
+
+func requireOAuthErrorCode(t *testing.T, recorder *httptest.ResponseRecorder, expected *oauthError) {
+	var response oauthError
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.Equal(t, expected.Status, recorder.Code)
+	require.Equal(t, expected.Code, response.Code)
+}
+
+func requireOAuthRedirect(t *testing.T, recorder *httptest.ResponseRecorder) *url.URL {
+	var response oauthRedirectResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	require.True(t, strings.HasPrefix(response.RedirectTo, testRedirectURI+"?"))
+
+	redirect, err := url.Parse(response.RedirectTo)
+	require.NoError(t, err)
+	return redirect
+}
+
+func requireIntrospection(t *testing.T, recorder *httptest.ResponseRecorder) oauthIntrospectionResponse {
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var response oauthIntrospectionResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	return response
+}
@@ -0,0 +1,750 @@
+package api
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"simple-bank/internal/db"
+	"simple-bank/internal/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	openAPIVersion = "3.1.0"
+	openAPIPath    = "/openapi.json"
+	docsPath       = "/docs"
+	docsAssetsPath = docsPath + "/assets"
+
+	securityBearer = "bearerAuth"
+	securityAPIKey = "apiKey"
+	securityOAuth  = "oauth2"
+)
+
+// routeAccess mirrors the middlewares guarding a route.
+type routeAccess int
+
+const (
+	accessPublic routeAccess = iota
+	// accessSession routes need an access token of a first-party session.
+	accessSession
+	// accessAdmin routes need a session of an admin.
+	accessAdmin
+	// accessScope routes accept any credentials granted the scope.
+	accessScope
+)
+
+// operation documents a route registered in NewServer. Requests and
+// responses are described by the structs the handlers bind and write, their
+// binding tags become the constraints of the schema.
+type operation struct {
+	method  string
+	path    string
+	tag     string
+	summary string
+	access  routeAccess
+	scope   string
+
+	uri   any
+	query any
+	// headers are read by the handler directly, they have no struct.
+	headers []openAPIParameter
+	body    any
+	// formBody is for the OAuth endpoints, they take form-encoded bodies.
+	formBody bool
+
+	status int
+	// responses are alternatives, a login either completes or asks for a
+	// second factor.
+	responses   []any
+	contentType string
+	// oauthErrors is for the endpoints answering with RFC 6749 errors
+	// instead of problems.
+	oauthErrors bool
+
+	deprecated bool
+}
+
+// docsOperations are the routes serving the documentation, they are not
+// versioned.
+var docsOperations = []operation{
+	{method: http.MethodGet, path: openAPIPath, tag: "docs", summary: "OpenAPI document of the API", status: http.StatusOK, contentType: "application/json"},
+	{method: http.MethodGet, path: docsPath, tag: "docs", summary: "Interactive documentation", status: http.StatusOK, contentType: "text/html"},
+	{method: http.MethodGet, path: docsAssetsPath + "/:name", tag: "docs", summary: "Assets of the interactive documentation", uri: getDocsAssetParams{}, status: http.StatusOK},
+}
+
+// ifNoneMatch and ifMatch are the conditional request headers of the routes
+// serving an ETag.
+var (
+	ifNoneMatch = openAPIParameter{
+		Name:        ifNoneMatchHeader,
+		In:          "header",
+		Description: "ETag of the copy the client has, a current one is answered with 304.",
+		Schema:      &openAPISchema{Type: "string"},
+	}
+	ifMatch = openAPIParameter{
+		Name:        ifMatchHeader,
+		In:          "header",
+		Description: "ETag the update is based on, the update fails with 412 once it is stale.",
+		Schema:      &openAPISchema{Type: "string"},
+	}
+)
+
+// v1Operations lists every route of v1 relative to the version prefix, a
+// route without an entry fails TestOpenAPI_CoversRoutes.
+var v1Operations = []operation{
+	{method: http.MethodPost, path: "/users", tag: "users", summary: "Sign up", body: createUserRequest{}, status: http.StatusOK, responses: []any{userResponse{}}},
+	{method: http.MethodPost, path: "/users/login", tag: "users", summary: "Log in, users with two-factor authentication get a challenge", body: loginUserRequest{}, status: http.StatusOK, responses: []any{loginUserResponse{}, loginChallengeResponse{}}},
+	{method: http.MethodPost, path: "/users/login/2fa", tag: "users", summary: "Complete a login challenge", body: loginTwoFactorRequest{}, status: http.StatusOK, responses: []any{loginUserResponse{}}},
+	{method: http.MethodPost, path: "/users/verify-email", tag: "users", summary: "Verify an email address", body: verifyEmailRequest{}, status: http.StatusOK, responses: []any{userResponse{}}},
+	{method: http.MethodPost, path: "/users/password-reset", tag: "users", summary: "Request a password reset link", body: requestPasswordResetRequest{}, status: http.StatusAccepted},
+	{method: http.MethodPost, path: "/users/password-reset/confirm", tag: "users", summary: "Reset a password", body: confirmPasswordResetRequest{}, status: http.StatusOK, responses: []any{userResponse{}}},
+
+	{method: http.MethodGet, path: "/users/me", tag: "users", summary: "Get the current user", access: accessSession, headers: []openAPIParameter{ifNoneMatch}, status: http.StatusOK, responses: []any{userResponse{}}},
+	{method: http.MethodPatch, path: "/users/me", tag: "users", summary: "Update the current user", access: accessSession, headers: []openAPIParameter{ifMatch}, body: updateUserRequest{}, status: http.StatusOK, responses: []any{userResponse{}}},
+	{method: http.MethodPost, path: "/users/me/password", tag: "users", summary: "Change the password and sign out other sessions", access: accessSession, body: changePasswordRequest{}, status: http.StatusOK, responses: []any{loginUserResponse{}}},
+	{method: http.MethodPost, path: "/users/me/verify-email", tag: "users", summary: "Resend the verification email", access: accessSession, status: http.StatusAccepted},
+	{method: http.MethodPost, path: "/users/me/totp", tag: "two-factor", summary: "Enroll a TOTP authenticator", access: accessSession, status: http.StatusOK, responses: []any{enrollTOTPResponse{}}},
+	{method: http.MethodPost, path: "/users/me/totp/confirm", tag: "two-factor", summary: "Confirm the TOTP enrollment", access: accessSession, body: confirmTOTPRequest{}, status: http.StatusOK, responses: []any{confirmTOTPResponse{}}},
+	{method: http.MethodPost, path: "/users/me/api-keys", tag: "api-keys", summary: "Create an API key", access: accessSession, body: createAPIKeyRequest{}, status: http.StatusCreated, responses: []any{createAPIKeyResponse{}}},
+	{method: http.MethodGet, path: "/users/me/api-keys", tag: "api-keys", summary: "List API keys", access: accessSession, status: http.StatusOK, responses: []any{[]apiKeyResponse{}}},
+	{method: http.MethodDelete, path: "/users/me/api-keys/:id", tag: "api-keys", summary: "Revoke an API key", access: accessSession, uri: revokeAPIKeyParams{}, status: http.StatusNoContent},
+
+	{method: http.MethodPost, path: "/oauth/token", tag: "oauth", summary: "Exchange a grant for an access token", body: oauthTokenRequest{}, formBody: true, status: http.StatusOK, responses: []any{oauthTokenResponse{}}, oauthErrors: true},
+	{method: http.MethodPost, path: "/oauth/introspect", tag: "oauth", summary: "Introspect a token", body: oauthIntrospectRequest{}, formBody: true, status: http.StatusOK, responses: []any{oauthIntrospectionResponse{}}, oauthErrors: true},
+	{method: http.MethodGet, path: "/oauth/authorize", tag: "oauth", summary: "Describe an authorization request for the consent screen", access: accessSession, query: oauthAuthorizeRequest{}, status: http.StatusOK, responses: []any{oauthConsentResponse{}}},
+	{method: http.MethodPost, path: "/oauth/authorize", tag: "oauth", summary: "Approve or deny an authorization request", access: accessSession, body: oauthConsentRequest{}, status: http.StatusOK, responses: []any{oauthRedirectResponse{}}},
+	{method: http.MethodPost, path: "/oauth/clients", tag: "oauth", summary: "Register an OAuth client", access: accessSession, body: createOAuthClientRequest{}, status: http.StatusCreated, responses: []any{createOAuthClientResponse{}}},
+	{method: http.MethodGet, path: "/oauth/clients", tag: "oauth", summary: "List OAuth clients", access: accessSession, status: http.StatusOK, responses: []any{[]oauthClientResponse{}}},
+
+	{method: http.MethodPost, path: "/accounts", tag: "accounts", summary: "Open an account", access: accessScope, scope: scopeAccountsWrite, body: createAccountRequest{}, status: http.StatusOK, responses: []any{accountResponse{}}},
+	{method: http.MethodGet, path: "/accounts/:id", tag: "accounts", summary: "Get an account", access: accessScope, scope: scopeAccountsRead, uri: getAccountParams{}, headers: []openAPIParameter{ifNoneMatch}, status: http.StatusOK, responses: []any{accountResponse{}}},
+	{method: http.MethodGet, path: "/accounts", tag: "accounts", summary: "List accounts", access: accessScope, scope: scopeAccountsRead, query: ListAccountParams{}, status: http.StatusOK, responses: []any{[]accountResponse{}}},
+	{
+		method: http.MethodGet, path: "/accounts/events", tag: "accounts", summary: "Stream balance changes and entries as server-sent events", access: accessScope, scope: scopeAccountsRead,
+		query: streamAccountEventsRequest{},
+		headers: []openAPIParameter{{
+			Name:        lastEventIDHeader,
+			In:          "header",
+			Description: "Resumes the stream from the id of the last event received, it wins over last_event_id. Events may be sent again after a reconnect, tell them apart by event id.",
+			Schema:      &openAPISchema{Type: "string"},
+		}},
+		status: http.StatusOK, contentType: "text/event-stream",
+	},
+
+	{method: http.MethodPost, path: "/transfers", tag: "transfers", summary: "Transfer money between accounts", access: accessScope, scope: scopeTransfersWrite, body: transferRequest{}, status: http.StatusOK, responses: []any{transferTxResponse{}}},
+
+	{method: http.MethodPost, path: "/webhooks", tag: "webhooks", summary: "Subscribe to events", access: accessScope, scope: scopeWebhooksManage, body: createWebhookRequest{}, status: http.StatusCreated, responses: []any{createWebhookResponse{}}},
+	{method: http.MethodGet, path: "/webhooks", tag: "webhooks", summary: "List subscriptions", access: accessScope, scope: scopeWebhooksManage, status: http.StatusOK, responses: []any{[]webhookResponse{}}},
+	{method: http.MethodGet, path: "/webhooks/:id", tag: "webhooks", summary: "Get a subscription", access: accessScope, scope: scopeWebhooksManage, uri: webhookParams{}, status: http.StatusOK, responses: []any{webhookResponse{}}},
+	{method: http.MethodPatch, path: "/webhooks/:id", tag: "webhooks", summary: "Update a subscription", access: accessScope, scope: scopeWebhooksManage, uri: webhookParams{}, body: updateWebhookRequest{}, status: http.StatusOK, responses: []any{webhookResponse{}}},
+	{method: http.MethodDelete, path: "/webhooks/:id", tag: "webhooks", summary: "Delete a subscription", access: accessScope, scope: scopeWebhooksManage, uri: webhookParams{}, status: http.StatusNoContent},
+	{method: http.MethodGet, path: "/webhooks/:id/deliveries", tag: "webhooks", summary: "List deliveries, newest first", access: accessScope, scope: scopeWebhooksManage, uri: webhookParams{}, query: listWebhookDeliveriesRequest{}, status: http.StatusOK, responses: []any{listWebhookDeliveriesResponse{}}},
+	{method: http.MethodPost, path: "/webhooks/:id/deliveries/:delivery_id/replay", tag: "webhooks", summary: "Send a delivery again", access: accessScope, scope: scopeWebhooksManage, uri: replayWebhookDeliveryParams{}, status: http.StatusAccepted, responses: []any{db.WebhookDelivery{}}},
+
+	{method: http.MethodGet, path: "/admin/audit-events", tag: "admin", summary: "Search the audit log, newest first", access: accessAdmin, query: listAuditEventsRequest{}, status: http.StatusOK, responses: []any{listAuditEventsResponse{}}},
+	{method: http.MethodGet, path: "/admin/audit-events/export", tag: "admin", summary: "Export the audit log as NDJSON", access: accessAdmin, query: listAuditEventsRequest{}, status: http.StatusOK, responses: []any{db.AuditEvent{}}, contentType: "application/x-ndjson"},
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]*openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type         string             `json:"type"`
+	Description  string             `json:"description,omitempty"`
+	Scheme       string             `json:"scheme,omitempty"`
+	BearerFormat string             `json:"bearerFormat,omitempty"`
+	Name         string             `json:"name,omitempty"`
+	In           string             `json:"in,omitempty"`
+	Flows        *openAPIOAuthFlows `json:"flows,omitempty"`
+}
+
+type openAPIOAuthFlows struct {
+	AuthorizationCode *openAPIOAuthFlow `json:"authorizationCode,omitempty"`
+	ClientCredentials *openAPIOAuthFlow `json:"clientCredentials,omitempty"`
+}
+
+type openAPIOAuthFlow struct {
+	AuthorizationURL string            `json:"authorizationUrl,omitempty"`
+	TokenURL         string            `json:"tokenUrl"`
+	Scopes           map[string]string `json:"scopes"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Tags        []string                    `json:"tags"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Security    []map[string][]string       `json:"security"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref              string                    `json:"$ref,omitempty"`
+	Type             string                    `json:"type,omitempty"`
+	Format           string                    `json:"format,omitempty"`
+	Description      string                    `json:"description,omitempty"`
+	Enum             []string                  `json:"enum,omitempty"`
+	Pattern          string                    `json:"pattern,omitempty"`
+	MinLength        *int64                    `json:"minLength,omitempty"`
+	MaxLength        *int64                    `json:"maxLength,omitempty"`
+	Minimum          *int64                    `json:"minimum,omitempty"`
+	Maximum          *int64                    `json:"maximum,omitempty"`
+	ExclusiveMinimum *int64                    `json:"exclusiveMinimum,omitempty"`
+	MinItems         *int64                    `json:"minItems,omitempty"`
+	MaxItems         *int64                    `json:"maxItems,omitempty"`
+	Items            *openAPISchema            `json:"items,omitempty"`
+	Properties       map[string]*openAPISchema `json:"properties,omitempty"`
+	Required         []string                  `json:"required,omitempty"`
+	OneOf            []*openAPISchema          `json:"oneOf,omitempty"`
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	rawType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// patterns of the validator rules matching a regular expression.
+const (
+	alphanumPattern = "^[a-zA-Z0-9]+$"
+	numericPattern  = `^[-+]?[0-9]+(?:\.[0-9]+)?$`
+)
+
+// openAPIBuilder collects the schemas of the named structs while the
+// operations are documented.
+type openAPIBuilder struct {
+	schemas map[string]*openAPISchema
+	types   map[string]reflect.Type
+}
+
+// documentedOperations are the operations of every version, under the
+// prefix of the version, and the docs routes.
+func documentedOperations() []operation {
+	var documented []operation
+	for _, version := range apiVersions {
+		for _, op := range version.operations {
+			op.path = version.prefix + op.path
+			op.deprecated = version.deprecated()
+			documented = append(documented, op)
+		}
+	}
+	return append(documented, docsOperations...)
+}
+
+// newOpenAPIDocument documents operations. It fails on a binding rule it
+// can't express, the spec would silently be looser than the API otherwise.
+func newOpenAPIDocument(operations []operation) (*openAPIDocument, error) {
+	builder := &openAPIBuilder{
+		schemas: make(map[string]*openAPISchema),
+		types:   make(map[string]reflect.Type),
+	}
+
+	problem, err := builder.schema(reflect.TypeOf(problemDetails{}), "json")
+	if err != nil {
+		return nil, err
+	}
+	oauthProblem, err := builder.schema(reflect.TypeOf(oauthError{}), "json")
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]map[string]*openAPIOperation)
+	for _, op := range operations {
+		path := openAPIPathOf(op.path)
+		documented, err := builder.operation(op)
+		if err != nil {
+			return nil, fmt.Errorf("openapi: %s %s: %w", op.method, op.path, err)
+		}
+
+		errorResponse := &openAPIResponse{
+			Description: "Error",
+			Content:     map[string]openAPIMediaType{problemContentType: {Schema: problem}},
+		}
+		if op.oauthErrors {
+			errorResponse.Content = map[string]openAPIMediaType{"application/json": {Schema: oauthProblem}}
+		}
+		documented.Responses["default"] = errorResponse
+
+		if paths[path] == nil {
+			paths[path] = make(map[string]*openAPIOperation)
+		}
+		paths[path][strings.ToLower(op.method)] = documented
+	}
+
+	scopes := make(map[string]string, len(grantableScopes))
+	for _, scope := range grantableScopes {
+		scopes[scope] = scope
+	}
+
+	return &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info:    openAPIInfo{Title: "Simple Bank API", Version: "1.0.0"},
+		Paths:   paths,
+		Components: openAPIComponents{
+			Schemas: builder.schemas,
+			SecuritySchemes: map[string]*openAPISecurityScheme{
+				securityBearer: {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "PASETO",
+					Description:  "Access token of a login, or of an OAuth grant.",
+				},
+				securityAPIKey: {
+					Type:        "apiKey",
+					Name:        authorizationHeader,
+					In:          "header",
+					Description: "API key sent as `Authorization: ApiKey <key>`.",
+				},
+				securityOAuth: {
+					Type: "oauth2",
+					Flows: &openAPIOAuthFlows{
+						AuthorizationCode: &openAPIOAuthFlow{AuthorizationURL: "/oauth/authorize", TokenURL: "/oauth/token", Scopes: scopes},
+						ClientCredentials: &openAPIOAuthFlow{TokenURL: "/oauth/token", Scopes: scopes},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (b *openAPIBuilder) operation(op operation) (*openAPIOperation, error) {
+	documented := &openAPIOperation{
+		OperationID: operationID(op.method, op.path),
+		Summary:     op.summary,
+		Tags:        []string{op.tag},
+		Deprecated:  op.deprecated,
+		Security:    operationSecurity(op),
+		Parameters:  op.headers,
+		Responses:   make(map[string]*openAPIResponse),
+	}
+
+	for _, source := range []struct {
+		value any
+		in    string
+		tag   string
+	}{
+		{op.uri, "path", "uri"},
+		{op.query, "query", "form"},
+	} {
+		if source.value == nil {
+			continue
+		}
+		parameters, err := b.parameters(reflect.TypeOf(source.value), source.in, source.tag)
+		if err != nil {
+			return nil, err
+		}
+		documented.Parameters = append(documented.Parameters, parameters...)
+	}
+
+	if op.body != nil {
+		contentType, tag := "application/json", "json"
+		if op.formBody {
+			contentType, tag = "application/x-www-form-urlencoded", "form"
+		}
+		schema, err := b.schema(reflect.TypeOf(op.body), tag)
+		if err != nil {
+			return nil, err
+		}
+		documented.RequestBody = &openAPIRequestBody{
+			Required: true,
+			Content:  map[string]openAPIMediaType{contentType: {Schema: schema}},
+		}
+	}
+
+	response := &openAPIResponse{Description: http.StatusText(op.status)}
+	contentType := op.contentType
+	if contentType == "" && len(op.responses) > 0 {
+		contentType = "application/json"
+	}
+	if contentType != "" {
+		schema := &openAPISchema{Type: "string"}
+		for _, value := range op.responses {
+			alternative, err := b.schema(reflect.TypeOf(value), "json")
+			if err != nil {
+				return nil, err
+			}
+			schema.OneOf = append(schema.OneOf, alternative)
+		}
+		if len(schema.OneOf) == 1 {
+			schema = schema.OneOf[0]
+		} else if len(schema.OneOf) > 1 {
+			schema.Type = ""
+		}
+		response.Content = map[string]openAPIMediaType{contentType: {Schema: schema}}
+	}
+	documented.Responses[strconv.Itoa(op.status)] = response
+
+	return documented, nil
+}
+
+func operationSecurity(op operation) []map[string][]string {
+	switch op.access {
+	case accessSession, accessAdmin:
+		return []map[string][]string{{securityBearer: {}}}
+	case accessScope:
+		return []map[string][]string{
+			{securityBearer: {op.scope}},
+			{securityAPIKey: {op.scope}},
+			{securityOAuth: {op.scope}},
+		}
+	default:
+		return []map[string][]string{}
+	}
+}
+
+func (b *openAPIBuilder) parameters(t reflect.Type, in, tag string) ([]openAPIParameter, error) {
+	var parameters []openAPIParameter
+	err := b.fields(t, tag, func(name string, field reflect.StructField, schema *openAPISchema, required bool) {
+		parameters = append(parameters, openAPIParameter{
+			Name:        name,
+			In:          in,
+			Description: schema.Description,
+			Required:    required || in == "path",
+			Schema:      schema,
+		})
+	})
+	return parameters, err
+}
+
+// schema describes t, named structs end up in the components and are
+// referenced.
+func (b *openAPIBuilder) schema(t reflect.Type, tag string) (*openAPISchema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}, nil
+	case t == uuidType:
+		return &openAPISchema{Type: "string", Format: "uuid"}, nil
+	case t == rawType:
+		return &openAPISchema{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &openAPISchema{Type: "string"}, nil
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}, nil
+	case reflect.Int32:
+		return &openAPISchema{Type: "integer", Format: "int32"}, nil
+	case reflect.Int, reflect.Int64:
+		return &openAPISchema{Type: "integer", Format: "int64"}, nil
+	case reflect.Slice:
+		items, err := b.schema(t.Elem(), tag)
+		if err != nil {
+			return nil, err
+		}
+		return &openAPISchema{Type: "array", Items: items}, nil
+	case reflect.Struct:
+		return b.structSchema(t, tag)
+	default:
+		return nil, fmt.Errorf("%s: unsupported type", t)
+	}
+}
+
+func (b *openAPIBuilder) structSchema(t reflect.Type, tag string) (*openAPISchema, error) {
+	name := componentName(t)
+	ref := &openAPISchema{Ref: "#/components/schemas/" + name}
+	if known, ok := b.types[name]; ok {
+		if known != t {
+			return nil, fmt.Errorf("%s and %s share the schema name %s", known, t, name)
+		}
+		return ref, nil
+	}
+	b.types[name] = t
+
+	schema := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	err := b.fields(t, tag, func(name string, _ reflect.StructField, property *openAPISchema, required bool) {
+		schema.Properties[name] = property
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	b.schemas[name] = schema
+
+	return ref, nil
+}
+
+// fields walks the fields of t named by tag, embedded structs are flattened
+// like encoding/json and gin do.
+func (b *openAPIBuilder) fields(t reflect.Type, tag string, visit func(name string, field reflect.StructField, schema *openAPISchema, required bool)) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := b.fields(field.Type, tag, visit); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := tagName(field, tag)
+		if name == "" || !field.IsExported() {
+			continue
+		}
+
+		schema, err := b.schema(field.Type, "json")
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+		}
+		if _, ok := field.Tag.Lookup("time_format"); ok {
+			schema.Format = "date-time"
+		}
+
+		required, err := applyBinding(schema, t, field, tag)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+		}
+
+		visit(name, field, schema, required)
+	}
+
+	return nil
+}
+
+// applyBinding turns the binding rules of field into constraints of its
+// schema and reports whether the field is required.
+func applyBinding(schema *openAPISchema, parent reflect.Type, field reflect.StructField, tag string) (bool, error) {
+	binding := field.Tag.Get("binding")
+	if binding == "" {
+		return false, nil
+	}
+
+	required := false
+	target := schema
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+
+		var err error
+		switch name {
+		case "omitempty":
+		case "required":
+			required = true
+		case "required_without":
+			other, ok := parent.FieldByName(param)
+			if !ok {
+				return false, fmt.Errorf("required_without refers to unknown field %s", param)
+			}
+			target.Description = fmt.Sprintf("Required when %s is not set.", tagName(other, tag))
+		case "dive":
+			if target.Items == nil {
+				return false, fmt.Errorf("dive on a non-array field")
+			}
+			target = target.Items
+		case "min", "max", "len":
+			err = applyLength(target, name, param)
+		case "gt":
+			if param == "" {
+				target.Description = "Must be in the future."
+				break
+			}
+			var value int64
+			value, err = strconv.ParseInt(param, 10, 64)
+			target.ExclusiveMinimum = &value
+		case "eq":
+			target.Enum = []string{param}
+		case "oneof":
+			target.Enum = strings.Fields(param)
+		case "currency":
+			target.Enum = utils.SupportedCurrencies
+		case "scope":
+			target.Enum = grantableScopes
+		case "email":
+			target.Format = "email"
+		case "url":
+			target.Format = "uri"
+		case "webhook_url":
+			target.Format = "uri"
+			target.Pattern = "^https://"
+		case "uuid":
+			target.Format = "uuid"
+		case "alphanum":
+			target.Pattern = alphanumPattern
+		case "numeric":
+			target.Pattern = numericPattern
+		default:
+			err = fmt.Errorf("unsupported binding rule %q", rule)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return required, nil
+}
+
+// applyLength sets the bounds matching the kind of schema, validator reads
+// min, max and len as a length for strings and arrays.
+func applyLength(schema *openAPISchema, rule, param string) error {
+	value, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s=%s: %w", rule, param, err)
+	}
+
+	var lower, upper **int64
+	switch schema.Type {
+	case "string":
+		lower, upper = &schema.MinLength, &schema.MaxLength
+	case "array":
+		lower, upper = &schema.MinItems, &schema.MaxItems
+	case "integer":
+		lower, upper = &schema.Minimum, &schema.Maximum
+	default:
+		return fmt.Errorf("%s on a %s field", rule, schema.Type)
+	}
+
+	switch rule {
+	case "min":
+		*lower = &value
+	case "max":
+		*upper = &value
+	case "len":
+		*lower, *upper = &value, &value
+	}
+
+	return nil
+}
+
+func tagName(field reflect.StructField, tag string) string {
+	name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// openAPIPathOf turns the :param segments of a gin path into {param}.
+func openAPIPathOf(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// operationID is derived from the route, POST /users/me/api-keys becomes
+// postUsersMeApiKeys.
+func operationID(method, path string) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(method))
+	for _, word := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '-' || r == '_' || r == '.' || r == ':'
+	}) {
+		id.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return id.String()
+}
+
+func (s *Server) getOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", s.openAPI)
+}
+
+// swaggerUI holds the Swagger UI assets, see swaggerui/README.md. The docs
+// page loads them from the API itself, never from a CDN.
+//
+//go:embed swaggerui
+var swaggerUI embed.FS
+
+type getDocsAssetParams struct {
+	Name string `uri:"name" binding:"required,oneof=swagger-ui.css swagger-ui-bundle.js"`
+}
+
+// registerDocs serves the document, the page rendering it and its assets.
+func (s *Server) registerDocs() {
+	s.engine.GET(openAPIPath, s.getOpenAPI)
+	s.engine.GET(docsPath, s.getDocs)
+	s.engine.GET(docsAssetsPath+"/:name", s.getDocsAsset)
+}
+
+func (s *Server) getDocsAsset(c *gin.Context) {
+	var params getDocsAssetParams
+	if err := c.ShouldBindUri(&params); err != nil {
+		abortWithError(c, errNotFound)
+		return
+	}
+
+	assets, err := fs.Sub(swaggerUI, "swaggerui")
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	c.FileFromFS(params.Name, http.FS(assets))
+}
+
+// docsPage renders the document with Swagger UI.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Simple Bank API</title>
+  <link rel="stylesheet" href="` + docsAssetsPath + `/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="` + docsAssetsPath + `/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "` + openAPIPath + `", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+func (s *Server) getDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
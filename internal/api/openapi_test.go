@@ -0,0 +1,138 @@
+package api
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	mockdb "simple-bank/internal/db/mock"
+	"testing"
+)
+
+func TestOpenAPI_CoversRoutes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	documented := make(map[string]bool)
+	for _, op := range documentedOperations() {
+		key := op.method + " " + op.path
+		require.False(t, documented[key], "%s is documented twice", key)
+		documented[key] = true
+	}
+
+	require.NoError(t, newTestContainer(t, store).Invoke(func(server *Server) {
+		registered := make(map[string]bool)
+		for _, route := range server.engine.Routes() {
+			key := route.Method + " " + route.Path
+			registered[key] = true
+			require.True(t, documented[key], "%s has no entry in operations", key)
+		}
+		for key := range documented {
+			require.True(t, registered[key], "%s is documented but not registered", key)
+		}
+	}))
+}
+
+func TestOpenAPI_Document(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	var document openAPIDocument
+	require.NoError(t, newTestContainer(t, store).Invoke(func(server *Server) {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, openAPIPath, nil)
+		require.NoError(t, err)
+
+		server.engine.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &document))
+	}))
+	require.Equal(t, openAPIVersion, document.OpenAPI)
+
+	listAccounts := document.Paths["/v1/accounts"]["get"]
+	require.NotNil(t, listAccounts)
+	require.False(t, listAccounts.Deprecated)
+	require.True(t, document.Paths["/accounts"]["get"].Deprecated)
+	require.Equal(t, []map[string][]string{
+		{securityBearer: {scopeAccountsRead}},
+		{securityAPIKey: {scopeAccountsRead}},
+		{securityOAuth: {scopeAccountsRead}},
+	}, listAccounts.Security)
+
+	parameters := make(map[string]openAPIParameter)
+	for _, parameter := range listAccounts.Parameters {
+		parameters[parameter.Name] = parameter
+	}
+	pageSize := parameters["page_size"]
+	require.Equal(t, "query", pageSize.In)
+	require.True(t, pageSize.Required)
+	require.Equal(t, int64(5), *pageSize.Schema.Minimum)
+	require.Equal(t, int64(10), *pageSize.Schema.Maximum)
+
+	revokeAPIKey := document.Paths["/v1/users/me/api-keys/{id}"]["delete"]
+	require.NotNil(t, revokeAPIKey)
+	require.Equal(t, "path", revokeAPIKey.Parameters[0].In)
+	require.Equal(t, "uuid", revokeAPIKey.Parameters[0].Schema.Format)
+	require.Contains(t, revokeAPIKey.Responses, "204")
+
+	transfer := document.Components.Schemas["TransferRequest"]
+	require.NotNil(t, transfer)
+	require.ElementsMatch(t, []string{"from_account_id", "to_account_id", "amount", "currency"}, transfer.Required)
+	require.Equal(t, int64(0), *transfer.Properties["amount"].ExclusiveMinimum)
+	require.ElementsMatch(t, []string{"USD", "EUR", "CAD"}, transfer.Properties["currency"].Enum)
+	require.Equal(t, int64(6), *transfer.Properties["totp_code"].MinLength)
+	require.Equal(t, int64(6), *transfer.Properties["totp_code"].MaxLength)
+
+	apiKey := document.Components.Schemas["CreateAPIKeyRequest"]
+	require.NotNil(t, apiKey)
+	require.Equal(t, int64(1), *apiKey.Properties["scopes"].MinItems)
+	require.ElementsMatch(t, grantableScopes, apiKey.Properties["scopes"].Items.Enum)
+
+	createAPIKey := document.Components.Schemas["CreateAPIKeyResponse"]
+	require.NotNil(t, createAPIKey)
+	require.Contains(t, createAPIKey.Properties, "key")
+	require.Contains(t, createAPIKey.Properties, "prefix")
+
+	login := document.Paths["/v1/users/login"]["post"]
+	require.Empty(t, login.Security)
+	require.Len(t, login.Responses["200"].Content["application/json"].Schema.OneOf, 2)
+}
+
+func TestOpenAPI_Docs(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	require.NoError(t, newTestContainer(t, store).Invoke(func(server *Server) {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, docsPath, nil)
+		require.NoError(t, err)
+
+		server.engine.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Contains(t, recorder.Body.String(), openAPIPath)
+
+		// the assets come from the API, nothing loads from another origin
+		require.Contains(t, recorder.Body.String(), docsAssetsPath+"/swagger-ui-bundle.js")
+		require.NotContains(t, recorder.Body.String(), "://")
+
+		// only the Swagger UI files are served
+		recorder = httptest.NewRecorder()
+		request, err = http.NewRequest(http.MethodGet, docsAssetsPath+"/README.md", nil)
+		require.NoError(t, err)
+
+		server.engine.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+	}))
+}
+
+func TestNewOpenAPIDocument_UnsupportedRule(t *testing.T) {
+	type request struct {
+		IBAN string `json:"iban" binding:"required,iban"`
+	}
+
+	_, err := newOpenAPIDocument([]operation{
+		{method: http.MethodPost, path: "/iban", body: request{}, status: http.StatusOK},
+	})
+	require.ErrorContains(t, err, `unsupported binding rule "iban"`)
+}
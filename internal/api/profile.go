@@ -0,0 +1,162 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"net/http"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	"simple-bank/internal/security"
+	"simple-bank/internal/service"
+	"time"
+)
+
+func (s *Server) getCurrentUser(c *gin.Context) {
+	user := getUserFromGinCtx(c)
+	if notModified(c, user.Version) {
+		return
+	}
+
+	c.JSON(http.StatusOK, present(c).user(user))
+}
+
+type updateUserRequest struct {
+	FullName *string `json:"full_name" binding:"required_without=Email,omitempty,min=6"`
+	Email    *string `json:"email" binding:"required_without=FullName,omitempty,email"`
+}
+
+func (s *Server) updateCurrentUser(c *gin.Context) {
+	var request updateUserRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	current := getUserFromGinCtx(c)
+	version, err := expectedVersion(c, current.Version)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	user, err := s.store.UpdateUser(c, db.UpdateUserParams{
+		FullName:        nullString(request.FullName),
+		Email:           nullString(request.Email),
+		Username:        current.Username,
+		ExpectedVersion: version,
+	})
+	if err != nil {
+		// the user exists, it was loaded to authenticate the request
+		if version.Valid && errors.Is(err, pgx.ErrNoRows) {
+			err = errPreconditionFailed.withDetail("user was changed by another request")
+		}
+		abortWithError(c, err)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionUserUpdate,
+		TargetType: auditTargetUser,
+		TargetID:   user.Username,
+		Outcome:    audit.OutcomeSuccess,
+		Diff:       userChanges(current, user),
+	})
+
+	if user.Email != current.Email {
+		s.bank.NotifyEmailVerification(c, user)
+	}
+
+	c.Header(etagHeader, versionETag(user.Version))
+	c.JSON(http.StatusOK, present(c).user(user))
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// changePassword signs out every other session of the user, the response
+// carries a fresh access token for the caller.
+func (s *Server) changePassword(c *gin.Context) {
+	var request changePasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	user := getUserFromGinCtx(c)
+	if err := s.bank.CheckPassword(c, user, request.CurrentPassword, c.ClientIP()); err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			err = errInvalidCredentials.withDetail("current password is incorrect")
+		}
+		s.failLogin(c, user.Username, err)
+		return
+	}
+
+	if err := s.bank.CheckPasswordPolicy(request.NewPassword); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	hashedPassword, err := security.HashPassword(request.NewPassword, s.bank.PasswordParams())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	// a reset link sent before the change must not be able to undo it
+	err = s.store.RevokeUserTokens(c, db.RevokeUserTokensParams{
+		Username: user.Username,
+		Purpose:  db.UserTokenPurposePasswordReset,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	user, err = s.store.UpdateUserPassword(c, db.UpdateUserPasswordParams{
+		Username:          user.Username,
+		HashedPassword:    hashedPassword,
+		PasswordChangedAt: time.Now(),
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionUserPasswordChange,
+		TargetType: auditTargetUser,
+		TargetID:   user.Username,
+		Outcome:    audit.OutcomeSuccess,
+	})
+
+	accessToken, err := s.bank.CreateAccessToken(user.Username)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, present(c).login(accessToken, user))
+}
+
+// userChanges is the audit diff of a profile update.
+func userChanges(before, after db.User) map[string]any {
+	changes := map[string]any{}
+	if before.FullName != after.FullName {
+		changes["full_name"] = audit.Change{From: before.FullName, To: after.FullName}
+	}
+	if before.Email != after.Email {
+		changes["email"] = audit.Change{From: before.Email, To: after.Email}
+	}
+	return changes
+}
+
+func nullString(value *string) pgtype.Text {
+	if value == nil {
+		return pgtype.Text{}
+	}
+	return pgtype.Text{String: *value, Valid: true}
+}
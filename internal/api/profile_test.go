@@ -0,0 +1,457 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"simple-bank/internal/db"
+	memdb "simple-bank/internal/db/memory"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/mail"
+	tokens2 "simple-bank/internal/tokens"
+	"testing"
+	"time"
+)
+
+func TestServer_getCurrentUser(t *testing.T) {
+	user, _ := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	stubAuthUsers(store, user)
+
+	testContainer := newTestContainer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/users/me", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+		addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+		server.engine.ServeHTTP(recorder, request)
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, versionETag(user.Version), recorder.Header().Get(etagHeader))
+		var result userResponse
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+		require.Equal(t, newUserResponse(user), result)
+	}))
+}
+
+func TestServer_getCurrentUser_notModified(t *testing.T) {
+	user, _ := randomUser(t)
+	user.Version = 4
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	stubAuthUsers(store, user)
+
+	testContainer := newTestContainer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/users/me", nil)
+	require.NoError(t, err)
+	request.Header.Set(ifNoneMatchHeader, versionETag(user.Version))
+
+	require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+		addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+		server.engine.ServeHTTP(recorder, request)
+
+		require.Equal(t, http.StatusNotModified, recorder.Code)
+		require.Empty(t, recorder.Body.Bytes())
+	}))
+}
+
+// TestServer_getCurrentUser_failedLoginKeepsETag runs on the memory store:
+// the lockout bookkeeping of a failed login is no change of the user.
+func TestServer_getCurrentUser_failedLoginKeepsETag(t *testing.T) {
+	user, _ := randomUser(t)
+
+	store := memdb.NewStore()
+	created, err := store.CreateUser(context.Background(), db.CreateUserParams{
+		Username:       user.Username,
+		HashedPassword: user.HashedPassword,
+		FullName:       user.FullName,
+		Email:          user.Email,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, newTestContainer(t, store).Invoke(func(tokensManager tokens2.Manager, server *Server) {
+		getCurrentUser := func() *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/users/me", nil)
+			require.NoError(t, err)
+			addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+			server.engine.ServeHTTP(recorder, request)
+			return recorder
+		}
+
+		etag := getCurrentUser().Header().Get(etagHeader)
+		require.Equal(t, versionETag(created.Version), etag)
+
+		rawBody, err := json.Marshal(loginUserRequest{Username: user.Username, Password: "wrong-password"})
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(rawBody))
+		require.NoError(t, err)
+		server.engine.ServeHTTP(recorder, request)
+		requireProblemCode(t, recorder, errInvalidCredentials)
+
+		failed, err := store.GetUser(context.Background(), user.Username)
+		require.NoError(t, err)
+		require.Equal(t, int32(1), failed.FailedLoginAttempts)
+		require.Equal(t, etag, getCurrentUser().Header().Get(etagHeader))
+	}))
+}
+func TestServer_updateCurrentUser(t *testing.T) {
+	user, _ := randomUser(t)
+	user.EmailVerifiedAt = time.Now()
+	user.Version = 2
+
+	newFullName := "New Full Name"
+	newEmail := "new-" + user.Email
+
+	renamedUser := user
+	renamedUser.FullName = newFullName
+	renamedUser.Version = user.Version + 1
+
+	movedUser := user
+	movedUser.Email = newEmail
+	movedUser.EmailVerifiedAt = time.Time{}
+
+	testCases := []struct {
+		name          string
+		body          string
+		ifMatch       string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message)
+	}{
+		{
+			name: "full_name",
+			body: `{"full_name": "` + newFullName + `"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Eq(db.UpdateUserParams{
+						FullName: pgtype.Text{String: newFullName, Valid: true},
+						Username: user.Username,
+					})).
+					Times(1).
+					Return(renamedUser, nil)
+				store.EXPECT().
+					CreateUserToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var result userResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+				require.Equal(t, newFullName, result.FullName)
+				require.True(t, result.EmailVerified)
+				require.Empty(t, outbox)
+			},
+		},
+		{
+			name: "email",
+			body: `{"email": "` + newEmail + `"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Eq(db.UpdateUserParams{
+						Email:    pgtype.Text{String: newEmail, Valid: true},
+						Username: user.Username,
+					})).
+					Times(1).
+					Return(movedUser, nil)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Any()).
+					Times(1)
+				store.EXPECT().
+					CreateUserToken(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var result userResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+				require.Equal(t, newEmail, result.Email)
+				require.False(t, result.EmailVerified)
+				require.Len(t, outbox, 1)
+				require.Equal(t, newEmail, outbox[0].To)
+			},
+		},
+		{
+			name: "email_taken",
+			body: `{"email": "` + newEmail + `"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, &pgconn.PgError{Code: "23505"})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				requireProblemCode(t, recorder, errAlreadyExists)
+				require.Empty(t, outbox)
+			},
+		},
+		{
+			name:    "if_match",
+			body:    `{"full_name": "` + newFullName + `"}`,
+			ifMatch: versionETag(user.Version),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Eq(db.UpdateUserParams{
+						FullName:        pgtype.Text{String: newFullName, Valid: true},
+						Username:        user.Username,
+						ExpectedVersion: pgtype.Int8{Int64: user.Version, Valid: true},
+					})).
+					Times(1).
+					Return(renamedUser, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, versionETag(renamedUser.Version), recorder.Header().Get(etagHeader))
+			},
+		},
+		{
+			name:    "if_match_stale",
+			body:    `{"full_name": "` + newFullName + `"}`,
+			ifMatch: versionETag(user.Version - 1),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				requireProblemCode(t, recorder, errPreconditionFailed)
+			},
+		},
+		{
+			name:    "if_match_weak",
+			body:    `{"full_name": "` + newFullName + `"}`,
+			ifMatch: "W/" + versionETag(user.Version),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				requireProblemCode(t, recorder, errPreconditionFailed)
+			},
+		},
+		{
+			name:    "if_match_concurrent_write",
+			body:    `{"full_name": "` + newFullName + `"}`,
+			ifMatch: versionETag(user.Version),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				requireProblemCode(t, recorder, errPreconditionFailed)
+			},
+		},
+		{
+			name: "empty_body",
+			body: `{}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+		{
+			name: "invalid_email",
+			body: `{"email": "not-an-email"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, user)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPatch, "/users/me", bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			if tc.ifMatch != "" {
+				request.Header.Set(ifMatchHeader, tc.ifMatch)
+			}
+
+			require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server, mailer mail.Mailer) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder, outboxMessages(t, mailer))
+			}))
+		})
+	}
+}
+
+func TestServer_changePassword(t *testing.T) {
+	user, password := randomUser(t)
+	newPassword := "new-secret"
+
+	lockedUser := user
+	lockedUser.LockedUntil = time.Now().Add(time.Minute)
+
+	testCases := []struct {
+		name          string
+		authUser      db.User
+		body          changePasswordRequest
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server)
+	}{
+		{
+			name:     "success",
+			authUser: user,
+			body:     changePasswordRequest{CurrentPassword: password, NewPassword: newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Eq(db.RevokeUserTokensParams{
+						Username: user.Username,
+						Purpose:  db.UserTokenPurposePasswordReset,
+					})).
+					Times(1)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Cond[db.UpdateUserPasswordParams](func(x db.UpdateUserPasswordParams) bool {
+						return x.Username == user.Username &&
+							passwordMatches(newPassword, x.HashedPassword) &&
+							time.Since(x.PasswordChangedAt) < time.Second
+					})).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.UpdateUserPasswordParams) (db.User, error) {
+						updated := user
+						updated.HashedPassword = arg.HashedPassword
+						updated.PasswordChangedAt = arg.PasswordChangedAt
+						return updated, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var result loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+
+				// the fresh token outlives the password change it follows
+				payload, err := server.tokensManager.VerifyToken(result.AccessToken)
+				require.NoError(t, err)
+				require.False(t, payload.IssuedAt.Before(result.User.PasswordChangedAt))
+			},
+		},
+		{
+			name:     "wrong_current_password",
+			authUser: user,
+			body:     changePasswordRequest{CurrentPassword: "wrong-password", NewPassword: newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RegisterFailedLogin(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, false)).
+					Times(1)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireProblemCode(t, recorder, errInvalidCredentials)
+			},
+		},
+		{
+			name:     "locked_user",
+			authUser: lockedUser,
+			body:     changePasswordRequest{CurrentPassword: password, NewPassword: newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, false)).
+					Times(1)
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireProblemCode(t, recorder, errInvalidCredentials)
+			},
+		},
+		{
+			name:     "breached_new_password",
+			authUser: user,
+			body:     changePasswordRequest{CurrentPassword: password, NewPassword: testBreachedPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireProblemCode(t, recorder, errWeakPassword)
+			},
+		},
+		{
+			name:     "short_new_password",
+			authUser: user,
+			body:     changePasswordRequest{CurrentPassword: password, NewPassword: "short"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					UpdateUserPassword(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, tc.authUser)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			rawBody, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/me/password", bytes.NewReader(rawBody))
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder, server)
+			}))
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"simple-bank/internal/tokens"
+	"slices"
+	"strings"
+)
+
+const (
+	scopeAccountsRead   = tokens.ScopeAccountsRead
+	scopeAccountsWrite  = tokens.ScopeAccountsWrite
+	scopeTransfersWrite = tokens.ScopeTransfersWrite
+	scopeWebhooksManage = tokens.ScopeWebhooksManage
+)
+
+// grantableScopes are the scopes API keys and OAuth clients can be granted.
+// Routes that are not guarded by one of them are only reachable with an
+// access token of a first-party session.
+var grantableScopes = []string{
+	scopeAccountsRead,
+	scopeAccountsWrite,
+	scopeTransfersWrite,
+	scopeWebhooksManage,
+}
+
+// parseScopes splits an OAuth scope parameter, a space-delimited list.
+func parseScopes(scope string) []string {
+	scopes := strings.Fields(scope)
+	slices.Sort(scopes)
+	return slices.Compact(scopes)
+}
+
+func formatScopes(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
@@ -1,54 +1,175 @@
 package api
 
 import (
+	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
+	"log/slog"
+	"simple-bank/internal/audit"
 	"simple-bank/internal/config"
 	"simple-bank/internal/db"
+	"simple-bank/internal/mail"
+	"simple-bank/internal/ratelimit"
+	"simple-bank/internal/security"
+	"simple-bank/internal/service"
+	"simple-bank/internal/stream"
 	"simple-bank/internal/tokens"
+	"sync/atomic"
 )
 
 type Server struct {
-	config        *config.Config
-	store         db.Store
-	engine        *gin.Engine
-	tokensManager tokens.Manager
+	configs          *config.Watcher
+	limits           atomic.Pointer[ratelimit.RouteLimits]
+	store            db.Store
+	bank             *service.Service
+	engine           *gin.Engine
+	tokensManager    tokens.Manager
+	rateLimitStorage ratelimit.Storage
+	mailer           mail.Mailer
+	auditRecorder    audit.Recorder
+	accountEvents    *stream.Broker
+	openAPI          []byte
 }
 
-func NewServer(config *config.Config, store db.Store, tokensManager tokens.Manager) (*Server, error) {
+func NewServer(configs *config.Watcher, store db.Store, tokensManager tokens.Manager, rateLimitStorage ratelimit.Storage, mailer mail.Mailer, passwordPolicy security.PasswordPolicy, auditRecorder audit.Recorder, accountEvents *stream.Broker) (*Server, error) {
 	server := &Server{
-		config:        config,
-		store:         store,
-		engine:        gin.Default(),
-		tokensManager: tokensManager,
+		configs:          configs,
+		store:            store,
+		bank:             service.New(configs, store, tokensManager, mailer, passwordPolicy),
+		engine:           gin.Default(),
+		tokensManager:    tokensManager,
+		rateLimitStorage: rateLimitStorage,
+		mailer:           mailer,
+		auditRecorder:    auditRecorder,
+		accountEvents:    accountEvents,
 	}
 
+	server.engine.Use(requestIDMiddleware())
+
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldName)
 		err := v.RegisterValidation("currency", validateCurrency)
 		if err != nil {
 			return nil, err
 		}
+		err = v.RegisterValidation("scope", validateScope)
+		if err != nil {
+			return nil, err
+		}
+		err = v.RegisterValidation("webhook_url", validateWebhookURL)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	server.engine.POST("/users", server.createUser)
-	server.engine.POST("/users/login", server.loginUser)
+	limits, err := parseRouteLimits(configs.Current())
+	if err != nil {
+		return nil, err
+	}
+	server.limits.Store(&limits)
+	configs.Subscribe(func(cfg *config.Config) {
+		limits, err := parseRouteLimits(cfg)
+		if err != nil {
+			slog.Error("rate limits not reloaded", "err", err)
+			return
+		}
+		server.limits.Store(&limits)
+	})
 
-	authRoutes := server.engine.Group("/").Use(authMiddleware(server.tokensManager))
+	document, err := newOpenAPIDocument(documentedOperations())
+	if err != nil {
+		return nil, err
+	}
+	server.openAPI, err = json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
 
-	authRoutes.POST("/accounts", server.createAccount)
-	authRoutes.GET("/accounts/:id", server.getAccount)
-	authRoutes.GET("/accounts", server.listAccounts)
+	server.registerDocs()
 
-	authRoutes.POST("/transfers", server.createTransfer)
+	for _, version := range apiVersions {
+		server.registerRoutes(server.engine.Group(version.prefix, versionMiddleware(version)))
+	}
 
 	return server, nil
 }
 
-func (s *Server) Start(address string) error {
-	return s.engine.Run(address)
+// config is the current configuration, read it once per use: a reload may
+// publish another one in between.
+func (s *Server) config() *config.Config {
+	return s.configs.Current()
+}
+
+func parseRouteLimits(cfg *config.Config) (ratelimit.RouteLimits, error) {
+	return ratelimit.ParseRouteLimits(cfg.RateLimitPublic, cfg.RateLimitAccounts, cfg.RateLimitTransfers)
+}
+
+func (s *Server) publicLimit() ratelimit.Limit    { return s.limits.Load().Public }
+func (s *Server) accountsLimit() ratelimit.Limit  { return s.limits.Load().Accounts }
+func (s *Server) transfersLimit() ratelimit.Limit { return s.limits.Load().Transfers }
+
+// registerRoutes registers the routes of an API version under router.
+func (s *Server) registerRoutes(router *gin.RouterGroup) {
+	publicRoutes := router.Group("/", rateLimitMiddleware(s.rateLimitStorage, ratelimit.GroupPublic, s.publicLimit))
+
+	publicRoutes.POST("/users", s.createUser)
+	publicRoutes.POST("/users/login", s.loginUser)
+	publicRoutes.POST("/users/login/2fa", s.loginTwoFactor)
+	publicRoutes.POST("/users/verify-email", s.verifyEmail)
+	publicRoutes.POST("/users/password-reset", s.requestPasswordReset)
+	publicRoutes.POST("/users/password-reset/confirm", s.confirmPasswordReset)
+	publicRoutes.POST("/oauth/token", s.oauthToken)
+	publicRoutes.POST("/oauth/introspect", s.oauthIntrospect)
+
+	authRoutes := router.Group("/", authMiddleware(s.bank))
+
+	userRoutes := authRoutes.Group("/users/me", requireSession(), rateLimitMiddleware(s.rateLimitStorage, ratelimit.GroupUsers, s.publicLimit))
+
+	userRoutes.GET("", s.getCurrentUser)
+	userRoutes.PATCH("", s.updateCurrentUser)
+	userRoutes.POST("/password", s.changePassword)
+	userRoutes.POST("/verify-email", s.resendEmailVerification)
+	userRoutes.POST("/totp", s.enrollTOTP)
+	userRoutes.POST("/totp/confirm", s.confirmTOTP)
+	userRoutes.POST("/api-keys", s.createAPIKey)
+	userRoutes.GET("/api-keys", s.listAPIKeys)
+	userRoutes.DELETE("/api-keys/:id", s.revokeAPIKey)
+
+	oauthRoutes := authRoutes.Group("/oauth", requireSession(), rateLimitMiddleware(s.rateLimitStorage, ratelimit.GroupUsers, s.publicLimit))
+
+	oauthRoutes.GET("/authorize", s.getOAuthConsent)
+	oauthRoutes.POST("/authorize", s.approveOAuthConsent)
+	oauthRoutes.POST("/clients", s.createOAuthClient)
+	oauthRoutes.GET("/clients", s.listOAuthClients)
+
+	accountRoutes := authRoutes.Group("/", rateLimitMiddleware(s.rateLimitStorage, ratelimit.GroupAccounts, s.accountsLimit))
+
+	accountRoutes.POST("/accounts", requireScope(scopeAccountsWrite), s.createAccount)
+	accountRoutes.GET("/accounts/:id", requireScope(scopeAccountsRead), s.getAccount)
+	accountRoutes.GET("/accounts", requireScope(scopeAccountsRead), s.listAccounts)
+	accountRoutes.GET("/accounts/events", requireScope(scopeAccountsRead), s.streamAccountEvents)
+
+	transferRoutes := authRoutes.Group("/", rateLimitMiddleware(s.rateLimitStorage, ratelimit.GroupTransfers, s.transfersLimit))
+
+	transferRoutes.POST("/transfers", requireScope(scopeTransfersWrite), s.createTransfer)
+
+	webhookRoutes := authRoutes.Group("/webhooks", requireScope(scopeWebhooksManage), rateLimitMiddleware(s.rateLimitStorage, ratelimit.GroupWebhooks, s.accountsLimit))
+
+	webhookRoutes.POST("", s.createWebhook)
+	webhookRoutes.GET("", s.listWebhooks)
+	webhookRoutes.GET("/:id", s.getWebhook)
+	webhookRoutes.PATCH("/:id", s.updateWebhook)
+	webhookRoutes.DELETE("/:id", s.deleteWebhook)
+	webhookRoutes.GET("/:id/deliveries", s.listWebhookDeliveries)
+	webhookRoutes.POST("/:id/deliveries/:delivery_id/replay", s.replayWebhookDelivery)
+
+	adminRoutes := authRoutes.Group("/admin", requireSession(), requireAdmin(s.auditRecorder))
+
+	adminRoutes.GET("/audit-events", s.listAuditEvents)
+	adminRoutes.GET("/audit-events/export", s.exportAuditEvents)
 }
 
-func errorResponse(err error) gin.H {
-	return gin.H{"error": err.Error()}
+func (s *Server) Start(address string) error {
+	return s.engine.Run(address)
 }
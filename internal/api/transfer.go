@@ -1,12 +1,14 @@
 package api
 
 import (
-	"database/sql"
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"simple-bank/internal/audit"
 	"simple-bank/internal/db"
+	"simple-bank/internal/service"
+	"strconv"
+	"time"
 )
 
 type transferRequest struct {
@@ -14,61 +16,117 @@ type transferRequest struct {
 	ToAccountID   int64  `json:"to_account_id" binding:"required,min=1"`
 	Amount        int64  `json:"amount" binding:"required,gt=0"`
 	Currency      string `json:"currency" binding:"required,currency"`
+	TOTPCode      string `json:"totp_code" binding:"omitempty,len=6,numeric"`
 }
 
-func (s *Server) createTransfer(c *gin.Context) {
-	var request transferRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
-		return
-	}
+type transferResponse struct {
+	ID            int64     `json:"id"`
+	FromAccountID int64     `json:"from_account_id"`
+	ToAccountID   int64     `json:"to_account_id"`
+	Amount        int64     `json:"amount"`
+	CreatedAt     time.Time `json:"created_at"`
+}
 
-	fromAccount, valid := s.validateAccount(c, request.FromAccountID, request.Currency)
-	if !valid {
-		return
+type entryResponse struct {
+	ID        int64     `json:"id"`
+	AccountID int64     `json:"account_id"`
+	Amount    int64     `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type transferTxResponse struct {
+	Transfer    transferResponse `json:"transfer"`
+	FromAccount accountResponse  `json:"from_account"`
+	ToAccount   accountResponse  `json:"to_account"`
+	FromEntry   entryResponse    `json:"from_entry"`
+	ToEntry     entryResponse    `json:"to_entry"`
+}
+
+func newTransferTxResponse(result db.TransferTxResult) transferTxResponse {
+	return transferTxResponse{
+		Transfer: transferResponse{
+			ID:            result.Transfer.ID,
+			FromAccountID: result.Transfer.FromAccountID,
+			ToAccountID:   result.Transfer.ToAccountID,
+			Amount:        result.Transfer.Amount,
+			CreatedAt:     result.Transfer.CreatedAt,
+		},
+		FromAccount: newAccountResponse(result.FromAccount),
+		ToAccount:   newAccountResponse(result.ToAccount),
+		FromEntry:   newEntryResponse(result.FromEntry),
+		ToEntry:     newEntryResponse(result.ToEntry),
 	}
+}
 
-	authPayload := getPayloadFromGinCtx(c)
-	if authPayload.Subject != fromAccount.Owner {
-		c.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("you do not own account %d", request.FromAccountID)))
-		return
+func newEntryResponse(entry db.Entry) entryResponse {
+	return entryResponse{
+		ID:        entry.ID,
+		AccountID: entry.AccountID,
+		Amount:    entry.Amount,
+		CreatedAt: entry.CreatedAt,
 	}
+}
 
-	_, valid = s.validateAccount(c, request.ToAccountID, request.Currency)
-	if !valid {
+func (s *Server) createTransfer(c *gin.Context) {
+	var request transferRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, bindingError(err))
 		return
 	}
 
-	arg := db.TransferTxParams{
+	txResult, err := s.bank.Transfer(c, service.TransferParams{
+		User:          getUserFromGinCtx(c),
 		FromAccountID: request.FromAccountID,
 		ToAccountID:   request.ToAccountID,
 		Amount:        request.Amount,
-	}
-
-	txResult, err := s.store.TransferTx(c, arg)
+		Currency:      request.Currency,
+		TOTPCode:      request.TOTPCode,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		var rejected *service.RejectedTransferError
+		if errors.As(err, &rejected) {
+			s.rejectTransfer(c, request, rejected.Err)
+			return
+		}
+		abortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, txResult)
+	s.recordTransfer(c, request, strconv.FormatInt(txResult.Transfer.ID, 10), audit.OutcomeSuccess, "")
+
+	c.JSON(http.StatusOK, present(c).transfer(txResult))
 }
 
-func (s *Server) validateAccount(c *gin.Context, accountId int64, currency string) (db.Account, bool) {
-	account, err := s.store.GetAccount(c, accountId)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			c.JSON(http.StatusNotFound, errorResponse(err))
-			return account, false
-		}
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
-		return account, false
+// rejectTransfer aborts a transfer that passed request validation and keeps a
+// trace of it: refusals (forbidden, missing second factor) are recorded as
+// denied, anything else as failed.
+func (s *Server) rejectTransfer(c *gin.Context, request transferRequest, err error) {
+	apiErr := toAPIError(err)
+	outcome := audit.OutcomeFailure
+	if apiErr.Status == http.StatusForbidden {
+		outcome = audit.OutcomeDenied
 	}
+	s.recordTransfer(c, request, "", outcome, apiErr.Code)
 
-	if account.Currency != currency {
-		c.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("account [%d] currency mismatched. expected: %s, actual: %s", accountId, currency, account.Currency)))
-		return account, false
+	abortWithError(c, err)
+}
+
+func (s *Server) recordTransfer(c *gin.Context, request transferRequest, transferID string, outcome audit.Outcome, reason string) {
+	diff := map[string]any{
+		"from_account_id": request.FromAccountID,
+		"to_account_id":   request.ToAccountID,
+		"amount":          request.Amount,
+		"currency":        request.Currency,
+	}
+	if reason != "" {
+		diff["reason"] = reason
 	}
 
-	return account, true
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionTransferCreate,
+		TargetType: auditTargetTransfer,
+		TargetID:   transferID,
+		Outcome:    outcome,
+		Diff:       diff,
+	})
 }
@@ -2,8 +2,8 @@ package api
 
 import (
 	"bytes"
-	"database/sql"
 	"encoding/json"
+	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	"net/http"
@@ -20,6 +20,7 @@ func TestServer_createTransfer(t *testing.T) {
 	user, _ := randomUser(t)
 	account1 := randomAccount(user.Username)
 	account2 := randomAccount(user.Username)
+	user.EmailVerifiedAt = time.Now()
 
 	testCases := []struct {
 		name          string
@@ -165,6 +166,46 @@ func TestServer_createTransfer(t *testing.T) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
 			},
 		},
+		{
+			name: "frozen_source_account",
+			createBody: func() transferRequest {
+				return transferRequest{
+					FromAccountID: account1.ID,
+					ToAccountID:   account2.ID,
+					Amount:        10,
+					Currency:      utils.CurrencyUSD,
+				}
+			},
+			setupAuth: func(t *testing.T, request *http.Request, tokensManager tokens2.Manager) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, tokens2.PayloadCreationParams{
+					Subject:   user.Username,
+					Audience:  "test",
+					Issuer:    "test",
+					NotBefore: time.Now(),
+					Duration:  time.Minute,
+				})
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				frozen := account1
+				frozen.Currency = utils.CurrencyUSD
+				frozen.FrozenAt = time.Now()
+
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).
+					Times(1).
+					Return(frozen, nil)
+				store.EXPECT().
+					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).
+					Times(0)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				requireProblemCode(t, recorder, errAccountFrozen)
+			},
+		},
 		{
 			name: "unexistent_source_account",
 			createBody: func() transferRequest {
@@ -188,7 +229,7 @@ func TestServer_createTransfer(t *testing.T) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account1.ID)).
 					Times(1).
-					Return(db.Account{}, sql.ErrNoRows)
+					Return(db.Account{}, pgx.ErrNoRows)
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).
 					Times(0)
@@ -229,7 +270,7 @@ func TestServer_createTransfer(t *testing.T) {
 				store.EXPECT().
 					GetAccount(gomock.Any(), gomock.Eq(account2.ID)).
 					Times(1).
-					Return(db.Account{}, sql.ErrNoRows)
+					Return(db.Account{}, pgx.ErrNoRows)
 				store.EXPECT().
 					TransferTx(gomock.Any(), gomock.Any()).
 					Times(0)
@@ -391,6 +432,7 @@ func TestServer_createTransfer(t *testing.T) {
 			defer ctrl.Finish()
 
 			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, user)
 			tc.buildStubs(store)
 
 			testContainer := newTestContainer(t, store)
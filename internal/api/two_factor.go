@@ -0,0 +1,185 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"net/http"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	"simple-bank/internal/security"
+	"simple-bank/internal/service"
+	"time"
+)
+
+const (
+	totpIssuer         = "simple-bank"
+	recoveryCodesCount = 10
+)
+
+type enrollTOTPResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+func (s *Server) enrollTOTP(c *gin.Context) {
+	authPayload := getPayloadFromGinCtx(c)
+
+	enabled, err := s.bank.TwoFactorEnabled(c, authPayload.Subject)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if enabled {
+		abortWithError(c, errTwoFactorAlreadyEnabled)
+		return
+	}
+
+	key, err := security.GenerateTOTP(totpIssuer, authPayload.Subject)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	_, err = s.store.UpsertUserTOTP(c, db.UpsertUserTOTPParams{
+		Username: authPayload.Subject,
+		Secret:   key.Secret,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollTOTPResponse{
+		Secret:          key.Secret,
+		ProvisioningURI: key.ProvisioningURI,
+	})
+}
+
+type confirmTOTPRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type confirmTOTPResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (s *Server) confirmTOTP(c *gin.Context) {
+	var request confirmTOTPRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	authPayload := getPayloadFromGinCtx(c)
+
+	userTOTP, err := s.store.GetUserTOTP(c, authPayload.Subject)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			abortWithError(c, errNotFound.withDetail("two-factor authentication is not enrolled"))
+			return
+		}
+		abortWithError(c, err)
+		return
+	}
+	if !userTOTP.ConfirmedAt.IsZero() {
+		abortWithError(c, errTwoFactorAlreadyEnabled)
+		return
+	}
+
+	step, err := security.ValidateTOTP(request.Code, userTOTP.Secret, time.Now(), userTOTP.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, security.ErrTOTPCodeInvalid) {
+			abortWithError(c, errTwoFactorCodeInvalid)
+			return
+		}
+		abortWithError(c, err)
+		return
+	}
+
+	recoveryCodes, err := security.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	hashedRecoveryCodes, err := s.bank.HashRecoveryCodes(recoveryCodes)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	_, err = s.store.ConfirmTOTPTx(c, db.ConfirmTOTPTxParams{
+		Username:      authPayload.Subject,
+		Step:          step,
+		RecoveryCodes: hashedRecoveryCodes,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionUserTOTPEnable,
+		TargetType: auditTargetUser,
+		TargetID:   authPayload.Subject,
+		Outcome:    audit.OutcomeSuccess,
+	})
+
+	c.JSON(http.StatusOK, confirmTOTPResponse{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+type loginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode   string `json:"recovery_code" binding:"required_without=Code"`
+}
+
+type loginChallengeResponse struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+func (s *Server) loginTwoFactor(c *gin.Context) {
+	var req loginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	user, err := s.bank.VerifyLoginChallenge(c, req.ChallengeToken)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	err = s.bank.LoginSecondFactor(c, service.LoginSecondFactorParams{
+		User:         user,
+		Code:         req.Code,
+		RecoveryCode: req.RecoveryCode,
+		ClientIP:     c.ClientIP(),
+	})
+	if err != nil {
+		s.failLogin(c, user.Username, err)
+		return
+	}
+
+	s.completeLogin(c, user)
+}
+
+func (s *Server) issueLoginChallenge(c *gin.Context, user db.User) {
+	challengeToken, expiresAt, err := s.bank.IssueLoginChallenge(user)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, loginChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    challengeToken,
+		ExpiresAt:         expiresAt,
+	})
+}
@@ -0,0 +1,649 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/security"
+	tokens2 "simple-bank/internal/tokens"
+	"simple-bank/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestServer_enrollTOTP(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "success",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().
+					UpsertUserTOTP(gomock.Any(), gomock.Cond[db.UpsertUserTOTPParams](func(x db.UpsertUserTOTPParams) bool {
+						return x.Username == user.Username && x.Secret != ""
+					})).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var result enrollTOTPResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+				require.NotEmpty(t, result.Secret)
+				require.Contains(t, result.ProvisioningURI, result.Secret)
+			},
+		},
+		{
+			name: "already_enabled",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{Username: user.Username, ConfirmedAt: time.Now()}, nil)
+				store.EXPECT().
+					UpsertUserTOTP(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTwoFactorAlreadyEnabled)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/me/totp", nil)
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestServer_confirmTOTP(t *testing.T) {
+	user, _ := randomUser(t)
+	userTOTP := randomUserTOTP(t, user.Username)
+	userTOTP.ConfirmedAt = time.Time{}
+
+	testCases := []struct {
+		name          string
+		code          func() string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "success",
+			code: func() string {
+				return currentTOTPCode(t, userTOTP.Secret)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTOTP, nil)
+				store.EXPECT().
+					ConfirmTOTPTx(gomock.Any(), gomock.Cond[db.ConfirmTOTPTxParams](func(x db.ConfirmTOTPTxParams) bool {
+						if x.Username != user.Username || x.Step <= 0 || len(x.RecoveryCodes) != recoveryCodesCount {
+							return false
+						}
+						lookups := make(map[string]bool)
+						for _, code := range x.RecoveryCodes {
+							lookups[code.Lookup] = code.HashedCode != ""
+						}
+						return len(lookups) == recoveryCodesCount && !lookups[""]
+					})).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var result confirmTOTPResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+				require.Len(t, result.RecoveryCodes, recoveryCodesCount)
+			},
+		},
+		{
+			name: "invalid_code",
+			code: func() string {
+				return otherTOTPCode(t, userTOTP.Secret)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTOTP, nil)
+				store.EXPECT().
+					ConfirmTOTPTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTwoFactorCodeInvalid)
+			},
+		},
+		{
+			name: "not_enrolled",
+			code: func() string {
+				return "123456"
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errNotFound)
+			},
+		},
+		{
+			name: "malformed_code",
+			code: func() string {
+				return "12345a"
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			rawBody, err := json.Marshal(confirmTOTPRequest{Code: tc.code()})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/me/totp/confirm", bytes.NewReader(rawBody))
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestServer_loginTwoFactor(t *testing.T) {
+	user, _ := randomUser(t)
+	userTOTP := randomUserTOTP(t, user.Username)
+
+	recoveryCode := "abcde-fghjk"
+	hashedRecoveryCode, err := security.HashPassword(recoveryCode, security.DefaultPasswordParams)
+	require.NoError(t, err)
+
+	recoveryCodeMatcher := gomock.Cond[db.GetUnusedRecoveryCodeParams](func(x db.GetUnusedRecoveryCodeParams) bool {
+		return x.Username == user.Username && x.Lookup != ""
+	})
+
+	testCases := []struct {
+		name          string
+		createBody    func(challengeToken string) loginTwoFactorRequest
+		challenge     tokens2.PayloadCreationParams
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "success_with_totp_code",
+			createBody: func(challengeToken string) loginTwoFactorRequest {
+				return loginTwoFactorRequest{ChallengeToken: challengeToken, Code: currentTOTPCode(t, userTOTP.Secret)}
+			},
+			challenge: challengeTokenParams(user.Username),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTOTP, nil)
+				store.EXPECT().
+					UseTOTPStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(1), nil)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, true)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var result loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+				require.NotEmpty(t, result.AccessToken)
+				require.Equal(t, newUserResponse(user), result.User)
+			},
+		},
+		{
+			name: "success_with_recovery_code",
+			createBody: func(challengeToken string) loginTwoFactorRequest {
+				return loginTwoFactorRequest{ChallengeToken: challengeToken, RecoveryCode: "ABCDEFGHJK"}
+			},
+			challenge: challengeTokenParams(user.Username),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUnusedRecoveryCode(gomock.Any(), recoveryCodeMatcher).
+					Times(1).
+					Return(db.RecoveryCode{ID: 7, Username: user.Username, HashedCode: hashedRecoveryCode, Lookup: "lookup"}, nil)
+				store.EXPECT().
+					ListUnusedRecoveryCodes(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Eq(int64(7))).
+					Times(1).
+					Return(int64(1), nil)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, true)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "success_with_legacy_recovery_code",
+			createBody: func(challengeToken string) loginTwoFactorRequest {
+				return loginTwoFactorRequest{ChallengeToken: challengeToken, RecoveryCode: "ABCDEFGHJK"}
+			},
+			challenge: challengeTokenParams(user.Username),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUnusedRecoveryCode(gomock.Any(), recoveryCodeMatcher).
+					Times(1).
+					Return(db.RecoveryCode{}, pgx.ErrNoRows)
+				store.EXPECT().
+					ListUnusedRecoveryCodes(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return([]db.RecoveryCode{{ID: 7, Username: user.Username, HashedCode: hashedRecoveryCode}}, nil)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Eq(int64(7))).
+					Times(1).
+					Return(int64(1), nil)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, true)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "unknown_recovery_code",
+			createBody: func(challengeToken string) loginTwoFactorRequest {
+				return loginTwoFactorRequest{ChallengeToken: challengeToken, RecoveryCode: "ABCDEFGHJM"}
+			},
+			challenge: challengeTokenParams(user.Username),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUnusedRecoveryCode(gomock.Any(), recoveryCodeMatcher).
+					Times(1).
+					Return(db.RecoveryCode{}, pgx.ErrNoRows)
+				// codes with a lookup were already ruled out, none is hashed again
+				store.EXPECT().
+					ListUnusedRecoveryCodes(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return([]db.RecoveryCode{{ID: 7, Username: user.Username, HashedCode: "not-a-hash", Lookup: "lookup"}}, nil)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					RegisterFailedLogin(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, false)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTwoFactorCodeInvalid)
+			},
+		},
+		{
+			name: "replayed_code",
+			createBody: func(challengeToken string) loginTwoFactorRequest {
+				return loginTwoFactorRequest{ChallengeToken: challengeToken, Code: currentTOTPCode(t, userTOTP.Secret)}
+			},
+			challenge: challengeTokenParams(user.Username),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTOTP, nil)
+				store.EXPECT().
+					UseTOTPStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(0), nil)
+				store.EXPECT().
+					RegisterFailedLogin(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, false)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTwoFactorCodeInvalid)
+			},
+		},
+		{
+			name: "access_token_as_challenge",
+			createBody: func(challengeToken string) loginTwoFactorRequest {
+				return loginTwoFactorRequest{ChallengeToken: challengeToken, Code: "123456"}
+			},
+			challenge: accessTokenParams(user.Username),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTokenInvalid)
+			},
+		},
+		{
+			name: "missing_code",
+			createBody: func(challengeToken string) loginTwoFactorRequest {
+				return loginTwoFactorRequest{ChallengeToken: challengeToken}
+			},
+			challenge: challengeTokenParams(user.Username),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+				challengeToken, err := tokensManager.CreateToken(tc.challenge)
+				require.NoError(t, err)
+
+				rawBody, err := json.Marshal(tc.createBody(challengeToken))
+				require.NoError(t, err)
+
+				request, err := http.NewRequest(http.MethodPost, "/users/login/2fa", bytes.NewReader(rawBody))
+				require.NoError(t, err)
+
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestServer_createTransferStepUp(t *testing.T) {
+	user, _ := randomUser(t)
+	userTOTP := randomUserTOTP(t, user.Username)
+	account1 := randomAccount(user.Username)
+	account2 := randomAccount(user.Username)
+	account1.Currency = utils.CurrencyUSD
+	account2.Currency = utils.CurrencyUSD
+	user.EmailVerifiedAt = time.Now()
+
+	testCases := []struct {
+		name          string
+		code          func() string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "success",
+			code: func() string {
+				return currentTOTPCode(t, userTOTP.Secret)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTOTP, nil)
+				store.EXPECT().
+					UseTOTPStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(1), nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "missing_code",
+			code: func() string {
+				return ""
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTOTP, nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTwoFactorRequired)
+			},
+		},
+		{
+			name: "two_factor_not_enabled",
+			code: func() string {
+				return "123456"
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTwoFactorRequired)
+			},
+		},
+		{
+			name: "invalid_code",
+			code: func() string {
+				return otherTOTPCode(t, userTOTP.Secret)
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(userTOTP, nil)
+				store.EXPECT().
+					RegisterFailedLogin(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errTwoFactorCodeInvalid)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				GetAccount(gomock.Any(), gomock.Eq(account1.ID)).
+				AnyTimes().
+				Return(account1, nil)
+			store.EXPECT().
+				GetAccount(gomock.Any(), gomock.Eq(account2.ID)).
+				AnyTimes().
+				Return(account2, nil)
+			stubAuthUsers(store, user)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+				rawBody, err := json.Marshal(transferRequest{
+					FromAccountID: account1.ID,
+					ToAccountID:   account2.ID,
+					Amount:        server.config().TransferStepUpThreshold,
+					Currency:      utils.CurrencyUSD,
+					TOTPCode:      tc.code(),
+				})
+				require.NoError(t, err)
+
+				request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(rawBody))
+				require.NoError(t, err)
+
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestAuthMiddleware_rejectsChallengeToken(t *testing.T) {
+	user, _ := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+	store.EXPECT().ListAccounts(gomock.Any(), gomock.Any()).Times(0)
+
+	testContainer := newTestContainer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/accounts?page_id=1&page_size=5", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+		addAuthorization(t, request, tokensManager, authorizationTypeBearer, challengeTokenParams(user.Username))
+		server.engine.ServeHTTP(recorder, request)
+		requireProblemCode(t, recorder, errTokenInvalid)
+	}))
+}
+
+func randomUserTOTP(t *testing.T, username string) db.UserTotp {
+	key, err := security.GenerateTOTP(totpIssuer, username)
+	require.NoError(t, err)
+
+	return db.UserTotp{
+		Username:    username,
+		Secret:      key.Secret,
+		ConfirmedAt: time.Now(),
+		CreatedAt:   time.Now(),
+	}
+}
+
+func currentTOTPCode(t *testing.T, secret string) string {
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	return code
+}
+
+// otherTOTPCode returns a well-formed code that is outside the accepted
+// window of secret.
+func otherTOTPCode(t *testing.T, secret string) string {
+	code, err := totp.GenerateCode(secret, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	if code == currentTOTPCode(t, secret) {
+		t.Skip("codes collided")
+	}
+	return code
+}
+
+func accessTokenParams(username string) tokens2.PayloadCreationParams {
+	return tokens2.PayloadCreationParams{
+		Subject:   username,
+		Audience:  accessTokenAudience,
+		Issuer:    tokenIssuer,
+		NotBefore: time.Now(),
+		Duration:  time.Minute,
+	}
+}
+
+func challengeTokenParams(username string) tokens2.PayloadCreationParams {
+	params := accessTokenParams(username)
+	params.Audience = twoFactorAudience
+	return params
+}
@@ -1,18 +1,22 @@
 package api
 
 import (
-	"database/sql"
 	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
-	"log"
 	"net/http"
+	"simple-bank/internal/audit"
 	"simple-bank/internal/db"
-	"simple-bank/internal/security"
+	"simple-bank/internal/service"
 	"simple-bank/internal/tokens"
 	"time"
 )
 
+const (
+	tokenIssuer         = tokens.Issuer
+	accessTokenAudience = tokens.AudienceAccess
+	twoFactorAudience   = tokens.AudienceTwoFactor
+)
+
 type createUserRequest struct {
 	Username string `json:"username" binding:"required,alphanum"`
 	Password string `json:"password" binding:"required,min=6"`
@@ -24,6 +28,7 @@ type userResponse struct {
 	Username          string    `json:"username"`
 	FullName          string    `json:"full_name"`
 	Email             string    `json:"email"`
+	EmailVerified     bool      `json:"email_verified"`
 	PasswordChangedAt time.Time `json:"password_changed_at"`
 	CreatedAt         time.Time `json:"created_at"`
 }
@@ -33,6 +38,7 @@ func newUserResponse(user db.User) userResponse {
 		Username:          user.Username,
 		FullName:          user.FullName,
 		Email:             user.Email,
+		EmailVerified:     !user.EmailVerifiedAt.IsZero(),
 		PasswordChangedAt: user.PasswordChangedAt,
 		CreatedAt:         user.CreatedAt,
 	}
@@ -41,40 +47,34 @@ func newUserResponse(user db.User) userResponse {
 func (s *Server) createUser(c *gin.Context) {
 	var request createUserRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(c, bindingError(err))
 		return
 	}
 
-	hashedPassword, err := security.HashPassword(request.Password)
+	user, err := s.bank.CreateUser(c, service.CreateUserParams{
+		Username: request.Username,
+		Password: request.Password,
+		FullName: request.FullName,
+		Email:    request.Email,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(c, err)
 		return
 	}
 
-	arg := db.CreateUserParams{
-		Username:       request.Username,
-		HashedPassword: hashedPassword,
-		FullName:       request.FullName,
-		Email:          request.Email,
-	}
-
-	user, err := s.store.CreateUser(c, arg)
-	if err != nil {
-		var pqErr *pq.Error
-		if errors.As(err, &pqErr) {
-			log.Println(pqErr.Code.Name(), pqErr.Message)
-
-			switch pqErr.Code.Name() {
-			case "unique_violation":
-				c.JSON(http.StatusForbidden, errorResponse(err))
-			}
-		} else {
-			c.JSON(http.StatusInternalServerError, errorResponse(err))
-		}
-		return
-	}
+	s.recordAudit(c, audit.Event{
+		Actor:      user.Username,
+		Action:     auditActionUserCreate,
+		TargetType: auditTargetUser,
+		TargetID:   user.Username,
+		Outcome:    audit.OutcomeSuccess,
+		Diff: map[string]any{
+			"full_name": user.FullName,
+			"email":     user.Email,
+		},
+	})
 
-	c.JSON(http.StatusOK, newUserResponse(user))
+	c.JSON(http.StatusOK, present(c).user(user))
 }
 
 type loginUserRequest struct {
@@ -90,44 +90,75 @@ type loginUserResponse struct {
 func (s *Server) loginUser(c *gin.Context) {
 	var req loginUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse(err))
+		abortWithError(c, bindingError(err))
 		return
 	}
 
-	user, err := s.store.GetUser(c.Request.Context(), req.Username)
+	result, err := s.bank.Login(c, service.LoginParams{
+		Username: req.Username,
+		Password: req.Password,
+		ClientIP: c.ClientIP(),
+	})
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			c.JSON(http.StatusNotFound, errorResponse(err))
-			return
-		}
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		s.failLogin(c, req.Username, err)
 		return
 	}
 
-	err = security.ComparePasswordAndHash(req.Password, user.HashedPassword)
-	if err != nil {
-		if errors.Is(err, security.ErrPasswordNotMatched) {
-			c.JSON(http.StatusUnauthorized, errorResponse(err))
-		} else {
-			c.JSON(http.StatusInternalServerError, errorResponse(err))
-		}
+	if result.TwoFactorRequired {
+		s.issueLoginChallenge(c, result.User)
 		return
 	}
 
-	accessToken, err := s.tokensManager.CreateToken(tokens.PayloadCreationParams{
-		Subject:   user.Username,
-		Audience:  "bank-service",
-		Issuer:    "bank-service",
-		NotBefore: time.Now(),
-		Duration:  s.config.AccessTokenDuration,
+	s.completeLogin(c, result.User)
+}
+
+// completeLogin is the last step of a login, once every factor has been
+// verified.
+func (s *Server) completeLogin(c *gin.Context, user db.User) {
+	if err := s.bank.CompleteLogin(c, user, c.ClientIP()); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Actor:      user.Username,
+		Action:     auditActionUserLogin,
+		TargetType: auditTargetUser,
+		TargetID:   user.Username,
+		Outcome:    audit.OutcomeSuccess,
 	})
+
+	accessToken, err := s.bank.CreateAccessToken(user.Username)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse(err))
+		abortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, loginUserResponse{
-		AccessToken: accessToken,
-		User:        newUserResponse(user),
+	c.JSON(http.StatusOK, present(c).login(accessToken, user))
+}
+
+// failLogin answers a rejected credential check. Wrong credentials and
+// locked users end up in the audit log, the attempt itself is already
+// recorded by the service. Both get the same answer, a locked user is only
+// told by email.
+func (s *Server) failLogin(c *gin.Context, username string, err error) {
+	apiErr := toAPIError(err)
+	if !apiErr.Is(errInvalidCredentials) && !apiErr.Is(errTwoFactorCodeInvalid) {
+		abortWithError(c, err)
+		return
+	}
+
+	outcome, reason := audit.OutcomeFailure, apiErr.Code
+	if errors.Is(err, service.ErrAccountLocked) {
+		outcome, reason = audit.OutcomeDenied, auditReasonAccountLocked
+	}
+	s.recordAudit(c, audit.Event{
+		Actor:      username,
+		Action:     auditActionUserLogin,
+		TargetType: auditTargetUser,
+		TargetID:   username,
+		Outcome:    outcome,
+		Diff:       map[string]any{"reason": reason},
 	})
+	abortWithError(c, apiErr)
 }
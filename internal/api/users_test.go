@@ -5,7 +5,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	"net/http"
@@ -42,6 +43,17 @@ func TestServer_createUser(t *testing.T) {
 					CreateUser(gomock.Any(), getCreateUserParamMatcher(user, password)).
 					Times(1).
 					Return(user, nil)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Eq(db.RevokeUserTokensParams{
+						Username: user.Username,
+						Purpose:  db.UserTokenPurposeEmailVerification,
+					})).
+					Times(1)
+				store.EXPECT().
+					CreateUserToken(gomock.Any(), gomock.Cond[db.CreateUserTokenParams](func(x db.CreateUserTokenParams) bool {
+						return x.Username == user.Username && x.Purpose == db.UserTokenPurposeEmailVerification && x.HashedToken != ""
+					})).
+					Times(1)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -50,6 +62,7 @@ func TestServer_createUser(t *testing.T) {
 				require.Equal(t, user.Username, result.Username)
 				require.Equal(t, user.FullName, result.FullName)
 				require.Equal(t, user.Email, result.Email)
+				require.False(t, result.EmailVerified)
 			},
 		},
 		{
@@ -66,7 +79,7 @@ func TestServer_createUser(t *testing.T) {
 				store.EXPECT().
 					CreateUser(gomock.Any(), getCreateUserParamMatcher(user, password)).
 					Times(1).
-					Return(db.User{}, &pq.Error{Code: "23505"})
+					Return(db.User{}, &pgconn.PgError{Code: "23505"})
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusForbidden, recorder.Code)
@@ -149,6 +162,25 @@ func TestServer_createUser(t *testing.T) {
 				require.Equal(t, http.StatusBadRequest, recorder.Code)
 			},
 		},
+		{
+			name: "breached_password",
+			createBody: func() createUserRequest {
+				return createUserRequest{
+					Username: user.Username,
+					Password: testBreachedPassword,
+					FullName: user.FullName,
+					Email:    user.Email,
+				}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errWeakPassword)
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -181,6 +213,14 @@ func TestServer_createUser(t *testing.T) {
 func TestServer_loginUser(t *testing.T) {
 	user, password := randomUser(t)
 
+	failedUser := user
+	failedUser.FailedLoginAttempts = 2
+
+	outdatedHash, err := security.HashPassword(password, security.PasswordParams{Memory: 8 * 1024, Iterations: 1, Parallelism: 1})
+	require.NoError(t, err)
+	outdatedUser := user
+	outdatedUser.HashedPassword = outdatedHash
+
 	testCases := []struct {
 		name          string
 		body          loginUserRequest
@@ -198,6 +238,16 @@ func TestServer_loginUser(t *testing.T) {
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
 					Return(user, nil)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().
+					ResetFailedLogins(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, true)).
+					Times(1)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -209,7 +259,124 @@ func TestServer_loginUser(t *testing.T) {
 				require.Equal(t, user.Username, payload.Subject)
 				require.WithinDuration(t, time.Now(), payload.IssuedAt, time.Second)
 				require.WithinDuration(t, time.Now(), payload.NotBefore, time.Second)
-				require.WithinDuration(t, time.Now().Add(server.config.AccessTokenDuration), payload.ExpiredAt, time.Second)
+				require.WithinDuration(t, time.Now().Add(server.config().AccessTokenDuration), payload.ExpiredAt, time.Second)
+			},
+		},
+		{
+			name: "success_resets_failed_attempts",
+			body: loginUserRequest{
+				Username: user.Username,
+				Password: password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(failedUser, nil)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().
+					ResetFailedLogins(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, true)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "success_upgrades_outdated_hash",
+			body: loginUserRequest{
+				Username: user.Username,
+				Password: password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(outdatedUser, nil)
+				store.EXPECT().
+					UpgradeUserPasswordHash(gomock.Any(), gomock.Cond[db.UpgradeUserPasswordHashParams](func(x db.UpgradeUserPasswordHashParams) bool {
+						return x.Username == user.Username &&
+							x.OldHashedPassword == outdatedHash &&
+							x.NewHashedPassword != outdatedHash &&
+							passwordMatches(password, x.NewHashedPassword)
+					})).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, true)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "hash_upgrade_failure_does_not_fail_login",
+			body: loginUserRequest{
+				Username: user.Username,
+				Password: password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(outdatedUser, nil)
+				store.EXPECT().
+					UpgradeUserPasswordHash(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(sql.ErrConnDone)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, true)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "two_factor_challenge",
+			body: loginUserRequest{
+				Username: user.Username,
+				Password: password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserTotp{Username: user.Username, ConfirmedAt: time.Now()}, nil)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var result loginChallengeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+				require.True(t, result.TwoFactorRequired)
+				payload, err := server.tokensManager.VerifyToken(result.ChallengeToken)
+				require.NoError(t, err)
+				require.Equal(t, user.Username, payload.Subject)
+				require.Equal(t, twoFactorAudience, payload.Audience)
+				require.WithinDuration(t, time.Now().Add(server.config().TwoFactorChallengeDuration), payload.ExpiredAt, time.Second)
 			},
 		},
 		{
@@ -222,10 +389,16 @@ func TestServer_loginUser(t *testing.T) {
 				store.EXPECT().
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
-					Return(db.User{}, sql.ErrNoRows)
+					Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().
+					RegisterFailedLogin(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, false)).
+					Times(1)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+				requireProblemCode(t, recorder, errInvalidCredentials)
 			},
 		},
 		{
@@ -235,13 +408,90 @@ func TestServer_loginUser(t *testing.T) {
 				Password: "invalid",
 			},
 			buildStubs: func(store *mockdb.MockStore) {
+				registered := user
+				registered.FailedLoginAttempts = 1
+
 				store.EXPECT().
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
 					Return(user, nil)
+				store.EXPECT().
+					RegisterFailedLogin(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(registered, nil)
+				store.EXPECT().
+					LockUser(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, false)).
+					Times(1)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
-				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				requireProblemCode(t, recorder, errInvalidCredentials)
+			},
+		},
+		{
+			name: "invalid_password_locks_user",
+			body: loginUserRequest{
+				Username: user.Username,
+				Password: "invalid",
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				registered := user
+				registered.FailedLoginAttempts = 6
+
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RegisterFailedLogin(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(registered, nil)
+				store.EXPECT().
+					LockUser(gomock.Any(), gomock.Cond[db.LockUserParams](func(x db.LockUserParams) bool {
+						expected := time.Now().Add(2 * time.Minute)
+						return x.Username == user.Username && x.LockedUntil.Sub(expected).Abs() < time.Second
+					})).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, false)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				requireProblemCode(t, recorder, errInvalidCredentials)
+
+				messages := outboxMessages(t, server.mailer)
+				require.Len(t, messages, 1)
+				require.Equal(t, user.Email, messages[0].To)
+			},
+		},
+		{
+			name: "locked_user",
+			body: loginUserRequest{
+				Username: user.Username,
+				Password: password,
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				// the lock starts with the case, the earlier ones take a while
+				lockedUser := user
+				lockedUser.LockedUntil = time.Now().Add(time.Minute)
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(lockedUser, nil)
+				store.EXPECT().
+					RegisterFailedLogin(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), getLoginAttemptMatcher(user.Username, false)).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				// the same answer as a wrong password, the lock isn't told
+				requireProblemCode(t, recorder, errInvalidCredentials)
+				require.Empty(t, recorder.Header().Get(retryAfterHeader))
 			},
 		},
 		{
@@ -255,6 +505,9 @@ func TestServer_loginUser(t *testing.T) {
 					GetUser(gomock.Any(), gomock.Eq(user.Username)).
 					Times(1).
 					Return(db.User{}, errors.New("internal server error"))
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), gomock.Any()).
+					Times(0)
 			},
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
@@ -286,7 +539,7 @@ func TestServer_loginUser(t *testing.T) {
 func randomUser(t *testing.T) (db.User, string) {
 	password := random2.String(6)
 
-	hashedPassword, err := security.HashPassword(password)
+	hashedPassword, err := security.HashPassword(password, security.DefaultPasswordParams)
 	require.NoError(t, err)
 
 	return db.User{
@@ -297,13 +550,18 @@ func randomUser(t *testing.T) (db.User, string) {
 	}, password
 }
 
+func passwordMatches(password, hash string) bool {
+	_, err := security.ComparePasswordAndHash(password, hash, security.DefaultPasswordParams)
+	return err == nil
+}
+
 func getCreateUserParamMatcher(user db.User, rawPassword string) gomock.Matcher {
 	return gomock.Cond[db.CreateUserParams](func(x db.CreateUserParams) bool {
 		if x.Username != user.Username {
 			return false
 		}
 
-		if err := security.ComparePasswordAndHash(rawPassword, x.HashedPassword); err != nil {
+		if !passwordMatches(rawPassword, x.HashedPassword) {
 			return false
 		}
 
@@ -318,3 +576,9 @@ func getCreateUserParamMatcher(user db.User, rawPassword string) gomock.Matcher
 		return true
 	})
 }
+
+func getLoginAttemptMatcher(username string, succeeded bool) gomock.Matcher {
+	return gomock.Cond[db.CreateLoginAttemptParams](func(x db.CreateLoginAttemptParams) bool {
+		return x.Username == username && x.Succeeded == succeeded
+	})
+}
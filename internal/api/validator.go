@@ -2,7 +2,9 @@ package api
 
 import (
 	"github.com/go-playground/validator/v10"
+	"simple-bank/internal/security"
 	"simple-bank/internal/utils"
+	"slices"
 )
 
 var validateCurrency validator.Func = func(fl validator.FieldLevel) bool {
@@ -12,3 +14,21 @@ var validateCurrency validator.Func = func(fl validator.FieldLevel) bool {
 
 	return false
 }
+
+var validateScope validator.Func = func(fl validator.FieldLevel) bool {
+	if scope, ok := fl.Field().Interface().(string); ok {
+		return slices.Contains(grantableScopes, scope)
+	}
+
+	return false
+}
+
+// validateWebhookURL keeps webhooks off plain http and internal hosts, the
+// sender refuses internal addresses again when it connects.
+var validateWebhookURL validator.Func = func(fl validator.FieldLevel) bool {
+	if url, ok := fl.Field().Interface().(string); ok {
+		return security.ValidOutboundURL(url)
+	}
+
+	return false
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"net/http"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	"simple-bank/internal/security"
+	"time"
+)
+
+type verifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+func (s *Server) verifyEmail(c *gin.Context) {
+	var request verifyEmailRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	userToken, ok := s.lookupUserToken(c, request.Token, db.UserTokenPurposeEmailVerification)
+	if !ok {
+		return
+	}
+
+	user, err := s.store.VerifyEmailTx(c, db.VerifyEmailTxParams{
+		TokenID:  userToken.ID,
+		Username: userToken.Username,
+	})
+	if err != nil {
+		abortWithError(c, userTokenError(err))
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Actor:      user.Username,
+		Action:     auditActionUserVerifyEmail,
+		TargetType: auditTargetUser,
+		TargetID:   user.Username,
+		Outcome:    audit.OutcomeSuccess,
+		Diff:       map[string]any{"email": user.Email},
+	})
+
+	c.JSON(http.StatusOK, present(c).user(user))
+}
+
+func (s *Server) resendEmailVerification(c *gin.Context) {
+	user := getUserFromGinCtx(c)
+	if !user.EmailVerifiedAt.IsZero() {
+		abortWithError(c, errForbidden.withDetail("email address is already verified"))
+		return
+	}
+
+	if err := s.bank.SendEmailVerification(c, user); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+type requestPasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// requestPasswordReset answers the same way whether the email is known or
+// not, so it can't be used to find out who has an account.
+func (s *Server) requestPasswordReset(c *gin.Context) {
+	var request requestPasswordResetRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	user, err := s.store.GetUserByEmail(c, request.Email)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			abortWithError(c, err)
+			return
+		}
+		c.Status(http.StatusAccepted)
+		return
+	}
+
+	if err := s.bank.SendPasswordReset(c, user); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+type confirmPasswordResetRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+func (s *Server) confirmPasswordReset(c *gin.Context) {
+	var request confirmPasswordResetRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	userToken, ok := s.lookupUserToken(c, request.Token, db.UserTokenPurposePasswordReset)
+	if !ok {
+		return
+	}
+
+	if err := s.bank.CheckPasswordPolicy(request.NewPassword); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	hashedPassword, err := security.HashPassword(request.NewPassword, s.bank.PasswordParams())
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	user, err := s.store.ResetPasswordTx(c, db.ResetPasswordTxParams{
+		TokenID:        userToken.ID,
+		Username:       userToken.Username,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		abortWithError(c, userTokenError(err))
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Actor:      user.Username,
+		Action:     auditActionUserPasswordReset,
+		TargetType: auditTargetUser,
+		TargetID:   user.Username,
+		Outcome:    audit.OutcomeSuccess,
+	})
+
+	c.JSON(http.StatusOK, present(c).user(user))
+}
+
+// lookupUserToken finds an unused and unexpired token. The token is only
+// consumed later, inside the transaction that acts on it.
+func (s *Server) lookupUserToken(c *gin.Context, token, purpose string) (db.UserToken, bool) {
+	userToken, err := s.store.GetUserToken(c, db.GetUserTokenParams{
+		HashedToken: security.HashOpaqueToken(token),
+		Purpose:     purpose,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			abortWithError(c, errUserTokenInvalid)
+			return userToken, false
+		}
+		abortWithError(c, err)
+		return userToken, false
+	}
+
+	if !userToken.UsedAt.IsZero() || time.Now().After(userToken.ExpiresAt) {
+		abortWithError(c, errUserTokenInvalid)
+		return userToken, false
+	}
+
+	return userToken, true
+}
+
+func userTokenError(err error) error {
+	if errors.Is(err, db.ErrUserTokenUsed) {
+		return errUserTokenInvalid
+	}
+	return err
+}
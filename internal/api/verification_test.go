@@ -0,0 +1,492 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/mail"
+	"simple-bank/internal/security"
+	tokens2 "simple-bank/internal/tokens"
+	"simple-bank/internal/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_verifyEmail(t *testing.T) {
+	user, _ := randomUser(t)
+	token, userToken := randomUserToken(t, user.Username, db.UserTokenPurposeEmailVerification)
+
+	verifiedUser := user
+	verifiedUser.EmailVerifiedAt = time.Now()
+
+	usedToken := userToken
+	usedToken.UsedAt = time.Now()
+
+	expiredToken := userToken
+	expiredToken.ExpiresAt = time.Now().Add(-time.Minute)
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "success",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserToken(gomock.Any(), gomock.Eq(db.GetUserTokenParams{
+						HashedToken: security.HashOpaqueToken(token),
+						Purpose:     db.UserTokenPurposeEmailVerification,
+					})).
+					Times(1).
+					Return(userToken, nil)
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Eq(db.VerifyEmailTxParams{
+						TokenID:  userToken.ID,
+						Username: user.Username,
+					})).
+					Times(1).
+					Return(verifiedUser, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var result userResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &result))
+				require.True(t, result.EmailVerified)
+			},
+		},
+		{
+			name: "unknown_token",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserToken{}, pgx.ErrNoRows)
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errUserTokenInvalid)
+			},
+		},
+		{
+			name: "used_token",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(usedToken, nil)
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errUserTokenInvalid)
+			},
+		},
+		{
+			name: "expired_token",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(expiredToken, nil)
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errUserTokenInvalid)
+			},
+		},
+		{
+			name: "token_used_concurrently",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(userToken, nil)
+				store.EXPECT().
+					VerifyEmailTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, db.ErrUserTokenUsed)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errUserTokenInvalid)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			rawBody, err := json.Marshal(verifyEmailRequest{Token: token})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/verify-email", bytes.NewReader(rawBody))
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestServer_resendEmailVerification(t *testing.T) {
+	user, _ := randomUser(t)
+
+	verifiedUser := user
+	verifiedUser.EmailVerifiedAt = time.Now()
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message)
+	}{
+		{
+			name: "success",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Eq(db.RevokeUserTokensParams{
+						Username: user.Username,
+						Purpose:  db.UserTokenPurposeEmailVerification,
+					})).
+					Times(1)
+				store.EXPECT().
+					CreateUserToken(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Len(t, outbox, 1)
+				require.Equal(t, user.Email, outbox[0].To)
+				require.Contains(t, outbox[0].Body, "http://localhost:8080/verify-email?token=")
+			},
+		},
+		{
+			name: "already_verified",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(verifiedUser, nil)
+				store.EXPECT().
+					CreateUserToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				requireProblemCode(t, recorder, errForbidden)
+				require.Empty(t, outbox)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/me/verify-email", nil)
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server, mailer mail.Mailer) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder, outboxMessages(t, mailer))
+			}))
+		})
+	}
+}
+
+func TestServer_requestPasswordReset(t *testing.T) {
+	user, _ := randomUser(t)
+	var storedToken db.CreateUserTokenParams
+
+	testCases := []struct {
+		name          string
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message)
+	}{
+		{
+			name: "known_email",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), gomock.Eq(db.RevokeUserTokensParams{
+						Username: user.Username,
+						Purpose:  db.UserTokenPurposePasswordReset,
+					})).
+					Times(1)
+				store.EXPECT().
+					CreateUserToken(gomock.Any(), gomock.Cond[db.CreateUserTokenParams](func(x db.CreateUserTokenParams) bool {
+						return x.Username == user.Username &&
+							x.Purpose == db.UserTokenPurposePasswordReset &&
+							time.Until(x.ExpiresAt) > 59*time.Minute
+					})).
+					Times(1).
+					Do(func(_ any, arg db.CreateUserTokenParams) {
+						storedToken = arg
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Len(t, outbox, 1)
+				require.Equal(t, user.Email, outbox[0].To)
+
+				// the emailed token is the one whose hash was stored
+				link := outbox[0].Body[strings.Index(outbox[0].Body, "http://"):]
+				link = strings.Fields(link)[0]
+				parsed, err := url.Parse(link)
+				require.NoError(t, err)
+				require.Equal(t, "/reset-password", parsed.Path)
+				require.Equal(t, storedToken.HashedToken, security.HashOpaqueToken(parsed.Query().Get("token")))
+			},
+		},
+		{
+			name: "unknown_email",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().
+					CreateUserToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				require.Empty(t, outbox)
+			},
+		},
+		{
+			name: "internal_error",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(db.User{}, sql.ErrConnDone)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, outbox []mail.Message) {
+				requireProblemCode(t, recorder, errInternal)
+				require.Empty(t, outbox)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			rawBody, err := json.Marshal(requestPasswordResetRequest{Email: user.Email})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/password-reset", bytes.NewReader(rawBody))
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server, mailer mail.Mailer) {
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder, outboxMessages(t, mailer))
+			}))
+		})
+	}
+}
+
+func TestServer_confirmPasswordReset(t *testing.T) {
+	user, _ := randomUser(t)
+	token, userToken := randomUserToken(t, user.Username, db.UserTokenPurposePasswordReset)
+	newPassword := "new-secret"
+
+	testCases := []struct {
+		name          string
+		body          confirmPasswordResetRequest
+		buildStubs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "success",
+			body: confirmPasswordResetRequest{Token: token, NewPassword: newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserToken(gomock.Any(), gomock.Eq(db.GetUserTokenParams{
+						HashedToken: security.HashOpaqueToken(token),
+						Purpose:     db.UserTokenPurposePasswordReset,
+					})).
+					Times(1).
+					Return(userToken, nil)
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Cond[db.ResetPasswordTxParams](func(x db.ResetPasswordTxParams) bool {
+						return x.TokenID == userToken.ID &&
+							x.Username == user.Username &&
+							passwordMatches(newPassword, x.HashedPassword)
+					})).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "token_of_other_purpose",
+			body: confirmPasswordResetRequest{Token: token, NewPassword: newPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserToken(gomock.Any(), gomock.Eq(db.GetUserTokenParams{
+						HashedToken: security.HashOpaqueToken(token),
+						Purpose:     db.UserTokenPurposePasswordReset,
+					})).
+					Times(1).
+					Return(db.UserToken{}, pgx.ErrNoRows)
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errUserTokenInvalid)
+			},
+		},
+		{
+			name: "breached_password",
+			body: confirmPasswordResetRequest{Token: token, NewPassword: testBreachedPassword},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(userToken, nil)
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errWeakPassword)
+			},
+		},
+		{
+			name: "short_password",
+			body: confirmPasswordResetRequest{Token: token, NewPassword: "short"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetUserToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			rawBody, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/password-reset/confirm", bytes.NewReader(rawBody))
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestServer_createTransferUnverifiedEmail(t *testing.T) {
+	user, _ := randomUser(t)
+	account1 := randomAccount(user.Username)
+	account2 := randomAccount(user.Username)
+	account1.Currency = utils.CurrencyUSD
+	account2.Currency = utils.CurrencyUSD
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+
+	testContainer := newTestContainer(t, store)
+	recorder := httptest.NewRecorder()
+
+	rawBody, err := json.Marshal(transferRequest{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+		Currency:      utils.CurrencyUSD,
+	})
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/transfers", bytes.NewReader(rawBody))
+	require.NoError(t, err)
+
+	require.NoError(t, testContainer.Invoke(func(tokensManager tokens2.Manager, server *Server) {
+		addAuthorization(t, request, tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+		server.engine.ServeHTTP(recorder, request)
+		requireProblemCode(t, recorder, errEmailNotVerified)
+	}))
+}
+
+func randomUserToken(t *testing.T, username, purpose string) (string, db.UserToken) {
+	token, err := security.GenerateOpaqueToken()
+	require.NoError(t, err)
+
+	return token, db.UserToken{
+		ID:          1,
+		Username:    username,
+		Purpose:     purpose,
+		HashedToken: security.HashOpaqueToken(token),
+		ExpiresAt:   time.Now().Add(time.Hour),
+		CreatedAt:   time.Now(),
+	}
+}
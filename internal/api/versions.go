@@ -0,0 +1,130 @@
+package api
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"simple-bank/internal/db"
+	"time"
+)
+
+const (
+	versionKey        = "api_version"
+	deprecationHeader = "Deprecation"
+	sunsetHeader      = "Sunset"
+	linkHeader        = "Link"
+)
+
+// apiVersion is a set of routes served under a common prefix. Every version
+// registers the same routes, what differs is how resources are rendered and,
+// once a request changes shape, the handler registered for it.
+type apiVersion struct {
+	name   string
+	prefix string
+	// presenter renders the versioned resources, see presenter.
+	presenter presenter
+	// operations documents the routes of the version.
+	operations []operation
+	// deprecatedAt and sunsetAt are announced to clients in the headers of
+	// every response, they are zero for a current version.
+	deprecatedAt time.Time
+	sunsetAt     time.Time
+	// successor is the prefix clients should migrate to.
+	successor string
+}
+
+var (
+	apiV1 = apiVersion{
+		name:       "v1",
+		prefix:     "/v1",
+		presenter:  v1Presenter{},
+		operations: v1Operations,
+	}
+
+	// apiUnversioned keeps the paths that predate versioning working while
+	// clients move to /v1, it behaves exactly like v1.
+	apiUnversioned = apiVersion{
+		name:         "v1",
+		prefix:       "",
+		presenter:    v1Presenter{},
+		operations:   v1Operations,
+		deprecatedAt: time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC),
+		sunsetAt:     time.Date(2027, time.April, 18, 0, 0, 0, 0, time.UTC),
+		successor:    apiV1.prefix,
+	}
+)
+
+// apiVersions are the versions served, a route missing from one of them is
+// a bug.
+var apiVersions = []apiVersion{apiV1, apiUnversioned}
+
+func (v apiVersion) deprecated() bool {
+	return !v.deprecatedAt.IsZero()
+}
+
+// versionMiddleware makes the version available to handlers and announces
+// the deprecation of an old version (RFC 9745 and RFC 8594).
+func versionMiddleware(version apiVersion) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(versionKey, version)
+
+		if version.deprecated() {
+			c.Header(deprecationHeader, fmt.Sprintf("@%d", version.deprecatedAt.Unix()))
+			if !version.sunsetAt.IsZero() {
+				c.Header(sunsetHeader, version.sunsetAt.UTC().Format(http.TimeFormat))
+			}
+			if version.successor != "" {
+				path := c.Request.URL.Path[len(version.prefix):]
+				c.Header(linkHeader, fmt.Sprintf(`<%s%s>; rel="successor-version"`, version.successor, path))
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// present returns the presenter of the version the route was requested
+// with.
+func present(c *gin.Context) presenter {
+	return c.MustGet(versionKey).(apiVersion).presenter
+}
+
+// presenter renders the resources clients depend on the shape of. Handlers
+// go through it instead of writing those resources themselves, a version
+// that changes one of them brings its own presenter and DTOs.
+type presenter interface {
+	user(user db.User) any
+	login(accessToken string, user db.User) any
+	account(account db.Account) any
+	accounts(accounts []db.Account) any
+	transfer(result db.TransferTxResult) any
+}
+
+type v1Presenter struct{}
+
+func (v1Presenter) user(user db.User) any {
+	return newUserResponse(user)
+}
+
+func (v1Presenter) login(accessToken string, user db.User) any {
+	return loginUserResponse{
+		AccessToken: accessToken,
+		User:        newUserResponse(user),
+	}
+}
+
+func (v1Presenter) account(account db.Account) any {
+	return newAccountResponse(account)
+}
+
+func (v1Presenter) accounts(accounts []db.Account) any {
+	response := make([]accountResponse, len(accounts))
+	for i, account := range accounts {
+		response[i] = newAccountResponse(account)
+	}
+	return response
+}
+
+func (v1Presenter) transfer(result db.TransferTxResult) any {
+	return newTransferTxResponse(result)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	mockdb "simple-bank/internal/db/mock"
+	tokens2 "simple-bank/internal/tokens"
+	"testing"
+	"time"
+)
+
+func TestServer_Versions(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	testCases := []struct {
+		name          string
+		path          string
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "v1",
+			path: fmt.Sprintf("/v1/accounts/%d", account.ID),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccount(t, recorder.Body, account)
+				require.Empty(t, recorder.Header().Get(deprecationHeader))
+				require.Empty(t, recorder.Header().Get(sunsetHeader))
+			},
+		},
+		{
+			name: "unversioned_alias",
+			path: fmt.Sprintf("/accounts/%d", account.ID),
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchAccount(t, recorder.Body, account)
+				require.Equal(t, fmt.Sprintf("@%d", apiUnversioned.deprecatedAt.Unix()), recorder.Header().Get(deprecationHeader))
+				require.Equal(t, apiUnversioned.sunsetAt.Format(http.TimeFormat), recorder.Header().Get(sunsetHeader))
+				require.Equal(t, fmt.Sprintf(`</v1/accounts/%d>; rel="successor-version"`, account.ID), recorder.Header().Get(linkHeader))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, user)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+
+			require.NoError(t, newTestContainer(t, store).Invoke(func(tokensManager tokens2.Manager, server *Server) {
+				addAuthorization(t, request, tokensManager, authorizationTypeBearer, tokens2.PayloadCreationParams{
+					Subject:   user.Username,
+					Audience:  accessTokenAudience,
+					Issuer:    tokenIssuer,
+					NotBefore: time.Now(),
+					Duration:  time.Minute,
+				})
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestVersionMiddleware_DeprecatedOnErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	require.NoError(t, newTestContainer(t, store).Invoke(func(server *Server) {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/accounts/1", nil)
+		require.NoError(t, err)
+
+		server.engine.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code)
+		require.NotEmpty(t, recorder.Header().Get(deprecationHeader))
+	}))
+}
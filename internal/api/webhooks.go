@@ -0,0 +1,342 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	"net/http"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	"simple-bank/internal/security"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	webhookSecretPrefix             = "whsec_"
+	webhookDeliveriesPageSize int32 = 50
+)
+
+type createWebhookRequest struct {
+	URL string `json:"url" binding:"required,max=2048,webhook_url"`
+	// EventTypes filters the events delivered, all of them are when empty.
+	EventTypes []string `json:"event_types" binding:"max=10,dive,oneof=account.created account.balance_changed transfer.completed"`
+}
+
+type webhookResponse struct {
+	ID                  int64     `json:"id"`
+	URL                 string    `json:"url"`
+	EventTypes          []string  `json:"event_types"`
+	Enabled             bool      `json:"enabled"`
+	ConsecutiveFailures int32     `json:"consecutive_failures"`
+	DisabledAt          time.Time `json:"disabled_at"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+func newWebhookResponse(subscription db.WebhookSubscription) webhookResponse {
+	return webhookResponse{
+		ID:                  subscription.ID,
+		URL:                 subscription.Url,
+		EventTypes:          subscription.EventTypes,
+		Enabled:             subscription.DisabledAt.IsZero(),
+		ConsecutiveFailures: subscription.ConsecutiveFailures,
+		DisabledAt:          subscription.DisabledAt,
+		CreatedAt:           subscription.CreatedAt,
+	}
+}
+
+type createWebhookResponse struct {
+	webhookResponse
+	// Secret signs the deliveries, it's only ever returned here.
+	Secret string `json:"secret"`
+}
+
+func (s *Server) createWebhook(c *gin.Context) {
+	var request createWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+	if request.EventTypes == nil {
+		request.EventTypes = []string{}
+	}
+
+	secret, err := security.GenerateOpaqueToken()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	secret = webhookSecretPrefix + secret
+
+	subscription, err := s.store.CreateWebhookSubscription(c, db.CreateWebhookSubscriptionParams{
+		Owner:      getPayloadFromGinCtx(c).Subject,
+		Url:        request.URL,
+		Secret:     secret,
+		EventTypes: request.EventTypes,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionWebhookCreate,
+		TargetType: auditTargetWebhook,
+		TargetID:   strconv.FormatInt(subscription.ID, 10),
+		Outcome:    audit.OutcomeSuccess,
+		Diff:       map[string]any{"url": subscription.Url, "event_types": subscription.EventTypes},
+	})
+
+	c.JSON(http.StatusCreated, createWebhookResponse{
+		webhookResponse: newWebhookResponse(subscription),
+		Secret:          secret,
+	})
+}
+
+func (s *Server) listWebhooks(c *gin.Context) {
+	subscriptions, err := s.store.ListWebhookSubscriptions(c, getPayloadFromGinCtx(c).Subject)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	response := make([]webhookResponse, len(subscriptions))
+	for i, subscription := range subscriptions {
+		response[i] = newWebhookResponse(subscription)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+type webhookParams struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// ownedWebhook loads the subscription of the route. Subscriptions of other
+// users are reported as missing.
+func (s *Server) ownedWebhook(c *gin.Context) (db.WebhookSubscription, bool) {
+	var params webhookParams
+	if err := c.ShouldBindUri(&params); err != nil {
+		abortWithError(c, bindingError(err))
+		return db.WebhookSubscription{}, false
+	}
+
+	subscription, err := s.store.GetWebhookSubscription(c, params.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return db.WebhookSubscription{}, false
+	}
+	if subscription.Owner != getPayloadFromGinCtx(c).Subject {
+		abortWithError(c, errNotFound)
+		return db.WebhookSubscription{}, false
+	}
+
+	return subscription, true
+}
+
+func (s *Server) getWebhook(c *gin.Context) {
+	subscription, ok := s.ownedWebhook(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newWebhookResponse(subscription))
+}
+
+type updateWebhookRequest struct {
+	URL        *string   `json:"url" binding:"omitempty,max=2048,webhook_url"`
+	EventTypes *[]string `json:"event_types" binding:"omitempty,max=10,dive,oneof=account.created account.balance_changed transfer.completed"`
+	// Enabled re-enables a subscription disabled after repeated failures,
+	// its pending deliveries are then sent again.
+	Enabled *bool `json:"enabled"`
+}
+
+func (s *Server) updateWebhook(c *gin.Context) {
+	var request updateWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	current, ok := s.ownedWebhook(c)
+	if !ok {
+		return
+	}
+
+	arg := db.UpdateWebhookSubscriptionParams{
+		ID:         current.ID,
+		Url:        current.Url,
+		EventTypes: current.EventTypes,
+		DisabledAt: current.DisabledAt,
+	}
+	if request.URL != nil {
+		arg.Url = *request.URL
+	}
+	if request.EventTypes != nil {
+		arg.EventTypes = *request.EventTypes
+	}
+	if request.Enabled != nil {
+		if !*request.Enabled && current.DisabledAt.IsZero() {
+			arg.DisabledAt = time.Now()
+		}
+		if *request.Enabled {
+			arg.DisabledAt = time.Time{}
+		}
+	}
+
+	subscription, err := s.store.UpdateWebhookSubscription(c, arg)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionWebhookUpdate,
+		TargetType: auditTargetWebhook,
+		TargetID:   strconv.FormatInt(subscription.ID, 10),
+		Outcome:    audit.OutcomeSuccess,
+		Diff:       webhookChanges(current, subscription),
+	})
+
+	c.JSON(http.StatusOK, newWebhookResponse(subscription))
+}
+
+func webhookChanges(before, after db.WebhookSubscription) map[string]any {
+	changes := map[string]any{}
+	if before.Url != after.Url {
+		changes["url"] = audit.Change{From: before.Url, To: after.Url}
+	}
+	if !slices.Equal(before.EventTypes, after.EventTypes) {
+		changes["event_types"] = audit.Change{From: before.EventTypes, To: after.EventTypes}
+	}
+	if before.DisabledAt.IsZero() != after.DisabledAt.IsZero() {
+		changes["enabled"] = audit.Change{From: before.DisabledAt.IsZero(), To: after.DisabledAt.IsZero()}
+	}
+	return changes
+}
+
+func (s *Server) deleteWebhook(c *gin.Context) {
+	var params webhookParams
+	if err := c.ShouldBindUri(&params); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	deleted, err := s.store.DeleteWebhookSubscription(c, db.DeleteWebhookSubscriptionParams{
+		ID:    params.ID,
+		Owner: getPayloadFromGinCtx(c).Subject,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if deleted == 0 {
+		abortWithError(c, errNotFound)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionWebhookDelete,
+		TargetType: auditTargetWebhook,
+		TargetID:   strconv.FormatInt(params.ID, 10),
+		Outcome:    audit.OutcomeSuccess,
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+type listWebhookDeliveriesRequest struct {
+	Status   string `form:"status" binding:"omitempty,oneof=pending succeeded failed"`
+	BeforeID int64  `form:"before_id" binding:"omitempty,min=1"`
+	PageSize int32  `form:"page_size" binding:"omitempty,min=1,max=100"`
+}
+
+type listWebhookDeliveriesResponse struct {
+	Deliveries []db.WebhookDelivery `json:"deliveries"`
+	// NextBeforeID is the before_id of the next page, it's omitted on the
+	// last one.
+	NextBeforeID int64 `json:"next_before_id,omitempty"`
+}
+
+// listWebhookDeliveries pages through the delivery log of a subscription,
+// newest first.
+func (s *Server) listWebhookDeliveries(c *gin.Context) {
+	var request listWebhookDeliveriesRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	subscription, ok := s.ownedWebhook(c)
+	if !ok {
+		return
+	}
+
+	pageSize := request.PageSize
+	if pageSize == 0 {
+		pageSize = webhookDeliveriesPageSize
+	}
+
+	deliveries, err := s.store.ListWebhookDeliveries(c, db.ListWebhookDeliveriesParams{
+		SubscriptionID: subscription.ID,
+		Status:         optionalString(request.Status),
+		BeforeID:       pgtype.Int8{Int64: request.BeforeID, Valid: request.BeforeID > 0},
+		RowLimit:       pageSize,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	response := listWebhookDeliveriesResponse{Deliveries: deliveries}
+	if len(deliveries) == int(pageSize) {
+		response.NextBeforeID = deliveries[len(deliveries)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+type replayWebhookDeliveryParams struct {
+	ID         int64 `uri:"id" binding:"required,min=1"`
+	DeliveryID int64 `uri:"delivery_id" binding:"required,min=1"`
+}
+
+// replayWebhookDelivery queues a new delivery of the same event. The original
+// stays in the log as it was.
+func (s *Server) replayWebhookDelivery(c *gin.Context) {
+	var params replayWebhookDeliveryParams
+	if err := c.ShouldBindUri(&params); err != nil {
+		abortWithError(c, bindingError(err))
+		return
+	}
+
+	subscription, ok := s.ownedWebhook(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := s.store.GetWebhookDelivery(c, params.DeliveryID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if delivery.SubscriptionID != subscription.ID {
+		abortWithError(c, errNotFound)
+		return
+	}
+
+	replay, err := s.store.ReplayWebhookDelivery(c, delivery.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	s.recordAudit(c, audit.Event{
+		Action:     auditActionWebhookReplay,
+		TargetType: auditTargetWebhook,
+		TargetID:   strconv.FormatInt(subscription.ID, 10),
+		Outcome:    audit.OutcomeSuccess,
+		Diff:       map[string]any{"delivery_id": delivery.ID, "replay_id": replay.ID},
+	})
+
+	c.JSON(http.StatusAccepted, replay)
+}
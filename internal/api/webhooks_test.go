@@ -0,0 +1,469 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/random"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webhookTestCase is shared by the webhook handler tests, they only differ
+// in the route they call.
+type webhookTestCase struct {
+	name          string
+	method        string
+	url           string
+	body          string
+	buildStubs    func(store *mockdb.MockStore)
+	checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+}
+
+func runWebhookTestCases(t *testing.T, user db.User, testCases []webhookTestCase) {
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			stubAuthUsers(store, user)
+			tc.buildStubs(store)
+
+			testContainer := newTestContainer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(tc.method, tc.url, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+
+			require.NoError(t, testContainer.Invoke(func(server *Server) {
+				addAuthorization(t, request, server.tokensManager, authorizationTypeBearer, accessTokenParams(user.Username))
+				server.engine.ServeHTTP(recorder, request)
+				tc.checkResponse(t, recorder)
+			}))
+		})
+	}
+}
+
+func TestServer_createWebhook(t *testing.T) {
+	user, _ := randomUser(t)
+
+	runWebhookTestCases(t, user, []webhookTestCase{
+		{
+			name:   "all_events",
+			method: http.MethodPost,
+			url:    "/webhooks",
+			body:   `{"url": "https://example.com/hooks"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateWebhookSubscription(gomock.Any(), gomock.Cond[db.CreateWebhookSubscriptionParams](func(x db.CreateWebhookSubscriptionParams) bool {
+						return x.Owner == user.Username &&
+							x.Url == "https://example.com/hooks" &&
+							strings.HasPrefix(x.Secret, webhookSecretPrefix) &&
+							x.EventTypes != nil && len(x.EventTypes) == 0
+					})).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateWebhookSubscriptionParams) (db.WebhookSubscription, error) {
+						return db.WebhookSubscription{ID: 1, Owner: arg.Owner, Url: arg.Url, Secret: arg.Secret, EventTypes: arg.EventTypes}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				var response createWebhookResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.True(t, strings.HasPrefix(response.Secret, webhookSecretPrefix))
+				require.True(t, response.Enabled)
+				require.Empty(t, response.EventTypes)
+			},
+		},
+		{
+			name:   "filtered",
+			method: http.MethodPost,
+			url:    "/webhooks",
+			body:   `{"url": "https://example.com/hooks", "event_types": ["transfer.completed"]}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateWebhookSubscription(gomock.Any(), gomock.Cond[db.CreateWebhookSubscriptionParams](func(x db.CreateWebhookSubscriptionParams) bool {
+						return len(x.EventTypes) == 1 && x.EventTypes[0] == db.EventTransferCompleted
+					})).
+					Times(1)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name:   "unknown_event_type",
+			method: http.MethodPost,
+			url:    "/webhooks",
+			body:   `{"url": "https://example.com/hooks", "event_types": ["user.created"]}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+		{
+			name:   "invalid_url",
+			method: http.MethodPost,
+			url:    "/webhooks",
+			body:   `{"url": "not a url"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+		{
+			name:   "plain_http",
+			method: http.MethodPost,
+			url:    "/webhooks",
+			body:   `{"url": "http://example.com/hooks"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+		{
+			name:   "loopback",
+			method: http.MethodPost,
+			url:    "/webhooks",
+			body:   `{"url": "https://127.0.0.1:8080/hooks"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+		{
+			name:   "metadata_service",
+			method: http.MethodPost,
+			url:    "/webhooks",
+			body:   `{"url": "https://169.254.169.254/latest/meta-data"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+	})
+}
+
+func TestServer_updateWebhook(t *testing.T) {
+	user, _ := randomUser(t)
+	other, _ := randomUser(t)
+	subscription := randomWebhookSubscription(user.Username)
+	disabled := subscription
+	disabled.DisabledAt = time.Now()
+	disabled.ConsecutiveFailures = 20
+	url := fmt.Sprintf("/webhooks/%d", subscription.ID)
+
+	runWebhookTestCases(t, user, []webhookTestCase{
+		{
+			name:   "change_url",
+			method: http.MethodPatch,
+			url:    url,
+			body:   `{"url": "https://example.com/new"}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetWebhookSubscription(gomock.Any(), gomock.Eq(subscription.ID)).
+					Times(1).
+					Return(subscription, nil)
+				store.EXPECT().
+					UpdateWebhookSubscription(gomock.Any(), gomock.Eq(db.UpdateWebhookSubscriptionParams{
+						ID:         subscription.ID,
+						Url:        "https://example.com/new",
+						EventTypes: subscription.EventTypes,
+					})).
+					Times(1).
+					Return(subscription, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "re_enable",
+			method: http.MethodPatch,
+			url:    url,
+			body:   `{"enabled": true}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetWebhookSubscription(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(disabled, nil)
+				store.EXPECT().
+					UpdateWebhookSubscription(gomock.Any(), gomock.Cond[db.UpdateWebhookSubscriptionParams](func(x db.UpdateWebhookSubscriptionParams) bool {
+						return x.DisabledAt.IsZero()
+					})).
+					Times(1).
+					Return(subscription, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var response webhookResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.True(t, response.Enabled)
+			},
+		},
+		{
+			name:   "disable",
+			method: http.MethodPatch,
+			url:    url,
+			body:   `{"enabled": false}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetWebhookSubscription(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(subscription, nil)
+				store.EXPECT().
+					UpdateWebhookSubscription(gomock.Any(), gomock.Cond[db.UpdateWebhookSubscriptionParams](func(x db.UpdateWebhookSubscriptionParams) bool {
+						return !x.DisabledAt.IsZero()
+					})).
+					Times(1).
+					Return(disabled, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "not_owner",
+			method: http.MethodPatch,
+			url:    url,
+			body:   `{"enabled": true}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetWebhookSubscription(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(randomWebhookSubscription(other.Username), nil)
+				store.EXPECT().UpdateWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errNotFound)
+			},
+		},
+		{
+			name:   "not_found",
+			method: http.MethodPatch,
+			url:    url,
+			body:   `{"enabled": true}`,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetWebhookSubscription(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.WebhookSubscription{}, pgx.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errNotFound)
+			},
+		},
+	})
+}
+
+func TestServer_deleteWebhook(t *testing.T) {
+	user, _ := randomUser(t)
+
+	runWebhookTestCases(t, user, []webhookTestCase{
+		{
+			name:   "deleted",
+			method: http.MethodDelete,
+			url:    "/webhooks/5",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					DeleteWebhookSubscription(gomock.Any(), gomock.Eq(db.DeleteWebhookSubscriptionParams{ID: 5, Owner: user.Username})).
+					Times(1).
+					Return(int64(1), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+		{
+			name:   "not_found",
+			method: http.MethodDelete,
+			url:    "/webhooks/5",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					DeleteWebhookSubscription(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(0), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errNotFound)
+			},
+		},
+	})
+}
+
+func TestServer_listWebhookDeliveries(t *testing.T) {
+	user, _ := randomUser(t)
+	subscription := randomWebhookSubscription(user.Username)
+	deliveries := []db.WebhookDelivery{
+		randomWebhookDelivery(subscription.ID),
+		randomWebhookDelivery(subscription.ID),
+	}
+
+	runWebhookTestCases(t, user, []webhookTestCase{
+		{
+			name:   "last_page",
+			method: http.MethodGet,
+			url:    fmt.Sprintf("/webhooks/%d/deliveries?status=failed&page_size=5", subscription.ID),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetWebhookSubscription(gomock.Any(), gomock.Eq(subscription.ID)).
+					Times(1).
+					Return(subscription, nil)
+				store.EXPECT().
+					ListWebhookDeliveries(gomock.Any(), gomock.Eq(db.ListWebhookDeliveriesParams{
+						SubscriptionID: subscription.ID,
+						Status:         pgtype.Text{String: db.WebhookDeliveryFailed, Valid: true},
+						RowLimit:       5,
+					})).
+					Times(1).
+					Return(deliveries, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var response listWebhookDeliveriesResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Len(t, response.Deliveries, 2)
+				require.Zero(t, response.NextBeforeID)
+			},
+		},
+		{
+			name:   "invalid_status",
+			method: http.MethodGet,
+			url:    fmt.Sprintf("/webhooks/%d/deliveries?status=lost", subscription.ID),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetWebhookSubscription(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errValidationFailed)
+			},
+		},
+	})
+}
+
+func TestServer_replayWebhookDelivery(t *testing.T) {
+	user, _ := randomUser(t)
+	subscription := randomWebhookSubscription(user.Username)
+	delivery := randomWebhookDelivery(subscription.ID)
+	foreign := randomWebhookDelivery(subscription.ID + 1)
+	url := fmt.Sprintf("/webhooks/%d/deliveries/%d/replay", subscription.ID, delivery.ID)
+
+	runWebhookTestCases(t, user, []webhookTestCase{
+		{
+			name:   "replayed",
+			method: http.MethodPost,
+			url:    url,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetWebhookSubscription(gomock.Any(), gomock.Eq(subscription.ID)).
+					Times(1).
+					Return(subscription, nil)
+				store.EXPECT().
+					GetWebhookDelivery(gomock.Any(), gomock.Eq(delivery.ID)).
+					Times(1).
+					Return(delivery, nil)
+
+				replay := randomWebhookDelivery(subscription.ID)
+				replay.ReplayOf = delivery.ID
+				store.EXPECT().
+					ReplayWebhookDelivery(gomock.Any(), gomock.Eq(delivery.ID)).
+					Times(1).
+					Return(replay, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusAccepted, recorder.Code)
+				var response db.WebhookDelivery
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, delivery.ID, response.ReplayOf)
+				require.Equal(t, db.WebhookDeliveryPending, response.Status)
+			},
+		},
+		{
+			name:   "delivery_of_other_subscription",
+			method: http.MethodPost,
+			url:    url,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					GetWebhookSubscription(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(subscription, nil)
+				store.EXPECT().
+					GetWebhookDelivery(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(foreign, nil)
+				store.EXPECT().ReplayWebhookDelivery(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireProblemCode(t, recorder, errNotFound)
+			},
+		},
+	})
+}
+
+func TestWebhooksRequireScope(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	user, _ := randomUser(t)
+	key, apiKey := randomAPIKey(t, user.Username, scopeAccountsRead)
+
+	store := mockdb.NewMockStore(ctrl)
+	stubAuthUsers(store, user)
+	store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), gomock.Any()).AnyTimes().Return(apiKey, nil)
+	store.EXPECT().TouchAPIKey(gomock.Any(), gomock.Any()).AnyTimes()
+	store.EXPECT().ListWebhookSubscriptions(gomock.Any(), gomock.Any()).Times(0)
+
+	testContainer := newTestContainer(t, store)
+	recorder := httptest.NewRecorder()
+
+	request, err := http.NewRequest(http.MethodGet, "/webhooks", nil)
+	require.NoError(t, err)
+	addAPIKeyAuthorization(request, key)
+
+	require.NoError(t, testContainer.Invoke(func(server *Server) {
+		server.engine.ServeHTTP(recorder, request)
+		requireProblemCode(t, recorder, errInsufficientScope)
+	}))
+}
+
+func randomWebhookSubscription(owner string) db.WebhookSubscription {
+	return db.WebhookSubscription{
+		ID:         random.Int64(1, 1000),
+		Owner:      owner,
+		Url:        "https://example.com/hooks/" + random.String(6),
+		Secret:     webhookSecretPrefix + random.String(32),
+		EventTypes: []string{db.EventTransferCompleted},
+		CreatedAt:  time.Now(),
+	}
+}
+
+func randomWebhookDelivery(subscriptionID int64) db.WebhookDelivery {
+	return db.WebhookDelivery{
+		ID:             random.Int64(1, 1000),
+		SubscriptionID: subscriptionID,
+		EventID:        random.Int64(1, 1000),
+		EventType:      db.EventTransferCompleted,
+		Payload:        json.RawMessage(`{}`),
+		Status:         db.WebhookDeliveryPending,
+		CreatedAt:      time.Now(),
+	}
+}
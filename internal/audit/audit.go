@@ -0,0 +1,38 @@
+package audit
+
+import (
+	"context"
+)
+
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+	OutcomeDenied  Outcome = "denied"
+)
+
+// Event is a single entry of the audit log. Actor is the user the action is
+// attributed to, for failed logins it's the username that was tried. Diff
+// holds whatever helps to reconstruct the action later, it must never carry
+// secrets.
+type Event struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	RequestID  string
+	IP         string
+	Outcome    Outcome
+	Diff       map[string]any
+}
+
+type Recorder interface {
+	Record(ctx context.Context, event Event) error
+}
+
+// Change is the Diff entry of a field that was modified.
+type Change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
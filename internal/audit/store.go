@@ -0,0 +1,40 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"simple-bank/internal/db"
+)
+
+// StoreRecorder appends events to the audit_events table.
+type StoreRecorder struct {
+	store db.Store
+}
+
+func NewStoreRecorder(store db.Store) *StoreRecorder {
+	return &StoreRecorder{store: store}
+}
+
+func (r *StoreRecorder) Record(ctx context.Context, event Event) error {
+	diff := event.Diff
+	if diff == nil {
+		diff = map[string]any{}
+	}
+	encoded, err := json.Marshal(diff)
+	if err != nil {
+		return fmt.Errorf("encode audit diff: %w", err)
+	}
+
+	_, err = r.store.CreateAuditEvent(ctx, db.CreateAuditEventParams{
+		Actor:      event.Actor,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		RequestID:  event.RequestID,
+		Ip:         event.IP,
+		Outcome:    string(event.Outcome),
+		Diff:       encoded,
+	})
+	return err
+}
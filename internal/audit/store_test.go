@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"testing"
+)
+
+func TestStoreRecorder_Record(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		CreateAuditEvent(gomock.Any(), gomock.Cond[db.CreateAuditEventParams](func(x db.CreateAuditEventParams) bool {
+			var diff map[string]Change
+			return x.Actor == "alice" &&
+				x.Action == "user.update" &&
+				x.Outcome == "success" &&
+				json.Unmarshal(x.Diff, &diff) == nil &&
+				diff["full_name"] == Change{From: "Alice", To: "Alice Smith"}
+		})).
+		Times(1)
+
+	err := NewStoreRecorder(store).Record(context.Background(), Event{
+		Actor:   "alice",
+		Action:  "user.update",
+		Outcome: OutcomeSuccess,
+		Diff:    map[string]any{"full_name": Change{From: "Alice", To: "Alice Smith"}},
+	})
+	require.NoError(t, err)
+}
+
+func TestStoreRecorder_EmptyDiff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		CreateAuditEvent(gomock.Any(), gomock.Cond[db.CreateAuditEventParams](func(x db.CreateAuditEventParams) bool {
+			return string(x.Diff) == "{}"
+		})).
+		Times(1)
+
+	require.NoError(t, NewStoreRecorder(store).Record(context.Background(), Event{Action: "user.login"}))
+}
@@ -1,35 +1,171 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"github.com/spf13/viper"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
 )
 
+// Config is read from app.env, app.yaml or app.toml and the environment,
+// the environment wins. Fields tagged secret can also be read from the file
+// named by KEY_FILE, secret:"url" only hides the password of a URL. Fields
+// tagged restart are read once by the services built at startup, a Watcher
+// refuses to change them.
 type Config struct {
-	DBDriver            string        `mapstructure:"DB_DRIVER"`
-	DBSource            string        `mapstructure:"DB_SOURCE"`
-	ServerAddress       string        `mapstructure:"SERVER_ADDRESS"`
-	TokenPrivateKey     string        `mapstructure:"TOKEN_PRIVATE_KEY"`
-	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	LogLevel                   string        `mapstructure:"LOG_LEVEL"`
+	DBDriver                   string        `mapstructure:"DB_DRIVER" restart:"true"`
+	DBSource                   string        `mapstructure:"DB_SOURCE" secret:"url" restart:"true"`
+	DBReplicaSource            string        `mapstructure:"DB_REPLICA_SOURCE" secret:"url" restart:"true"`
+	DBMaxConns                 int32         `mapstructure:"DB_MAX_CONNS" restart:"true"`
+	DBMinConns                 int32         `mapstructure:"DB_MIN_CONNS" restart:"true"`
+	DBConnMaxLifetime          time.Duration `mapstructure:"DB_CONN_MAX_LIFETIME" restart:"true"`
+	DBConnMaxIdleTime          time.Duration `mapstructure:"DB_CONN_MAX_IDLE_TIME" restart:"true"`
+	DBConnectAttempts          int           `mapstructure:"DB_CONNECT_ATTEMPTS" restart:"true"`
+	DBConnectBackoff           time.Duration `mapstructure:"DB_CONNECT_BACKOFF" restart:"true"`
+	AutoMigrate                bool          `mapstructure:"AUTO_MIGRATE" restart:"true"`
+	ServerAddress              string        `mapstructure:"SERVER_ADDRESS" restart:"true"`
+	GRPCServerAddress          string        `mapstructure:"GRPC_SERVER_ADDRESS" restart:"true"`
+	TokenPrivateKey            string        `mapstructure:"TOKEN_PRIVATE_KEY" secret:"true" restart:"true"`
+	AccessTokenDuration        time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	LoginMaxAttempts           int           `mapstructure:"LOGIN_MAX_ATTEMPTS"`
+	LoginLockoutDuration       time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	TwoFactorChallengeDuration time.Duration `mapstructure:"TWO_FACTOR_CHALLENGE_DURATION"`
+	RecoveryCodeKey            string        `mapstructure:"RECOVERY_CODE_KEY" secret:"true" restart:"true"`
+	TransferStepUpThreshold    int64         `mapstructure:"TRANSFER_STEP_UP_THRESHOLD"`
+	RateLimitStorage           string        `mapstructure:"RATE_LIMIT_STORAGE" restart:"true"`
+	RateLimitPublic            string        `mapstructure:"RATE_LIMIT_PUBLIC"`
+	RateLimitAccounts          string        `mapstructure:"RATE_LIMIT_ACCOUNTS"`
+	RateLimitTransfers         string        `mapstructure:"RATE_LIMIT_TRANSFERS"`
+	AppBaseURL                 string        `mapstructure:"APP_BASE_URL"`
+	EmailVerificationDuration  time.Duration `mapstructure:"EMAIL_VERIFICATION_DURATION"`
+	PasswordResetDuration      time.Duration `mapstructure:"PASSWORD_RESET_DURATION"`
+	Mailer                     string        `mapstructure:"MAILER" restart:"true"`
+	MailFrom                   string        `mapstructure:"MAIL_FROM" restart:"true"`
+	MailOutboxDir              string        `mapstructure:"MAIL_OUTBOX_DIR" restart:"true"`
+	SMTPHost                   string        `mapstructure:"SMTP_HOST" restart:"true"`
+	SMTPPort                   int           `mapstructure:"SMTP_PORT" restart:"true"`
+	SMTPUsername               string        `mapstructure:"SMTP_USERNAME" restart:"true"`
+	SMTPPassword               string        `mapstructure:"SMTP_PASSWORD" secret:"true" restart:"true"`
+	PasswordHashMemory         uint32        `mapstructure:"PASSWORD_HASH_MEMORY"`
+	PasswordHashIterations     uint32        `mapstructure:"PASSWORD_HASH_ITERATIONS"`
+	PasswordHashParallelism    uint8         `mapstructure:"PASSWORD_HASH_PARALLELISM"`
+	PasswordMinLength          int           `mapstructure:"PASSWORD_MIN_LENGTH" restart:"true"`
+	BreachedPasswordsFile      string        `mapstructure:"BREACHED_PASSWORDS_FILE" restart:"true"`
+	OAuthCodeDuration          time.Duration `mapstructure:"OAUTH_CODE_DURATION"`
+	OutboxSink                 string        `mapstructure:"OUTBOX_SINK" restart:"true"`
+	OutboxHTTPURL              string        `mapstructure:"OUTBOX_HTTP_URL" secret:"url" restart:"true"`
+	OutboxNATSURL              string        `mapstructure:"OUTBOX_NATS_URL" secret:"url" restart:"true"`
+	OutboxNATSSubject          string        `mapstructure:"OUTBOX_NATS_SUBJECT" restart:"true"`
+	OutboxPollInterval         time.Duration `mapstructure:"OUTBOX_POLL_INTERVAL" restart:"true"`
+	OutboxBatchSize            int32         `mapstructure:"OUTBOX_BATCH_SIZE" restart:"true"`
+	WebhookMaxAttempts         int32         `mapstructure:"WEBHOOK_MAX_ATTEMPTS" restart:"true"`
+	WebhookDisableAfter        int32         `mapstructure:"WEBHOOK_DISABLE_AFTER" restart:"true"`
 }
 
+// Load reads the configuration and validates it, every invalid setting is
+// reported at once.
 func Load(path string) (*Config, error) {
-	viper.AddConfigPath(path)
-	viper.SetConfigName("app")
-	viper.SetConfigType("env")
-
-	viper.AutomaticEnv()
-	_ = viper.BindEnv("DB_DRIVER")
-	_ = viper.BindEnv("DB_SOURCE")
-	_ = viper.BindEnv("SERVER_ADDRESS")
-	_ = viper.BindEnv("TOKEN_PRIVATE_KEY")
-	_ = viper.BindEnv("ACCESS_TOKEN_DURATION")
-	_ = viper.ReadInConfig()
+	config, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return config, nil
+}
 
+// Read reads the configuration without validating it. CONFIG_FILE names the
+// file to read, otherwise app.env, app.yaml or app.toml is looked up in path.
+// Without a file everything comes from the environment, which is logged.
+func Read(path string) (*Config, error) {
+	v, err := newViper(path)
+	if err != nil {
+		return nil, err
+	}
+	return decode(v)
+}
+
+func newViper(path string) (*viper.Viper, error) {
+	v := viper.New()
+	if file := os.Getenv("CONFIG_FILE"); file != "" {
+		v.SetConfigFile(file)
+	} else {
+		v.AddConfigPath(path)
+		v.SetConfigName("app")
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("read config: %w", err)
+		}
+		slog.Warn("no config file found, reading the environment only",
+			"searched", filepath.Join(path, "app.{env,yaml,toml}"))
+	}
+
+	v.AutomaticEnv()
+	for _, field := range fields() {
+		_ = v.BindEnv(field.key)
+		if field.secret != "" {
+			if err := readSecretFile(v, field.key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+func decode(v *viper.Viper) (*Config, error) {
 	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := v.Unmarshal(&config); err != nil {
 		return nil, err
 	}
 
 	return &config, nil
 }
+
+// readSecretFile sets key from the file named by KEY_FILE, the way Docker
+// and Kubernetes secrets are mounted. The file wins over KEY.
+func readSecretFile(v *viper.Viper, key string) error {
+	fileKey := key + "_FILE"
+	_ = v.BindEnv(fileKey)
+	file := v.GetString(fileKey)
+	if file == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", fileKey, err)
+	}
+	v.Set(key, strings.TrimRight(string(content), "\r\n"))
+	return nil
+}
+
+type field struct {
+	index   int
+	key     string
+	secret  string
+	restart bool
+}
+
+func fields() []field {
+	t := reflect.TypeOf(Config{})
+	fields := make([]field, t.NumField())
+	for i := range fields {
+		fields[i] = field{
+			index:   i,
+			key:     t.Field(i).Tag.Get("mapstructure"),
+			secret:  t.Field(i).Tag.Get("secret"),
+			restart: t.Field(i).Tag.Get("restart") == "true",
+		}
+	}
+	return fields
+}
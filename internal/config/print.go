@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+)
+
+const masked = "********"
+
+// Print writes the configuration as KEY=value lines with the secrets masked.
+func (c *Config) Print(w io.Writer) error {
+	value := reflect.ValueOf(*c)
+	for _, field := range fields() {
+		_, err := fmt.Fprintf(w, "%s=%s\n", field.key, mask(field.secret, fmt.Sprint(value.Field(field.index).Interface())))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mask(secret, value string) string {
+	if secret == "" || value == "" {
+		return value
+	}
+	if secret == "url" {
+		if u, err := url.Parse(value); err == nil {
+			return u.Redacted()
+		}
+	}
+	return masked
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/chacha20poly1305"
+	"log/slog"
+	"net"
+	"net/url"
+	"simple-bank/internal/security"
+	"slices"
+	"time"
+)
+
+// Validate checks the settings the services rely on and joins every problem
+// into the returned error, nothing is caught later at first use.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		check(slices.Contains(allowed, value), "%s must be one of %q, got %q", key, allowed, value)
+	}
+	positive := func(key string, d time.Duration) {
+		check(d > 0, "%s must be a positive duration", key)
+	}
+	address := func(key, value string) {
+		_, _, err := net.SplitHostPort(value)
+		check(err == nil, "%s must be host:port, got %q", key, value)
+	}
+	absoluteURL := func(key, value string) {
+		u, err := url.Parse(value)
+		check(err == nil && u.Scheme != "" && u.Host != "", "%s must be an absolute URL", key)
+	}
+
+	_, err := c.SlogLevel()
+	check(err == nil, "LOG_LEVEL must be debug, info, warn or error, got %q", c.LogLevel)
+	oneOf("DB_DRIVER", c.DBDriver, "postgres", "sqlite")
+	check(c.DBSource != "", "DB_SOURCE is required")
+	check(c.DBDriver != "sqlite" || c.DBReplicaSource == "", "DB_REPLICA_SOURCE is not supported by the sqlite driver")
+	check(c.DBMaxConns >= 0, "DB_MAX_CONNS must not be negative")
+	check(c.DBMinConns >= 0, "DB_MIN_CONNS must not be negative")
+	check(c.DBMaxConns == 0 || c.DBMinConns <= c.DBMaxConns, "DB_MIN_CONNS must not exceed DB_MAX_CONNS")
+	check(c.DBConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME must not be negative")
+	check(c.DBConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME must not be negative")
+	check(c.DBConnectAttempts > 0, "DB_CONNECT_ATTEMPTS must be positive")
+	check(c.DBConnectBackoff >= 0, "DB_CONNECT_BACKOFF must not be negative")
+	address("SERVER_ADDRESS", c.ServerAddress)
+	address("GRPC_SERVER_ADDRESS", c.GRPCServerAddress)
+	check(len(c.TokenPrivateKey) == chacha20poly1305.KeySize, "TOKEN_PRIVATE_KEY must be %d bytes long", chacha20poly1305.KeySize)
+	positive("ACCESS_TOKEN_DURATION", c.AccessTokenDuration)
+	check(c.LoginMaxAttempts >= 0, "LOGIN_MAX_ATTEMPTS must not be negative")
+	check(c.LoginLockoutDuration >= 0, "LOGIN_LOCKOUT_DURATION must not be negative")
+	positive("TWO_FACTOR_CHALLENGE_DURATION", c.TwoFactorChallengeDuration)
+	check(len(c.RecoveryCodeKey) >= security.RecoveryCodeKeySize, "RECOVERY_CODE_KEY must be at least %d bytes long", security.RecoveryCodeKeySize)
+	check(c.TransferStepUpThreshold >= 0, "TRANSFER_STEP_UP_THRESHOLD must not be negative")
+	oneOf("RATE_LIMIT_STORAGE", c.RateLimitStorage, "", "memory", "postgres")
+	absoluteURL("APP_BASE_URL", c.AppBaseURL)
+	positive("EMAIL_VERIFICATION_DURATION", c.EmailVerificationDuration)
+	positive("PASSWORD_RESET_DURATION", c.PasswordResetDuration)
+
+	oneOf("MAILER", c.Mailer, "", "file", "smtp")
+	check(c.MailFrom != "", "MAIL_FROM is required")
+	if c.Mailer == "smtp" {
+		check(c.SMTPHost != "", "SMTP_HOST is required by the smtp mailer")
+		check(c.SMTPPort > 0 && c.SMTPPort <= 65535, "SMTP_PORT must be a port number")
+	} else {
+		check(c.MailOutboxDir != "", "MAIL_OUTBOX_DIR is required by the file mailer")
+	}
+
+	check(c.PasswordMinLength >= 0, "PASSWORD_MIN_LENGTH must not be negative")
+	positive("OAUTH_CODE_DURATION", c.OAuthCodeDuration)
+
+	oneOf("OUTBOX_SINK", c.OutboxSink, "", "log", "http", "nats")
+	switch c.OutboxSink {
+	case "http":
+		absoluteURL("OUTBOX_HTTP_URL", c.OutboxHTTPURL)
+	case "nats":
+		check(c.OutboxNATSURL != "", "OUTBOX_NATS_URL is required by the nats sink")
+		check(c.OutboxNATSSubject != "", "OUTBOX_NATS_SUBJECT is required by the nats sink")
+	}
+	positive("OUTBOX_POLL_INTERVAL", c.OutboxPollInterval)
+	check(c.OutboxBatchSize > 0, "OUTBOX_BATCH_SIZE must be positive")
+	check(c.WebhookMaxAttempts > 0, "WEBHOOK_MAX_ATTEMPTS must be positive")
+	check(c.WebhookDisableAfter >= 0, "WEBHOOK_DISABLE_AFTER must not be negative")
+
+	return errors.Join(errs...)
+}
+
+// SlogLevel is LOG_LEVEL, info when it is not set.
+func (c *Config) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if c.LogLevel == "" {
+		return slog.LevelInfo, nil
+	}
+	err := level.UnmarshalText([]byte(c.LogLevel))
+	return level, err
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Watcher hands out the current configuration. A snapshot is never modified,
+// a reload publishes a new one, so a request reads one consistent
+// configuration however long it runs.
+type Watcher struct {
+	current atomic.Pointer[Config]
+
+	mu          sync.Mutex
+	subscribers []func(config *Config)
+}
+
+// Fixed returns a Watcher that keeps config until Update is called.
+func Fixed(config *Config) *Watcher {
+	w := &Watcher{}
+	w.current.Store(config)
+	return w
+}
+
+// Watch loads the configuration like Load and reloads it whenever the config
+// file changes. Environment variables are not watched.
+func Watch(path string) (*Watcher, error) {
+	v, err := newViper(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := decode(v)
+	if err != nil {
+		return nil, err
+	}
+	if err = config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	w := Fixed(config)
+	if v.ConfigFileUsed() != "" {
+		v.OnConfigChange(func(fsnotify.Event) {
+			next, err := decode(v)
+			if err == nil {
+				err = w.Update(next)
+			}
+			if err != nil {
+				slog.Error("configuration not reloaded", "err", err)
+				return
+			}
+			slog.Info("configuration reloaded", "file", v.ConfigFileUsed())
+		})
+		v.WatchConfig()
+	}
+	return w, nil
+}
+
+func (w *Watcher) Current() *Config {
+	return w.current.Load()
+}
+
+// Subscribe calls fn with every configuration published after it, in order.
+func (w *Watcher) Subscribe(fn func(config *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Update publishes next unless it is invalid or changes a restart-only
+// setting, the current configuration is kept then.
+func (w *Watcher) Update(next *Config) error {
+	if err := next.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if changed := restartOnlyChanges(w.current.Load(), next); len(changed) > 0 {
+		return fmt.Errorf("%s only change on restart", strings.Join(changed, ", "))
+	}
+	w.current.Store(next)
+	for _, fn := range w.subscribers {
+		fn(next)
+	}
+	return nil
+}
+
+func restartOnlyChanges(current, next *Config) []string {
+	a, b := reflect.ValueOf(*current), reflect.ValueOf(*next)
+
+	var changed []string
+	for _, field := range fields() {
+		if field.restart && !a.Field(field.index).Equal(b.Field(field.index)) {
+			changed = append(changed, field.key)
+		}
+	}
+	return changed
+}
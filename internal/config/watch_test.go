@@ -0,0 +1,60 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatcher_Update(t *testing.T) {
+	config, err := Load("../../")
+	require.NoError(t, err)
+	w := Fixed(config)
+
+	var published []*Config
+	w.Subscribe(func(config *Config) {
+		published = append(published, config)
+	})
+
+	next := *config
+	next.AccessTokenDuration = time.Hour
+	next.LogLevel = "debug"
+	require.NoError(t, w.Update(&next))
+	require.Equal(t, &next, w.Current())
+	require.Equal(t, []*Config{&next}, published)
+	require.Equal(t, 15*time.Minute, config.AccessTokenDuration, "the old snapshot is not modified")
+
+	restart := next
+	restart.DBSource = "postgresql://elsewhere"
+	restart.ServerAddress = "0.0.0.0:9090"
+	err = w.Update(&restart)
+	require.ErrorContains(t, err, "DB_SOURCE, SERVER_ADDRESS only change on restart")
+	require.Equal(t, &next, w.Current())
+
+	invalid := next
+	invalid.LogLevel = "loud"
+	require.ErrorContains(t, w.Update(&invalid), "LOG_LEVEL")
+	require.Equal(t, &next, w.Current())
+	require.Len(t, published, 1)
+}
+
+func TestWatch_ReloadsFile(t *testing.T) {
+	env, err := os.ReadFile("../../app.env")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.env")
+	require.NoError(t, os.WriteFile(file, env, 0o600))
+
+	w, err := Watch(dir)
+	require.NoError(t, err)
+	require.Equal(t, 15*time.Minute, w.Current().AccessTokenDuration)
+
+	changed := strings.Replace(string(env), "ACCESS_TOKEN_DURATION=15m", "ACCESS_TOKEN_DURATION=5m", 1)
+	require.NoError(t, os.WriteFile(file, []byte(changed), 0o600))
+	require.Eventually(t, func() bool {
+		return w.Current().AccessTokenDuration == 5*time.Minute
+	}, 5*time.Second, 10*time.Millisecond)
+}
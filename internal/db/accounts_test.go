@@ -2,14 +2,14 @@ package db
 
 import (
 	"context"
-	"database/sql"
+	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/require"
 	"simple-bank/internal/random"
 	"testing"
 	"time"
 )
 
-func createRandomAccount(t *testing.T) Account {
+func createRandomAccount(t testing.TB) Account {
 	user := createRandomUser(t)
 
 	arg := CreateAccountParams{
@@ -61,7 +61,7 @@ func TestQueries_DeleteAccount(t *testing.T) {
 
 	account, err := testQueries.GetAccount(context.Background(), randomAccount.ID)
 	require.Error(t, err)
-	require.EqualError(t, err, sql.ErrNoRows.Error())
+	require.EqualError(t, err, pgx.ErrNoRows.Error())
 	require.Empty(t, account)
 }
 
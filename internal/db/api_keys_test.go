@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/random"
+	"testing"
+	"time"
+)
+
+func createRandomAPIKey(t *testing.T, username string) ApiKey {
+	arg := CreateAPIKeyParams{
+		ID:           uuid.New(),
+		Username:     username,
+		Name:         random.String(8),
+		Prefix:       "sbk_" + random.String(12),
+		HashedSecret: random.String(64),
+		Scopes:       []string{"accounts:read", "transfers:write"},
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+
+	apiKey, err := testQueries.CreateAPIKey(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ID, apiKey.ID)
+	require.Equal(t, arg.Prefix, apiKey.Prefix)
+	require.Equal(t, arg.Scopes, apiKey.Scopes)
+	require.WithinDuration(t, arg.ExpiresAt, apiKey.ExpiresAt, time.Second)
+	require.True(t, apiKey.LastUsedAt.IsZero())
+	require.True(t, apiKey.RevokedAt.IsZero())
+
+	return apiKey
+}
+
+func TestQueries_GetAPIKeyByPrefix(t *testing.T) {
+	user := createRandomUser(t)
+	apiKey := createRandomAPIKey(t, user.Username)
+
+	found, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey.Prefix)
+	require.NoError(t, err)
+	require.Equal(t, apiKey.ID, found.ID)
+	require.Equal(t, apiKey.HashedSecret, found.HashedSecret)
+	require.Equal(t, apiKey.Scopes, found.Scopes)
+}
+
+func TestQueries_RevokeAPIKey(t *testing.T) {
+	user := createRandomUser(t)
+	other := createRandomUser(t)
+	apiKey1 := createRandomAPIKey(t, user.Username)
+	apiKey2 := createRandomAPIKey(t, user.Username)
+
+	// somebody else's key can't be revoked
+	revoked, err := testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{ID: apiKey1.ID, Username: other.Username})
+	require.NoError(t, err)
+	require.Zero(t, revoked)
+
+	revoked, err = testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{ID: apiKey1.ID, Username: user.Username})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), revoked)
+
+	revoked, err = testQueries.RevokeAPIKey(context.Background(), RevokeAPIKeyParams{ID: apiKey1.ID, Username: user.Username})
+	require.NoError(t, err)
+	require.Zero(t, revoked)
+
+	apiKeys, err := testQueries.ListAPIKeys(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, apiKeys, 1)
+	require.Equal(t, apiKey2.ID, apiKeys[0].ID)
+}
+
+func TestQueries_TouchAPIKey(t *testing.T) {
+	user := createRandomUser(t)
+	apiKey := createRandomAPIKey(t, user.Username)
+
+	require.NoError(t, testQueries.TouchAPIKey(context.Background(), apiKey.ID))
+	touched, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey.Prefix)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), touched.LastUsedAt, 5*time.Second)
+
+	// a second use within the minute is not written
+	require.NoError(t, testQueries.TouchAPIKey(context.Background(), apiKey.ID))
+	again, err := testQueries.GetAPIKeyByPrefix(context.Background(), apiKey.Prefix)
+	require.NoError(t, err)
+	require.Equal(t, touched.LastUsedAt, again.LastUsedAt)
+}
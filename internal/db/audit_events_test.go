@@ -0,0 +1,81 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/random"
+	"testing"
+)
+
+func createRandomAuditEvent(t *testing.T, actor, action string) AuditEvent {
+	arg := CreateAuditEventParams{
+		Actor:      actor,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   actor,
+		RequestID:  random.String(16),
+		Ip:         "192.0.2.1",
+		Outcome:    "success",
+		Diff:       json.RawMessage(`{"email": "someone@example.com"}`),
+	}
+
+	event, err := testQueries.CreateAuditEvent(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, event.ID)
+	require.Equal(t, arg.Actor, event.Actor)
+	require.Equal(t, arg.Action, event.Action)
+	require.Equal(t, arg.RequestID, event.RequestID)
+	require.JSONEq(t, string(arg.Diff), string(event.Diff))
+	require.NotZero(t, event.CreatedAt)
+
+	return event
+}
+
+func TestQueries_ListAuditEvents(t *testing.T) {
+	actor := random.Username()
+	first := createRandomAuditEvent(t, actor, "user.login")
+	second := createRandomAuditEvent(t, actor, "user.update")
+	third := createRandomAuditEvent(t, actor, "user.login")
+	createRandomAuditEvent(t, random.Username(), "user.login")
+
+	events, err := testQueries.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Actor:    pgtype.Text{String: actor, Valid: true},
+		RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	// newest first
+	require.Equal(t, third.ID, events[0].ID)
+	require.Equal(t, second.ID, events[1].ID)
+	require.Equal(t, first.ID, events[2].ID)
+
+	events, err = testQueries.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Actor:    pgtype.Text{String: actor, Valid: true},
+		Action:   pgtype.Text{String: "user.login", Valid: true},
+		BeforeID: pgtype.Int8{Int64: third.ID, Valid: true},
+		RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, first.ID, events[0].ID)
+
+	events, err = testQueries.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Actor:    pgtype.Text{String: actor, Valid: true},
+		Since:    pgtype.Timestamptz{Time: third.CreatedAt.Add(1), Valid: true},
+		RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func TestAuditEvents_AppendOnly(t *testing.T) {
+	event := createRandomAuditEvent(t, random.Username(), "user.login")
+
+	_, err := testDB.Exec(context.Background(), "UPDATE audit_events SET outcome = 'failure' WHERE id = $1", event.ID)
+	require.ErrorContains(t, err, "append-only")
+
+	_, err = testDB.Exec(context.Background(), "DELETE FROM audit_events WHERE id = $1", event.ID)
+	require.ErrorContains(t, err, "append-only")
+}
@@ -0,0 +1,25 @@
+package db_test
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/config"
+	"simple-bank/internal/db"
+	"simple-bank/internal/db/storetest"
+	"testing"
+)
+
+// TestSQLStore_Conformance runs the suite the in-memory store is held to,
+// so that both keep behaving alike.
+func TestSQLStore_Conformance(t *testing.T) {
+	cfg, err := config.Load("../../")
+	require.NoError(t, err)
+	conn, err := pgxpool.New(context.Background(), cfg.DBSource)
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+
+	storetest.Run(t, func(t *testing.T) db.Store {
+		return db.NewStore(conn)
+	})
+}
@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"log/slog"
+	"time"
+)
+
+// PoolConfig sizes the connection pool, zero values keep the pgxpool
+// defaults.
+type PoolConfig struct {
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+}
+
+// Open opens a pool and pings it until the database answers, waiting backoff
+// after the first failure and twice as long after every next one. The
+// database usually starts alongside the app and takes a moment to accept
+// connections.
+func Open(ctx context.Context, source string, pool PoolConfig, attempts int, backoff time.Duration) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(source)
+	if err != nil {
+		return nil, err
+	}
+	if pool.MaxConns > 0 {
+		config.MaxConns = pool.MaxConns
+	}
+	if pool.MinConns > 0 {
+		config.MinConns = pool.MinConns
+	}
+	if pool.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = pool.MaxConnLifetime
+	}
+	if pool.MaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = pool.MaxConnIdleTime
+	}
+
+	conn, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if err = ping(ctx, conn, attempts, backoff); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+func ping(ctx context.Context, conn pinger, attempts int, backoff time.Duration) error {
+	for attempt := 1; ; attempt++ {
+		err := conn.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return fmt.Errorf("database not reachable after %d attempts: %w", attempt, err)
+		}
+
+		slog.Warn("database not reachable, retrying", "backoff", backoff, "err", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
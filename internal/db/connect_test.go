@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type flakyPinger struct {
+	failures int
+	pings    int
+}
+
+func (p *flakyPinger) Ping(context.Context) error {
+	p.pings++
+	if p.pings <= p.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestPing_Retries(t *testing.T) {
+	conn := &flakyPinger{failures: 2}
+	require.NoError(t, ping(context.Background(), conn, 3, time.Millisecond))
+	require.Equal(t, 3, conn.pings)
+
+	conn = &flakyPinger{failures: 3}
+	err := ping(context.Background(), conn, 3, time.Millisecond)
+	require.ErrorContains(t, err, "after 3 attempts")
+	require.ErrorContains(t, err, "connection refused")
+	require.Equal(t, 3, conn.pings)
+}
+
+func TestPing_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	conn := &flakyPinger{failures: 1}
+	require.ErrorIs(t, ping(ctx, conn, 3, time.Hour), context.Canceled)
+	require.Equal(t, 1, conn.pings)
+}
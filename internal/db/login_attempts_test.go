@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/random"
+	"testing"
+)
+
+func TestQueries_LoginAttempts(t *testing.T) {
+	username := random.Username()
+
+	for _, succeeded := range []bool{false, true} {
+		attempt, err := testQueries.CreateLoginAttempt(context.Background(), CreateLoginAttemptParams{
+			Username:  username,
+			ClientIp:  "127.0.0.1",
+			Succeeded: succeeded,
+		})
+		require.NoError(t, err)
+		require.NotZero(t, attempt.ID)
+		require.NotZero(t, attempt.CreatedAt)
+		require.Equal(t, username, attempt.Username)
+		require.Equal(t, succeeded, attempt.Succeeded)
+	}
+
+	attempts, err := testQueries.ListLoginAttempts(context.Background(), ListLoginAttemptsParams{
+		Username: username,
+		Limit:    10,
+	})
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+	require.True(t, attempts[0].Succeeded)
+	require.False(t, attempts[1].Succeeded)
+}
@@ -1,8 +1,8 @@
 package db
 
 import (
-	"database/sql"
-	_ "github.com/lib/pq"
+	"context"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"log"
 	"os"
 	"simple-bank/internal/config"
@@ -10,19 +10,21 @@ import (
 )
 
 var testQueries *Queries
-var testDB *sql.DB
+var testDB *pgxpool.Pool
+var testDBSource string
 
 func TestMain(m *testing.M) {
 	cfg, err := config.Load("../../")
 	if err != nil {
 		log.Fatal(err)
 	}
-	conn, err := sql.Open(cfg.DBDriver, cfg.DBSource)
+	conn, err := pgxpool.New(context.Background(), cfg.DBSource)
 	if err != nil {
 		log.Fatal("can't connect to db", err)
 	}
 
 	testDB = conn
+	testDBSource = cfg.DBSource
 	testQueries = New(conn)
 
 	os.Exit(m.Run())
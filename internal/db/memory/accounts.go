@@ -0,0 +1,305 @@
+package memdb
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"simple-bank/internal/db"
+	"strconv"
+)
+
+func (s *Store) CreateAccount(_ context.Context, arg db.CreateAccountParams) (db.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkNewAccount(arg); err != nil {
+		return db.Account{}, err
+	}
+	return s.createAccount(arg), nil
+}
+
+func (s *Store) checkNewAccount(arg db.CreateAccountParams) error {
+	if _, ok := s.users[arg.Owner]; !ok {
+		return foreignKeyViolation("accounts", "accounts_owner_fkey")
+	}
+	for _, account := range s.accounts {
+		if account.Owner == arg.Owner && account.Currency == arg.Currency {
+			return uniqueViolation("accounts", "owner_currency_key")
+		}
+	}
+	return nil
+}
+
+func (s *Store) createAccount(arg db.CreateAccountParams) db.Account {
+	account := db.Account{
+		ID:        s.nextID("accounts"),
+		Owner:     arg.Owner,
+		Balance:   arg.Balance,
+		Currency:  arg.Currency,
+		CreatedAt: now(),
+		Version:   1,
+	}
+	s.accounts[account.ID] = account
+	return account
+}
+
+func (s *Store) GetAccount(_ context.Context, id int64) (db.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[id]
+	if !ok {
+		return db.Account{}, pgx.ErrNoRows
+	}
+	return account, nil
+}
+
+// GetAccountForUpdate has nothing to lock, every call holds the store lock.
+func (s *Store) GetAccountForUpdate(ctx context.Context, id int64) (db.Account, error) {
+	return s.GetAccount(ctx, id)
+}
+
+func (s *Store) ListAccounts(_ context.Context, arg db.ListAccountsParams) ([]db.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.Account{}
+	for _, account := range sortedByID(s.accounts) {
+		if account.Owner == arg.Owner {
+			items = append(items, account)
+		}
+	}
+	if int(arg.Offset) >= len(items) {
+		return []db.Account{}, nil
+	}
+	return limit(items[arg.Offset:], arg.Limit), nil
+}
+
+func (s *Store) AddAccountBalance(_ context.Context, arg db.AddAccountBalanceParams) (db.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addAccountBalance(arg)
+}
+
+func (s *Store) addAccountBalance(arg db.AddAccountBalanceParams) (db.Account, error) {
+	account, ok := s.accounts[arg.ID]
+	if !ok || !account.FrozenAt.IsZero() {
+		return db.Account{}, pgx.ErrNoRows
+	}
+	account.Balance += arg.Amount
+	account.Version++
+	s.accounts[account.ID] = account
+	return account, nil
+}
+
+func (s *Store) FreezeAccount(_ context.Context, id int64) (db.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.accounts[id]
+	if !ok {
+		return db.Account{}, pgx.ErrNoRows
+	}
+	account.FrozenAt = now()
+	account.Version++
+	s.accounts[id] = account
+	return account, nil
+}
+
+func (s *Store) DeleteAccount(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range s.entries {
+		if entry.AccountID == id {
+			return referencedViolation("entries", "accounts", "entries_account_id_fkey")
+		}
+	}
+	for _, transfer := range s.transfers {
+		if transfer.FromAccountID == id {
+			return referencedViolation("transfers", "accounts", "transfers_from_account_id_fkey")
+		}
+		if transfer.ToAccountID == id {
+			return referencedViolation("transfers", "accounts", "transfers_to_account_id_fkey")
+		}
+	}
+	delete(s.accounts, id)
+	return nil
+}
+
+func (s *Store) CreateEntry(_ context.Context, arg db.CreateEntryParams) (db.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.accounts[arg.AccountID]; !ok {
+		return db.Entry{}, foreignKeyViolation("entries", "entries_account_id_fkey")
+	}
+	return s.createEntry(arg), nil
+}
+
+func (s *Store) createEntry(arg db.CreateEntryParams) db.Entry {
+	entry := db.Entry{
+		ID:        s.nextID("entries"),
+		AccountID: arg.AccountID,
+		Amount:    arg.Amount,
+		CreatedAt: now(),
+	}
+	s.entries[entry.ID] = entry
+	return entry
+}
+
+func (s *Store) GetEntry(_ context.Context, id int64) (db.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return db.Entry{}, pgx.ErrNoRows
+	}
+	return entry, nil
+}
+
+func (s *Store) CreateTransfer(_ context.Context, arg db.CreateTransferParams) (db.Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkNewTransfer(arg.FromAccountID, arg.ToAccountID); err != nil {
+		return db.Transfer{}, err
+	}
+	return s.createTransfer(arg), nil
+}
+
+func (s *Store) checkNewTransfer(fromAccountID, toAccountID int64) error {
+	if _, ok := s.accounts[fromAccountID]; !ok {
+		return foreignKeyViolation("transfers", "transfers_from_account_id_fkey")
+	}
+	if _, ok := s.accounts[toAccountID]; !ok {
+		return foreignKeyViolation("transfers", "transfers_to_account_id_fkey")
+	}
+	return nil
+}
+
+func (s *Store) createTransfer(arg db.CreateTransferParams) db.Transfer {
+	transfer := db.Transfer{
+		ID:            s.nextID("transfers"),
+		FromAccountID: arg.FromAccountID,
+		ToAccountID:   arg.ToAccountID,
+		Amount:        arg.Amount,
+		CreatedAt:     now(),
+	}
+	s.transfers[transfer.ID] = transfer
+	return transfer
+}
+
+func (s *Store) GetTransfer(_ context.Context, id int64) (db.Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfer, ok := s.transfers[id]
+	if !ok {
+		return db.Transfer{}, pgx.ErrNoRows
+	}
+	return transfer, nil
+}
+
+func (s *Store) CreateAccountTx(ctx context.Context, args db.CreateAccountParams) (db.Account, error) {
+	account, err := func() (db.Account, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if err := s.checkNewAccount(args); err != nil {
+			return db.Account{}, err
+		}
+
+		account := s.createAccount(args)
+		payload, err := json.Marshal(account)
+		if err != nil {
+			delete(s.accounts, account.ID)
+			return db.Account{}, err
+		}
+		s.createOutboxEvent(db.OutboxAggregateAccount, account.ID, db.EventAccountCreated, payload)
+		return account, nil
+	}()
+	if err != nil {
+		return db.Account{}, err
+	}
+
+	return account, s.NotifyAccountEvents(ctx, account.Owner)
+}
+
+func (s *Store) TransferTx(ctx context.Context, args db.TransferTxParams) (db.TransferTxResult, error) {
+	result, err := s.transferTx(args)
+	if err != nil {
+		return db.TransferTxResult{}, err
+	}
+
+	// the notifications of a transaction follow its commit
+	if err = s.NotifyAccountEvents(ctx, result.FromAccount.Owner); err != nil {
+		return db.TransferTxResult{}, err
+	}
+	return result, s.NotifyAccountEvents(ctx, result.ToAccount.Owner)
+}
+
+func (s *Store) transferTx(args db.TransferTxParams) (db.TransferTxResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkNewTransfer(args.FromAccountID, args.ToAccountID); err != nil {
+		return db.TransferTxResult{}, err
+	}
+	// checked in the order SQLStore updates the balances
+	for _, id := range []int64{min(args.FromAccountID, args.ToAccountID), max(args.FromAccountID, args.ToAccountID)} {
+		if !s.accounts[id].FrozenAt.IsZero() {
+			return db.TransferTxResult{}, &db.AccountFrozenError{AccountID: id}
+		}
+	}
+
+	var result db.TransferTxResult
+	result.Transfer = s.createTransfer(db.CreateTransferParams{
+		FromAccountID: args.FromAccountID,
+		ToAccountID:   args.ToAccountID,
+		Amount:        args.Amount,
+	})
+	result.FromEntry = s.createEntry(db.CreateEntryParams{AccountID: args.FromAccountID, Amount: -args.Amount})
+	result.ToEntry = s.createEntry(db.CreateEntryParams{AccountID: args.ToAccountID, Amount: args.Amount})
+
+	// both accounts exist and neither is frozen, no update can fail. The order is the one
+	// SQLStore locks them in, it shows in a transfer to the same account.
+	if args.FromAccountID < args.ToAccountID {
+		result.FromAccount, _ = s.addAccountBalance(db.AddAccountBalanceParams{ID: args.FromAccountID, Amount: -args.Amount})
+		result.ToAccount, _ = s.addAccountBalance(db.AddAccountBalanceParams{ID: args.ToAccountID, Amount: args.Amount})
+	} else {
+		result.ToAccount, _ = s.addAccountBalance(db.AddAccountBalanceParams{ID: args.ToAccountID, Amount: args.Amount})
+		result.FromAccount, _ = s.addAccountBalance(db.AddAccountBalanceParams{ID: args.FromAccountID, Amount: -args.Amount})
+	}
+
+	events := []struct {
+		aggregateType string
+		aggregateID   int64
+		eventType     string
+		payload       any
+	}{
+		{db.OutboxAggregateAccount, result.FromAccount.ID, db.EventAccountBalanceChanged, db.BalanceChangedEvent{Account: result.FromAccount, Entry: result.FromEntry}},
+		{db.OutboxAggregateAccount, result.ToAccount.ID, db.EventAccountBalanceChanged, db.BalanceChangedEvent{Account: result.ToAccount, Entry: result.ToEntry}},
+		{db.OutboxAggregateTransfer, result.Transfer.ID, db.EventTransferCompleted, result},
+	}
+	for _, event := range events {
+		payload, err := json.Marshal(event.payload)
+		if err != nil {
+			return db.TransferTxResult{}, err
+		}
+		s.createOutboxEvent(event.aggregateType, event.aggregateID, event.eventType, payload)
+	}
+
+	return result, nil
+}
+
+func (s *Store) createOutboxEvent(aggregateType string, aggregateID int64, eventType string, payload json.RawMessage) db.Outbox {
+	return s.insertOutboxEvent(db.CreateOutboxEventParams{
+		AggregateType: aggregateType,
+		AggregateID:   strconv.FormatInt(aggregateID, 10),
+		EventType:     eventType,
+		Payload:       payload,
+	})
+}
@@ -0,0 +1,101 @@
+package memdb
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"simple-bank/internal/db"
+	"slices"
+	"time"
+)
+
+func (s *Store) CreateAPIKey(_ context.Context, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.apiKeyIndex(arg.ID) >= 0 {
+		return db.ApiKey{}, uniqueViolation("api_keys", "api_keys_pkey")
+	}
+	if slices.ContainsFunc(s.apiKeys, func(key db.ApiKey) bool { return key.Prefix == arg.Prefix }) {
+		return db.ApiKey{}, uniqueViolation("api_keys", "api_keys_prefix_key")
+	}
+	if _, ok := s.users[arg.Username]; !ok {
+		return db.ApiKey{}, foreignKeyViolation("api_keys", "api_keys_username_fkey")
+	}
+	scopes, err := array("api_keys", "scopes", arg.Scopes)
+	if err != nil {
+		return db.ApiKey{}, err
+	}
+
+	key := db.ApiKey{
+		ID:           arg.ID,
+		Username:     arg.Username,
+		Name:         arg.Name,
+		Prefix:       arg.Prefix,
+		HashedSecret: arg.HashedSecret,
+		Scopes:       scopes,
+		ExpiresAt:    timestamp(arg.ExpiresAt),
+		CreatedAt:    now(),
+	}
+	s.apiKeys = append(s.apiKeys, key)
+	return cloneAPIKey(key), nil
+}
+
+func (s *Store) GetAPIKeyByPrefix(_ context.Context, prefix string) (db.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.apiKeys {
+		if key.Prefix == prefix {
+			return cloneAPIKey(key), nil
+		}
+	}
+	return db.ApiKey{}, pgx.ErrNoRows
+}
+
+func (s *Store) ListAPIKeys(_ context.Context, username string) ([]db.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.ApiKey{}
+	for _, key := range s.apiKeys {
+		if key.Username == username && key.RevokedAt.IsZero() {
+			items = append(items, cloneAPIKey(key))
+		}
+	}
+	stableSortBy(items, func(key db.ApiKey) int64 { return key.CreatedAt.UnixNano() })
+	return items, nil
+}
+
+func (s *Store) RevokeAPIKey(_ context.Context, arg db.RevokeAPIKeyParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.apiKeyIndex(arg.ID)
+	if i < 0 || s.apiKeys[i].Username != arg.Username || !s.apiKeys[i].RevokedAt.IsZero() {
+		return 0, nil
+	}
+	s.apiKeys[i].RevokedAt = now()
+	return 1, nil
+}
+
+// TouchAPIKey records the use of a key at most once a minute.
+func (s *Store) TouchAPIKey(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.apiKeyIndex(id)
+	if i >= 0 && s.apiKeys[i].LastUsedAt.Before(time.Now().Add(-time.Minute)) {
+		s.apiKeys[i].LastUsedAt = now()
+	}
+	return nil
+}
+
+func (s *Store) apiKeyIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.apiKeys, func(key db.ApiKey) bool { return key.ID == id })
+}
+
+func cloneAPIKey(key db.ApiKey) db.ApiKey {
+	key.Scopes = slices.Clone(key.Scopes)
+	return key
+}
@@ -0,0 +1,60 @@
+package memdb
+
+import (
+	"context"
+	"simple-bank/internal/db"
+	"slices"
+)
+
+func (s *Store) CreateAuditEvent(_ context.Context, arg db.CreateAuditEventParams) (db.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	diff, err := jsonb("audit_events", "diff", arg.Diff)
+	if err != nil {
+		return db.AuditEvent{}, err
+	}
+
+	event := db.AuditEvent{
+		ID:         s.nextID("audit_events"),
+		Actor:      arg.Actor,
+		Action:     arg.Action,
+		TargetType: arg.TargetType,
+		TargetID:   arg.TargetID,
+		RequestID:  arg.RequestID,
+		Ip:         arg.Ip,
+		Outcome:    arg.Outcome,
+		Diff:       diff,
+		CreatedAt:  now(),
+	}
+	s.auditEvents[event.ID] = event
+	return cloneAuditEvent(event), nil
+}
+
+// ListAuditEvents applies the filters that are set, newest events first.
+func (s *Store) ListAuditEvents(_ context.Context, arg db.ListAuditEventsParams) ([]db.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.AuditEvent{}
+	for _, event := range reversed(sortedByID(s.auditEvents)) {
+		switch {
+		case arg.Actor.Valid && event.Actor != arg.Actor.String,
+			arg.Action.Valid && event.Action != arg.Action.String,
+			arg.TargetType.Valid && event.TargetType != arg.TargetType.String,
+			arg.TargetID.Valid && event.TargetID != arg.TargetID.String,
+			arg.Outcome.Valid && event.Outcome != arg.Outcome.String,
+			arg.Since.Valid && event.CreatedAt.Before(arg.Since.Time),
+			arg.Until.Valid && !event.CreatedAt.Before(arg.Until.Time),
+			arg.BeforeID.Valid && event.ID >= arg.BeforeID.Int64:
+			continue
+		}
+		items = append(items, cloneAuditEvent(event))
+	}
+	return limit(items, arg.RowLimit), nil
+}
+
+func cloneAuditEvent(event db.AuditEvent) db.AuditEvent {
+	event.Diff = slices.Clone(event.Diff)
+	return event
+}
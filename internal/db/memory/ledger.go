@@ -0,0 +1,33 @@
+package memdb
+
+import (
+	"context"
+	"simple-bank/internal/db"
+)
+
+func (s *Store) ListLedgerMismatches(_ context.Context) ([]db.ListLedgerMismatchesRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make(map[int64]int64)
+	for _, entry := range s.entries {
+		entries[entry.AccountID] += entry.Amount
+	}
+	transfers := make(map[int64]int64)
+	for _, transfer := range s.transfers {
+		transfers[transfer.ToAccountID] += transfer.Amount
+		transfers[transfer.FromAccountID] -= transfer.Amount
+	}
+
+	items := []db.ListLedgerMismatchesRow{}
+	for _, account := range sortedByID(s.accounts) {
+		if entries[account.ID] != transfers[account.ID] {
+			items = append(items, db.ListLedgerMismatchesRow{
+				AccountID:      account.ID,
+				EntriesTotal:   entries[account.ID],
+				TransfersTotal: transfers[account.ID],
+			})
+		}
+	}
+	return items, nil
+}
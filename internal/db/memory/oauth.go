@@ -0,0 +1,141 @@
+package memdb
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"simple-bank/internal/db"
+	"slices"
+	"time"
+)
+
+func (s *Store) CreateOAuthClient(_ context.Context, arg db.CreateOAuthClientParams) (db.OauthClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.oauthClientIndex(arg.ID) >= 0 {
+		return db.OauthClient{}, uniqueViolation("oauth_clients", "oauth_clients_pkey")
+	}
+	if _, ok := s.users[arg.Owner]; !ok {
+		return db.OauthClient{}, foreignKeyViolation("oauth_clients", "oauth_clients_owner_fkey")
+	}
+	redirectURIs, err := array("oauth_clients", "redirect_uris", arg.RedirectUris)
+	if err != nil {
+		return db.OauthClient{}, err
+	}
+	scopes, err := array("oauth_clients", "scopes", arg.Scopes)
+	if err != nil {
+		return db.OauthClient{}, err
+	}
+
+	client := db.OauthClient{
+		ID:           arg.ID,
+		Owner:        arg.Owner,
+		Name:         arg.Name,
+		HashedSecret: arg.HashedSecret,
+		RedirectUris: redirectURIs,
+		Scopes:       scopes,
+		CreatedAt:    now(),
+	}
+	s.oauthClients = append(s.oauthClients, client)
+	return cloneOAuthClient(client), nil
+}
+
+func (s *Store) GetOAuthClient(_ context.Context, id string) (db.OauthClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.oauthClientIndex(id)
+	if i < 0 {
+		return db.OauthClient{}, pgx.ErrNoRows
+	}
+	return cloneOAuthClient(s.oauthClients[i]), nil
+}
+
+func (s *Store) ListOAuthClients(_ context.Context, owner string) ([]db.OauthClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.OauthClient{}
+	for _, client := range s.oauthClients {
+		if client.Owner == owner {
+			items = append(items, cloneOAuthClient(client))
+		}
+	}
+	stableSortBy(items, func(client db.OauthClient) int64 { return client.CreatedAt.UnixNano() })
+	return items, nil
+}
+
+func (s *Store) CreateOAuthAuthorizationCode(_ context.Context, arg db.CreateOAuthAuthorizationCodeParams) (db.OauthAuthorizationCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, code := range s.oauthCodes {
+		if code.HashedCode == arg.HashedCode {
+			return db.OauthAuthorizationCode{}, uniqueViolation("oauth_authorization_codes", "oauth_authorization_codes_hashed_code_key")
+		}
+	}
+	if s.oauthClientIndex(arg.ClientID) < 0 {
+		return db.OauthAuthorizationCode{}, foreignKeyViolation("oauth_authorization_codes", "oauth_authorization_codes_client_id_fkey")
+	}
+	if _, ok := s.users[arg.Username]; !ok {
+		return db.OauthAuthorizationCode{}, foreignKeyViolation("oauth_authorization_codes", "oauth_authorization_codes_username_fkey")
+	}
+	scopes, err := array("oauth_authorization_codes", "scopes", arg.Scopes)
+	if err != nil {
+		return db.OauthAuthorizationCode{}, err
+	}
+
+	code := db.OauthAuthorizationCode{
+		ID:            s.nextID("oauth_authorization_codes"),
+		HashedCode:    arg.HashedCode,
+		ClientID:      arg.ClientID,
+		Username:      arg.Username,
+		RedirectUri:   arg.RedirectUri,
+		Scopes:        scopes,
+		CodeChallenge: arg.CodeChallenge,
+		ExpiresAt:     timestamp(arg.ExpiresAt),
+		CreatedAt:     now(),
+	}
+	s.oauthCodes[code.ID] = code
+	return cloneOAuthCode(code), nil
+}
+
+func (s *Store) GetOAuthAuthorizationCode(_ context.Context, hashedCode string) (db.OauthAuthorizationCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, code := range s.oauthCodes {
+		if code.HashedCode == hashedCode {
+			return cloneOAuthCode(code), nil
+		}
+	}
+	return db.OauthAuthorizationCode{}, pgx.ErrNoRows
+}
+
+func (s *Store) UseOAuthAuthorizationCode(_ context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.oauthCodes[id]
+	if !ok || !code.UsedAt.IsZero() || !code.ExpiresAt.After(time.Now()) {
+		return 0, nil
+	}
+	code.UsedAt = now()
+	s.oauthCodes[id] = code
+	return 1, nil
+}
+
+func (s *Store) oauthClientIndex(id string) int {
+	return slices.IndexFunc(s.oauthClients, func(client db.OauthClient) bool { return client.ID == id })
+}
+
+func cloneOAuthClient(client db.OauthClient) db.OauthClient {
+	client.RedirectUris = slices.Clone(client.RedirectUris)
+	client.Scopes = slices.Clone(client.Scopes)
+	return client
+}
+
+func cloneOAuthCode(code db.OauthAuthorizationCode) db.OauthAuthorizationCode {
+	code.Scopes = slices.Clone(code.Scopes)
+	return code
+}
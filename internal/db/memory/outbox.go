@@ -0,0 +1,151 @@
+package memdb
+
+import (
+	"context"
+	"simple-bank/internal/db"
+	"slices"
+	"strconv"
+	"time"
+)
+
+func (s *Store) CreateOutboxEvent(_ context.Context, arg db.CreateOutboxEventParams) (db.Outbox, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	payload, err := jsonb("outbox", "payload", arg.Payload)
+	if err != nil {
+		return db.Outbox{}, err
+	}
+	arg.Payload = payload
+	return cloneOutboxEvent(s.insertOutboxEvent(arg)), nil
+}
+
+func (s *Store) insertOutboxEvent(arg db.CreateOutboxEventParams) db.Outbox {
+	t := now()
+	event := db.Outbox{
+		ID:            s.nextID("outbox"),
+		AggregateType: arg.AggregateType,
+		AggregateID:   arg.AggregateID,
+		EventType:     arg.EventType,
+		Payload:       arg.Payload,
+		NextAttemptAt: t,
+		CreatedAt:     t,
+	}
+	s.outbox[event.ID] = event
+	return event
+}
+
+// ClaimOutboxEvents leases the events that are due. An event waits for the
+// earlier unpublished events of its aggregate, they are published in order.
+func (s *Store) ClaimOutboxEvents(_ context.Context, arg db.ClaimOutboxEventsParams) ([]db.Outbox, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := time.Now()
+	blocked := make(map[[2]string]bool)
+	items := []db.Outbox{}
+	for _, event := range sortedByID(s.outbox) {
+		if !event.PublishedAt.IsZero() {
+			continue
+		}
+		aggregate := [2]string{event.AggregateType, event.AggregateID}
+		if blocked[aggregate] {
+			continue
+		}
+		blocked[aggregate] = true
+
+		if event.NextAttemptAt.After(t) || len(items) == int(arg.RowLimit) {
+			continue
+		}
+		event.Attempts++
+		event.NextAttemptAt = timestamp(arg.LeaseUntil)
+		s.outbox[event.ID] = event
+		items = append(items, cloneOutboxEvent(event))
+	}
+	return items, nil
+}
+
+func (s *Store) MarkOutboxEventPublished(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if event, ok := s.outbox[id]; ok {
+		event.PublishedAt = now()
+		event.LastError = ""
+		s.outbox[id] = event
+	}
+	return nil
+}
+
+func (s *Store) MarkOutboxEventFailed(_ context.Context, arg db.MarkOutboxEventFailedParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if event, ok := s.outbox[arg.ID]; ok {
+		event.LastError = arg.LastError
+		event.NextAttemptAt = timestamp(arg.NextAttemptAt)
+		s.outbox[arg.ID] = event
+	}
+	return nil
+}
+
+func (s *Store) DeletePublishedOutboxEvents(_ context.Context, publishedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, event := range s.outbox {
+		if !event.PublishedAt.IsZero() && event.PublishedAt.Before(publishedAt) {
+			delete(s.outbox, id)
+		}
+	}
+	return nil
+}
+
+// ListAccountEvents returns the events of the accounts of owner after the
+// given id, whether they are published or not.
+func (s *Store) ListAccountEvents(_ context.Context, arg db.ListAccountEventsParams) ([]db.Outbox, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := make(map[string]bool)
+	for id, account := range s.accounts {
+		if account.Owner == arg.Owner {
+			accounts[strconv.FormatInt(id, 10)] = true
+		}
+	}
+
+	items := []db.Outbox{}
+	for _, event := range sortedByID(s.outbox) {
+		if event.AggregateType == db.OutboxAggregateAccount && accounts[event.AggregateID] && event.ID > arg.AfterID {
+			items = append(items, cloneOutboxEvent(event))
+		}
+	}
+	return limit(items, arg.RowLimit), nil
+}
+
+// GetLatestAccountEventID returns the id of the last event of the accounts
+// of owner, zero when there is none.
+func (s *Store) GetLatestAccountEventID(_ context.Context, owner string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var latestID int64
+	for _, event := range s.outbox {
+		if event.AggregateType != db.OutboxAggregateAccount || event.ID <= latestID {
+			continue
+		}
+		id, err := strconv.ParseInt(event.AggregateID, 10, 64)
+		if err != nil {
+			continue
+		}
+		if account, ok := s.accounts[id]; ok && account.Owner == owner {
+			latestID = event.ID
+		}
+	}
+	return latestID, nil
+}
+
+func cloneOutboxEvent(event db.Outbox) db.Outbox {
+	event.Payload = slices.Clone(event.Payload)
+	return event
+}
@@ -0,0 +1,52 @@
+package memdb
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"simple-bank/internal/db"
+	"time"
+)
+
+// RefillRateLimitBucket adds the tokens earned since the last refill, a new
+// bucket starts full.
+func (s *Store) RefillRateLimitBucket(_ context.Context, arg db.RefillRateLimitBucketParams) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := now()
+	bucket, ok := s.rateLimitBuckets[arg.Key]
+	if !ok {
+		bucket = db.RateLimitBucket{Key: arg.Key, Tokens: arg.Burst}
+	} else {
+		elapsed := max(t.Sub(bucket.UpdatedAt).Seconds(), 0)
+		bucket.Tokens = min(arg.Burst, bucket.Tokens+elapsed*arg.Rate)
+	}
+	bucket.UpdatedAt = t
+	s.rateLimitBuckets[arg.Key] = bucket
+	return bucket.Tokens, nil
+}
+
+func (s *Store) TakeRateLimitToken(_ context.Context, key string) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.rateLimitBuckets[key]
+	if !ok || bucket.Tokens < 1 {
+		return 0, pgx.ErrNoRows
+	}
+	bucket.Tokens--
+	s.rateLimitBuckets[key] = bucket
+	return bucket.Tokens, nil
+}
+
+func (s *Store) DeleteStaleRateLimitBuckets(_ context.Context, updatedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, bucket := range s.rateLimitBuckets {
+		if bucket.UpdatedAt.Before(updatedAt) {
+			delete(s.rateLimitBuckets, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+// Package memdb is an in-memory db.Store for tests and local development.
+// It follows the semantics of the Postgres schema, constraint violations
+// are reported as the *pq.Error Postgres would have returned and missing
+// rows as pgx.ErrNoRows.
+package memdb
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"simple-bank/internal/db"
+	"slices"
+	"sync"
+	"time"
+)
+
+var _ db.Store = (*Store)(nil)
+
+// Store keeps every table in memory behind a single lock. Transactions run
+// their checks before any write, a failed one leaves nothing behind.
+type Store struct {
+	mu sync.Mutex
+
+	sequences map[string]int64
+
+	accounts             map[int64]db.Account
+	entries              map[int64]db.Entry
+	transfers            map[int64]db.Transfer
+	users                map[string]db.User
+	loginAttempts        map[int64]db.LoginAttempt
+	rateLimitBuckets     map[string]db.RateLimitBucket
+	userTOTPs            map[string]db.UserTotp
+	recoveryCodes        map[int64]db.RecoveryCode
+	userTokens           map[int64]db.UserToken
+	apiKeys              []db.ApiKey
+	oauthClients         []db.OauthClient
+	oauthCodes           map[int64]db.OauthAuthorizationCode
+	auditEvents          map[int64]db.AuditEvent
+	outbox               map[int64]db.Outbox
+	webhookSubscriptions map[int64]db.WebhookSubscription
+	webhookDeliveries    map[int64]db.WebhookDelivery
+
+	accountEventsListener func(owner string)
+}
+
+func NewStore() *Store {
+	return &Store{
+		sequences:            make(map[string]int64),
+		accounts:             make(map[int64]db.Account),
+		entries:              make(map[int64]db.Entry),
+		transfers:            make(map[int64]db.Transfer),
+		users:                make(map[string]db.User),
+		loginAttempts:        make(map[int64]db.LoginAttempt),
+		rateLimitBuckets:     make(map[string]db.RateLimitBucket),
+		userTOTPs:            make(map[string]db.UserTotp),
+		recoveryCodes:        make(map[int64]db.RecoveryCode),
+		userTokens:           make(map[int64]db.UserToken),
+		oauthCodes:           make(map[int64]db.OauthAuthorizationCode),
+		auditEvents:          make(map[int64]db.AuditEvent),
+		outbox:               make(map[int64]db.Outbox),
+		webhookSubscriptions: make(map[int64]db.WebhookSubscription),
+		webhookDeliveries:    make(map[int64]db.WebhookDelivery),
+	}
+}
+
+// OnAccountEvents registers the listener NotifyAccountEvents calls, it
+// stands in for LISTEN on the db.AccountEventsChannel.
+func (s *Store) OnAccountEvents(listener func(owner string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accountEventsListener = listener
+}
+
+func (s *Store) NotifyAccountEvents(_ context.Context, owner string) error {
+	s.mu.Lock()
+	listener := s.accountEventsListener
+	s.mu.Unlock()
+
+	if listener != nil {
+		listener(owner)
+	}
+	return nil
+}
+
+// nextID is the bigserial of table.
+func (s *Store) nextID(table string) int64 {
+	s.sequences[table]++
+	return s.sequences[table]
+}
+
+// now has the precision of timestamptz.
+func now() time.Time {
+	return time.Now().Round(time.Microsecond)
+}
+
+func timestamp(t time.Time) time.Time {
+	return t.Round(time.Microsecond)
+}
+
+// sortedByID returns the rows of a table with a bigserial key in id order.
+func sortedByID[T any](rows map[int64]T) []T {
+	ids := make([]int64, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	sorted := make([]T, len(ids))
+	for i, id := range ids {
+		sorted[i] = rows[id]
+	}
+	return sorted
+}
+
+// limit applies a LIMIT clause.
+func limit[T any](rows []T, n int32) []T {
+	if n < 0 || int(n) >= len(rows) {
+		return rows
+	}
+	return rows[:n]
+}
+
+func reversed[T any](rows []T) []T {
+	slices.Reverse(rows)
+	return rows
+}
+
+func stableSortBy[T any, K cmp.Ordered](rows []T, key func(T) K) {
+	slices.SortStableFunc(rows, func(a, b T) int {
+		return cmp.Compare(key(a), key(b))
+	})
+}
+
+// array is a varchar[] column, NULL is refused like NOT NULL does.
+func array(table, column string, values []string) ([]string, error) {
+	if values == nil {
+		return nil, notNullViolation(table, column)
+	}
+	return slices.Clone(values), nil
+}
+
+// jsonb is a jsonb column, it only takes valid documents.
+func jsonb(table, column string, value json.RawMessage) (json.RawMessage, error) {
+	if value == nil {
+		return nil, notNullViolation(table, column)
+	}
+	if !json.Valid(value) {
+		return nil, &pgconn.PgError{
+			Code:       pgerrcode.InvalidTextRepresentation,
+			Message:    "invalid input syntax for type json",
+			TableName:  table,
+			ColumnName: column,
+		}
+	}
+	return slices.Clone(value), nil
+}
+
+func uniqueViolation(table, constraint string) error {
+	return &pgconn.PgError{
+		Code:           pgerrcode.UniqueViolation,
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		TableName:      table,
+		ConstraintName: constraint,
+	}
+}
+
+func foreignKeyViolation(table, constraint string) error {
+	return &pgconn.PgError{
+		Code:           pgerrcode.ForeignKeyViolation,
+		Message:        fmt.Sprintf("insert or update on table %q violates foreign key constraint %q", table, constraint),
+		TableName:      table,
+		ConstraintName: constraint,
+	}
+}
+
+// referencedViolation is the foreign key violation of a delete, table is
+// the one still referencing the row.
+func referencedViolation(table, referencedTable, constraint string) error {
+	return &pgconn.PgError{
+		Code:           pgerrcode.ForeignKeyViolation,
+		Message:        fmt.Sprintf("update or delete on table %q violates foreign key constraint %q on table %q", referencedTable, constraint, table),
+		TableName:      referencedTable,
+		ConstraintName: constraint,
+	}
+}
+
+func notNullViolation(table, column string) error {
+	return &pgconn.PgError{
+		Code:       pgerrcode.NotNullViolation,
+		Message:    fmt.Sprintf("null value in column %q of relation %q violates not-null constraint", column, table),
+		TableName:  table,
+		ColumnName: column,
+	}
+}
@@ -0,0 +1,13 @@
+package memdb
+
+import (
+	"simple-bank/internal/db"
+	"simple-bank/internal/db/storetest"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) db.Store {
+		return NewStore()
+	})
+}
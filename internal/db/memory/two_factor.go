@@ -0,0 +1,170 @@
+package memdb
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"simple-bank/internal/db"
+)
+
+func (s *Store) UpsertUserTOTP(_ context.Context, arg db.UpsertUserTOTPParams) (db.UserTotp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.Username]; !ok {
+		return db.UserTotp{}, foreignKeyViolation("user_totp", "user_totp_username_fkey")
+	}
+
+	// a conflict starts the enrollment over
+	totp := db.UserTotp{
+		Username:  arg.Username,
+		Secret:    arg.Secret,
+		CreatedAt: now(),
+	}
+	s.userTOTPs[totp.Username] = totp
+	return totp, nil
+}
+
+func (s *Store) GetUserTOTP(_ context.Context, username string) (db.UserTotp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, ok := s.userTOTPs[username]
+	if !ok {
+		return db.UserTotp{}, pgx.ErrNoRows
+	}
+	return totp, nil
+}
+
+func (s *Store) ConfirmUserTOTP(_ context.Context, arg db.ConfirmUserTOTPParams) (db.UserTotp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.confirmUserTOTP(arg)
+}
+
+func (s *Store) confirmUserTOTP(arg db.ConfirmUserTOTPParams) (db.UserTotp, error) {
+	totp, ok := s.userTOTPs[arg.Username]
+	if !ok {
+		return db.UserTotp{}, pgx.ErrNoRows
+	}
+	totp.ConfirmedAt = now()
+	totp.LastUsedStep = arg.LastUsedStep
+	s.userTOTPs[arg.Username] = totp
+	return totp, nil
+}
+
+func (s *Store) UseTOTPStep(_ context.Context, arg db.UseTOTPStepParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, ok := s.userTOTPs[arg.Username]
+	if !ok || totp.LastUsedStep >= arg.Step {
+		return 0, nil
+	}
+	totp.LastUsedStep = arg.Step
+	s.userTOTPs[arg.Username] = totp
+	return 1, nil
+}
+
+func (s *Store) CreateRecoveryCode(_ context.Context, arg db.CreateRecoveryCodeParams) (db.RecoveryCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.Username]; !ok {
+		return db.RecoveryCode{}, foreignKeyViolation("recovery_codes", "recovery_codes_username_fkey")
+	}
+	return s.createRecoveryCode(arg), nil
+}
+
+func (s *Store) createRecoveryCode(arg db.CreateRecoveryCodeParams) db.RecoveryCode {
+	code := db.RecoveryCode{
+		ID:         s.nextID("recovery_codes"),
+		Username:   arg.Username,
+		HashedCode: arg.HashedCode,
+		CreatedAt:  now(),
+		Lookup:     arg.Lookup,
+	}
+	s.recoveryCodes[code.ID] = code
+	return code
+}
+
+func (s *Store) GetUnusedRecoveryCode(_ context.Context, arg db.GetUnusedRecoveryCodeParams) (db.RecoveryCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, code := range sortedByID(s.recoveryCodes) {
+		if code.Username == arg.Username && code.Lookup == arg.Lookup && code.UsedAt.IsZero() {
+			return code, nil
+		}
+	}
+	return db.RecoveryCode{}, pgx.ErrNoRows
+}
+
+func (s *Store) ListUnusedRecoveryCodes(_ context.Context, username string) ([]db.RecoveryCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.RecoveryCode{}
+	for _, code := range sortedByID(s.recoveryCodes) {
+		if code.Username == username && code.UsedAt.IsZero() {
+			items = append(items, code)
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) UseRecoveryCode(_ context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, ok := s.recoveryCodes[id]
+	if !ok || !code.UsedAt.IsZero() {
+		return 0, nil
+	}
+	code.UsedAt = now()
+	s.recoveryCodes[id] = code
+	return 1, nil
+}
+
+func (s *Store) DeleteRecoveryCodes(_ context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteRecoveryCodes(username)
+	return nil
+}
+
+func (s *Store) deleteRecoveryCodes(username string) {
+	for id, code := range s.recoveryCodes {
+		if code.Username == username {
+			delete(s.recoveryCodes, id)
+		}
+	}
+}
+
+func (s *Store) ConfirmTOTPTx(_ context.Context, args db.ConfirmTOTPTxParams) (db.ConfirmTOTPTxResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the totp belongs to an existing user, so do the new recovery codes
+	if _, ok := s.userTOTPs[args.Username]; !ok {
+		return db.ConfirmTOTPTxResult{}, pgx.ErrNoRows
+	}
+
+	var result db.ConfirmTOTPTxResult
+	result.TOTP, _ = s.confirmUserTOTP(db.ConfirmUserTOTPParams{
+		LastUsedStep: args.Step,
+		Username:     args.Username,
+	})
+
+	s.deleteRecoveryCodes(args.Username)
+	result.RecoveryCodes = make([]db.RecoveryCode, 0, len(args.RecoveryCodes))
+	for _, recoveryCode := range args.RecoveryCodes {
+		result.RecoveryCodes = append(result.RecoveryCodes, s.createRecoveryCode(db.CreateRecoveryCodeParams{
+			Username:   args.Username,
+			HashedCode: recoveryCode.HashedCode,
+			Lookup:     recoveryCode.Lookup,
+		}))
+	}
+	return result, nil
+}
@@ -0,0 +1,332 @@
+package memdb
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"simple-bank/internal/db"
+	"time"
+)
+
+func (s *Store) CreateUser(_ context.Context, arg db.CreateUserParams) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.Username]; ok {
+		return db.User{}, uniqueViolation("users", "users_pkey")
+	}
+	if s.emailTaken(arg.Email) {
+		return db.User{}, uniqueViolation("users", "users_email_key")
+	}
+
+	user := db.User{
+		Username:       arg.Username,
+		HashedPassword: arg.HashedPassword,
+		FullName:       arg.FullName,
+		Email:          arg.Email,
+		CreatedAt:      now(),
+		Role:           db.UserRoleCustomer,
+		Version:        1,
+	}
+	s.users[user.Username] = user
+	return user, nil
+}
+
+func (s *Store) emailTaken(email string) bool {
+	for _, user := range s.users {
+		if user.Email == email {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) GetUser(_ context.Context, username string) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+	return user, nil
+}
+
+func (s *Store) GetUserByEmail(_ context.Context, email string) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return db.User{}, pgx.ErrNoRows
+}
+
+// updateUser applies update to the user and bumps its version, a missing
+// one is pgx.ErrNoRows.
+func (s *Store) updateUser(username string, update func(user *db.User)) (db.User, error) {
+	return s.setUser(username, func(user *db.User) {
+		update(user)
+		user.Version++
+	})
+}
+
+// setUser applies update to the user and leaves its version alone, for the
+// login bookkeeping clients never see.
+func (s *Store) setUser(username string, update func(user *db.User)) (db.User, error) {
+	user, ok := s.users[username]
+	if !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+	update(&user)
+	s.users[username] = user
+	return user, nil
+}
+
+func (s *Store) RegisterFailedLogin(_ context.Context, username string) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.setUser(username, func(user *db.User) {
+		user.FailedLoginAttempts++
+	})
+}
+
+func (s *Store) LockUser(_ context.Context, arg db.LockUserParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, _ = s.setUser(arg.Username, func(user *db.User) {
+		user.LockedUntil = timestamp(arg.LockedUntil)
+	})
+	return nil
+}
+
+func (s *Store) ResetFailedLogins(_ context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.resetFailedLogins(username)
+	return nil
+}
+
+func (s *Store) resetFailedLogins(username string) {
+	_, _ = s.setUser(username, func(user *db.User) {
+		user.FailedLoginAttempts = 0
+		user.LockedUntil = time.Time{}
+	})
+}
+
+func (s *Store) VerifyUserEmail(_ context.Context, username string) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.verifyUserEmail(username)
+}
+
+func (s *Store) verifyUserEmail(username string) (db.User, error) {
+	return s.updateUser(username, func(user *db.User) {
+		user.EmailVerifiedAt = now()
+	})
+}
+
+func (s *Store) UpdateUserPassword(_ context.Context, arg db.UpdateUserPasswordParams) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateUserPassword(arg)
+}
+
+func (s *Store) updateUserPassword(arg db.UpdateUserPasswordParams) (db.User, error) {
+	return s.updateUser(arg.Username, func(user *db.User) {
+		user.HashedPassword = arg.HashedPassword
+		user.PasswordChangedAt = timestamp(arg.PasswordChangedAt)
+	})
+}
+
+func (s *Store) UpdateUserRole(_ context.Context, arg db.UpdateUserRoleParams) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateUser(arg.Username, func(user *db.User) {
+		user.Role = arg.Role
+	})
+}
+
+func (s *Store) UpdateUser(_ context.Context, arg db.UpdateUserParams) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[arg.Username]
+	if !ok || arg.ExpectedVersion.Valid && arg.ExpectedVersion.Int64 != user.Version {
+		return db.User{}, pgx.ErrNoRows
+	}
+	if arg.Email.Valid && arg.Email.String != user.Email && s.emailTaken(arg.Email.String) {
+		return db.User{}, uniqueViolation("users", "users_email_key")
+	}
+
+	return s.updateUser(arg.Username, func(user *db.User) {
+		if arg.FullName.Valid {
+			user.FullName = arg.FullName.String
+		}
+		if arg.Email.Valid && arg.Email.String != user.Email {
+			user.Email = arg.Email.String
+			user.EmailVerifiedAt = time.Time{}
+		}
+	})
+}
+
+func (s *Store) UpgradeUserPasswordHash(_ context.Context, arg db.UpgradeUserPasswordHashParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[arg.Username]
+	if ok && user.HashedPassword == arg.OldHashedPassword {
+		user.HashedPassword = arg.NewHashedPassword
+		s.users[arg.Username] = user
+	}
+	return nil
+}
+
+func (s *Store) CreateLoginAttempt(_ context.Context, arg db.CreateLoginAttemptParams) (db.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempt := db.LoginAttempt{
+		ID:        s.nextID("login_attempts"),
+		Username:  arg.Username,
+		ClientIp:  arg.ClientIp,
+		Succeeded: arg.Succeeded,
+		CreatedAt: now(),
+	}
+	s.loginAttempts[attempt.ID] = attempt
+	return attempt, nil
+}
+
+func (s *Store) ListLoginAttempts(_ context.Context, arg db.ListLoginAttemptsParams) ([]db.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.LoginAttempt{}
+	for _, attempt := range reversed(sortedByID(s.loginAttempts)) {
+		if attempt.Username == arg.Username {
+			items = append(items, attempt)
+		}
+	}
+	return limit(items, arg.Limit), nil
+}
+
+func (s *Store) CreateUserToken(_ context.Context, arg db.CreateUserTokenParams) (db.UserToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.Username]; !ok {
+		return db.UserToken{}, foreignKeyViolation("user_tokens", "user_tokens_username_fkey")
+	}
+	for _, token := range s.userTokens {
+		if token.HashedToken == arg.HashedToken {
+			return db.UserToken{}, uniqueViolation("user_tokens", "user_tokens_hashed_token_key")
+		}
+	}
+
+	token := db.UserToken{
+		ID:          s.nextID("user_tokens"),
+		Username:    arg.Username,
+		Purpose:     arg.Purpose,
+		HashedToken: arg.HashedToken,
+		ExpiresAt:   timestamp(arg.ExpiresAt),
+		CreatedAt:   now(),
+	}
+	s.userTokens[token.ID] = token
+	return token, nil
+}
+
+func (s *Store) GetUserToken(_ context.Context, arg db.GetUserTokenParams) (db.UserToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.userTokens {
+		if token.HashedToken == arg.HashedToken && token.Purpose == arg.Purpose {
+			return token, nil
+		}
+	}
+	return db.UserToken{}, pgx.ErrNoRows
+}
+
+func (s *Store) UseUserToken(_ context.Context, id int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.useUserToken(id), nil
+}
+
+func (s *Store) useUserToken(id int64) int64 {
+	if !s.userTokenUsable(id) {
+		return 0
+	}
+	token := s.userTokens[id]
+	token.UsedAt = now()
+	s.userTokens[id] = token
+	return 1
+}
+
+func (s *Store) userTokenUsable(id int64) bool {
+	token, ok := s.userTokens[id]
+	return ok && token.UsedAt.IsZero() && token.ExpiresAt.After(time.Now())
+}
+
+func (s *Store) RevokeUserTokens(_ context.Context, arg db.RevokeUserTokensParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokeUserTokens(arg)
+	return nil
+}
+
+func (s *Store) revokeUserTokens(arg db.RevokeUserTokensParams) {
+	for id, token := range s.userTokens {
+		if token.Username == arg.Username && token.Purpose == arg.Purpose && token.UsedAt.IsZero() {
+			token.UsedAt = now()
+			s.userTokens[id] = token
+		}
+	}
+}
+
+func (s *Store) VerifyEmailTx(_ context.Context, args db.VerifyEmailTxParams) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.userTokenUsable(args.TokenID) {
+		return db.User{}, db.ErrUserTokenUsed
+	}
+	if _, ok := s.users[args.Username]; !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+	s.useUserToken(args.TokenID)
+	return s.verifyUserEmail(args.Username)
+}
+
+func (s *Store) ResetPasswordTx(_ context.Context, args db.ResetPasswordTxParams) (db.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.userTokenUsable(args.TokenID) {
+		return db.User{}, db.ErrUserTokenUsed
+	}
+	if _, ok := s.users[args.Username]; !ok {
+		return db.User{}, pgx.ErrNoRows
+	}
+	s.useUserToken(args.TokenID)
+
+	s.revokeUserTokens(db.RevokeUserTokensParams{
+		Username: args.Username,
+		Purpose:  db.UserTokenPurposePasswordReset,
+	})
+	s.resetFailedLogins(args.Username)
+	return s.updateUserPassword(db.UpdateUserPasswordParams{
+		Username:          args.Username,
+		HashedPassword:    args.HashedPassword,
+		PasswordChangedAt: time.Now(),
+	})
+}
@@ -0,0 +1,308 @@
+package memdb
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"simple-bank/internal/db"
+	"slices"
+	"time"
+)
+
+func (s *Store) CreateWebhookSubscription(_ context.Context, arg db.CreateWebhookSubscriptionParams) (db.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[arg.Owner]; !ok {
+		return db.WebhookSubscription{}, foreignKeyViolation("webhook_subscriptions", "webhook_subscriptions_owner_fkey")
+	}
+	eventTypes, err := array("webhook_subscriptions", "event_types", arg.EventTypes)
+	if err != nil {
+		return db.WebhookSubscription{}, err
+	}
+
+	subscription := db.WebhookSubscription{
+		ID:         s.nextID("webhook_subscriptions"),
+		Owner:      arg.Owner,
+		Url:        arg.Url,
+		Secret:     arg.Secret,
+		EventTypes: eventTypes,
+		CreatedAt:  now(),
+	}
+	s.webhookSubscriptions[subscription.ID] = subscription
+	return cloneWebhookSubscription(subscription), nil
+}
+
+func (s *Store) GetWebhookSubscription(_ context.Context, id int64) (db.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.webhookSubscriptions[id]
+	if !ok {
+		return db.WebhookSubscription{}, pgx.ErrNoRows
+	}
+	return cloneWebhookSubscription(subscription), nil
+}
+
+func (s *Store) ListWebhookSubscriptions(_ context.Context, owner string) ([]db.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.WebhookSubscription{}
+	for _, subscription := range sortedByID(s.webhookSubscriptions) {
+		if subscription.Owner == owner {
+			items = append(items, cloneWebhookSubscription(subscription))
+		}
+	}
+	return items, nil
+}
+
+// ListWebhookSubscriptionsForEvent returns the enabled subscriptions of the
+// owners that take the event type, no event types means every type.
+func (s *Store) ListWebhookSubscriptionsForEvent(_ context.Context, arg db.ListWebhookSubscriptionsForEventParams) ([]db.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.WebhookSubscription{}
+	for _, subscription := range sortedByID(s.webhookSubscriptions) {
+		if !slices.Contains(arg.Owners, subscription.Owner) || !subscription.DisabledAt.IsZero() {
+			continue
+		}
+		if len(subscription.EventTypes) == 0 || slices.Contains(subscription.EventTypes, arg.EventType) {
+			items = append(items, cloneWebhookSubscription(subscription))
+		}
+	}
+	return items, nil
+}
+
+func (s *Store) UpdateWebhookSubscription(_ context.Context, arg db.UpdateWebhookSubscriptionParams) (db.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.webhookSubscriptions[arg.ID]
+	if !ok {
+		return db.WebhookSubscription{}, pgx.ErrNoRows
+	}
+	eventTypes, err := array("webhook_subscriptions", "event_types", arg.EventTypes)
+	if err != nil {
+		return db.WebhookSubscription{}, err
+	}
+
+	subscription.Url = arg.Url
+	subscription.EventTypes = eventTypes
+	subscription.DisabledAt = timestamp(arg.DisabledAt)
+	subscription.ConsecutiveFailures = 0
+	s.webhookSubscriptions[arg.ID] = subscription
+	return cloneWebhookSubscription(subscription), nil
+}
+
+// DeleteWebhookSubscription takes the deliveries of the subscription with it.
+func (s *Store) DeleteWebhookSubscription(_ context.Context, arg db.DeleteWebhookSubscriptionParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.webhookSubscriptions[arg.ID]
+	if !ok || subscription.Owner != arg.Owner {
+		return 0, nil
+	}
+	delete(s.webhookSubscriptions, arg.ID)
+	for id, delivery := range s.webhookDeliveries {
+		if delivery.SubscriptionID == arg.ID {
+			delete(s.webhookDeliveries, id)
+		}
+	}
+	return 1, nil
+}
+
+// RecordWebhookSubscriptionFailure disables the subscription once it has
+// failed DisableAfter times in a row.
+func (s *Store) RecordWebhookSubscriptionFailure(_ context.Context, arg db.RecordWebhookSubscriptionFailureParams) (db.WebhookSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.webhookSubscriptions[arg.ID]
+	if !ok {
+		return db.WebhookSubscription{}, pgx.ErrNoRows
+	}
+	subscription.ConsecutiveFailures++
+	if subscription.ConsecutiveFailures >= arg.DisableAfter && subscription.DisabledAt.IsZero() {
+		subscription.DisabledAt = now()
+	}
+	s.webhookSubscriptions[arg.ID] = subscription
+	return cloneWebhookSubscription(subscription), nil
+}
+
+func (s *Store) ResetWebhookSubscriptionFailures(_ context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if subscription, ok := s.webhookSubscriptions[id]; ok {
+		subscription.ConsecutiveFailures = 0
+		s.webhookSubscriptions[id] = subscription
+	}
+	return nil
+}
+
+// CreateWebhookDelivery ignores an event already queued for the
+// subscription, replays aside.
+func (s *Store) CreateWebhookDelivery(_ context.Context, arg db.CreateWebhookDeliveryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhookSubscriptions[arg.SubscriptionID]; !ok {
+		return foreignKeyViolation("webhook_deliveries", "webhook_deliveries_subscription_id_fkey")
+	}
+	payload, err := jsonb("webhook_deliveries", "payload", arg.Payload)
+	if err != nil {
+		return err
+	}
+	for _, delivery := range s.webhookDeliveries {
+		if delivery.SubscriptionID == arg.SubscriptionID && delivery.EventID == arg.EventID && delivery.ReplayOf == 0 {
+			return nil
+		}
+	}
+
+	s.insertWebhookDelivery(db.WebhookDelivery{
+		SubscriptionID: arg.SubscriptionID,
+		EventID:        arg.EventID,
+		EventType:      arg.EventType,
+		Payload:        payload,
+	})
+	return nil
+}
+
+func (s *Store) insertWebhookDelivery(delivery db.WebhookDelivery) db.WebhookDelivery {
+	t := now()
+	delivery.ID = s.nextID("webhook_deliveries")
+	delivery.Status = db.WebhookDeliveryPending
+	delivery.NextAttemptAt = t
+	delivery.CreatedAt = t
+	s.webhookDeliveries[delivery.ID] = delivery
+	return delivery
+}
+
+// ReplayWebhookDelivery queues a copy of a delivery, whatever became of it.
+func (s *Store) ReplayWebhookDelivery(_ context.Context, id int64) (db.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	original, ok := s.webhookDeliveries[id]
+	if !ok {
+		return db.WebhookDelivery{}, pgx.ErrNoRows
+	}
+
+	delivery := s.insertWebhookDelivery(db.WebhookDelivery{
+		SubscriptionID: original.SubscriptionID,
+		EventID:        original.EventID,
+		EventType:      original.EventType,
+		Payload:        slices.Clone(original.Payload),
+		ReplayOf:       original.ID,
+	})
+	return cloneWebhookDelivery(delivery), nil
+}
+
+func (s *Store) GetWebhookDelivery(_ context.Context, id int64) (db.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delivery, ok := s.webhookDeliveries[id]
+	if !ok {
+		return db.WebhookDelivery{}, pgx.ErrNoRows
+	}
+	return cloneWebhookDelivery(delivery), nil
+}
+
+func (s *Store) ListWebhookDeliveries(_ context.Context, arg db.ListWebhookDeliveriesParams) ([]db.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := []db.WebhookDelivery{}
+	for _, delivery := range reversed(sortedByID(s.webhookDeliveries)) {
+		switch {
+		case delivery.SubscriptionID != arg.SubscriptionID,
+			arg.Status.Valid && delivery.Status != arg.Status.String,
+			arg.BeforeID.Valid && delivery.ID >= arg.BeforeID.Int64:
+			continue
+		}
+		items = append(items, cloneWebhookDelivery(delivery))
+	}
+	return limit(items, arg.RowLimit), nil
+}
+
+// ClaimWebhookDeliveries leases the pending deliveries that are due, those
+// of disabled subscriptions wait for them to be enabled again.
+func (s *Store) ClaimWebhookDeliveries(_ context.Context, arg db.ClaimWebhookDeliveriesParams) ([]db.ClaimWebhookDeliveriesRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := time.Now()
+	items := []db.ClaimWebhookDeliveriesRow{}
+	for _, delivery := range sortedByID(s.webhookDeliveries) {
+		if len(items) == int(arg.RowLimit) {
+			break
+		}
+		subscription := s.webhookSubscriptions[delivery.SubscriptionID]
+		if delivery.Status != db.WebhookDeliveryPending || delivery.NextAttemptAt.After(t) || !subscription.DisabledAt.IsZero() {
+			continue
+		}
+
+		delivery.Attempts++
+		delivery.NextAttemptAt = timestamp(arg.LeaseUntil)
+		s.webhookDeliveries[delivery.ID] = delivery
+		items = append(items, db.ClaimWebhookDeliveriesRow{
+			ID:             delivery.ID,
+			SubscriptionID: delivery.SubscriptionID,
+			EventID:        delivery.EventID,
+			EventType:      delivery.EventType,
+			Payload:        slices.Clone(delivery.Payload),
+			ReplayOf:       delivery.ReplayOf,
+			Status:         delivery.Status,
+			Attempts:       delivery.Attempts,
+			ResponseStatus: delivery.ResponseStatus,
+			LastError:      delivery.LastError,
+			NextAttemptAt:  delivery.NextAttemptAt,
+			DeliveredAt:    delivery.DeliveredAt,
+			CreatedAt:      delivery.CreatedAt,
+			Url:            subscription.Url,
+			Secret:         subscription.Secret,
+		})
+	}
+	return items, nil
+}
+
+func (s *Store) MarkWebhookDeliverySucceeded(_ context.Context, arg db.MarkWebhookDeliverySucceededParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if delivery, ok := s.webhookDeliveries[arg.ID]; ok {
+		delivery.Status = db.WebhookDeliverySucceeded
+		delivery.ResponseStatus = arg.ResponseStatus
+		delivery.LastError = ""
+		delivery.DeliveredAt = now()
+		s.webhookDeliveries[arg.ID] = delivery
+	}
+	return nil
+}
+
+func (s *Store) MarkWebhookDeliveryFailed(_ context.Context, arg db.MarkWebhookDeliveryFailedParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if delivery, ok := s.webhookDeliveries[arg.ID]; ok {
+		delivery.Status = arg.Status
+		delivery.ResponseStatus = arg.ResponseStatus
+		delivery.LastError = arg.LastError
+		delivery.NextAttemptAt = timestamp(arg.NextAttemptAt)
+		s.webhookDeliveries[arg.ID] = delivery
+	}
+	return nil
+}
+
+func cloneWebhookSubscription(subscription db.WebhookSubscription) db.WebhookSubscription {
+	subscription.EventTypes = slices.Clone(subscription.EventTypes)
+	return subscription
+}
+
+func cloneWebhookDelivery(delivery db.WebhookDelivery) db.WebhookDelivery {
+	delivery.Payload = slices.Clone(delivery.Payload)
+	return delivery
+}
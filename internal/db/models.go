@@ -5,7 +5,10 @@
 package db
 
 import (
+	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type Account struct {
@@ -14,6 +17,34 @@ type Account struct {
 	Balance   int64     `json:"balance"`
 	Currency  string    `json:"currency"`
 	CreatedAt time.Time `json:"created_at"`
+	FrozenAt  time.Time `json:"frozen_at"`
+	Version   int64     `json:"version"`
+}
+
+type ApiKey struct {
+	ID           uuid.UUID `json:"id"`
+	Username     string    `json:"username"`
+	Name         string    `json:"name"`
+	Prefix       string    `json:"prefix"`
+	HashedSecret string    `json:"hashed_secret"`
+	Scopes       []string  `json:"scopes"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	LastUsedAt   time.Time `json:"last_used_at"`
+	RevokedAt    time.Time `json:"revoked_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type AuditEvent struct {
+	ID         int64           `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	RequestID  string          `json:"request_id"`
+	Ip         string          `json:"ip"`
+	Outcome    string          `json:"outcome"`
+	Diff       json.RawMessage `json:"diff"`
+	CreatedAt  time.Time       `json:"created_at"`
 }
 
 type Entry struct {
@@ -24,6 +55,65 @@ type Entry struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+type LoginAttempt struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	ClientIp  string    `json:"client_ip"`
+	Succeeded bool      `json:"succeeded"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type OauthAuthorizationCode struct {
+	ID            int64     `json:"id"`
+	HashedCode    string    `json:"hashed_code"`
+	ClientID      string    `json:"client_id"`
+	Username      string    `json:"username"`
+	RedirectUri   string    `json:"redirect_uri"`
+	Scopes        []string  `json:"scopes"`
+	CodeChallenge string    `json:"code_challenge"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	UsedAt        time.Time `json:"used_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type OauthClient struct {
+	ID           string    `json:"id"`
+	Owner        string    `json:"owner"`
+	Name         string    `json:"name"`
+	HashedSecret string    `json:"hashed_secret"`
+	RedirectUris []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Outbox struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	Attempts      int32           `json:"attempts"`
+	LastError     string          `json:"last_error"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	PublishedAt   time.Time       `json:"published_at"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type RateLimitBucket struct {
+	Key       string    `json:"key"`
+	Tokens    float64   `json:"tokens"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type RecoveryCode struct {
+	ID         int64     `json:"id"`
+	Username   string    `json:"username"`
+	HashedCode string    `json:"hashed_code"`
+	UsedAt     time.Time `json:"used_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	Lookup     string    `json:"lookup"`
+}
+
 type Transfer struct {
 	ID            int64 `json:"id"`
 	FromAccountID int64 `json:"from_account_id"`
@@ -34,10 +124,60 @@ type Transfer struct {
 }
 
 type User struct {
-	Username          string    `json:"username"`
-	HashedPassword    string    `json:"hashed_password"`
-	FullName          string    `json:"full_name"`
-	Email             string    `json:"email"`
-	PasswordChangedAt time.Time `json:"password_changed_at"`
-	CreatedAt         time.Time `json:"created_at"`
+	Username            string    `json:"username"`
+	HashedPassword      string    `json:"hashed_password"`
+	FullName            string    `json:"full_name"`
+	Email               string    `json:"email"`
+	PasswordChangedAt   time.Time `json:"password_changed_at"`
+	CreatedAt           time.Time `json:"created_at"`
+	FailedLoginAttempts int32     `json:"failed_login_attempts"`
+	LockedUntil         time.Time `json:"locked_until"`
+	EmailVerifiedAt     time.Time `json:"email_verified_at"`
+	Role                string    `json:"role"`
+	Version             int64     `json:"version"`
+}
+
+type UserTotp struct {
+	Username     string    `json:"username"`
+	Secret       string    `json:"secret"`
+	LastUsedStep int64     `json:"last_used_step"`
+	ConfirmedAt  time.Time `json:"confirmed_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type UserToken struct {
+	ID          int64     `json:"id"`
+	Username    string    `json:"username"`
+	Purpose     string    `json:"purpose"`
+	HashedToken string    `json:"hashed_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	UsedAt      time.Time `json:"used_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	SubscriptionID int64           `json:"subscription_id"`
+	EventID        int64           `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	ReplayOf       int64           `json:"replay_of"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	ResponseStatus int32           `json:"response_status"`
+	LastError      string          `json:"last_error"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	DeliveredAt    time.Time       `json:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type WebhookSubscription struct {
+	ID                  int64     `json:"id"`
+	Owner               string    `json:"owner"`
+	Url                 string    `json:"url"`
+	Secret              string    `json:"secret"`
+	EventTypes          []string  `json:"event_types"`
+	ConsecutiveFailures int32     `json:"consecutive_failures"`
+	DisabledAt          time.Time `json:"disabled_at"`
+	CreatedAt           time.Time `json:"created_at"`
 }
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/random"
+	"testing"
+	"time"
+)
+
+func createRandomOAuthClient(t *testing.T, owner string) OauthClient {
+	arg := CreateOAuthClientParams{
+		ID:           uuid.NewString(),
+		Owner:        owner,
+		Name:         random.String(8),
+		HashedSecret: random.String(64),
+		RedirectUris: []string{"https://client.example.com/callback"},
+		Scopes:       []string{"accounts:read"},
+	}
+
+	client, err := testQueries.CreateOAuthClient(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ID, client.ID)
+	require.Equal(t, arg.Owner, client.Owner)
+	require.Equal(t, arg.HashedSecret, client.HashedSecret)
+	require.Equal(t, arg.RedirectUris, client.RedirectUris)
+	require.Equal(t, arg.Scopes, client.Scopes)
+	require.NotZero(t, client.CreatedAt)
+
+	return client
+}
+
+func createRandomOAuthAuthorizationCode(t *testing.T, client OauthClient, username string, expiresAt time.Time) OauthAuthorizationCode {
+	arg := CreateOAuthAuthorizationCodeParams{
+		HashedCode:    random.String(64),
+		ClientID:      client.ID,
+		Username:      username,
+		RedirectUri:   client.RedirectUris[0],
+		Scopes:        client.Scopes,
+		CodeChallenge: random.String(43),
+		ExpiresAt:     expiresAt,
+	}
+
+	code, err := testQueries.CreateOAuthAuthorizationCode(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.HashedCode, code.HashedCode)
+	require.Equal(t, arg.ClientID, code.ClientID)
+	require.Equal(t, arg.CodeChallenge, code.CodeChallenge)
+	require.True(t, code.UsedAt.IsZero())
+
+	return code
+}
+
+func TestQueries_GetOAuthClient(t *testing.T) {
+	user := createRandomUser(t)
+	client := createRandomOAuthClient(t, user.Username)
+
+	found, err := testQueries.GetOAuthClient(context.Background(), client.ID)
+	require.NoError(t, err)
+	require.Equal(t, client.Name, found.Name)
+	require.Equal(t, client.RedirectUris, found.RedirectUris)
+}
+
+func TestQueries_ListOAuthClients(t *testing.T) {
+	user := createRandomUser(t)
+	createRandomOAuthClient(t, user.Username)
+	createRandomOAuthClient(t, user.Username)
+	createRandomOAuthClient(t, createRandomUser(t).Username)
+
+	clients, err := testQueries.ListOAuthClients(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, clients, 2)
+	for _, client := range clients {
+		require.Equal(t, user.Username, client.Owner)
+	}
+}
+
+func TestQueries_UseOAuthAuthorizationCode(t *testing.T) {
+	user := createRandomUser(t)
+	client := createRandomOAuthClient(t, user.Username)
+	code := createRandomOAuthAuthorizationCode(t, client, user.Username, time.Now().Add(time.Minute))
+
+	used, err := testQueries.UseOAuthAuthorizationCode(context.Background(), code.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), used)
+
+	// a code can only be exchanged once
+	used, err = testQueries.UseOAuthAuthorizationCode(context.Background(), code.ID)
+	require.NoError(t, err)
+	require.Zero(t, used)
+
+	found, err := testQueries.GetOAuthAuthorizationCode(context.Background(), code.HashedCode)
+	require.NoError(t, err)
+	require.False(t, found.UsedAt.IsZero())
+
+	expired := createRandomOAuthAuthorizationCode(t, client, user.Username, time.Now().Add(-time.Minute))
+	used, err = testQueries.UseOAuthAuthorizationCode(context.Background(), expired.ID)
+	require.NoError(t, err)
+	require.Zero(t, used)
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/random"
+	"simple-bank/internal/utils"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func createRandomOutboxEvent(t *testing.T, aggregateID string) Outbox {
+	arg := CreateOutboxEventParams{
+		AggregateType: OutboxAggregateAccount,
+		AggregateID:   aggregateID,
+		EventType:     EventAccountBalanceChanged,
+		Payload:       json.RawMessage(`{}`),
+	}
+
+	event, err := testQueries.CreateOutboxEvent(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.AggregateID, event.AggregateID)
+	require.Equal(t, arg.EventType, event.EventType)
+	require.Zero(t, event.Attempts)
+	require.True(t, event.PublishedAt.IsZero())
+
+	return event
+}
+
+// outboxEvents lists the events of one aggregate, oldest first.
+func outboxEvents(t *testing.T, aggregateType, aggregateID string) []Outbox {
+	rows, err := testDB.Query(context.Background(), `SELECT id, event_type, payload FROM outbox
+		WHERE aggregate_type = $1 AND aggregate_id = $2 ORDER BY id`, aggregateType, aggregateID)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var events []Outbox
+	for rows.Next() {
+		var event Outbox
+		require.NoError(t, rows.Scan(&event.ID, &event.EventType, &event.Payload))
+		events = append(events, event)
+	}
+	require.NoError(t, rows.Err())
+	return events
+}
+
+func claimedIDs(t *testing.T) map[int64]bool {
+	claimed, err := testQueries.ClaimOutboxEvents(context.Background(), ClaimOutboxEventsParams{
+		LeaseUntil: time.Now().Add(time.Minute),
+		RowLimit:   10000,
+	})
+	require.NoError(t, err)
+
+	ids := make(map[int64]bool, len(claimed))
+	for _, event := range claimed {
+		ids[event.ID] = true
+	}
+	return ids
+}
+
+func TestStore_CreateAccountTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	account, err := store.CreateAccountTx(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Currency: random.AccountCurrency(),
+	})
+	require.NoError(t, err)
+
+	events := outboxEvents(t, OutboxAggregateAccount, strconv.FormatInt(account.ID, 10))
+	require.Len(t, events, 1)
+	require.Equal(t, EventAccountCreated, events[0].EventType)
+
+	var payload Account
+	require.NoError(t, json.Unmarshal(events[0].Payload, &payload))
+	require.Equal(t, account.ID, payload.ID)
+	require.Equal(t, account.Currency, payload.Currency)
+}
+
+func TestStore_TransferTxEvents(t *testing.T) {
+	store := NewStore(testDB)
+	account1 := createRandomAccount(t)
+	account2 := createRandomAccount(t)
+
+	result, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	transferEvents := outboxEvents(t, OutboxAggregateTransfer, strconv.FormatInt(result.Transfer.ID, 10))
+	require.Len(t, transferEvents, 1)
+	require.Equal(t, EventTransferCompleted, transferEvents[0].EventType)
+
+	for _, account := range []Account{result.FromAccount, result.ToAccount} {
+		events := outboxEvents(t, OutboxAggregateAccount, strconv.FormatInt(account.ID, 10))
+		require.NotEmpty(t, events)
+
+		last := events[len(events)-1]
+		require.Equal(t, EventAccountBalanceChanged, last.EventType)
+
+		var payload BalanceChangedEvent
+		require.NoError(t, json.Unmarshal(last.Payload, &payload))
+		require.Equal(t, account.Balance, payload.Account.Balance)
+		require.Equal(t, account.ID, payload.Entry.AccountID)
+	}
+}
+
+func TestQueries_ClaimOutboxEvents(t *testing.T) {
+	aggregateID := random.String(12)
+	first := createRandomOutboxEvent(t, aggregateID)
+	second := createRandomOutboxEvent(t, aggregateID)
+	other := createRandomOutboxEvent(t, random.String(12))
+
+	// only the oldest pending event of an aggregate can be claimed
+	claimed := claimedIDs(t)
+	require.True(t, claimed[first.ID])
+	require.False(t, claimed[second.ID])
+	require.True(t, claimed[other.ID])
+
+	// leased events aren't claimed twice
+	claimed = claimedIDs(t)
+	require.False(t, claimed[first.ID])
+	require.False(t, claimed[second.ID])
+
+	require.NoError(t, testQueries.MarkOutboxEventPublished(context.Background(), first.ID))
+	claimed = claimedIDs(t)
+	require.True(t, claimed[second.ID])
+
+	err := testQueries.MarkOutboxEventFailed(context.Background(), MarkOutboxEventFailedParams{
+		ID:            second.ID,
+		LastError:     "unavailable",
+		NextAttemptAt: time.Now().Add(-time.Second),
+	})
+	require.NoError(t, err)
+
+	claimed = claimedIDs(t)
+	require.True(t, claimed[second.ID])
+}
+
+func TestQueries_ListAccountEvents(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	other := createRandomAccount(t)
+
+	var accounts []Account
+	for _, currency := range []string{utils.CurrencyUSD, utils.CurrencyEUR} {
+		account, err := store.CreateAccountTx(context.Background(), CreateAccountParams{
+			Owner:    user.Username,
+			Currency: currency,
+		})
+		require.NoError(t, err)
+		accounts = append(accounts, account)
+	}
+
+	_, err := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: other.ID,
+		ToAccountID:   accounts[0].ID,
+		Amount:        10,
+	})
+	require.NoError(t, err)
+
+	// the other side of the transfer and the transfer itself aren't listed
+	events, err := testQueries.ListAccountEvents(context.Background(), ListAccountEventsParams{
+		Owner:    user.Username,
+		RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	require.Equal(t, EventAccountCreated, events[0].EventType)
+	require.Equal(t, EventAccountCreated, events[1].EventType)
+	require.Equal(t, EventAccountBalanceChanged, events[2].EventType)
+	require.Equal(t, strconv.FormatInt(accounts[0].ID, 10), events[2].AggregateID)
+
+	events, err = testQueries.ListAccountEvents(context.Background(), ListAccountEventsParams{
+		Owner:    user.Username,
+		AfterID:  events[1].ID,
+		RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, EventAccountBalanceChanged, events[0].EventType)
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+)
+
+const (
+	OutboxAggregateAccount  = "account"
+	OutboxAggregateTransfer = "transfer"
+)
+
+const (
+	EventAccountCreated        = "account.created"
+	EventAccountBalanceChanged = "account.balance_changed"
+	EventTransferCompleted     = "transfer.completed"
+)
+
+// AccountEventsChannel is the LISTEN/NOTIFY channel told about new account
+// events. The payload is the owner of the account, the events themselves
+// are read from the outbox.
+const AccountEventsChannel = "account_events"
+
+// BalanceChangedEvent is the payload of an account.balance_changed event.
+type BalanceChangedEvent struct {
+	Account Account `json:"account"`
+	Entry   Entry   `json:"entry"`
+}
+
+// CreateAccountTx creates an account and records its account.created event
+// in the same transaction.
+func (s *SQLStore) CreateAccountTx(ctx context.Context, args CreateAccountParams) (Account, error) {
+	var account Account
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		var err error
+		account, err = q.CreateAccount(ctx, args)
+		if err != nil {
+			return err
+		}
+
+		err = addOutboxEvent(ctx, q, OutboxAggregateAccount, account.ID, EventAccountCreated, account)
+		if err != nil {
+			return err
+		}
+
+		return q.NotifyAccountEvents(ctx, account.Owner)
+	})
+	return account, err
+}
+
+// addOutboxEvent must run inside the transaction that changes the aggregate.
+// Events of an aggregate are written after its row is locked, so their ids
+// follow the order in which the changes were committed.
+func addOutboxEvent(ctx context.Context, q *Queries, aggregateType string, aggregateID int64, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.CreateOutboxEvent(ctx, CreateOutboxEventParams{
+		AggregateType: aggregateType,
+		AggregateID:   strconv.FormatInt(aggregateID, 10),
+		EventType:     eventType,
+		Payload:       data,
+	})
+	return err
+}
@@ -6,21 +6,100 @@ package db
 
 import (
 	"context"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type Querier interface {
+	// AddAccountBalance leaves frozen accounts alone, a freeze committed while
+	// a transfer waits for the row lock is seen by the update.
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	ClaimOutboxEvents(ctx context.Context, arg ClaimOutboxEventsParams) ([]Outbox, error)
+	ClaimWebhookDeliveries(ctx context.Context, arg ClaimWebhookDeliveriesParams) ([]ClaimWebhookDeliveriesRow, error)
+	ConfirmUserTOTP(ctx context.Context, arg ConfirmUserTOTPParams) (UserTotp, error)
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error)
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	CreateLoginAttempt(ctx context.Context, arg CreateLoginAttemptParams) (LoginAttempt, error)
+	CreateOAuthAuthorizationCode(ctx context.Context, arg CreateOAuthAuthorizationCodeParams) (OauthAuthorizationCode, error)
+	CreateOAuthClient(ctx context.Context, arg CreateOAuthClientParams) (OauthClient, error)
+	CreateOutboxEvent(ctx context.Context, arg CreateOutboxEventParams) (Outbox, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) (RecoveryCode, error)
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateUserToken(ctx context.Context, arg CreateUserTokenParams) (UserToken, error)
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error
+	CreateWebhookSubscription(ctx context.Context, arg CreateWebhookSubscriptionParams) (WebhookSubscription, error)
 	DeleteAccount(ctx context.Context, id int64) error
+	DeletePublishedOutboxEvents(ctx context.Context, publishedAt time.Time) error
+	DeleteRecoveryCodes(ctx context.Context, username string) error
+	DeleteStaleRateLimitBuckets(ctx context.Context, updatedAt time.Time) error
+	DeleteWebhookSubscription(ctx context.Context, arg DeleteWebhookSubscriptionParams) (int64, error)
+	FreezeAccount(ctx context.Context, id int64) (Account, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (ApiKey, error)
 	GetAccount(ctx context.Context, id int64) (Account, error)
 	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
 	GetEntry(ctx context.Context, id int64) (Entry, error)
+	GetLatestAccountEventID(ctx context.Context, owner string) (int64, error)
+	GetOAuthAuthorizationCode(ctx context.Context, hashedCode string) (OauthAuthorizationCode, error)
+	GetOAuthClient(ctx context.Context, id string) (OauthClient, error)
 	GetTransfer(ctx context.Context, id int64) (Transfer, error)
+	GetUnusedRecoveryCode(ctx context.Context, arg GetUnusedRecoveryCodeParams) (RecoveryCode, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserTOTP(ctx context.Context, username string) (UserTotp, error)
+	GetUserToken(ctx context.Context, arg GetUserTokenParams) (UserToken, error)
+	GetWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error)
+	GetWebhookSubscription(ctx context.Context, id int64) (WebhookSubscription, error)
+	ListAPIKeys(ctx context.Context, username string) ([]ApiKey, error)
+	ListAccountEvents(ctx context.Context, arg ListAccountEventsParams) ([]Outbox, error)
 	ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error)
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error)
+	// ListLedgerMismatches returns the accounts whose entries do not add up to
+	// the transfers in and out of them.
+	ListLedgerMismatches(ctx context.Context) ([]ListLedgerMismatchesRow, error)
+	ListLoginAttempts(ctx context.Context, arg ListLoginAttemptsParams) ([]LoginAttempt, error)
+	ListOAuthClients(ctx context.Context, owner string) ([]OauthClient, error)
+	ListUnusedRecoveryCodes(ctx context.Context, username string) ([]RecoveryCode, error)
+	ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error)
+	ListWebhookSubscriptions(ctx context.Context, owner string) ([]WebhookSubscription, error)
+	ListWebhookSubscriptionsForEvent(ctx context.Context, arg ListWebhookSubscriptionsForEventParams) ([]WebhookSubscription, error)
+	// LockUser does not bump the version, like RegisterFailedLogin.
+	LockUser(ctx context.Context, arg LockUserParams) error
+	MarkOutboxEventFailed(ctx context.Context, arg MarkOutboxEventFailedParams) error
+	MarkOutboxEventPublished(ctx context.Context, id int64) error
+	MarkWebhookDeliveryFailed(ctx context.Context, arg MarkWebhookDeliveryFailedParams) error
+	MarkWebhookDeliverySucceeded(ctx context.Context, arg MarkWebhookDeliverySucceededParams) error
+	NotifyAccountEvents(ctx context.Context, owner string) error
+	RecordWebhookSubscriptionFailure(ctx context.Context, arg RecordWebhookSubscriptionFailureParams) (WebhookSubscription, error)
+	RefillRateLimitBucket(ctx context.Context, arg RefillRateLimitBucketParams) (float64, error)
+	// RegisterFailedLogin, unlike the other user updates, does not bump the
+	// version: the lockout state is not part of the user clients see, so a
+	// wrong password must not break their ETag or If-Match.
+	RegisterFailedLogin(ctx context.Context, username string) (User, error)
+	ReplayWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error)
+	// ResetFailedLogins does not bump the version, like RegisterFailedLogin.
+	ResetFailedLogins(ctx context.Context, username string) error
+	ResetWebhookSubscriptionFailures(ctx context.Context, id int64) error
+	RevokeAPIKey(ctx context.Context, arg RevokeAPIKeyParams) (int64, error)
+	RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error
+	TakeRateLimitToken(ctx context.Context, key string) (float64, error)
+	TouchAPIKey(ctx context.Context, id uuid.UUID) error
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error)
+	UpdateWebhookSubscription(ctx context.Context, arg UpdateWebhookSubscriptionParams) (WebhookSubscription, error)
+	// UpgradeUserPasswordHash does not bump the version either, the hash is
+	// never shown and the rehash happens on login.
+	UpgradeUserPasswordHash(ctx context.Context, arg UpgradeUserPasswordHashParams) error
+	UpsertUserTOTP(ctx context.Context, arg UpsertUserTOTPParams) (UserTotp, error)
+	UseOAuthAuthorizationCode(ctx context.Context, id int64) (int64, error)
+	UseRecoveryCode(ctx context.Context, id int64) (int64, error)
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error)
+	UseUserToken(ctx context.Context, id int64) (int64, error)
+	VerifyUserEmail(ctx context.Context, username string) (User, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/random"
+	"testing"
+	"time"
+)
+
+func TestQueries_RateLimitBucket(t *testing.T) {
+	key := random.String(12)
+	arg := RefillRateLimitBucketParams{
+		Key:   key,
+		Burst: 2,
+		Rate:  0.001,
+	}
+
+	tokens, err := testQueries.RefillRateLimitBucket(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, float64(2), tokens)
+
+	for i := 1; i >= 0; i-- {
+		tokens, err = testQueries.TakeRateLimitToken(context.Background(), key)
+		require.NoError(t, err)
+		require.InDelta(t, float64(i), tokens, 0.01)
+	}
+
+	tokens, err = testQueries.RefillRateLimitBucket(context.Background(), arg)
+	require.NoError(t, err)
+	require.Less(t, tokens, float64(1))
+
+	_, err = testQueries.TakeRateLimitToken(context.Background(), key)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	require.NoError(t, testQueries.DeleteStaleRateLimitBuckets(context.Background(), time.Now().Add(time.Minute)))
+
+	_, err = testQueries.TakeRateLimitToken(context.Background(), key)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+)
+
+// ReplicaStore sends account and history reads to a read replica, they may
+// trail the primary by the replication lag. Transactions and every other
+// query go to the primary.
+type ReplicaStore struct {
+	Store
+	replica Querier
+}
+
+func NewReplicaStore(primary Store, replica Querier) *ReplicaStore {
+	return &ReplicaStore{
+		Store:   primary,
+		replica: replica,
+	}
+}
+
+func (s *ReplicaStore) GetAccount(ctx context.Context, id int64) (Account, error) {
+	return s.replica.GetAccount(ctx, id)
+}
+
+func (s *ReplicaStore) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]Account, error) {
+	return s.replica.ListAccounts(ctx, arg)
+}
+
+func (s *ReplicaStore) GetEntry(ctx context.Context, id int64) (Entry, error) {
+	return s.replica.GetEntry(ctx, id)
+}
+
+func (s *ReplicaStore) GetTransfer(ctx context.Context, id int64) (Transfer, error) {
+	return s.replica.GetTransfer(ctx, id)
+}
+
+func (s *ReplicaStore) ListAccountEvents(ctx context.Context, arg ListAccountEventsParams) ([]Outbox, error) {
+	return s.replica.ListAccountEvents(ctx, arg)
+}
+
+func (s *ReplicaStore) GetLatestAccountEventID(ctx context.Context, owner string) (int64, error) {
+	return s.replica.GetLatestAccountEventID(ctx, owner)
+}
+
+func (s *ReplicaStore) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	return s.replica.ListAuditEvents(ctx, arg)
+}
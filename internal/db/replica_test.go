@@ -0,0 +1,52 @@
+package db_test
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/db"
+	memdb "simple-bank/internal/db/memory"
+	"simple-bank/internal/db/storetest"
+	"simple-bank/internal/random"
+	"simple-bank/internal/utils"
+	"testing"
+)
+
+func TestReplicaStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) db.Store {
+		store := memdb.NewStore()
+		return db.NewReplicaStore(store, store)
+	})
+}
+
+func TestReplicaStore_Routing(t *testing.T) {
+	ctx := context.Background()
+	primary, replica := memdb.NewStore(), memdb.NewStore()
+	store := db.NewReplicaStore(primary, replica)
+
+	user, err := store.CreateUser(ctx, db.CreateUserParams{
+		Username:       random.Username(),
+		HashedPassword: random.String(32),
+		FullName:       random.Username(),
+		Email:          random.UserEmail(),
+	})
+	require.NoError(t, err)
+	account, err := store.CreateAccount(ctx, db.CreateAccountParams{
+		Owner:    user.Username,
+		Balance:  100,
+		Currency: utils.CurrencyUSD,
+	})
+	require.NoError(t, err)
+
+	_, err = primary.GetAccount(ctx, account.ID)
+	require.NoError(t, err, "writes go to the primary")
+	_, err = store.GetAccount(ctx, account.ID)
+	require.ErrorIs(t, err, pgx.ErrNoRows, "reads go to the replica, it has not caught up")
+
+	_, err = store.GetUser(ctx, user.Username)
+	require.NoError(t, err, "queries that are not routed go to the primary")
+
+	accounts, err := store.ListAccounts(ctx, db.ListAccountsParams{Owner: user.Username, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, accounts)
+}
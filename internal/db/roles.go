@@ -0,0 +1,6 @@
+package db
+
+const (
+	UserRoleCustomer = "customer"
+	UserRoleAdmin    = "admin"
+)
@@ -0,0 +1,180 @@
+package sqlitedb
+
+import (
+	"context"
+	"simple-bank/internal/db"
+)
+
+func toAccount(account Account) db.Account {
+	return db.Account(account)
+}
+
+func toEntry(entry Entry) db.Entry {
+	return db.Entry(entry)
+}
+
+func toTransfer(transfer Transfer) db.Transfer {
+	return db.Transfer(transfer)
+}
+
+func (q *querier) CreateAccount(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
+	account, err := q.q.CreateAccount(ctx, CreateAccountParams(arg))
+	return one(account, err, toAccount)
+}
+
+func (q *querier) GetAccount(ctx context.Context, id int64) (db.Account, error) {
+	account, err := q.q.GetAccount(ctx, id)
+	return one(account, err, toAccount)
+}
+
+func (q *querier) GetAccountForUpdate(ctx context.Context, id int64) (db.Account, error) {
+	account, err := q.q.GetAccountForUpdate(ctx, id)
+	return one(account, err, toAccount)
+}
+
+func (q *querier) ListAccounts(ctx context.Context, arg db.ListAccountsParams) ([]db.Account, error) {
+	accounts, err := q.q.ListAccounts(ctx, ListAccountsParams{
+		Owner:  arg.Owner,
+		Limit:  int64(arg.Limit),
+		Offset: int64(arg.Offset),
+	})
+	return many(accounts, err, toAccount)
+}
+
+func (q *querier) AddAccountBalance(ctx context.Context, arg db.AddAccountBalanceParams) (db.Account, error) {
+	account, err := q.q.AddAccountBalance(ctx, AddAccountBalanceParams(arg))
+	return one(account, err, toAccount)
+}
+
+func (q *querier) FreezeAccount(ctx context.Context, id int64) (db.Account, error) {
+	account, err := q.q.FreezeAccount(ctx, id)
+	return one(account, err, toAccount)
+}
+
+func (q *querier) DeleteAccount(ctx context.Context, id int64) error {
+	return pgError(q.q.DeleteAccount(ctx, id))
+}
+
+func (q *querier) CreateEntry(ctx context.Context, arg db.CreateEntryParams) (db.Entry, error) {
+	entry, err := q.q.CreateEntry(ctx, CreateEntryParams(arg))
+	return one(entry, err, toEntry)
+}
+
+func (q *querier) GetEntry(ctx context.Context, id int64) (db.Entry, error) {
+	entry, err := q.q.GetEntry(ctx, id)
+	return one(entry, err, toEntry)
+}
+
+func (q *querier) CreateTransfer(ctx context.Context, arg db.CreateTransferParams) (db.Transfer, error) {
+	transfer, err := q.q.CreateTransfer(ctx, CreateTransferParams(arg))
+	return one(transfer, err, toTransfer)
+}
+
+func (q *querier) GetTransfer(ctx context.Context, id int64) (db.Transfer, error) {
+	transfer, err := q.q.GetTransfer(ctx, id)
+	return one(transfer, err, toTransfer)
+}
+
+// CreateAccountTx creates an account and records its account.created event
+// in the same transaction.
+func (s *Store) CreateAccountTx(ctx context.Context, args db.CreateAccountParams) (db.Account, error) {
+	var account db.Account
+
+	err := s.execTx(ctx, func(q *querier) error {
+		var err error
+		account, err = q.CreateAccount(ctx, args)
+		if err != nil {
+			return err
+		}
+
+		err = addOutboxEvent(ctx, q, db.OutboxAggregateAccount, account.ID, db.EventAccountCreated, account)
+		if err != nil {
+			return err
+		}
+
+		return q.NotifyAccountEvents(ctx, account.Owner)
+	})
+	return account, err
+}
+
+// TransferTx is the transfer of the Postgres store without its lock
+// ordering. The transaction holds the write lock of the whole database
+// from the start, two transfers cannot deadlock on each other's accounts.
+func (s *Store) TransferTx(ctx context.Context, args db.TransferTxParams) (db.TransferTxResult, error) {
+	var result db.TransferTxResult
+
+	err := s.execTx(ctx, func(q *querier) error {
+		var err error
+
+		result.Transfer, err = q.CreateTransfer(ctx, db.CreateTransferParams{
+			FromAccountID: args.FromAccountID,
+			ToAccountID:   args.ToAccountID,
+			Amount:        args.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.FromEntry, err = q.CreateEntry(ctx, db.CreateEntryParams{
+			AccountID: args.FromAccountID,
+			Amount:    -args.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.ToEntry, err = q.CreateEntry(ctx, db.CreateEntryParams{
+			AccountID: args.ToAccountID,
+			Amount:    args.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.FromAccount, err = q.AddAccountBalance(ctx, db.AddAccountBalanceParams{
+			ID:     args.FromAccountID,
+			Amount: -args.Amount,
+		})
+		if err != nil {
+			return db.TransferBalanceError(args.FromAccountID, err)
+		}
+
+		result.ToAccount, err = q.AddAccountBalance(ctx, db.AddAccountBalanceParams{
+			ID:     args.ToAccountID,
+			Amount: args.Amount,
+		})
+		if err != nil {
+			return db.TransferBalanceError(args.ToAccountID, err)
+		}
+
+		return addTransferEvents(ctx, q, result)
+	})
+	return result, err
+}
+
+func addTransferEvents(ctx context.Context, q *querier, result db.TransferTxResult) error {
+	err := addOutboxEvent(ctx, q, db.OutboxAggregateAccount, result.FromAccount.ID, db.EventAccountBalanceChanged, db.BalanceChangedEvent{
+		Account: result.FromAccount,
+		Entry:   result.FromEntry,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = addOutboxEvent(ctx, q, db.OutboxAggregateAccount, result.ToAccount.ID, db.EventAccountBalanceChanged, db.BalanceChangedEvent{
+		Account: result.ToAccount,
+		Entry:   result.ToEntry,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = q.NotifyAccountEvents(ctx, result.FromAccount.Owner); err != nil {
+		return err
+	}
+	if err = q.NotifyAccountEvents(ctx, result.ToAccount.Owner); err != nil {
+		return err
+	}
+
+	return addOutboxEvent(ctx, q, db.OutboxAggregateTransfer, result.Transfer.ID, db.EventTransferCompleted, result)
+}
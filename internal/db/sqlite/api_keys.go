@@ -0,0 +1,54 @@
+package sqlitedb
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"simple-bank/internal/db"
+)
+
+func toAPIKey(key ApiKey) db.ApiKey {
+	return db.ApiKey{
+		ID:           key.ID,
+		Username:     key.Username,
+		Name:         key.Name,
+		Prefix:       key.Prefix,
+		HashedSecret: key.HashedSecret,
+		Scopes:       key.Scopes,
+		ExpiresAt:    key.ExpiresAt,
+		LastUsedAt:   key.LastUsedAt,
+		RevokedAt:    key.RevokedAt,
+		CreatedAt:    key.CreatedAt,
+	}
+}
+
+func (q *querier) CreateAPIKey(ctx context.Context, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+	key, err := q.q.CreateAPIKey(ctx, CreateAPIKeyParams{
+		ID:           arg.ID,
+		Username:     arg.Username,
+		Name:         arg.Name,
+		Prefix:       arg.Prefix,
+		HashedSecret: arg.HashedSecret,
+		Scopes:       arg.Scopes,
+		ExpiresAt:    arg.ExpiresAt,
+	})
+	return one(key, err, toAPIKey)
+}
+
+func (q *querier) GetAPIKeyByPrefix(ctx context.Context, prefix string) (db.ApiKey, error) {
+	key, err := q.q.GetAPIKeyByPrefix(ctx, prefix)
+	return one(key, err, toAPIKey)
+}
+
+func (q *querier) ListAPIKeys(ctx context.Context, username string) ([]db.ApiKey, error) {
+	keys, err := q.q.ListAPIKeys(ctx, username)
+	return many(keys, err, toAPIKey)
+}
+
+func (q *querier) RevokeAPIKey(ctx context.Context, arg db.RevokeAPIKeyParams) (int64, error) {
+	revoked, err := q.q.RevokeAPIKey(ctx, RevokeAPIKeyParams(arg))
+	return revoked, pgError(err)
+}
+
+func (q *querier) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	return pgError(q.q.TouchAPIKey(ctx, id))
+}
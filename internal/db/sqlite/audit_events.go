@@ -0,0 +1,30 @@
+package sqlitedb
+
+import (
+	"context"
+	"simple-bank/internal/db"
+)
+
+func toAuditEvent(event AuditEvent) db.AuditEvent {
+	return db.AuditEvent(event)
+}
+
+func (q *querier) CreateAuditEvent(ctx context.Context, arg db.CreateAuditEventParams) (db.AuditEvent, error) {
+	event, err := q.q.CreateAuditEvent(ctx, CreateAuditEventParams(arg))
+	return one(event, err, toAuditEvent)
+}
+
+func (q *querier) ListAuditEvents(ctx context.Context, arg db.ListAuditEventsParams) ([]db.AuditEvent, error) {
+	events, err := q.q.ListAuditEvents(ctx, ListAuditEventsParams{
+		Actor:      nullString(arg.Actor),
+		Action:     nullString(arg.Action),
+		TargetType: nullString(arg.TargetType),
+		TargetID:   nullString(arg.TargetID),
+		Outcome:    nullString(arg.Outcome),
+		Since:      nullTime(arg.Since),
+		Until:      nullTime(arg.Until),
+		BeforeID:   nullInt64(arg.BeforeID),
+		RowLimit:   int64(arg.RowLimit),
+	})
+	return many(events, err, toAuditEvent)
+}
@@ -0,0 +1,96 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"regexp"
+	"strings"
+)
+
+// uniqueConstraints names the unique constraints of the Postgres schema by
+// the columns SQLite reports.
+var uniqueConstraints = map[string]string{
+	"users.username":                        "users_pkey",
+	"users.email":                           "users_email_key",
+	"accounts.owner, accounts.currency":     "owner_currency_key",
+	"rate_limit_buckets.key":                "rate_limit_buckets_pkey",
+	"user_totp.username":                    "user_totp_pkey",
+	"user_tokens.hashed_token":              "user_tokens_hashed_token_key",
+	"api_keys.id":                           "api_keys_pkey",
+	"api_keys.prefix":                       "api_keys_prefix_key",
+	"oauth_clients.id":                      "oauth_clients_pkey",
+	"oauth_authorization_codes.hashed_code": "oauth_authorization_codes_hashed_code_key",
+	"webhook_deliveries.subscription_id, webhook_deliveries.event_id": "webhook_deliveries_subscription_id_event_id_idx",
+}
+
+// foreignKeyTrigger matches the messages of the foreign key triggers of the
+// schema, they are the ones of Postgres.
+var foreignKeyTrigger = regexp.MustCompile(`on table "(\w+)" violates foreign key constraint "(\w+)"`)
+
+// pgError translates err into the error the Postgres store returns, so
+// callers need not know which database they talk to. Constraint violations
+// become a *pgconn.PgError and missing rows pgx.ErrNoRows.
+func pgError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return pgx.ErrNoRows
+	}
+
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return err
+	}
+
+	message := sqliteErr.Error()
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		columns := strings.TrimPrefix(message, "UNIQUE constraint failed: ")
+		table, _, _ := strings.Cut(columns, ".")
+		constraint := uniqueConstraints[columns]
+		return &pgconn.PgError{
+			Code:           pgerrcode.UniqueViolation,
+			Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+			TableName:      table,
+			ConstraintName: constraint,
+		}
+	case sqlite3.ErrConstraintNotNull:
+		table, column, _ := strings.Cut(strings.TrimPrefix(message, "NOT NULL constraint failed: "), ".")
+		return &pgconn.PgError{
+			Code:       pgerrcode.NotNullViolation,
+			Message:    fmt.Sprintf("null value in column %q of relation %q violates not-null constraint", column, table),
+			TableName:  table,
+			ColumnName: column,
+		}
+	case sqlite3.ErrConstraintCheck:
+		constraint := strings.TrimPrefix(message, "CHECK constraint failed: ")
+		return &pgconn.PgError{
+			Code:           pgerrcode.CheckViolation,
+			Message:        fmt.Sprintf("new row violates check constraint %q", constraint),
+			ConstraintName: constraint,
+		}
+	case sqlite3.ErrConstraintForeignKey:
+		// a foreign key no trigger covers, SQLite does not say which
+		return &pgconn.PgError{
+			Code:    pgerrcode.ForeignKeyViolation,
+			Message: message,
+		}
+	case sqlite3.ErrConstraintTrigger:
+		if match := foreignKeyTrigger.FindStringSubmatch(message); match != nil {
+			return &pgconn.PgError{
+				Code:           pgerrcode.ForeignKeyViolation,
+				Message:        message,
+				TableName:      match[1],
+				ConstraintName: match[2],
+			}
+		}
+		return &pgconn.PgError{
+			Code:    pgerrcode.RaiseException,
+			Message: message,
+		}
+	}
+	return err
+}
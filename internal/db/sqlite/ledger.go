@@ -0,0 +1,13 @@
+package sqlitedb
+
+import (
+	"context"
+	"simple-bank/internal/db"
+)
+
+func (q *querier) ListLedgerMismatches(ctx context.Context) ([]db.ListLedgerMismatchesRow, error) {
+	mismatches, err := q.q.ListLedgerMismatches(ctx)
+	return many(mismatches, err, func(mismatch ListLedgerMismatchesRow) db.ListLedgerMismatchesRow {
+		return db.ListLedgerMismatchesRow(mismatch)
+	})
+}
@@ -0,0 +1,78 @@
+package sqlitedb
+
+import (
+	"context"
+	"simple-bank/internal/db"
+)
+
+func toOAuthClient(client OauthClient) db.OauthClient {
+	return db.OauthClient{
+		ID:           client.ID,
+		Owner:        client.Owner,
+		Name:         client.Name,
+		HashedSecret: client.HashedSecret,
+		RedirectUris: client.RedirectUris,
+		Scopes:       client.Scopes,
+		CreatedAt:    client.CreatedAt,
+	}
+}
+
+func toOAuthAuthorizationCode(code OauthAuthorizationCode) db.OauthAuthorizationCode {
+	return db.OauthAuthorizationCode{
+		ID:            code.ID,
+		HashedCode:    code.HashedCode,
+		ClientID:      code.ClientID,
+		Username:      code.Username,
+		RedirectUri:   code.RedirectUri,
+		Scopes:        code.Scopes,
+		CodeChallenge: code.CodeChallenge,
+		ExpiresAt:     code.ExpiresAt,
+		UsedAt:        code.UsedAt,
+		CreatedAt:     code.CreatedAt,
+	}
+}
+
+func (q *querier) CreateOAuthClient(ctx context.Context, arg db.CreateOAuthClientParams) (db.OauthClient, error) {
+	client, err := q.q.CreateOAuthClient(ctx, CreateOAuthClientParams{
+		ID:           arg.ID,
+		Owner:        arg.Owner,
+		Name:         arg.Name,
+		HashedSecret: arg.HashedSecret,
+		RedirectUris: arg.RedirectUris,
+		Scopes:       arg.Scopes,
+	})
+	return one(client, err, toOAuthClient)
+}
+
+func (q *querier) GetOAuthClient(ctx context.Context, id string) (db.OauthClient, error) {
+	client, err := q.q.GetOAuthClient(ctx, id)
+	return one(client, err, toOAuthClient)
+}
+
+func (q *querier) ListOAuthClients(ctx context.Context, owner string) ([]db.OauthClient, error) {
+	clients, err := q.q.ListOAuthClients(ctx, owner)
+	return many(clients, err, toOAuthClient)
+}
+
+func (q *querier) CreateOAuthAuthorizationCode(ctx context.Context, arg db.CreateOAuthAuthorizationCodeParams) (db.OauthAuthorizationCode, error) {
+	code, err := q.q.CreateOAuthAuthorizationCode(ctx, CreateOAuthAuthorizationCodeParams{
+		HashedCode:    arg.HashedCode,
+		ClientID:      arg.ClientID,
+		Username:      arg.Username,
+		RedirectUri:   arg.RedirectUri,
+		Scopes:        arg.Scopes,
+		CodeChallenge: arg.CodeChallenge,
+		ExpiresAt:     arg.ExpiresAt,
+	})
+	return one(code, err, toOAuthAuthorizationCode)
+}
+
+func (q *querier) GetOAuthAuthorizationCode(ctx context.Context, hashedCode string) (db.OauthAuthorizationCode, error) {
+	code, err := q.q.GetOAuthAuthorizationCode(ctx, hashedCode)
+	return one(code, err, toOAuthAuthorizationCode)
+}
+
+func (q *querier) UseOAuthAuthorizationCode(ctx context.Context, id int64) (int64, error) {
+	used, err := q.q.UseOAuthAuthorizationCode(ctx, id)
+	return used, pgError(err)
+}
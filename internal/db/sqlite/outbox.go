@@ -0,0 +1,74 @@
+package sqlitedb
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"simple-bank/internal/db"
+	"slices"
+	"strconv"
+	"time"
+)
+
+func toOutbox(event Outbox) db.Outbox {
+	return db.Outbox(event)
+}
+
+func (q *querier) CreateOutboxEvent(ctx context.Context, arg db.CreateOutboxEventParams) (db.Outbox, error) {
+	event, err := q.q.CreateOutboxEvent(ctx, CreateOutboxEventParams(arg))
+	return one(event, err, toOutbox)
+}
+
+func (q *querier) ClaimOutboxEvents(ctx context.Context, arg db.ClaimOutboxEventsParams) ([]db.Outbox, error) {
+	events, err := q.q.ClaimOutboxEvents(ctx, ClaimOutboxEventsParams{
+		LeaseUntil: arg.LeaseUntil,
+		RowLimit:   int64(arg.RowLimit),
+	})
+	slices.SortFunc(events, func(a, b Outbox) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return many(events, err, toOutbox)
+}
+
+func (q *querier) MarkOutboxEventPublished(ctx context.Context, id int64) error {
+	return pgError(q.q.MarkOutboxEventPublished(ctx, id))
+}
+
+func (q *querier) MarkOutboxEventFailed(ctx context.Context, arg db.MarkOutboxEventFailedParams) error {
+	return pgError(q.q.MarkOutboxEventFailed(ctx, MarkOutboxEventFailedParams(arg)))
+}
+
+func (q *querier) DeletePublishedOutboxEvents(ctx context.Context, publishedAt time.Time) error {
+	return pgError(q.q.DeletePublishedOutboxEvents(ctx, publishedAt))
+}
+
+func (q *querier) ListAccountEvents(ctx context.Context, arg db.ListAccountEventsParams) ([]db.Outbox, error) {
+	events, err := q.q.ListAccountEvents(ctx, ListAccountEventsParams{
+		Owner:    arg.Owner,
+		AfterID:  arg.AfterID,
+		RowLimit: int64(arg.RowLimit),
+	})
+	return many(events, err, toOutbox)
+}
+
+func (q *querier) GetLatestAccountEventID(ctx context.Context, owner string) (int64, error) {
+	latestID, err := q.q.GetLatestAccountEventID(ctx, owner)
+	return latestID, pgError(err)
+}
+
+// addOutboxEvent must run inside the transaction that changes the
+// aggregate, like the one of the Postgres store.
+func addOutboxEvent(ctx context.Context, q *querier, aggregateType string, aggregateID int64, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.CreateOutboxEvent(ctx, db.CreateOutboxEventParams{
+		AggregateType: aggregateType,
+		AggregateID:   strconv.FormatInt(aggregateID, 10),
+		EventType:     eventType,
+		Payload:       data,
+	})
+	return err
+}
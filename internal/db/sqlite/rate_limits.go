@@ -0,0 +1,21 @@
+package sqlitedb
+
+import (
+	"context"
+	"simple-bank/internal/db"
+	"time"
+)
+
+func (q *querier) RefillRateLimitBucket(ctx context.Context, arg db.RefillRateLimitBucketParams) (float64, error) {
+	tokens, err := q.q.RefillRateLimitBucket(ctx, RefillRateLimitBucketParams(arg))
+	return tokens, pgError(err)
+}
+
+func (q *querier) TakeRateLimitToken(ctx context.Context, key string) (float64, error) {
+	tokens, err := q.q.TakeRateLimitToken(ctx, key)
+	return tokens, pgError(err)
+}
+
+func (q *querier) DeleteStaleRateLimitBuckets(ctx context.Context, updatedAt time.Time) error {
+	return pgError(q.q.DeleteStaleRateLimitBuckets(ctx, updatedAt))
+}
@@ -0,0 +1,183 @@
+// Package sqlitedb is a db.Store on SQLite, for running the bank without a
+// Postgres server. It follows the semantics of the Postgres store,
+// constraint violations are reported as the *pgconn.PgError Postgres would
+// have returned and missing rows as pgx.ErrNoRows.
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jackc/pgx/v5/pgtype"
+	_ "github.com/mattn/go-sqlite3"
+	"simple-bank/internal/db"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ db.Store = (*Store)(nil)
+
+// Store runs the queries of db/sqlite/query behind the db.Store interface.
+type Store struct {
+	*querier
+	db *sql.DB
+
+	mu                    sync.Mutex
+	accountEventsListener func(owner string)
+}
+
+func NewStore(sqlDB *sql.DB) *Store {
+	s := &Store{db: sqlDB}
+	s.querier = &querier{
+		q:      New(utcDB{sqlDB}),
+		notify: s.notifyAccountEvents,
+	}
+	return s
+}
+
+// Open opens the database at source, a file path, and checks it can be
+// read. SQLite has a single writer: transactions take the write lock as
+// they begin and wait for it, rather than fail when upgrading a read lock
+// that another writer got first.
+func Open(ctx context.Context, source string) (*sql.DB, error) {
+	separator := "?"
+	if strings.Contains(source, "?") {
+		separator = "&"
+	}
+	sqlDB, err := sql.Open("sqlite3", source+separator+"_fk=1&_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	if err = sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return sqlDB, nil
+}
+
+// OnAccountEvents registers the listener NotifyAccountEvents calls, it
+// stands in for LISTEN on the db.AccountEventsChannel.
+func (s *Store) OnAccountEvents(listener func(owner string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accountEventsListener = listener
+}
+
+func (s *Store) notifyAccountEvents(owner string) {
+	s.mu.Lock()
+	listener := s.accountEventsListener
+	s.mu.Unlock()
+
+	if listener != nil {
+		listener(owner)
+	}
+}
+
+// execTx runs fn in a transaction. Account events are only notified once
+// it commits, each owner once, like NOTIFY does.
+func (s *Store) execTx(ctx context.Context, fn func(q *querier) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return pgError(err)
+	}
+
+	var owners []string
+	q := &querier{
+		q: New(utcDB{tx}),
+		notify: func(owner string) {
+			owners = append(owners, owner)
+		},
+	}
+	if err = fn(q); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return rbErr
+		}
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return pgError(err)
+	}
+
+	slices.Sort(owners)
+	for _, owner := range slices.Compact(owners) {
+		s.notifyAccountEvents(owner)
+	}
+	return nil
+}
+
+// querier is db.Querier on the queries of this package, on the database or
+// on a transaction.
+type querier struct {
+	q      *Queries
+	notify func(owner string)
+}
+
+func (q *querier) NotifyAccountEvents(_ context.Context, owner string) error {
+	q.notify(owner)
+	return nil
+}
+
+// utcDB binds times in UTC. They are stored as text, which only sorts like
+// the times it holds when they are all in the same zone.
+type utcDB struct {
+	DBTX
+}
+
+func (d utcDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return d.DBTX.ExecContext(ctx, query, utc(args)...)
+}
+
+func (d utcDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.DBTX.QueryContext(ctx, query, utc(args)...)
+}
+
+func (d utcDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.DBTX.QueryRowContext(ctx, query, utc(args)...)
+}
+
+func utc(args []any) []any {
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case time.Time:
+			args[i] = arg.UTC()
+		case sql.NullTime:
+			args[i] = sql.NullTime{Time: arg.Time.UTC(), Valid: arg.Valid}
+		}
+	}
+	return args
+}
+
+// one converts the row of a :one query.
+func one[T, U any](row T, err error, convert func(T) U) (U, error) {
+	if err != nil {
+		var zero U
+		return zero, pgError(err)
+	}
+	return convert(row), nil
+}
+
+// many converts the rows of a :many query, an empty result stays an empty
+// slice.
+func many[T, U any](rows []T, err error, convert func(T) U) ([]U, error) {
+	if err != nil {
+		return nil, pgError(err)
+	}
+	converted := make([]U, 0, len(rows))
+	for _, row := range rows {
+		converted = append(converted, convert(row))
+	}
+	return converted, nil
+}
+
+func nullString(value pgtype.Text) sql.NullString {
+	return sql.NullString{String: value.String, Valid: value.Valid}
+}
+
+func nullInt64(value pgtype.Int8) sql.NullInt64 {
+	return sql.NullInt64{Int64: value.Int64, Valid: value.Valid}
+}
+
+func nullTime(value pgtype.Timestamptz) sql.NullTime {
+	return sql.NullTime{Time: value.Time, Valid: value.Valid}
+}
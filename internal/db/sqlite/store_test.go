@@ -0,0 +1,29 @@
+package sqlitedb
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	sqlitemigration "simple-bank/db/sqlite/migration"
+	"simple-bank/internal/db"
+	"simple-bank/internal/db/storetest"
+	"simple-bank/internal/migrate"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	sqlDB, err := Open(ctx, filepath.Join(t.TempDir(), "bank.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	migrator, err := migrate.NewSQLite(sqlDB, sqlitemigration.FS)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	store := NewStore(sqlDB)
+	storetest.Run(t, func(t *testing.T) db.Store {
+		return store
+	})
+}
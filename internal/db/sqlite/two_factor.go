@@ -0,0 +1,96 @@
+package sqlitedb
+
+import (
+	"context"
+	"simple-bank/internal/db"
+)
+
+func toUserTOTP(totp UserTotp) db.UserTotp {
+	return db.UserTotp(totp)
+}
+
+func toRecoveryCode(code RecoveryCode) db.RecoveryCode {
+	return db.RecoveryCode(code)
+}
+
+func (q *querier) UpsertUserTOTP(ctx context.Context, arg db.UpsertUserTOTPParams) (db.UserTotp, error) {
+	totp, err := q.q.UpsertUserTOTP(ctx, UpsertUserTOTPParams(arg))
+	return one(totp, err, toUserTOTP)
+}
+
+func (q *querier) GetUserTOTP(ctx context.Context, username string) (db.UserTotp, error) {
+	totp, err := q.q.GetUserTOTP(ctx, username)
+	return one(totp, err, toUserTOTP)
+}
+
+func (q *querier) ConfirmUserTOTP(ctx context.Context, arg db.ConfirmUserTOTPParams) (db.UserTotp, error) {
+	totp, err := q.q.ConfirmUserTOTP(ctx, ConfirmUserTOTPParams(arg))
+	return one(totp, err, toUserTOTP)
+}
+
+func (q *querier) UseTOTPStep(ctx context.Context, arg db.UseTOTPStepParams) (int64, error) {
+	used, err := q.q.UseTOTPStep(ctx, UseTOTPStepParams(arg))
+	return used, pgError(err)
+}
+
+func (q *querier) CreateRecoveryCode(ctx context.Context, arg db.CreateRecoveryCodeParams) (db.RecoveryCode, error) {
+	code, err := q.q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams(arg))
+	return one(code, err, toRecoveryCode)
+}
+
+func (q *querier) GetUnusedRecoveryCode(ctx context.Context, arg db.GetUnusedRecoveryCodeParams) (db.RecoveryCode, error) {
+	code, err := q.q.GetUnusedRecoveryCode(ctx, GetUnusedRecoveryCodeParams(arg))
+	return one(code, err, toRecoveryCode)
+}
+
+func (q *querier) ListUnusedRecoveryCodes(ctx context.Context, username string) ([]db.RecoveryCode, error) {
+	codes, err := q.q.ListUnusedRecoveryCodes(ctx, username)
+	return many(codes, err, toRecoveryCode)
+}
+
+func (q *querier) UseRecoveryCode(ctx context.Context, id int64) (int64, error) {
+	used, err := q.q.UseRecoveryCode(ctx, id)
+	return used, pgError(err)
+}
+
+func (q *querier) DeleteRecoveryCodes(ctx context.Context, username string) error {
+	return pgError(q.q.DeleteRecoveryCodes(ctx, username))
+}
+
+// ConfirmTOTPTx enables two-factor authentication and replaces the recovery
+// codes of the user in a single transaction.
+func (s *Store) ConfirmTOTPTx(ctx context.Context, args db.ConfirmTOTPTxParams) (db.ConfirmTOTPTxResult, error) {
+	var result db.ConfirmTOTPTxResult
+
+	err := s.execTx(ctx, func(q *querier) error {
+		var err error
+
+		result.TOTP, err = q.ConfirmUserTOTP(ctx, db.ConfirmUserTOTPParams{
+			LastUsedStep: args.Step,
+			Username:     args.Username,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = q.DeleteRecoveryCodes(ctx, args.Username); err != nil {
+			return err
+		}
+
+		result.RecoveryCodes = make([]db.RecoveryCode, 0, len(args.RecoveryCodes))
+		for _, recoveryCode := range args.RecoveryCodes {
+			code, err := q.CreateRecoveryCode(ctx, db.CreateRecoveryCodeParams{
+				Username:   args.Username,
+				HashedCode: recoveryCode.HashedCode,
+				Lookup:     recoveryCode.Lookup,
+			})
+			if err != nil {
+				return err
+			}
+			result.RecoveryCodes = append(result.RecoveryCodes, code)
+		}
+
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,168 @@
+package sqlitedb
+
+import (
+	"context"
+	"simple-bank/internal/db"
+	"time"
+)
+
+func toUser(user User) db.User {
+	return db.User(user)
+}
+
+func toLoginAttempt(attempt LoginAttempt) db.LoginAttempt {
+	return db.LoginAttempt(attempt)
+}
+
+func toUserToken(token UserToken) db.UserToken {
+	return db.UserToken(token)
+}
+
+func (q *querier) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	user, err := q.q.CreateUser(ctx, CreateUserParams(arg))
+	return one(user, err, toUser)
+}
+
+func (q *querier) GetUser(ctx context.Context, username string) (db.User, error) {
+	user, err := q.q.GetUser(ctx, username)
+	return one(user, err, toUser)
+}
+
+func (q *querier) GetUserByEmail(ctx context.Context, email string) (db.User, error) {
+	user, err := q.q.GetUserByEmail(ctx, email)
+	return one(user, err, toUser)
+}
+
+func (q *querier) RegisterFailedLogin(ctx context.Context, username string) (db.User, error) {
+	user, err := q.q.RegisterFailedLogin(ctx, username)
+	return one(user, err, toUser)
+}
+
+func (q *querier) LockUser(ctx context.Context, arg db.LockUserParams) error {
+	return pgError(q.q.LockUser(ctx, LockUserParams(arg)))
+}
+
+func (q *querier) ResetFailedLogins(ctx context.Context, username string) error {
+	return pgError(q.q.ResetFailedLogins(ctx, username))
+}
+
+func (q *querier) VerifyUserEmail(ctx context.Context, username string) (db.User, error) {
+	user, err := q.q.VerifyUserEmail(ctx, username)
+	return one(user, err, toUser)
+}
+
+func (q *querier) UpdateUserRole(ctx context.Context, arg db.UpdateUserRoleParams) (db.User, error) {
+	user, err := q.q.UpdateUserRole(ctx, UpdateUserRoleParams(arg))
+	return one(user, err, toUser)
+}
+
+func (q *querier) UpdateUserPassword(ctx context.Context, arg db.UpdateUserPasswordParams) (db.User, error) {
+	user, err := q.q.UpdateUserPassword(ctx, UpdateUserPasswordParams(arg))
+	return one(user, err, toUser)
+}
+
+func (q *querier) UpdateUser(ctx context.Context, arg db.UpdateUserParams) (db.User, error) {
+	user, err := q.q.UpdateUser(ctx, UpdateUserParams{
+		FullName:        nullString(arg.FullName),
+		Email:           nullString(arg.Email),
+		Username:        arg.Username,
+		ExpectedVersion: nullInt64(arg.ExpectedVersion),
+	})
+	return one(user, err, toUser)
+}
+
+func (q *querier) UpgradeUserPasswordHash(ctx context.Context, arg db.UpgradeUserPasswordHashParams) error {
+	return pgError(q.q.UpgradeUserPasswordHash(ctx, UpgradeUserPasswordHashParams(arg)))
+}
+
+func (q *querier) CreateLoginAttempt(ctx context.Context, arg db.CreateLoginAttemptParams) (db.LoginAttempt, error) {
+	attempt, err := q.q.CreateLoginAttempt(ctx, CreateLoginAttemptParams(arg))
+	return one(attempt, err, toLoginAttempt)
+}
+
+func (q *querier) ListLoginAttempts(ctx context.Context, arg db.ListLoginAttemptsParams) ([]db.LoginAttempt, error) {
+	attempts, err := q.q.ListLoginAttempts(ctx, ListLoginAttemptsParams{
+		Username: arg.Username,
+		Limit:    int64(arg.Limit),
+	})
+	return many(attempts, err, toLoginAttempt)
+}
+
+func (q *querier) CreateUserToken(ctx context.Context, arg db.CreateUserTokenParams) (db.UserToken, error) {
+	token, err := q.q.CreateUserToken(ctx, CreateUserTokenParams(arg))
+	return one(token, err, toUserToken)
+}
+
+func (q *querier) GetUserToken(ctx context.Context, arg db.GetUserTokenParams) (db.UserToken, error) {
+	token, err := q.q.GetUserToken(ctx, GetUserTokenParams(arg))
+	return one(token, err, toUserToken)
+}
+
+func (q *querier) UseUserToken(ctx context.Context, id int64) (int64, error) {
+	used, err := q.q.UseUserToken(ctx, id)
+	return used, pgError(err)
+}
+
+func (q *querier) RevokeUserTokens(ctx context.Context, arg db.RevokeUserTokensParams) error {
+	return pgError(q.q.RevokeUserTokens(ctx, RevokeUserTokensParams(arg)))
+}
+
+// VerifyEmailTx consumes an email verification token and marks the email of
+// its owner as verified.
+func (s *Store) VerifyEmailTx(ctx context.Context, args db.VerifyEmailTxParams) (db.User, error) {
+	var user db.User
+
+	err := s.execTx(ctx, func(q *querier) error {
+		if err := consumeUserToken(ctx, q, args.TokenID); err != nil {
+			return err
+		}
+
+		var err error
+		user, err = q.VerifyUserEmail(ctx, args.Username)
+		return err
+	})
+	return user, err
+}
+
+// ResetPasswordTx consumes a password reset token, sets the new password,
+// lifts a lockout and revokes every other reset token of the user.
+func (s *Store) ResetPasswordTx(ctx context.Context, args db.ResetPasswordTxParams) (db.User, error) {
+	var user db.User
+
+	err := s.execTx(ctx, func(q *querier) error {
+		if err := consumeUserToken(ctx, q, args.TokenID); err != nil {
+			return err
+		}
+
+		err := q.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+			Username: args.Username,
+			Purpose:  db.UserTokenPurposePasswordReset,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = q.ResetFailedLogins(ctx, args.Username); err != nil {
+			return err
+		}
+
+		user, err = q.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{
+			Username:          args.Username,
+			HashedPassword:    args.HashedPassword,
+			PasswordChangedAt: time.Now(),
+		})
+		return err
+	})
+	return user, err
+}
+
+func consumeUserToken(ctx context.Context, q *querier, id int64) error {
+	used, err := q.UseUserToken(ctx, id)
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return db.ErrUserTokenUsed
+	}
+	return nil
+}
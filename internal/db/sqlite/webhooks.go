@@ -0,0 +1,125 @@
+package sqlitedb
+
+import (
+	"cmp"
+	"context"
+	"simple-bank/internal/db"
+	"slices"
+)
+
+func toWebhookSubscription(subscription WebhookSubscription) db.WebhookSubscription {
+	return db.WebhookSubscription{
+		ID:                  subscription.ID,
+		Owner:               subscription.Owner,
+		Url:                 subscription.Url,
+		Secret:              subscription.Secret,
+		EventTypes:          subscription.EventTypes,
+		ConsecutiveFailures: subscription.ConsecutiveFailures,
+		DisabledAt:          subscription.DisabledAt,
+		CreatedAt:           subscription.CreatedAt,
+	}
+}
+
+func toWebhookDelivery(delivery WebhookDelivery) db.WebhookDelivery {
+	return db.WebhookDelivery(delivery)
+}
+
+func (q *querier) CreateWebhookSubscription(ctx context.Context, arg db.CreateWebhookSubscriptionParams) (db.WebhookSubscription, error) {
+	subscription, err := q.q.CreateWebhookSubscription(ctx, CreateWebhookSubscriptionParams{
+		Owner:      arg.Owner,
+		Url:        arg.Url,
+		Secret:     arg.Secret,
+		EventTypes: arg.EventTypes,
+	})
+	return one(subscription, err, toWebhookSubscription)
+}
+
+func (q *querier) GetWebhookSubscription(ctx context.Context, id int64) (db.WebhookSubscription, error) {
+	subscription, err := q.q.GetWebhookSubscription(ctx, id)
+	return one(subscription, err, toWebhookSubscription)
+}
+
+func (q *querier) ListWebhookSubscriptions(ctx context.Context, owner string) ([]db.WebhookSubscription, error) {
+	subscriptions, err := q.q.ListWebhookSubscriptions(ctx, owner)
+	return many(subscriptions, err, toWebhookSubscription)
+}
+
+func (q *querier) ListWebhookSubscriptionsForEvent(ctx context.Context, arg db.ListWebhookSubscriptionsForEventParams) ([]db.WebhookSubscription, error) {
+	subscriptions, err := q.q.ListWebhookSubscriptionsForEvent(ctx, ListWebhookSubscriptionsForEventParams{
+		Owners:    StringList(arg.Owners),
+		EventType: arg.EventType,
+	})
+	return many(subscriptions, err, toWebhookSubscription)
+}
+
+func (q *querier) UpdateWebhookSubscription(ctx context.Context, arg db.UpdateWebhookSubscriptionParams) (db.WebhookSubscription, error) {
+	subscription, err := q.q.UpdateWebhookSubscription(ctx, UpdateWebhookSubscriptionParams{
+		ID:         arg.ID,
+		Url:        arg.Url,
+		EventTypes: arg.EventTypes,
+		DisabledAt: arg.DisabledAt,
+	})
+	return one(subscription, err, toWebhookSubscription)
+}
+
+func (q *querier) DeleteWebhookSubscription(ctx context.Context, arg db.DeleteWebhookSubscriptionParams) (int64, error) {
+	deleted, err := q.q.DeleteWebhookSubscription(ctx, DeleteWebhookSubscriptionParams(arg))
+	return deleted, pgError(err)
+}
+
+func (q *querier) RecordWebhookSubscriptionFailure(ctx context.Context, arg db.RecordWebhookSubscriptionFailureParams) (db.WebhookSubscription, error) {
+	subscription, err := q.q.RecordWebhookSubscriptionFailure(ctx, RecordWebhookSubscriptionFailureParams{
+		DisableAfter: int64(arg.DisableAfter),
+		ID:           arg.ID,
+	})
+	return one(subscription, err, toWebhookSubscription)
+}
+
+func (q *querier) ResetWebhookSubscriptionFailures(ctx context.Context, id int64) error {
+	return pgError(q.q.ResetWebhookSubscriptionFailures(ctx, id))
+}
+
+func (q *querier) CreateWebhookDelivery(ctx context.Context, arg db.CreateWebhookDeliveryParams) error {
+	return pgError(q.q.CreateWebhookDelivery(ctx, CreateWebhookDeliveryParams(arg)))
+}
+
+func (q *querier) ReplayWebhookDelivery(ctx context.Context, id int64) (db.WebhookDelivery, error) {
+	delivery, err := q.q.ReplayWebhookDelivery(ctx, id)
+	return one(delivery, err, toWebhookDelivery)
+}
+
+func (q *querier) GetWebhookDelivery(ctx context.Context, id int64) (db.WebhookDelivery, error) {
+	delivery, err := q.q.GetWebhookDelivery(ctx, id)
+	return one(delivery, err, toWebhookDelivery)
+}
+
+func (q *querier) ListWebhookDeliveries(ctx context.Context, arg db.ListWebhookDeliveriesParams) ([]db.WebhookDelivery, error) {
+	deliveries, err := q.q.ListWebhookDeliveries(ctx, ListWebhookDeliveriesParams{
+		SubscriptionID: arg.SubscriptionID,
+		Status:         nullString(arg.Status),
+		BeforeID:       nullInt64(arg.BeforeID),
+		RowLimit:       int64(arg.RowLimit),
+	})
+	return many(deliveries, err, toWebhookDelivery)
+}
+
+func (q *querier) ClaimWebhookDeliveries(ctx context.Context, arg db.ClaimWebhookDeliveriesParams) ([]db.ClaimWebhookDeliveriesRow, error) {
+	deliveries, err := q.q.ClaimWebhookDeliveries(ctx, ClaimWebhookDeliveriesParams{
+		LeaseUntil: arg.LeaseUntil,
+		RowLimit:   int64(arg.RowLimit),
+	})
+	slices.SortFunc(deliveries, func(a, b ClaimWebhookDeliveriesRow) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return many(deliveries, err, func(delivery ClaimWebhookDeliveriesRow) db.ClaimWebhookDeliveriesRow {
+		return db.ClaimWebhookDeliveriesRow(delivery)
+	})
+}
+
+func (q *querier) MarkWebhookDeliverySucceeded(ctx context.Context, arg db.MarkWebhookDeliverySucceededParams) error {
+	return pgError(q.q.MarkWebhookDeliverySucceeded(ctx, MarkWebhookDeliverySucceededParams(arg)))
+}
+
+func (q *querier) MarkWebhookDeliveryFailed(ctx context.Context, arg db.MarkWebhookDeliveryFailedParams) error {
+	return pgError(q.q.MarkWebhookDeliveryFailed(ctx, MarkWebhookDeliveryFailedParams(arg)))
+}
@@ -2,23 +2,30 @@ package db
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Store interface {
+	CreateAccountTx(ctx context.Context, args CreateAccountParams) (Account, error)
 	TransferTx(ctx context.Context, args TransferTxParams) (TransferTxResult, error)
+	ConfirmTOTPTx(ctx context.Context, args ConfirmTOTPTxParams) (ConfirmTOTPTxResult, error)
+	VerifyEmailTx(ctx context.Context, args VerifyEmailTxParams) (User, error)
+	ResetPasswordTx(ctx context.Context, args ResetPasswordTxParams) (User, error)
 	Querier
 }
 
 type SQLStore struct {
 	*Queries
-	db *sql.DB
+	pool *pgxpool.Pool
 }
 
-func NewStore(db *sql.DB) Store {
+func NewStore(pool *pgxpool.Pool) Store {
 	return &SQLStore{
-		db:      db,
-		Queries: New(db),
+		pool:    pool,
+		Queries: New(pool),
 	}
 }
 
@@ -28,6 +35,26 @@ type TransferTxParams struct {
 	Amount        int64 `json:"amount"`
 }
 
+// AccountFrozenError is returned by TransferTx when one of the accounts is
+// frozen by the time its balance is updated.
+type AccountFrozenError struct {
+	AccountID int64
+}
+
+func (e *AccountFrozenError) Error() string {
+	return fmt.Sprintf("account [%d] is frozen", e.AccountID)
+}
+
+// TransferBalanceError converts the error of AddAccountBalance within a
+// transfer: the transfer row references both accounts, an update that finds
+// none was refused by the freeze condition.
+func TransferBalanceError(accountID int64, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &AccountFrozenError{AccountID: accountID}
+	}
+	return err
+}
+
 type TransferTxResult struct {
 	Transfer    Transfer `json:"transfer"`
 	FromAccount Account  `json:"from_account"`
@@ -68,42 +95,73 @@ func (s *SQLStore) TransferTx(ctx context.Context, args TransferTxParams) (Trans
 		}
 
 		if args.FromAccountID < args.ToAccountID {
-			result.FromAccount, result.ToAccount, err = s.addBalance(ctx, args.FromAccountID, -args.Amount, args.ToAccountID, args.Amount)
+			result.FromAccount, result.ToAccount, err = addBalance(ctx, q, args.FromAccountID, -args.Amount, args.ToAccountID, args.Amount)
 		} else {
-			result.ToAccount, result.FromAccount, err = s.addBalance(ctx, args.ToAccountID, args.Amount, args.FromAccountID, -args.Amount)
+			result.ToAccount, result.FromAccount, err = addBalance(ctx, q, args.ToAccountID, args.Amount, args.FromAccountID, -args.Amount)
 		}
 
 		if err != nil {
 			return err
 		}
 
-		return nil
+		return addTransferEvents(ctx, q, result)
 	})
 	return result, err
 }
 
-func (s *SQLStore) addBalance(
+func addBalance(
 	ctx context.Context,
+	q *Queries,
 	account1Id int64,
 	amount1 int64,
 	account2Id int64,
 	amount2 int64) (account1 Account, account2 Account, err error) {
-	account1, err = s.AddAccountBalance(ctx, AddAccountBalanceParams{
+	account1, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
 		ID:     account1Id,
 		Amount: amount1,
 	})
 	if err != nil {
+		err = TransferBalanceError(account1Id, err)
 		return
 	}
-	account2, err = s.AddAccountBalance(ctx, AddAccountBalanceParams{
+	account2, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
 		ID:     account2Id,
 		Amount: amount2,
 	})
+	err = TransferBalanceError(account2Id, err)
 	return
 }
 
+func addTransferEvents(ctx context.Context, q *Queries, result TransferTxResult) error {
+	err := addOutboxEvent(ctx, q, OutboxAggregateAccount, result.FromAccount.ID, EventAccountBalanceChanged, BalanceChangedEvent{
+		Account: result.FromAccount,
+		Entry:   result.FromEntry,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = addOutboxEvent(ctx, q, OutboxAggregateAccount, result.ToAccount.ID, EventAccountBalanceChanged, BalanceChangedEvent{
+		Account: result.ToAccount,
+		Entry:   result.ToEntry,
+	})
+	if err != nil {
+		return err
+	}
+
+	// notifications are only sent on commit, identical ones just once
+	if err = q.NotifyAccountEvents(ctx, result.FromAccount.Owner); err != nil {
+		return err
+	}
+	if err = q.NotifyAccountEvents(ctx, result.ToAccount.Owner); err != nil {
+		return err
+	}
+
+	return addOutboxEvent(ctx, q, OutboxAggregateTransfer, result.Transfer.ID, EventTransferCompleted, result)
+}
+
 func (s *SQLStore) execTx(ctx context.Context, cb func(q *Queries) error) error {
-	tx, err := s.db.BeginTx(ctx, nil)
+	tx, err := s.pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
@@ -111,11 +169,11 @@ func (s *SQLStore) execTx(ctx context.Context, cb func(q *Queries) error) error
 	q := New(tx)
 	err = cb(q)
 	if err != nil {
-		if rbErr := tx.Rollback(); rbErr != nil {
+		if rbErr := tx.Rollback(ctx); rbErr != nil {
 			return rbErr
 		}
 		return err
 	}
 
-	return tx.Commit()
+	return tx.Commit(ctx)
 }
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jackc/pgx/v5/pgxpool"
+	_ "github.com/lib/pq"
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+// BenchmarkTransfer compares the statements of a transfer run natively on
+// pgx, the way the store runs them now, with the same statements through
+// database/sql on lib/pq, the way the store ran them before. lib/pq is a
+// test-only dependency kept for this comparison. Every goroutine moves money
+// between accounts of its own, so row locks do not serialize the runs.
+//
+//	go test ./internal/db -run '^$' -bench Transfer -benchtime 10s
+func BenchmarkTransfer(b *testing.B) {
+	pairs := make([]TransferTxParams, runtime.GOMAXPROCS(0))
+	for i := range pairs {
+		from, to := createRandomAccount(b), createRandomAccount(b)
+		pairs[i] = TransferTxParams{FromAccountID: from.ID, ToAccountID: to.ID, Amount: 1}
+	}
+
+	run := func(b *testing.B, transfer func(ctx context.Context, arg TransferTxParams) error) {
+		var next atomic.Int32
+		b.RunParallel(func(pb *testing.PB) {
+			arg := pairs[int(next.Add(1)-1)%len(pairs)]
+			for pb.Next() {
+				if err := transfer(context.Background(), arg); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	}
+
+	b.Run("pgx", func(b *testing.B) {
+		run(b, func(ctx context.Context, arg TransferTxParams) error {
+			return pgxTransfer(ctx, testDB, arg)
+		})
+	})
+
+	b.Run("lib_pq", func(b *testing.B) {
+		conn, err := sql.Open("postgres", testDBSource)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer conn.Close()
+		conn.SetMaxOpenConns(int(testDB.Config().MaxConns))
+
+		run(b, func(ctx context.Context, arg TransferTxParams) error {
+			return sqlTransfer(ctx, conn, arg)
+		})
+	})
+}
+
+// pgxTransfer is TransferTx without the outbox events, FromAccountID must be
+// the lower id.
+func pgxTransfer(ctx context.Context, pool *pgxpool.Pool, arg TransferTxParams) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := New(tx)
+	if _, err = q.CreateTransfer(ctx, CreateTransferParams(arg)); err != nil {
+		return err
+	}
+	if _, err = q.CreateEntry(ctx, CreateEntryParams{AccountID: arg.FromAccountID, Amount: -arg.Amount}); err != nil {
+		return err
+	}
+	if _, err = q.CreateEntry(ctx, CreateEntryParams{AccountID: arg.ToAccountID, Amount: arg.Amount}); err != nil {
+		return err
+	}
+	if _, _, err = addBalance(ctx, q, arg.FromAccountID, -arg.Amount, arg.ToAccountID, arg.Amount); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// sqlTransfer runs the statements of pgxTransfer through database/sql and
+// scans the same rows.
+func sqlTransfer(ctx context.Context, conn *sql.DB, arg TransferTxParams) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var transfer Transfer
+	err = tx.QueryRowContext(ctx, createTransfer, arg.FromAccountID, arg.ToAccountID, arg.Amount).
+		Scan(&transfer.ID, &transfer.FromAccountID, &transfer.ToAccountID, &transfer.Amount, &transfer.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range []CreateEntryParams{
+		{AccountID: arg.FromAccountID, Amount: -arg.Amount},
+		{AccountID: arg.ToAccountID, Amount: arg.Amount},
+	} {
+		var i Entry
+		err = tx.QueryRowContext(ctx, createEntry, entry.AccountID, entry.Amount).
+			Scan(&i.ID, &i.AccountID, &i.Amount, &i.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, balance := range []AddAccountBalanceParams{
+		{ID: arg.FromAccountID, Amount: -arg.Amount},
+		{ID: arg.ToAccountID, Amount: arg.Amount},
+	} {
+		var i Account
+		err = tx.QueryRowContext(ctx, addAccountBalance, balance.Amount, balance.ID).
+			Scan(&i.ID, &i.Owner, &i.Balance, &i.Currency, &i.CreatedAt, &i.FrozenAt, &i.Version)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
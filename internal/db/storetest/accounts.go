@@ -0,0 +1,348 @@
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/db"
+	"simple-bank/internal/utils"
+	"strconv"
+	"testing"
+)
+
+var accountTests = []testCase{
+	{"CreateAccount", testCreateAccount},
+	{"GetAccount_NotFound", testGetAccountNotFound},
+	{"ListAccounts", testListAccounts},
+	{"AddAccountBalance", testAddAccountBalance},
+	{"FreezeAccount", testFreezeAccount},
+	{"DeleteAccount", testDeleteAccount},
+	{"TransferTx", testTransferTx},
+	{"TransferTx_Concurrent", testTransferTxConcurrent},
+	{"TransferTx_MissingAccount", testTransferTxMissingAccount},
+	{"TransferTx_FrozenAccount", testTransferTxFrozenAccount},
+	{"CreateAccountTx", testCreateAccountTx},
+	{"LedgerMismatches", testLedgerMismatches},
+}
+
+func testCreateAccount(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	account, err := store.CreateAccount(ctx, db.CreateAccountParams{
+		Owner:    user.Username,
+		Balance:  100,
+		Currency: utils.CurrencyUSD,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), account.Version)
+
+	got, err := store.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account, got)
+
+	_, err = store.CreateAccount(ctx, db.CreateAccountParams{
+		Owner:    user.Username,
+		Currency: utils.CurrencyUSD,
+	})
+	requireViolation(t, err, uniqueViolation, "owner_currency_key")
+
+	_, err = store.CreateAccount(ctx, db.CreateAccountParams{
+		Owner:    user.Username + "_missing",
+		Currency: utils.CurrencyUSD,
+	})
+	requireViolation(t, err, foreignKeyViolation, "accounts_owner_fkey")
+}
+
+func testGetAccountNotFound(t *testing.T, store db.Store) {
+	_, err := store.GetAccount(context.Background(), -1)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	_, err = store.GetAccountForUpdate(context.Background(), -1)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testListAccounts(t *testing.T, store db.Store) {
+	user := createUser(t, store)
+
+	var accounts []db.Account
+	for _, currency := range utils.SupportedCurrencies {
+		account, err := store.CreateAccount(context.Background(), db.CreateAccountParams{
+			Owner:    user.Username,
+			Currency: currency,
+		})
+		require.NoError(t, err)
+		accounts = append(accounts, account)
+	}
+	createAccount(t, store, createUser(t, store).Username, 0)
+
+	page, err := store.ListAccounts(context.Background(), db.ListAccountsParams{
+		Owner:  user.Username,
+		Limit:  2,
+		Offset: 1,
+	})
+	require.NoError(t, err)
+	require.Equal(t, accounts[1:3], page)
+
+	page, err = store.ListAccounts(context.Background(), db.ListAccountsParams{
+		Owner:  user.Username,
+		Limit:  2,
+		Offset: int32(len(accounts)),
+	})
+	require.NoError(t, err)
+	require.NotNil(t, page)
+	require.Empty(t, page)
+}
+
+func testAddAccountBalance(t *testing.T, store db.Store) {
+	account := createAccount(t, store, createUser(t, store).Username, 100)
+
+	updated, err := store.AddAccountBalance(context.Background(), db.AddAccountBalanceParams{
+		ID:     account.ID,
+		Amount: -30,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(70), updated.Balance)
+	require.Equal(t, account.Version+1, updated.Version)
+
+	_, err = store.AddAccountBalance(context.Background(), db.AddAccountBalanceParams{ID: -1, Amount: 1})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testFreezeAccount(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account := createAccount(t, store, createUser(t, store).Username, 100)
+	require.True(t, account.FrozenAt.IsZero())
+
+	frozen, err := store.FreezeAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.False(t, frozen.FrozenAt.IsZero())
+	require.Equal(t, account.Balance, frozen.Balance)
+	require.Equal(t, account.Version+1, frozen.Version)
+
+	got, err := store.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	require.Equal(t, frozen, got)
+
+	_, err = store.AddAccountBalance(ctx, db.AddAccountBalanceParams{ID: account.ID, Amount: 1})
+	require.ErrorIs(t, err, pgx.ErrNoRows, "frozen balances don't move")
+
+	_, err = store.FreezeAccount(ctx, -1)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testDeleteAccount(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	account := createAccount(t, store, createUser(t, store).Username, 0)
+
+	_, err := store.CreateEntry(ctx, db.CreateEntryParams{AccountID: -1, Amount: 10})
+	requireViolation(t, err, foreignKeyViolation, "entries_account_id_fkey")
+
+	entry, err := store.CreateEntry(ctx, db.CreateEntryParams{AccountID: account.ID, Amount: 10})
+	require.NoError(t, err)
+	got, err := store.GetEntry(ctx, entry.ID)
+	require.NoError(t, err)
+	require.Equal(t, entry, got)
+
+	err = store.DeleteAccount(ctx, account.ID)
+	requireViolation(t, err, foreignKeyViolation, "entries_account_id_fkey")
+
+	empty := createAccount(t, store, createUser(t, store).Username, 0)
+	require.NoError(t, store.DeleteAccount(ctx, empty.ID))
+	_, err = store.GetAccount(ctx, empty.ID)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// deleting a missing account is not an error
+	require.NoError(t, store.DeleteAccount(ctx, empty.ID))
+}
+
+func testTransferTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	from := createAccount(t, store, createUser(t, store).Username, 100)
+	to := createAccount(t, store, createUser(t, store).Username, 100)
+
+	result, err := store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        40,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, from.ID, result.Transfer.FromAccountID)
+	require.Equal(t, to.ID, result.Transfer.ToAccountID)
+	require.Equal(t, int64(40), result.Transfer.Amount)
+	require.Equal(t, from.ID, result.FromEntry.AccountID)
+	require.Equal(t, int64(-40), result.FromEntry.Amount)
+	require.Equal(t, to.ID, result.ToEntry.AccountID)
+	require.Equal(t, int64(40), result.ToEntry.Amount)
+	require.Equal(t, int64(60), result.FromAccount.Balance)
+	require.Equal(t, int64(140), result.ToAccount.Balance)
+	require.Equal(t, from.Version+1, result.FromAccount.Version)
+	require.Equal(t, to.Version+1, result.ToAccount.Version)
+
+	transfer, err := store.GetTransfer(ctx, result.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.Transfer, transfer)
+
+	events, err := store.ListAccountEvents(ctx, db.ListAccountEventsParams{
+		Owner:    from.Owner,
+		RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, db.EventAccountBalanceChanged, events[0].EventType)
+	require.Equal(t, strconv.FormatInt(from.ID, 10), events[0].AggregateID)
+
+	var payload db.BalanceChangedEvent
+	require.NoError(t, json.Unmarshal(events[0].Payload, &payload))
+	require.Equal(t, result.FromEntry.ID, payload.Entry.ID)
+	require.Equal(t, result.FromAccount.Balance, payload.Account.Balance)
+}
+
+func testTransferTxConcurrent(t *testing.T, store db.Store) {
+	account1 := createAccount(t, store, createUser(t, store).Username, 1000)
+	account2 := createAccount(t, store, createUser(t, store).Username, 1000)
+
+	n := 10
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		from, to := account1, account2
+		if i%2 == 1 {
+			from, to = account2, account1
+		}
+		go func() {
+			_, err := store.TransferTx(context.Background(), db.TransferTxParams{
+				FromAccountID: from.ID,
+				ToAccountID:   to.ID,
+				Amount:        10,
+			})
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	for _, account := range []db.Account{account1, account2} {
+		updated, err := store.GetAccount(context.Background(), account.ID)
+		require.NoError(t, err)
+		require.Equal(t, account.Balance, updated.Balance)
+	}
+}
+
+func testTransferTxMissingAccount(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	from := createAccount(t, store, createUser(t, store).Username, 100)
+
+	_, err := store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: from.ID,
+		ToAccountID:   -1,
+		Amount:        10,
+	})
+	requireViolation(t, err, foreignKeyViolation, "transfers_to_account_id_fkey")
+
+	// nothing of the transfer is left behind
+	account, err := store.GetAccount(ctx, from.ID)
+	require.NoError(t, err)
+	require.Equal(t, from.Balance, account.Balance)
+
+	events, err := store.ListAccountEvents(ctx, db.ListAccountEventsParams{Owner: from.Owner, RowLimit: 10})
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func testTransferTxFrozenAccount(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	from := createAccount(t, store, createUser(t, store).Username, 100)
+	to := createAccount(t, store, createUser(t, store).Username, 100)
+
+	// frozen after the API checked the accounts
+	_, err := store.FreezeAccount(ctx, to.ID)
+	require.NoError(t, err)
+
+	_, err = store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        10,
+	})
+	var frozen *db.AccountFrozenError
+	require.ErrorAs(t, err, &frozen)
+	require.Equal(t, to.ID, frozen.AccountID)
+
+	// nothing of the transfer is left behind
+	account, err := store.GetAccount(ctx, from.ID)
+	require.NoError(t, err)
+	require.Equal(t, from.Balance, account.Balance)
+
+	events, err := store.ListAccountEvents(ctx, db.ListAccountEventsParams{Owner: from.Owner, RowLimit: 10})
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func testCreateAccountTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	account, err := store.CreateAccountTx(ctx, db.CreateAccountParams{
+		Owner:    user.Username,
+		Currency: utils.CurrencyEUR,
+	})
+	require.NoError(t, err)
+
+	events, err := store.ListAccountEvents(ctx, db.ListAccountEventsParams{Owner: user.Username, RowLimit: 10})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, db.EventAccountCreated, events[0].EventType)
+
+	var payload db.Account
+	require.NoError(t, json.Unmarshal(events[0].Payload, &payload))
+	require.Equal(t, account.ID, payload.ID)
+
+	_, err = store.CreateAccountTx(ctx, db.CreateAccountParams{
+		Owner:    user.Username,
+		Currency: utils.CurrencyEUR,
+	})
+	requireViolation(t, err, uniqueViolation, "owner_currency_key")
+
+	events, err = store.ListAccountEvents(ctx, db.ListAccountEventsParams{Owner: user.Username, RowLimit: 10})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	latestID, err := store.GetLatestAccountEventID(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, events[0].ID, latestID)
+
+	latestID, err = store.GetLatestAccountEventID(ctx, createUser(t, store).Username)
+	require.NoError(t, err)
+	require.Zero(t, latestID)
+}
+
+func testLedgerMismatches(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	from := createAccount(t, store, createUser(t, store).Username, 100)
+	to := createAccount(t, store, createUser(t, store).Username, 100)
+
+	_, err := store.TransferTx(ctx, db.TransferTxParams{FromAccountID: from.ID, ToAccountID: to.ID, Amount: 10})
+	require.NoError(t, err)
+
+	// other tests leave mismatches behind, only ours matter
+	mismatches := func() []db.ListLedgerMismatchesRow {
+		rows, err := store.ListLedgerMismatches(ctx)
+		require.NoError(t, err)
+		var ours []db.ListLedgerMismatchesRow
+		for _, row := range rows {
+			if row.AccountID == from.ID || row.AccountID == to.ID {
+				ours = append(ours, row)
+			}
+		}
+		return ours
+	}
+	require.Empty(t, mismatches())
+
+	_, err = store.CreateEntry(ctx, db.CreateEntryParams{AccountID: to.ID, Amount: 5})
+	require.NoError(t, err)
+	require.Equal(t, []db.ListLedgerMismatchesRow{
+		{AccountID: to.ID, EntriesTotal: 15, TransfersTotal: 10},
+	}, mismatches())
+}
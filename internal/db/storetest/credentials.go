@@ -0,0 +1,303 @@
+package storetest
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/db"
+	"simple-bank/internal/random"
+	"testing"
+	"time"
+)
+
+var credentialTests = []testCase{
+	{"VerifyEmailTx", testVerifyEmailTx},
+	{"ResetPasswordTx", testResetPasswordTx},
+	{"ConfirmTOTPTx", testConfirmTOTPTx},
+	{"UseTOTPStep", testUseTOTPStep},
+	{"APIKeys", testAPIKeys},
+	{"OAuth", testOAuth},
+	{"RateLimitBucket", testRateLimitBucket},
+}
+
+func createUserToken(t *testing.T, store db.Store, username, purpose string, expiresAt time.Time) db.UserToken {
+	t.Helper()
+
+	token, err := store.CreateUserToken(context.Background(), db.CreateUserTokenParams{
+		Username:    username,
+		Purpose:     purpose,
+		HashedToken: random.String(32),
+		ExpiresAt:   expiresAt,
+	})
+	require.NoError(t, err)
+	return token
+}
+
+func testVerifyEmailTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+	token := createUserToken(t, store, user.Username, db.UserTokenPurposeEmailVerification, time.Now().Add(time.Hour))
+
+	_, err := store.CreateUserToken(ctx, db.CreateUserTokenParams{
+		Username:    user.Username,
+		Purpose:     db.UserTokenPurposeEmailVerification,
+		HashedToken: token.HashedToken,
+		ExpiresAt:   token.ExpiresAt,
+	})
+	requireViolation(t, err, uniqueViolation, "user_tokens_hashed_token_key")
+
+	got, err := store.GetUserToken(ctx, db.GetUserTokenParams{
+		HashedToken: token.HashedToken,
+		Purpose:     db.UserTokenPurposeEmailVerification,
+	})
+	require.NoError(t, err)
+	require.Equal(t, token, got)
+
+	_, err = store.GetUserToken(ctx, db.GetUserTokenParams{
+		HashedToken: token.HashedToken,
+		Purpose:     db.UserTokenPurposePasswordReset,
+	})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	verified, err := store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{TokenID: token.ID, Username: user.Username})
+	require.NoError(t, err)
+	require.False(t, verified.EmailVerifiedAt.IsZero())
+
+	_, err = store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{TokenID: token.ID, Username: user.Username})
+	require.ErrorIs(t, err, db.ErrUserTokenUsed)
+
+	expired := createUserToken(t, store, user.Username, db.UserTokenPurposeEmailVerification, time.Now().Add(-time.Second))
+	_, err = store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{TokenID: expired.ID, Username: user.Username})
+	require.ErrorIs(t, err, db.ErrUserTokenUsed)
+}
+
+func testResetPasswordTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+	_, err := store.RegisterFailedLogin(ctx, user.Username)
+	require.NoError(t, err)
+
+	token := createUserToken(t, store, user.Username, db.UserTokenPurposePasswordReset, time.Now().Add(time.Hour))
+	other := createUserToken(t, store, user.Username, db.UserTokenPurposePasswordReset, time.Now().Add(time.Hour))
+
+	updated, err := store.ResetPasswordTx(ctx, db.ResetPasswordTxParams{
+		TokenID:        token.ID,
+		Username:       user.Username,
+		HashedPassword: "new-hash",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "new-hash", updated.HashedPassword)
+	require.Zero(t, updated.FailedLoginAttempts)
+	require.WithinDuration(t, time.Now(), updated.PasswordChangedAt, time.Minute)
+
+	// the other reset tokens of the user are revoked
+	used, err := store.UseUserToken(ctx, other.ID)
+	require.NoError(t, err)
+	require.Zero(t, used)
+}
+
+func testConfirmTOTPTx(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	_, err := store.UpsertUserTOTP(ctx, db.UpsertUserTOTPParams{Username: user.Username + "_missing", Secret: "secret"})
+	requireViolation(t, err, foreignKeyViolation, "user_totp_username_fkey")
+
+	_, err = store.ConfirmTOTPTx(ctx, db.ConfirmTOTPTxParams{Username: user.Username, Step: 1})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	totp, err := store.UpsertUserTOTP(ctx, db.UpsertUserTOTPParams{Username: user.Username, Secret: "secret"})
+	require.NoError(t, err)
+	require.True(t, totp.ConfirmedAt.IsZero())
+
+	old, err := store.CreateRecoveryCode(ctx, db.CreateRecoveryCodeParams{Username: user.Username, HashedCode: "old"})
+	require.NoError(t, err)
+
+	result, err := store.ConfirmTOTPTx(ctx, db.ConfirmTOTPTxParams{
+		Username: user.Username,
+		Step:     42,
+		RecoveryCodes: []db.RecoveryCodeHash{
+			{HashedCode: "a", Lookup: "lookup-a"},
+			{HashedCode: "b", Lookup: "lookup-b"},
+		},
+	})
+	require.NoError(t, err)
+	require.False(t, result.TOTP.ConfirmedAt.IsZero())
+	require.Equal(t, int64(42), result.TOTP.LastUsedStep)
+	require.Len(t, result.RecoveryCodes, 2)
+
+	codes, err := store.ListUnusedRecoveryCodes(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, result.RecoveryCodes, codes)
+
+	code, err := store.GetUnusedRecoveryCode(ctx, db.GetUnusedRecoveryCodeParams{Username: user.Username, Lookup: "lookup-a"})
+	require.NoError(t, err)
+	require.Equal(t, codes[0], code)
+	_, err = store.GetUnusedRecoveryCode(ctx, db.GetUnusedRecoveryCodeParams{Username: user.Username + "_other", Lookup: "lookup-a"})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	used, err := store.UseRecoveryCode(ctx, old.ID)
+	require.NoError(t, err)
+	require.Zero(t, used)
+
+	used, err = store.UseRecoveryCode(ctx, codes[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), used)
+	used, err = store.UseRecoveryCode(ctx, codes[0].ID)
+	require.NoError(t, err)
+	require.Zero(t, used)
+	_, err = store.GetUnusedRecoveryCode(ctx, db.GetUnusedRecoveryCodeParams{Username: user.Username, Lookup: "lookup-a"})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// enrolling again starts over
+	totp, err = store.UpsertUserTOTP(ctx, db.UpsertUserTOTPParams{Username: user.Username, Secret: "other"})
+	require.NoError(t, err)
+	require.Equal(t, "other", totp.Secret)
+	require.Zero(t, totp.LastUsedStep)
+	require.True(t, totp.ConfirmedAt.IsZero())
+}
+
+func testUseTOTPStep(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+	_, err := store.UpsertUserTOTP(ctx, db.UpsertUserTOTPParams{Username: user.Username, Secret: "secret"})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		step int64
+		used int64
+	}{{10, 1}, {10, 0}, {9, 0}, {11, 1}} {
+		used, err := store.UseTOTPStep(ctx, db.UseTOTPStepParams{Username: user.Username, Step: tc.step})
+		require.NoError(t, err)
+		require.Equal(t, tc.used, used, "step %d", tc.step)
+	}
+
+	totp, err := store.GetUserTOTP(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, int64(11), totp.LastUsedStep)
+}
+
+func testAPIKeys(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	arg := db.CreateAPIKeyParams{
+		ID:           uuid.New(),
+		Username:     user.Username,
+		Name:         "ci",
+		Prefix:       random.String(12),
+		HashedSecret: random.String(32),
+		Scopes:       []string{"accounts:read"},
+	}
+	key, err := store.CreateAPIKey(ctx, arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Scopes, key.Scopes)
+	require.True(t, key.RevokedAt.IsZero())
+
+	duplicate := arg
+	duplicate.ID = uuid.New()
+	_, err = store.CreateAPIKey(ctx, duplicate)
+	requireViolation(t, err, uniqueViolation, "api_keys_prefix_key")
+
+	got, err := store.GetAPIKeyByPrefix(ctx, key.Prefix)
+	require.NoError(t, err)
+	require.Equal(t, key, got)
+
+	require.NoError(t, store.TouchAPIKey(ctx, key.ID))
+	got, err = store.GetAPIKeyByPrefix(ctx, key.Prefix)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), got.LastUsedAt, time.Minute)
+
+	keys, err := store.ListAPIKeys(ctx, user.Username)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+
+	revoked, err := store.RevokeAPIKey(ctx, db.RevokeAPIKeyParams{ID: key.ID, Username: createUser(t, store).Username})
+	require.NoError(t, err)
+	require.Zero(t, revoked)
+
+	revoked, err = store.RevokeAPIKey(ctx, db.RevokeAPIKeyParams{ID: key.ID, Username: user.Username})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), revoked)
+
+	keys, err = store.ListAPIKeys(ctx, user.Username)
+	require.NoError(t, err)
+	require.Empty(t, keys)
+}
+
+func testOAuth(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	client, err := store.CreateOAuthClient(ctx, db.CreateOAuthClientParams{
+		ID:           random.String(16),
+		Owner:        user.Username,
+		Name:         "app",
+		HashedSecret: random.String(32),
+		RedirectUris: []string{"https://example.com/callback"},
+		Scopes:       []string{"accounts:read"},
+	})
+	require.NoError(t, err)
+
+	got, err := store.GetOAuthClient(ctx, client.ID)
+	require.NoError(t, err)
+	require.Equal(t, client, got)
+
+	clients, err := store.ListOAuthClients(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, []db.OauthClient{client}, clients)
+
+	arg := db.CreateOAuthAuthorizationCodeParams{
+		HashedCode:  random.String(32),
+		ClientID:    client.ID + "_missing",
+		Username:    user.Username,
+		RedirectUri: client.RedirectUris[0],
+		Scopes:      client.Scopes,
+		ExpiresAt:   time.Now().Add(time.Minute),
+	}
+	_, err = store.CreateOAuthAuthorizationCode(ctx, arg)
+	requireViolation(t, err, foreignKeyViolation, "oauth_authorization_codes_client_id_fkey")
+
+	arg.ClientID = client.ID
+	code, err := store.CreateOAuthAuthorizationCode(ctx, arg)
+	require.NoError(t, err)
+
+	gotCode, err := store.GetOAuthAuthorizationCode(ctx, code.HashedCode)
+	require.NoError(t, err)
+	require.Equal(t, code, gotCode)
+
+	used, err := store.UseOAuthAuthorizationCode(ctx, code.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), used)
+	used, err = store.UseOAuthAuthorizationCode(ctx, code.ID)
+	require.NoError(t, err)
+	require.Zero(t, used)
+}
+
+func testRateLimitBucket(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	key := "storetest:" + random.String(16)
+
+	tokens, err := store.RefillRateLimitBucket(ctx, db.RefillRateLimitBucketParams{Key: key, Burst: 2, Rate: 0})
+	require.NoError(t, err)
+	require.Equal(t, float64(2), tokens)
+
+	for _, left := range []float64{1, 0} {
+		tokens, err = store.TakeRateLimitToken(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, left, tokens)
+	}
+	_, err = store.TakeRateLimitToken(ctx, key)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// the rate refills up to the burst
+	time.Sleep(time.Millisecond)
+	tokens, err = store.RefillRateLimitBucket(ctx, db.RefillRateLimitBucketParams{Key: key, Burst: 2, Rate: 1e6})
+	require.NoError(t, err)
+	require.Equal(t, float64(2), tokens)
+
+	require.NoError(t, store.DeleteStaleRateLimitBuckets(ctx, time.Now().Add(time.Minute)))
+	_, err = store.TakeRateLimitToken(ctx, key)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
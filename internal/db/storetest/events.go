@@ -0,0 +1,259 @@
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/db"
+	"simple-bank/internal/random"
+	"testing"
+	"time"
+)
+
+var eventTests = []testCase{
+	{"AuditEvents", testAuditEvents},
+	{"ClaimOutboxEvents", testClaimOutboxEvents},
+	{"WebhookSubscriptions", testWebhookSubscriptions},
+	{"WebhookDeliveries", testWebhookDeliveries},
+}
+
+func testAuditEvents(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	actor := random.Username()
+
+	var events []db.AuditEvent
+	for _, action := range []string{"login", "transfer", "login"} {
+		event, err := store.CreateAuditEvent(ctx, db.CreateAuditEventParams{
+			Actor:   actor,
+			Action:  action,
+			Outcome: "success",
+			Diff:    json.RawMessage(`{"amount": 10}`),
+		})
+		require.NoError(t, err)
+		require.JSONEq(t, `{"amount": 10}`, string(event.Diff))
+		events = append(events, event)
+	}
+
+	listed, err := store.ListAuditEvents(ctx, db.ListAuditEventsParams{
+		Actor:    pgtype.Text{String: actor, Valid: true},
+		Action:   pgtype.Text{String: "login", Valid: true},
+		RowLimit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+	require.Equal(t, events[2].ID, listed[0].ID)
+	require.Equal(t, events[0].ID, listed[1].ID)
+
+	listed, err = store.ListAuditEvents(ctx, db.ListAuditEventsParams{
+		Actor:    pgtype.Text{String: actor, Valid: true},
+		BeforeID: pgtype.Int8{Int64: events[2].ID, Valid: true},
+		RowLimit: 1,
+	})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	require.Equal(t, events[1].ID, listed[0].ID)
+
+	_, err = store.CreateAuditEvent(ctx, db.CreateAuditEventParams{Actor: actor, Diff: json.RawMessage(`{`)})
+	require.Error(t, err)
+}
+
+// claimed claims every due event and returns those of the aggregate.
+func claimed(t *testing.T, store db.Store, aggregateID string) []db.Outbox {
+	t.Helper()
+
+	events, err := store.ClaimOutboxEvents(context.Background(), db.ClaimOutboxEventsParams{
+		LeaseUntil: time.Now().Add(time.Minute),
+		RowLimit:   10000,
+	})
+	require.NoError(t, err)
+
+	var ofAggregate []db.Outbox
+	for _, event := range events {
+		if event.AggregateID == aggregateID {
+			ofAggregate = append(ofAggregate, event)
+		}
+	}
+	return ofAggregate
+}
+
+func testClaimOutboxEvents(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	aggregateID := "storetest-" + random.String(12)
+
+	var events []db.Outbox
+	for i := 0; i < 2; i++ {
+		event, err := store.CreateOutboxEvent(ctx, db.CreateOutboxEventParams{
+			AggregateType: db.OutboxAggregateAccount,
+			AggregateID:   aggregateID,
+			EventType:     db.EventAccountBalanceChanged,
+			Payload:       json.RawMessage(`{"n": 1}`),
+		})
+		require.NoError(t, err)
+		require.Zero(t, event.Attempts)
+		events = append(events, event)
+	}
+
+	// the second event waits for the first one to be published
+	first := claimed(t, store, aggregateID)
+	require.Len(t, first, 1)
+	require.Equal(t, events[0].ID, first[0].ID)
+	require.Equal(t, int32(1), first[0].Attempts)
+	require.JSONEq(t, `{"n": 1}`, string(first[0].Payload))
+
+	// a leased event is not claimed again
+	require.Empty(t, claimed(t, store, aggregateID))
+
+	require.NoError(t, store.MarkOutboxEventPublished(ctx, events[0].ID))
+	second := claimed(t, store, aggregateID)
+	require.Len(t, second, 1)
+	require.Equal(t, events[1].ID, second[0].ID)
+
+	require.NoError(t, store.MarkOutboxEventFailed(ctx, db.MarkOutboxEventFailedParams{
+		ID:            events[1].ID,
+		LastError:     "unreachable",
+		NextAttemptAt: time.Now().Add(-time.Second),
+	}))
+	retried := claimed(t, store, aggregateID)
+	require.Len(t, retried, 1)
+	require.Equal(t, int32(2), retried[0].Attempts)
+	require.Equal(t, "unreachable", retried[0].LastError)
+}
+
+func createWebhookSubscription(t *testing.T, store db.Store, owner string, eventTypes ...string) db.WebhookSubscription {
+	t.Helper()
+
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	subscription, err := store.CreateWebhookSubscription(context.Background(), db.CreateWebhookSubscriptionParams{
+		Owner:      owner,
+		Url:        "https://example.com/" + random.String(8),
+		Secret:     random.String(32),
+		EventTypes: eventTypes,
+	})
+	require.NoError(t, err)
+	require.Equal(t, eventTypes, subscription.EventTypes)
+	return subscription
+}
+
+func testWebhookSubscriptions(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	_, err := store.CreateWebhookSubscription(ctx, db.CreateWebhookSubscriptionParams{
+		Owner:      user.Username + "_missing",
+		EventTypes: []string{},
+	})
+	requireViolation(t, err, foreignKeyViolation, "webhook_subscriptions_owner_fkey")
+
+	all := createWebhookSubscription(t, store, user.Username)
+	transfers := createWebhookSubscription(t, store, user.Username, db.EventTransferCompleted)
+
+	subscriptions, err := store.ListWebhookSubscriptionsForEvent(ctx, db.ListWebhookSubscriptionsForEventParams{
+		Owners:    []string{user.Username},
+		EventType: db.EventAccountCreated,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []db.WebhookSubscription{all}, subscriptions)
+
+	for i := 0; i < 2; i++ {
+		transfers, err = store.RecordWebhookSubscriptionFailure(ctx, db.RecordWebhookSubscriptionFailureParams{
+			ID:           transfers.ID,
+			DisableAfter: 2,
+		})
+		require.NoError(t, err)
+	}
+	require.Equal(t, int32(2), transfers.ConsecutiveFailures)
+	require.False(t, transfers.DisabledAt.IsZero())
+
+	subscriptions, err = store.ListWebhookSubscriptionsForEvent(ctx, db.ListWebhookSubscriptionsForEventParams{
+		Owners:    []string{user.Username},
+		EventType: db.EventTransferCompleted,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []db.WebhookSubscription{all}, subscriptions)
+
+	updated, err := store.UpdateWebhookSubscription(ctx, db.UpdateWebhookSubscriptionParams{
+		ID:         transfers.ID,
+		Url:        transfers.Url,
+		EventTypes: transfers.EventTypes,
+	})
+	require.NoError(t, err)
+	require.Zero(t, updated.ConsecutiveFailures)
+	require.True(t, updated.DisabledAt.IsZero())
+
+	listed, err := store.ListWebhookSubscriptions(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, []db.WebhookSubscription{all, updated}, listed)
+
+	_, err = store.UpdateWebhookSubscription(ctx, db.UpdateWebhookSubscriptionParams{ID: -1, EventTypes: []string{}})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testWebhookDeliveries(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+	subscription := createWebhookSubscription(t, store, user.Username)
+
+	arg := db.CreateWebhookDeliveryParams{
+		SubscriptionID: subscription.ID,
+		EventID:        random.Int64(1, 1<<40),
+		EventType:      db.EventAccountCreated,
+		Payload:        json.RawMessage(`{"id": 1}`),
+	}
+	require.NoError(t, store.CreateWebhookDelivery(ctx, arg))
+	// the same event is delivered once
+	require.NoError(t, store.CreateWebhookDelivery(ctx, arg))
+
+	deliveries, err := store.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{
+		SubscriptionID: subscription.ID,
+		RowLimit:       10,
+	})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	delivery := deliveries[0]
+	require.Equal(t, db.WebhookDeliveryPending, delivery.Status)
+
+	require.NoError(t, store.MarkWebhookDeliverySucceeded(ctx, db.MarkWebhookDeliverySucceededParams{
+		ID:             delivery.ID,
+		ResponseStatus: 204,
+	}))
+	replay, err := store.ReplayWebhookDelivery(ctx, delivery.ID)
+	require.NoError(t, err)
+	require.Equal(t, delivery.ID, replay.ReplayOf)
+	require.Equal(t, db.WebhookDeliveryPending, replay.Status)
+	require.JSONEq(t, `{"id": 1}`, string(replay.Payload))
+
+	succeeded, err := store.ListWebhookDeliveries(ctx, db.ListWebhookDeliveriesParams{
+		SubscriptionID: subscription.ID,
+		Status:         pgtype.Text{String: db.WebhookDeliverySucceeded, Valid: true},
+		RowLimit:       10,
+	})
+	require.NoError(t, err)
+	require.Len(t, succeeded, 1)
+	require.Equal(t, int32(204), succeeded[0].ResponseStatus)
+	require.False(t, succeeded[0].DeliveredAt.IsZero())
+
+	deleted, err := store.DeleteWebhookSubscription(ctx, db.DeleteWebhookSubscriptionParams{
+		ID:    subscription.ID,
+		Owner: createUser(t, store).Username,
+	})
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+
+	deleted, err = store.DeleteWebhookSubscription(ctx, db.DeleteWebhookSubscriptionParams{
+		ID:    subscription.ID,
+		Owner: user.Username,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+
+	// the deliveries go with the subscription
+	_, err = store.GetWebhookDelivery(ctx, replay.ID)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	err = store.CreateWebhookDelivery(ctx, arg)
+	requireViolation(t, err, foreignKeyViolation, "webhook_deliveries_subscription_id_fkey")
+}
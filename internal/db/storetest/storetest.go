@@ -0,0 +1,83 @@
+// Package storetest is the conformance suite of db.Store. Every
+// implementation runs it, so that one can stand in for another in tests.
+//
+// The suite runs against stores that may be shared with other tests, it
+// only looks at the rows it created.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/db"
+	"simple-bank/internal/random"
+	"testing"
+)
+
+type testCase struct {
+	name string
+	test func(t *testing.T, store db.Store)
+}
+
+// Run runs the suite, newStore is called once per test.
+func Run(t *testing.T, newStore func(t *testing.T) db.Store) {
+	var testCases []testCase
+	testCases = append(testCases, accountTests...)
+	testCases = append(testCases, userTests...)
+	testCases = append(testCases, credentialTests...)
+	testCases = append(testCases, eventTests...)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newStore(t))
+		})
+	}
+}
+
+// requireViolation checks err is the violation of constraint, code is the
+// SQLSTATE.
+func requireViolation(t *testing.T, err error, code string, constraint string) {
+	t.Helper()
+
+	var pgErr *pgconn.PgError
+	require.True(t, errors.As(err, &pgErr), "%v is not a *pgconn.PgError", err)
+	require.Equal(t, code, pgErr.Code)
+	require.Equal(t, constraint, pgErr.ConstraintName)
+}
+
+const (
+	uniqueViolation     = pgerrcode.UniqueViolation
+	foreignKeyViolation = pgerrcode.ForeignKeyViolation
+)
+
+func createUser(t *testing.T, store db.Store) db.User {
+	t.Helper()
+
+	arg := db.CreateUserParams{
+		Username:       random.Username(),
+		HashedPassword: random.String(32),
+		FullName:       random.Username(),
+		Email:          random.UserEmail(),
+	}
+	user, err := store.CreateUser(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Username, user.Username)
+	require.Equal(t, arg.Email, user.Email)
+	return user
+}
+
+func createAccount(t *testing.T, store db.Store, owner string, balance int64) db.Account {
+	t.Helper()
+
+	account, err := store.CreateAccount(context.Background(), db.CreateAccountParams{
+		Owner:    owner,
+		Balance:  balance,
+		Currency: random.AccountCurrency(),
+	})
+	require.NoError(t, err)
+	require.NotZero(t, account.ID)
+	require.NotZero(t, account.CreatedAt)
+	return account
+}
@@ -0,0 +1,206 @@
+package storetest
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/db"
+	"simple-bank/internal/random"
+	"testing"
+	"time"
+)
+
+var userTests = []testCase{
+	{"CreateUser", testCreateUser},
+	{"FailedLogins", testFailedLogins},
+	{"UpdateUser", testUpdateUser},
+	{"UpdateUser_ExpectedVersion", testUpdateUserExpectedVersion},
+	{"UpdateUserRole", testUpdateUserRole},
+	{"UpgradeUserPasswordHash", testUpgradeUserPasswordHash},
+	{"LoginAttempts", testLoginAttempts},
+}
+
+func testCreateUser(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+	require.Equal(t, db.UserRoleCustomer, user.Role)
+	require.Equal(t, int64(1), user.Version)
+	require.True(t, user.PasswordChangedAt.IsZero())
+	require.True(t, user.EmailVerifiedAt.IsZero())
+	require.NotZero(t, user.CreatedAt)
+
+	got, err := store.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, user, got)
+
+	got, err = store.GetUserByEmail(ctx, user.Email)
+	require.NoError(t, err)
+	require.Equal(t, user, got)
+
+	_, err = store.GetUser(ctx, random.Username()+"_missing")
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	_, err = store.CreateUser(ctx, db.CreateUserParams{
+		Username: user.Username,
+		Email:    random.UserEmail(),
+	})
+	requireViolation(t, err, uniqueViolation, "users_pkey")
+
+	_, err = store.CreateUser(ctx, db.CreateUserParams{
+		Username: random.Username(),
+		Email:    user.Email,
+	})
+	requireViolation(t, err, uniqueViolation, "users_email_key")
+}
+
+func testFailedLogins(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	for i := 1; i <= 2; i++ {
+		updated, err := store.RegisterFailedLogin(ctx, user.Username)
+		require.NoError(t, err)
+		require.Equal(t, int32(i), updated.FailedLoginAttempts)
+		require.Equal(t, user.Version, updated.Version, "the lockout state leaves the version alone")
+	}
+
+	lockedUntil := time.Now().Add(time.Minute)
+	require.NoError(t, store.LockUser(ctx, db.LockUserParams{Username: user.Username, LockedUntil: lockedUntil}))
+	locked, err := store.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.WithinDuration(t, lockedUntil, locked.LockedUntil, time.Millisecond)
+	require.Equal(t, user.Version, locked.Version)
+
+	require.NoError(t, store.ResetFailedLogins(ctx, user.Username))
+	reset, err := store.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Zero(t, reset.FailedLoginAttempts)
+	require.True(t, reset.LockedUntil.IsZero())
+	require.Equal(t, user.Version, reset.Version)
+
+	_, err = store.RegisterFailedLogin(ctx, user.Username+"_missing")
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testUpdateUser(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+	other := createUser(t, store)
+
+	verified, err := store.VerifyUserEmail(ctx, user.Username)
+	require.NoError(t, err)
+	require.False(t, verified.EmailVerifiedAt.IsZero())
+
+	// the same email keeps the verification
+	updated, err := store.UpdateUser(ctx, db.UpdateUserParams{
+		Username: user.Username,
+		FullName: pgtype.Text{String: "New Name", Valid: true},
+		Email:    pgtype.Text{String: user.Email, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "New Name", updated.FullName)
+	require.Equal(t, verified.EmailVerifiedAt, updated.EmailVerifiedAt)
+
+	_, err = store.UpdateUser(ctx, db.UpdateUserParams{
+		Username: user.Username,
+		Email:    pgtype.Text{String: other.Email, Valid: true},
+	})
+	requireViolation(t, err, uniqueViolation, "users_email_key")
+
+	email := random.UserEmail()
+	updated, err = store.UpdateUser(ctx, db.UpdateUserParams{
+		Username: user.Username,
+		Email:    pgtype.Text{String: email, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, email, updated.Email)
+	require.Equal(t, "New Name", updated.FullName)
+	require.True(t, updated.EmailVerifiedAt.IsZero())
+
+	_, err = store.UpdateUser(ctx, db.UpdateUserParams{Username: user.Username + "_missing"})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testUpdateUserExpectedVersion(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	updated, err := store.UpdateUser(ctx, db.UpdateUserParams{
+		Username:        user.Username,
+		FullName:        pgtype.Text{String: "First Writer", Valid: true},
+		ExpectedVersion: pgtype.Int8{Int64: user.Version, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, user.Version+1, updated.Version)
+
+	// a write based on the version the first one replaced is lost otherwise
+	_, err = store.UpdateUser(ctx, db.UpdateUserParams{
+		Username:        user.Username,
+		FullName:        pgtype.Text{String: "Second Writer", Valid: true},
+		ExpectedVersion: pgtype.Int8{Int64: user.Version, Valid: true},
+	})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	got, err := store.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, updated, got)
+}
+
+func testUpdateUserRole(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+	require.Equal(t, db.UserRoleCustomer, user.Role)
+
+	updated, err := store.UpdateUserRole(ctx, db.UpdateUserRoleParams{Username: user.Username, Role: db.UserRoleAdmin})
+	require.NoError(t, err)
+	require.Equal(t, db.UserRoleAdmin, updated.Role)
+
+	_, err = store.UpdateUserRole(ctx, db.UpdateUserRoleParams{Username: user.Username + "_missing", Role: db.UserRoleAdmin})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func testUpgradeUserPasswordHash(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	user := createUser(t, store)
+
+	require.NoError(t, store.UpgradeUserPasswordHash(ctx, db.UpgradeUserPasswordHashParams{
+		Username:          user.Username,
+		OldHashedPassword: "stale",
+		NewHashedPassword: "ignored",
+	}))
+	got, err := store.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, user.HashedPassword, got.HashedPassword)
+	require.Equal(t, user.Version, got.Version)
+
+	require.NoError(t, store.UpgradeUserPasswordHash(ctx, db.UpgradeUserPasswordHashParams{
+		Username:          user.Username,
+		OldHashedPassword: user.HashedPassword,
+		NewHashedPassword: "upgraded",
+	}))
+	got, err = store.GetUser(ctx, user.Username)
+	require.NoError(t, err)
+	require.Equal(t, "upgraded", got.HashedPassword)
+	require.Equal(t, user.Version, got.Version, "the hash is never shown")
+}
+
+func testLoginAttempts(t *testing.T, store db.Store) {
+	ctx := context.Background()
+	username := random.Username()
+
+	var attempts []db.LoginAttempt
+	for i := 0; i < 3; i++ {
+		attempt, err := store.CreateLoginAttempt(ctx, db.CreateLoginAttemptParams{
+			Username:  username,
+			ClientIp:  "127.0.0.1",
+			Succeeded: i == 2,
+		})
+		require.NoError(t, err)
+		attempts = append(attempts, attempt)
+	}
+
+	latest, err := store.ListLoginAttempts(ctx, db.ListLoginAttemptsParams{Username: username, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, []db.LoginAttempt{attempts[2], attempts[1]}, latest)
+}
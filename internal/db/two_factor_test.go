@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStore_ConfirmTOTPTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	enrolled, err := testQueries.UpsertUserTOTP(context.Background(), UpsertUserTOTPParams{
+		Username: user.Username,
+		Secret:   "JBSWY3DPEHPK3PXP",
+	})
+	require.NoError(t, err)
+	require.True(t, enrolled.ConfirmedAt.IsZero())
+
+	result, err := store.ConfirmTOTPTx(context.Background(), ConfirmTOTPTxParams{
+		Username: user.Username,
+		Step:     100,
+		RecoveryCodes: []RecoveryCodeHash{
+			{HashedCode: "hash-1", Lookup: "lookup-1"},
+			{HashedCode: "hash-2", Lookup: "lookup-2"},
+		},
+	})
+	require.NoError(t, err)
+	require.False(t, result.TOTP.ConfirmedAt.IsZero())
+	require.Equal(t, int64(100), result.TOTP.LastUsedStep)
+	require.Len(t, result.RecoveryCodes, 2)
+
+	// a step can only be used once and never go backwards
+	updated, err := testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{Step: 100, Username: user.Username})
+	require.NoError(t, err)
+	require.Zero(t, updated)
+	updated, err = testQueries.UseTOTPStep(context.Background(), UseTOTPStepParams{Step: 101, Username: user.Username})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), updated)
+
+	used, err := testQueries.UseRecoveryCode(context.Background(), result.RecoveryCodes[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), used)
+	used, err = testQueries.UseRecoveryCode(context.Background(), result.RecoveryCodes[0].ID)
+	require.NoError(t, err)
+	require.Zero(t, used)
+
+	unused, err := testQueries.ListUnusedRecoveryCodes(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Len(t, unused, 1)
+	require.Equal(t, result.RecoveryCodes[1].ID, unused[0].ID)
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+)
+
+// RecoveryCodeHash is a recovery code as it is stored: the slow hash that
+// verifies it and the keyed lookup that finds it.
+type RecoveryCodeHash struct {
+	HashedCode string `json:"hashed_code"`
+	Lookup     string `json:"lookup"`
+}
+
+type ConfirmTOTPTxParams struct {
+	Username      string             `json:"username"`
+	Step          int64              `json:"step"`
+	RecoveryCodes []RecoveryCodeHash `json:"recovery_codes"`
+}
+
+type ConfirmTOTPTxResult struct {
+	TOTP          UserTotp       `json:"totp"`
+	RecoveryCodes []RecoveryCode `json:"recovery_codes"`
+}
+
+// ConfirmTOTPTx enables two-factor authentication and replaces the recovery
+// codes of the user in a single transaction.
+func (s *SQLStore) ConfirmTOTPTx(ctx context.Context, args ConfirmTOTPTxParams) (ConfirmTOTPTxResult, error) {
+	var result ConfirmTOTPTxResult
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.TOTP, err = q.ConfirmUserTOTP(ctx, ConfirmUserTOTPParams{
+			LastUsedStep: args.Step,
+			Username:     args.Username,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = q.DeleteRecoveryCodes(ctx, args.Username); err != nil {
+			return err
+		}
+
+		result.RecoveryCodes = make([]RecoveryCode, 0, len(args.RecoveryCodes))
+		for _, recoveryCode := range args.RecoveryCodes {
+			code, err := q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+				Username:   args.Username,
+				HashedCode: recoveryCode.HashedCode,
+				Lookup:     recoveryCode.Lookup,
+			})
+			if err != nil {
+				return err
+			}
+			result.RecoveryCodes = append(result.RecoveryCodes, code)
+		}
+
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/random"
+	"testing"
+	"time"
+)
+
+func createRandomUserToken(t *testing.T, username, purpose string, expiresAt time.Time) UserToken {
+	arg := CreateUserTokenParams{
+		Username:    username,
+		Purpose:     purpose,
+		HashedToken: random.String(64),
+		ExpiresAt:   expiresAt,
+	}
+
+	userToken, err := testQueries.CreateUserToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, userToken.ID)
+	require.Equal(t, arg.HashedToken, userToken.HashedToken)
+	require.True(t, userToken.UsedAt.IsZero())
+
+	return userToken
+}
+
+func TestStore_VerifyEmailTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	require.True(t, user.EmailVerifiedAt.IsZero())
+
+	userToken := createRandomUserToken(t, user.Username, UserTokenPurposeEmailVerification, time.Now().Add(time.Hour))
+
+	verified, err := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		TokenID:  userToken.ID,
+		Username: user.Username,
+	})
+	require.NoError(t, err)
+	require.False(t, verified.EmailVerifiedAt.IsZero())
+
+	_, err = store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		TokenID:  userToken.ID,
+		Username: user.Username,
+	})
+	require.ErrorIs(t, err, ErrUserTokenUsed)
+}
+
+func TestStore_ResetPasswordTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	expired := createRandomUserToken(t, user.Username, UserTokenPurposePasswordReset, time.Now().Add(-time.Minute))
+	_, err := store.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+		TokenID:        expired.ID,
+		Username:       user.Username,
+		HashedPassword: "new-hash",
+	})
+	require.ErrorIs(t, err, ErrUserTokenUsed)
+
+	userToken := createRandomUserToken(t, user.Username, UserTokenPurposePasswordReset, time.Now().Add(time.Hour))
+	other := createRandomUserToken(t, user.Username, UserTokenPurposePasswordReset, time.Now().Add(time.Hour))
+
+	updated, err := store.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+		TokenID:        userToken.ID,
+		Username:       user.Username,
+		HashedPassword: "new-hash",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "new-hash", updated.HashedPassword)
+	require.True(t, updated.PasswordChangedAt.After(user.PasswordChangedAt))
+
+	// the other outstanding reset link no longer works
+	revoked, err := testQueries.GetUserToken(context.Background(), GetUserTokenParams{
+		HashedToken: other.HashedToken,
+		Purpose:     UserTokenPurposePasswordReset,
+	})
+	require.NoError(t, err)
+	require.False(t, revoked.UsedAt.IsZero())
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	UserTokenPurposeEmailVerification = "email_verification"
+	UserTokenPurposePasswordReset     = "password_reset"
+)
+
+// ErrUserTokenUsed is returned when a single-use token has already been used
+// or has expired by the time it is consumed.
+var ErrUserTokenUsed = errors.New("user token is already used or expired")
+
+type VerifyEmailTxParams struct {
+	TokenID  int64  `json:"token_id"`
+	Username string `json:"username"`
+}
+
+// VerifyEmailTx consumes an email verification token and marks the email of
+// its owner as verified.
+func (s *SQLStore) VerifyEmailTx(ctx context.Context, args VerifyEmailTxParams) (User, error) {
+	var user User
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		if err := consumeUserToken(ctx, q, args.TokenID); err != nil {
+			return err
+		}
+
+		var err error
+		user, err = q.VerifyUserEmail(ctx, args.Username)
+		return err
+	})
+	return user, err
+}
+
+type ResetPasswordTxParams struct {
+	TokenID        int64  `json:"token_id"`
+	Username       string `json:"username"`
+	HashedPassword string `json:"hashed_password"`
+}
+
+// ResetPasswordTx consumes a password reset token, sets the new password,
+// lifts a lockout and revokes every other reset token of the user.
+func (s *SQLStore) ResetPasswordTx(ctx context.Context, args ResetPasswordTxParams) (User, error) {
+	var user User
+
+	err := s.execTx(ctx, func(q *Queries) error {
+		if err := consumeUserToken(ctx, q, args.TokenID); err != nil {
+			return err
+		}
+
+		err := q.RevokeUserTokens(ctx, RevokeUserTokensParams{
+			Username: args.Username,
+			Purpose:  UserTokenPurposePasswordReset,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = q.ResetFailedLogins(ctx, args.Username); err != nil {
+			return err
+		}
+
+		user, err = q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			Username:          args.Username,
+			HashedPassword:    args.HashedPassword,
+			PasswordChangedAt: time.Now(),
+		})
+		return err
+	})
+	return user, err
+}
+
+func consumeUserToken(ctx context.Context, q *Queries, id int64) error {
+	used, err := q.UseUserToken(ctx, id)
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return ErrUserTokenUsed
+	}
+	return nil
+}
@@ -7,6 +7,9 @@ package db
 
 import (
 	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -18,7 +21,7 @@ VALUES ($1,
         $2,
         $3,
         $4)
-RETURNING username, hashed_password, full_name, email, password_changed_at, created_at
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, failed_login_attempts, locked_until, email_verified_at, role, version
 `
 
 type CreateUserParams struct {
@@ -29,7 +32,7 @@ type CreateUserParams struct {
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser,
+	row := q.db.QueryRow(ctx, createUser,
 		arg.Username,
 		arg.HashedPassword,
 		arg.FullName,
@@ -43,19 +46,267 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Version,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT username, hashed_password, full_name, email, password_changed_at, created_at
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, failed_login_attempts, locked_until, email_verified_at, role, version
 FROM users
 WHERE username = $1
 LIMIT 1
 `
 
 func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUser, username)
+	row := q.db.QueryRow(ctx, getUser, username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Version,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT username, hashed_password, full_name, email, password_changed_at, created_at, failed_login_attempts, locked_until, email_verified_at, role, version
+FROM users
+WHERE email = $1
+LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Version,
+	)
+	return i, err
+}
+
+const lockUser = `-- name: LockUser :exec
+UPDATE users
+SET locked_until = $2
+WHERE username = $1
+`
+
+type LockUserParams struct {
+	Username    string    `json:"username"`
+	LockedUntil time.Time `json:"locked_until"`
+}
+
+// LockUser does not bump the version, like RegisterFailedLogin.
+func (q *Queries) LockUser(ctx context.Context, arg LockUserParams) error {
+	_, err := q.db.Exec(ctx, lockUser, arg.Username, arg.LockedUntil)
+	return err
+}
+
+const registerFailedLogin = `-- name: RegisterFailedLogin :one
+UPDATE users
+SET failed_login_attempts = failed_login_attempts + 1
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, failed_login_attempts, locked_until, email_verified_at, role, version
+`
+
+// RegisterFailedLogin, unlike the other user updates, does not bump the
+// version: the lockout state is not part of the user clients see, so a
+// wrong password must not break their ETag or If-Match.
+func (q *Queries) RegisterFailedLogin(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRow(ctx, registerFailedLogin, username)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Version,
+	)
+	return i, err
+}
+
+const resetFailedLogins = `-- name: ResetFailedLogins :exec
+UPDATE users
+SET failed_login_attempts = 0,
+    locked_until          = '0001-01-01 00:00:00Z'
+WHERE username = $1
+`
+
+// ResetFailedLogins does not bump the version, like RegisterFailedLogin.
+func (q *Queries) ResetFailedLogins(ctx context.Context, username string) error {
+	_, err := q.db.Exec(ctx, resetFailedLogins, username)
+	return err
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET full_name         = COALESCE($1, full_name),
+    email             = COALESCE($2, email),
+    email_verified_at = CASE
+                            WHEN $2::varchar IS NULL OR $2 = email THEN email_verified_at
+                            ELSE '0001-01-01 00:00:00Z'
+        END,
+    version           = version + 1
+WHERE username = $3
+  AND ($4::bigint IS NULL OR version = $4)
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, failed_login_attempts, locked_until, email_verified_at, role, version
+`
+
+type UpdateUserParams struct {
+	FullName        pgtype.Text `json:"full_name"`
+	Email           pgtype.Text `json:"email"`
+	Username        string      `json:"username"`
+	ExpectedVersion pgtype.Int8 `json:"expected_version"`
+}
+
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUser,
+		arg.FullName,
+		arg.Email,
+		arg.Username,
+		arg.ExpectedVersion,
+	)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Version,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password     = $2,
+    password_changed_at = $3,
+    version             = version + 1
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, failed_login_attempts, locked_until, email_verified_at, role, version
+`
+
+type UpdateUserPasswordParams struct {
+	Username          string    `json:"username"`
+	HashedPassword    string    `json:"hashed_password"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserPassword, arg.Username, arg.HashedPassword, arg.PasswordChangedAt)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Version,
+	)
+	return i, err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE users
+SET role    = $2,
+    version = version + 1
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, failed_login_attempts, locked_until, email_verified_at, role, version
+`
+
+type UpdateUserRoleParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserRole, arg.Username, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.Username,
+		&i.HashedPassword,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Version,
+	)
+	return i, err
+}
+
+const upgradeUserPasswordHash = `-- name: UpgradeUserPasswordHash :exec
+UPDATE users
+SET hashed_password = $1
+WHERE username = $2
+  AND hashed_password = $3
+`
+
+type UpgradeUserPasswordHashParams struct {
+	NewHashedPassword string `json:"new_hashed_password"`
+	Username          string `json:"username"`
+	OldHashedPassword string `json:"old_hashed_password"`
+}
+
+// UpgradeUserPasswordHash does not bump the version either, the hash is
+// never shown and the rehash happens on login.
+func (q *Queries) UpgradeUserPasswordHash(ctx context.Context, arg UpgradeUserPasswordHashParams) error {
+	_, err := q.db.Exec(ctx, upgradeUserPasswordHash, arg.NewHashedPassword, arg.Username, arg.OldHashedPassword)
+	return err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET email_verified_at = now(),
+    version           = version + 1
+WHERE username = $1
+RETURNING username, hashed_password, full_name, email, password_changed_at, created_at, failed_login_attempts, locked_until, email_verified_at, role, version
+`
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRow(ctx, verifyUserEmail, username)
 	var i User
 	err := row.Scan(
 		&i.Username,
@@ -64,6 +315,11 @@ func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
 		&i.Email,
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
+		&i.FailedLoginAttempts,
+		&i.LockedUntil,
+		&i.EmailVerifiedAt,
+		&i.Role,
+		&i.Version,
 	)
 	return i, err
 }
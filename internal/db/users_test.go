@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/require"
 	random2 "simple-bank/internal/random"
 	"simple-bank/internal/security"
@@ -9,8 +10,8 @@ import (
 	"time"
 )
 
-func createRandomUser(t *testing.T) User {
-	hashedPassword, err := security.HashPassword(random2.String(6))
+func createRandomUser(t testing.TB) User {
+	hashedPassword, err := security.HashPassword(random2.String(6), security.DefaultPasswordParams)
 	require.NoError(t, err)
 	require.NotEmpty(t, hashedPassword)
 	arg := CreateUserParams{
@@ -27,6 +28,9 @@ func createRandomUser(t *testing.T) User {
 
 	require.NotEmpty(t, user.Username)
 	require.True(t, user.PasswordChangedAt.IsZero())
+	require.Zero(t, user.FailedLoginAttempts)
+	require.True(t, user.LockedUntil.IsZero())
+	require.Equal(t, UserRoleCustomer, user.Role)
 	require.NotZero(t, user.CreatedAt)
 
 	require.Equal(t, arg.Username, user.Username)
@@ -56,3 +60,90 @@ func TestQueries_GetUser(t *testing.T) {
 	require.WithinDuration(t, randomUser.PasswordChangedAt, account.PasswordChangedAt, time.Second)
 	require.WithinDuration(t, randomUser.CreatedAt, account.CreatedAt, time.Second)
 }
+
+func TestQueries_FailedLogins(t *testing.T) {
+	randomUser := createRandomUser(t)
+
+	for i := int32(1); i <= 3; i++ {
+		user, err := testQueries.RegisterFailedLogin(context.Background(), randomUser.Username)
+		require.NoError(t, err)
+		require.Equal(t, i, user.FailedLoginAttempts)
+	}
+
+	lockedUntil := time.Now().Add(time.Minute)
+	err := testQueries.LockUser(context.Background(), LockUserParams{
+		Username:    randomUser.Username,
+		LockedUntil: lockedUntil,
+	})
+	require.NoError(t, err)
+
+	user, err := testQueries.GetUser(context.Background(), randomUser.Username)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), user.FailedLoginAttempts)
+	require.WithinDuration(t, lockedUntil, user.LockedUntil, time.Second)
+
+	require.NoError(t, testQueries.ResetFailedLogins(context.Background(), randomUser.Username))
+
+	user, err = testQueries.GetUser(context.Background(), randomUser.Username)
+	require.NoError(t, err)
+	require.Zero(t, user.FailedLoginAttempts)
+	require.True(t, user.LockedUntil.IsZero())
+	require.Equal(t, UserRoleCustomer, user.Role)
+}
+
+func TestQueries_UpdateUser(t *testing.T) {
+	randomUser := createRandomUser(t)
+	randomUser, err := testQueries.VerifyUserEmail(context.Background(), randomUser.Username)
+	require.NoError(t, err)
+	require.False(t, randomUser.EmailVerifiedAt.IsZero())
+
+	newFullName := random2.Username()
+	user, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		FullName: pgtype.Text{String: newFullName, Valid: true},
+		Username: randomUser.Username,
+	})
+	require.NoError(t, err)
+	require.Equal(t, newFullName, user.FullName)
+	require.Equal(t, randomUser.Email, user.Email)
+	require.WithinDuration(t, randomUser.EmailVerifiedAt, user.EmailVerifiedAt, time.Second)
+
+	newEmail := random2.UserEmail()
+	user, err = testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Email:    pgtype.Text{String: newEmail, Valid: true},
+		Username: randomUser.Username,
+	})
+	require.NoError(t, err)
+	require.Equal(t, newFullName, user.FullName)
+	require.Equal(t, newEmail, user.Email)
+	require.True(t, user.EmailVerifiedAt.IsZero())
+}
+
+func TestQueries_UpgradeUserPasswordHash(t *testing.T) {
+	randomUser := createRandomUser(t)
+	newHash, err := security.HashPassword(random2.String(6), security.DefaultPasswordParams)
+	require.NoError(t, err)
+
+	// a stale old hash means the password changed in between, nothing happens
+	err = testQueries.UpgradeUserPasswordHash(context.Background(), UpgradeUserPasswordHashParams{
+		NewHashedPassword: newHash,
+		Username:          randomUser.Username,
+		OldHashedPassword: "stale",
+	})
+	require.NoError(t, err)
+
+	user, err := testQueries.GetUser(context.Background(), randomUser.Username)
+	require.NoError(t, err)
+	require.Equal(t, randomUser.HashedPassword, user.HashedPassword)
+
+	err = testQueries.UpgradeUserPasswordHash(context.Background(), UpgradeUserPasswordHashParams{
+		NewHashedPassword: newHash,
+		Username:          randomUser.Username,
+		OldHashedPassword: randomUser.HashedPassword,
+	})
+	require.NoError(t, err)
+
+	user, err = testQueries.GetUser(context.Background(), randomUser.Username)
+	require.NoError(t, err)
+	require.Equal(t, newHash, user.HashedPassword)
+	require.Equal(t, randomUser.PasswordChangedAt, user.PasswordChangedAt)
+}
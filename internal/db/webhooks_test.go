@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/random"
+	"testing"
+	"time"
+)
+
+func createRandomWebhookSubscription(t *testing.T, owner string, eventTypes ...string) WebhookSubscription {
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	arg := CreateWebhookSubscriptionParams{
+		Owner:      owner,
+		Url:        "https://example.com/hooks/" + random.String(6),
+		Secret:     random.String(32),
+		EventTypes: eventTypes,
+	}
+
+	subscription, err := testQueries.CreateWebhookSubscription(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Owner, subscription.Owner)
+	require.Equal(t, arg.Url, subscription.Url)
+	require.Equal(t, arg.EventTypes, subscription.EventTypes)
+	require.True(t, subscription.DisabledAt.IsZero())
+
+	return subscription
+}
+
+func createRandomWebhookDelivery(t *testing.T, subscription WebhookSubscription) WebhookDelivery {
+	eventID := random.Int64(1, 1<<40)
+	arg := CreateWebhookDeliveryParams{
+		SubscriptionID: subscription.ID,
+		EventID:        eventID,
+		EventType:      EventTransferCompleted,
+		Payload:        json.RawMessage(`{}`),
+	}
+
+	require.NoError(t, testQueries.CreateWebhookDelivery(context.Background(), arg))
+	// queueing the same event again is a no-op
+	require.NoError(t, testQueries.CreateWebhookDelivery(context.Background(), arg))
+
+	deliveries, err := testQueries.ListWebhookDeliveries(context.Background(), ListWebhookDeliveriesParams{
+		SubscriptionID: subscription.ID,
+		RowLimit:       100,
+	})
+	require.NoError(t, err)
+
+	var found []WebhookDelivery
+	for _, delivery := range deliveries {
+		if delivery.EventID == eventID {
+			found = append(found, delivery)
+		}
+	}
+	require.Len(t, found, 1)
+	require.Equal(t, WebhookDeliveryPending, found[0].Status)
+
+	return found[0]
+}
+
+func TestQueries_ListWebhookSubscriptionsForEvent(t *testing.T) {
+	user := createRandomUser(t)
+	all := createRandomWebhookSubscription(t, user.Username)
+	transfers := createRandomWebhookSubscription(t, user.Username, EventTransferCompleted)
+	createRandomWebhookSubscription(t, user.Username, EventAccountCreated)
+	disabled := createRandomWebhookSubscription(t, user.Username)
+	createRandomWebhookSubscription(t, createRandomUser(t).Username)
+
+	_, err := testQueries.UpdateWebhookSubscription(context.Background(), UpdateWebhookSubscriptionParams{
+		ID:         disabled.ID,
+		Url:        disabled.Url,
+		EventTypes: disabled.EventTypes,
+		DisabledAt: time.Now(),
+	})
+	require.NoError(t, err)
+
+	subscriptions, err := testQueries.ListWebhookSubscriptionsForEvent(context.Background(), ListWebhookSubscriptionsForEventParams{
+		Owners:    []string{user.Username},
+		EventType: EventTransferCompleted,
+	})
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 2)
+	require.Equal(t, all.ID, subscriptions[0].ID)
+	require.Equal(t, transfers.ID, subscriptions[1].ID)
+}
+
+func TestQueries_RecordWebhookSubscriptionFailure(t *testing.T) {
+	subscription := createRandomWebhookSubscription(t, createRandomUser(t).Username)
+
+	for i := int32(1); i < 3; i++ {
+		failed, err := testQueries.RecordWebhookSubscriptionFailure(context.Background(), RecordWebhookSubscriptionFailureParams{
+			ID:           subscription.ID,
+			DisableAfter: 3,
+		})
+		require.NoError(t, err)
+		require.Equal(t, i, failed.ConsecutiveFailures)
+		require.True(t, failed.DisabledAt.IsZero())
+	}
+
+	disabled, err := testQueries.RecordWebhookSubscriptionFailure(context.Background(), RecordWebhookSubscriptionFailureParams{
+		ID:           subscription.ID,
+		DisableAfter: 3,
+	})
+	require.NoError(t, err)
+	require.False(t, disabled.DisabledAt.IsZero())
+
+	require.NoError(t, testQueries.ResetWebhookSubscriptionFailures(context.Background(), subscription.ID))
+	found, err := testQueries.GetWebhookSubscription(context.Background(), subscription.ID)
+	require.NoError(t, err)
+	require.Zero(t, found.ConsecutiveFailures)
+	// only an update by the owner enables it again
+	require.False(t, found.DisabledAt.IsZero())
+}
+
+func TestQueries_ClaimWebhookDeliveries(t *testing.T) {
+	user := createRandomUser(t)
+	subscription := createRandomWebhookSubscription(t, user.Username)
+	delivery := createRandomWebhookDelivery(t, subscription)
+
+	claimed := claimedDeliveries(t)
+	require.Contains(t, claimed, delivery.ID)
+	require.Equal(t, subscription.Url, claimed[delivery.ID].Url)
+	require.Equal(t, subscription.Secret, claimed[delivery.ID].Secret)
+	require.Equal(t, int32(1), claimed[delivery.ID].Attempts)
+
+	// leased
+	require.NotContains(t, claimedDeliveries(t), delivery.ID)
+
+	err := testQueries.MarkWebhookDeliveryFailed(context.Background(), MarkWebhookDeliveryFailedParams{
+		ID:             delivery.ID,
+		Status:         WebhookDeliveryPending,
+		ResponseStatus: 500,
+		LastError:      "unexpected status 500",
+		NextAttemptAt:  time.Now().Add(-time.Second),
+	})
+	require.NoError(t, err)
+
+	// deliveries of disabled subscriptions wait
+	_, err = testQueries.UpdateWebhookSubscription(context.Background(), UpdateWebhookSubscriptionParams{
+		ID:         subscription.ID,
+		Url:        subscription.Url,
+		EventTypes: subscription.EventTypes,
+		DisabledAt: time.Now(),
+	})
+	require.NoError(t, err)
+	require.NotContains(t, claimedDeliveries(t), delivery.ID)
+
+	_, err = testQueries.UpdateWebhookSubscription(context.Background(), UpdateWebhookSubscriptionParams{
+		ID:         subscription.ID,
+		Url:        subscription.Url,
+		EventTypes: subscription.EventTypes,
+	})
+	require.NoError(t, err)
+	require.Contains(t, claimedDeliveries(t), delivery.ID)
+
+	err = testQueries.MarkWebhookDeliverySucceeded(context.Background(), MarkWebhookDeliverySucceededParams{
+		ID:             delivery.ID,
+		ResponseStatus: 204,
+	})
+	require.NoError(t, err)
+
+	succeeded, err := testQueries.GetWebhookDelivery(context.Background(), delivery.ID)
+	require.NoError(t, err)
+	require.Equal(t, WebhookDeliverySucceeded, succeeded.Status)
+	require.False(t, succeeded.DeliveredAt.IsZero())
+	require.Empty(t, succeeded.LastError)
+}
+
+func TestQueries_ReplayWebhookDelivery(t *testing.T) {
+	subscription := createRandomWebhookSubscription(t, createRandomUser(t).Username)
+	delivery := createRandomWebhookDelivery(t, subscription)
+
+	replay, err := testQueries.ReplayWebhookDelivery(context.Background(), delivery.ID)
+	require.NoError(t, err)
+	require.NotEqual(t, delivery.ID, replay.ID)
+	require.Equal(t, delivery.ID, replay.ReplayOf)
+	require.Equal(t, delivery.EventID, replay.EventID)
+	require.Equal(t, WebhookDeliveryPending, replay.Status)
+	require.Zero(t, replay.Attempts)
+}
+
+func TestQueries_DeleteWebhookSubscription(t *testing.T) {
+	user := createRandomUser(t)
+	subscription := createRandomWebhookSubscription(t, user.Username)
+	createRandomWebhookDelivery(t, subscription)
+
+	deleted, err := testQueries.DeleteWebhookSubscription(context.Background(), DeleteWebhookSubscriptionParams{
+		ID:    subscription.ID,
+		Owner: createRandomUser(t).Username,
+	})
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+
+	deleted, err = testQueries.DeleteWebhookSubscription(context.Background(), DeleteWebhookSubscriptionParams{
+		ID:    subscription.ID,
+		Owner: user.Username,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+}
+
+func claimedDeliveries(t *testing.T) map[int64]ClaimWebhookDeliveriesRow {
+	claimed, err := testQueries.ClaimWebhookDeliveries(context.Background(), ClaimWebhookDeliveriesParams{
+		LeaseUntil: time.Now().Add(time.Minute),
+		RowLimit:   10000,
+	})
+	require.NoError(t, err)
+
+	deliveries := make(map[int64]ClaimWebhookDeliveriesRow, len(claimed))
+	for _, delivery := range claimed {
+		deliveries[delivery.ID] = delivery
+	}
+	return deliveries
+}
@@ -1,36 +1,213 @@
 package dependency
 
 import (
+	"context"
 	"database/sql"
-	_ "github.com/lib/pq"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 	"go.uber.org/dig"
+	"log"
+	"simple-bank/db/migration"
+	sqlitemigration "simple-bank/db/sqlite/migration"
 	"simple-bank/internal/api"
+	"simple-bank/internal/audit"
 	"simple-bank/internal/config"
 	"simple-bank/internal/db"
+	sqlitedb "simple-bank/internal/db/sqlite"
+	"simple-bank/internal/gapi"
+	"simple-bank/internal/mail"
+	"simple-bank/internal/migrate"
+	"simple-bank/internal/outbox"
+	"simple-bank/internal/ratelimit"
+	"simple-bank/internal/security"
+	"simple-bank/internal/stream"
 	"simple-bank/internal/tokens"
 	"simple-bank/internal/utils"
+	"simple-bank/internal/webhook"
 )
 
 func NewDependency() *dig.Container {
 	container := dig.New()
 
+	utils.NoError(container.Provide(newConfigWatcher))
 	utils.NoError(container.Provide(newConfig))
 	utils.NoError(container.Provide(newPasetoManager))
-	utils.NoError(container.Provide(newSqlConnection))
-	utils.NoError(container.Provide(db.NewStore))
+	utils.NoError(container.Provide(newDatabase))
+	utils.NoError(container.Provide(newMigrator))
+	utils.NoError(container.Provide(newStore))
+	utils.NoError(container.Provide(newRateLimitStorage))
+	utils.NoError(container.Provide(newMailer))
+	utils.NoError(container.Provide(newPasswordPolicy))
+	utils.NoError(container.Provide(newAuditRecorder))
+	utils.NoError(container.Provide(newWebhookDispatcher))
+	utils.NoError(container.Provide(newWebhookSender))
+	utils.NoError(container.Provide(newOutboxSink))
+	utils.NoError(container.Provide(newOutboxRelay))
+	utils.NoError(container.Provide(stream.NewBroker))
+	utils.NoError(container.Provide(newAccountEventsListener))
 	utils.NoError(container.Provide(api.NewServer))
+	utils.NoError(container.Provide(gapi.NewServer))
 
 	return container
 }
 
-func newConfig() (*config.Config, error) {
-	return config.Load("./")
+func newConfigWatcher() (*config.Watcher, error) {
+	return config.Watch("./")
+}
+
+// newConfig is the configuration at startup, what is built from it is only
+// rebuilt on restart. Code that follows reloads takes the *config.Watcher.
+func newConfig(configs *config.Watcher) *config.Config {
+	return configs.Current()
 }
 
 func newPasetoManager(cfg *config.Config) (tokens.Manager, error) {
 	return tokens.NewPasetoManager(cfg.TokenPrivateKey)
 }
 
-func newSqlConnection(cfg *config.Config) (*sql.DB, error) {
-	return sql.Open(cfg.DBDriver, cfg.DBSource)
+// database is the connection to DB_DRIVER, only the field of the driver
+// is set.
+type database struct {
+	pool   *pgxpool.Pool
+	sqlite *sql.DB
+}
+
+func newDatabase(cfg *config.Config) (*database, error) {
+	if cfg.DBDriver == "sqlite" {
+		sqlDB, err := sqlitedb.Open(context.Background(), cfg.DBSource)
+		if err != nil {
+			return nil, err
+		}
+		return &database{sqlite: sqlDB}, nil
+	}
+
+	pool, err := openPool(cfg, cfg.DBSource)
+	if err != nil {
+		return nil, err
+	}
+	return &database{pool: pool}, nil
+}
+
+// newStore reads accounts and history from DB_REPLICA_SOURCE when it is set.
+func newStore(cfg *config.Config, database *database) (db.Store, error) {
+	if database.sqlite != nil {
+		return sqlitedb.NewStore(database.sqlite), nil
+	}
+
+	store := db.NewStore(database.pool)
+	if cfg.DBReplicaSource == "" {
+		return store, nil
+	}
+
+	replica, err := openPool(cfg, cfg.DBReplicaSource)
+	if err != nil {
+		return nil, fmt.Errorf("replica: %w", err)
+	}
+	return db.NewReplicaStore(store, db.New(replica)), nil
+}
+
+func openPool(cfg *config.Config, source string) (*pgxpool.Pool, error) {
+	pool := db.PoolConfig{
+		MaxConns:        cfg.DBMaxConns,
+		MinConns:        cfg.DBMinConns,
+		MaxConnLifetime: cfg.DBConnMaxLifetime,
+		MaxConnIdleTime: cfg.DBConnMaxIdleTime,
+	}
+	return db.Open(context.Background(), source, pool, cfg.DBConnectAttempts, cfg.DBConnectBackoff)
+}
+
+// newMigrator runs the migrations through database/sql, on top of the pool
+// for Postgres.
+func newMigrator(database *database) (*migrate.Migrator, error) {
+	if database.sqlite != nil {
+		return migrate.NewSQLite(database.sqlite, sqlitemigration.FS)
+	}
+	return migrate.New(stdlib.OpenDBFromPool(database.pool), migration.FS)
+}
+
+func newRateLimitStorage(cfg *config.Config, store db.Store) (ratelimit.Storage, error) {
+	switch cfg.RateLimitStorage {
+	case "", "memory":
+		return ratelimit.NewMemoryStorage(), nil
+	case "postgres":
+		return ratelimit.NewPostgresStorage(store), nil
+	default:
+		return nil, fmt.Errorf("unsupported rate limit storage: %s", cfg.RateLimitStorage)
+	}
+}
+
+func newMailer(cfg *config.Config) (mail.Mailer, error) {
+	switch cfg.Mailer {
+	case "", "file":
+		return mail.NewFileMailer(cfg.MailOutboxDir, cfg.MailFrom)
+	case "smtp":
+		return mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	default:
+		return nil, fmt.Errorf("unsupported mailer: %s", cfg.Mailer)
+	}
+}
+
+func newPasswordPolicy(cfg *config.Config) (security.PasswordPolicy, error) {
+	policy := security.PasswordPolicy{MinLength: cfg.PasswordMinLength}
+	if cfg.BreachedPasswordsFile == "" {
+		return policy, nil
+	}
+
+	breached, err := security.LoadBreachedPasswords(cfg.BreachedPasswordsFile)
+	if err != nil {
+		return policy, fmt.Errorf("load breached passwords: %w", err)
+	}
+	policy.Breached = breached
+
+	return policy, nil
+}
+
+func newAuditRecorder(store db.Store) audit.Recorder {
+	return audit.NewStoreRecorder(store)
+}
+
+// newOutboxSink hands every event to webhooks as well as to the configured
+// sink.
+func newOutboxSink(cfg *config.Config, webhooks *webhook.Dispatcher) (outbox.Sink, error) {
+	var sink outbox.Sink
+	switch cfg.OutboxSink {
+	case "", "log":
+		sink = outbox.NewLogSink(log.Default())
+	case "http":
+		sink = outbox.NewHTTPSink(cfg.OutboxHTTPURL)
+	case "nats":
+		natsSink, err := outbox.NewNATSSink(cfg.OutboxNATSURL, cfg.OutboxNATSSubject)
+		if err != nil {
+			return nil, err
+		}
+		sink = natsSink
+	default:
+		return nil, fmt.Errorf("unsupported outbox sink: %s", cfg.OutboxSink)
+	}
+
+	return outbox.NewFanout(sink, webhooks), nil
+}
+
+func newOutboxRelay(cfg *config.Config, store db.Store, sink outbox.Sink) *outbox.Relay {
+	return outbox.NewRelay(store, sink, cfg.OutboxPollInterval, cfg.OutboxBatchSize)
+}
+
+// newWebhookDispatcher renders webhooks the way the API renders the events
+// it streams.
+func newWebhookDispatcher(store db.Store) *webhook.Dispatcher {
+	return webhook.NewDispatcher(store, api.EventData)
+}
+
+func newWebhookSender(cfg *config.Config, store db.Store) *webhook.Sender {
+	return webhook.NewSender(store, cfg.OutboxPollInterval, cfg.OutboxBatchSize, cfg.WebhookMaxAttempts, cfg.WebhookDisableAfter)
+}
+
+// newAccountEventsListener listens on the account events channel, or to the
+// store itself when it has no NOTIFY.
+func newAccountEventsListener(cfg *config.Config, store db.Store, broker *stream.Broker) stream.Listener {
+	if source, ok := store.(stream.AccountEventsSource); ok {
+		return stream.NewStoreListener(source, broker)
+	}
+	return stream.NewPostgresListener(cfg.DBSource, broker)
 }
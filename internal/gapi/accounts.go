@@ -0,0 +1,76 @@
+package gapi
+
+import (
+	"context"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	"simple-bank/internal/pb"
+	"strconv"
+)
+
+func (s *Server) CreateAccount(ctx context.Context, req *pb.CreateAccountRequest) (*pb.CreateAccountResponse, error) {
+	if err := validateFields(field{"currency", req.GetCurrency(), "required,currency"}); err != nil {
+		return nil, err
+	}
+
+	account, err := s.store.CreateAccountTx(ctx, db.CreateAccountParams{
+		Owner:    getPayload(ctx).Subject,
+		Balance:  0,
+		Currency: req.GetCurrency(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Action:     auditActionAccountCreate,
+		TargetType: auditTargetAccount,
+		TargetID:   strconv.FormatInt(account.ID, 10),
+		Outcome:    audit.OutcomeSuccess,
+		Diff:       map[string]any{"currency": account.Currency},
+	})
+
+	return &pb.CreateAccountResponse{Account: convertAccount(account)}, nil
+}
+
+func (s *Server) GetAccount(ctx context.Context, req *pb.GetAccountRequest) (*pb.GetAccountResponse, error) {
+	if err := validateFields(field{"id", req.GetId(), "required,min=1"}); err != nil {
+		return nil, err
+	}
+
+	account, err := s.store.GetAccount(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if getPayload(ctx).Subject != account.Owner {
+		return nil, errForbidden.withMessage("you do not own this account")
+	}
+
+	return &pb.GetAccountResponse{Account: convertAccount(account)}, nil
+}
+
+func (s *Server) ListAccounts(ctx context.Context, req *pb.ListAccountsRequest) (*pb.ListAccountsResponse, error) {
+	err := validateFields(
+		field{"page_id", req.GetPageId(), "required,min=1"},
+		field{"page_size", req.GetPageSize(), "required,min=5,max=10"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := s.store.ListAccounts(ctx, db.ListAccountsParams{
+		Owner:  getPayload(ctx).Subject,
+		Limit:  req.GetPageSize(),
+		Offset: (req.GetPageId() - 1) * req.GetPageSize(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &pb.ListAccountsResponse{Accounts: make([]*pb.Account, len(accounts))}
+	for i, account := range accounts {
+		response.Accounts[i] = convertAccount(account)
+	}
+	return response, nil
+}
@@ -0,0 +1,142 @@
+package gapi
+
+import (
+	"database/sql"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/pb"
+	"testing"
+)
+
+func TestServer_CreateAccount(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), user.Username).Times(2).Return(user, nil)
+	store.EXPECT().
+		CreateAccountTx(gomock.Any(), db.CreateAccountParams{Owner: user.Username, Currency: account.Currency}).
+		Times(1).
+		Return(account, nil)
+
+	server := newTestServer(t, store)
+	ctx := server.withAccessToken(t, user.Username)
+
+	response, err := server.accounts.CreateAccount(ctx, &pb.CreateAccountRequest{Currency: account.Currency})
+	require.NoError(t, err)
+	require.Equal(t, account.ID, response.GetAccount().GetId())
+	require.Equal(t, account.Currency, response.GetAccount().GetCurrency())
+	require.Equal(t, auditActionAccountCreate, server.auditEvents()[0].Action)
+
+	_, err = server.accounts.CreateAccount(ctx, &pb.CreateAccountRequest{Currency: "XYZ"})
+	requireRPCError(t, err, codes.InvalidArgument, "validation_failed")
+}
+
+func TestServer_GetAccount(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	other := randomAccount("other")
+
+	testCases := []struct {
+		name       string
+		id         int64
+		buildStubs func(store *mockdb.MockStore)
+		code       codes.Code
+		reason     string
+	}{
+		{
+			name: "ok",
+			id:   account.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(account, nil)
+			},
+			code: codes.OK,
+		},
+		{
+			name: "not_owner",
+			id:   other.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), other.ID).Times(1).Return(other, nil)
+			},
+			code:   codes.PermissionDenied,
+			reason: "forbidden",
+		},
+		{
+			name: "not_found",
+			id:   account.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(db.Account{}, pgx.ErrNoRows)
+			},
+			code:   codes.NotFound,
+			reason: "not_found",
+		},
+		{
+			name: "internal_error",
+			id:   account.ID,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(db.Account{}, sql.ErrConnDone)
+			},
+			code:   codes.Internal,
+			reason: "internal_error",
+		},
+		{
+			name: "invalid_id",
+			id:   0,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code:   codes.InvalidArgument,
+			reason: "validation_failed",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			response, err := server.accounts.GetAccount(server.withAccessToken(t, user.Username), &pb.GetAccountRequest{Id: tc.id})
+			if tc.code != codes.OK {
+				requireRPCError(t, err, tc.code, tc.reason)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, account.ID, response.GetAccount().GetId())
+			require.Equal(t, account.Balance, response.GetAccount().GetBalance())
+		})
+	}
+}
+
+func TestServer_ListAccounts(t *testing.T) {
+	user, _ := randomUser(t)
+	accounts := []db.Account{randomAccount(user.Username), randomAccount(user.Username)}
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), user.Username).Times(2).Return(user, nil)
+	store.EXPECT().
+		ListAccounts(gomock.Any(), db.ListAccountsParams{Owner: user.Username, Limit: 5, Offset: 5}).
+		Times(1).
+		Return(accounts, nil)
+
+	server := newTestServer(t, store)
+	ctx := server.withAccessToken(t, user.Username)
+
+	response, err := server.accounts.ListAccounts(ctx, &pb.ListAccountsRequest{PageId: 2, PageSize: 5})
+	require.NoError(t, err)
+	require.Len(t, response.GetAccounts(), len(accounts))
+	for i, account := range accounts {
+		require.Equal(t, account.ID, response.GetAccounts()[i].GetId())
+	}
+
+	_, err = server.accounts.ListAccounts(ctx, &pb.ListAccountsRequest{PageId: 1, PageSize: 50})
+	requireRPCError(t, err, codes.InvalidArgument, "validation_failed")
+}
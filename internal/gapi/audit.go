@@ -0,0 +1,59 @@
+package gapi
+
+import (
+	"context"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"log/slog"
+	"net"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/tokens"
+)
+
+// requestIDMetadata is read for the audit log, as X-Request-ID is over HTTP.
+const requestIDMetadata = "x-request-id"
+
+// the audit log records gRPC calls with the actions of their HTTP
+// counterparts
+const (
+	auditActionUserCreate     = "user.create"
+	auditActionUserLogin      = "user.login"
+	auditActionAccountCreate  = "account.create"
+	auditActionTransferCreate = "transfer.create"
+	auditTargetUser           = "user"
+	auditTargetAccount        = "account"
+	auditTargetTransfer       = "transfer"
+	// auditReasonAccountLocked tells a locked user apart in the audit log,
+	// the client gets invalid_credentials
+	auditReasonAccountLocked = "account_locked"
+)
+
+// recordAudit fills in what is known about the call and appends event to the
+// audit log. A failure is logged, it never fails the call.
+func (s *Server) recordAudit(ctx context.Context, event audit.Event) {
+	if event.Actor == "" {
+		if payload, ok := ctx.Value(payloadKey{}).(*tokens.Payload); ok {
+			event.Actor = payload.Subject
+		}
+	}
+	if values := metadata.ValueFromIncomingContext(ctx, requestIDMetadata); len(values) > 0 {
+		event.RequestID = values[0]
+	}
+	event.IP = clientIP(ctx)
+
+	if err := s.auditRecorder.Record(ctx, event); err != nil {
+		slog.Error("record audit event", "action", event.Action, "actor", event.Actor, "err", err)
+	}
+}
+
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
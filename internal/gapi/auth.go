@@ -0,0 +1,103 @@
+package gapi
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"simple-bank/internal/db"
+	"simple-bank/internal/pb"
+	"simple-bank/internal/ratelimit"
+	"simple-bank/internal/service"
+	"simple-bank/internal/tokens"
+	"strings"
+)
+
+const (
+	authorizationMetadata   = "authorization"
+	authorizationTypeBearer = service.AuthorizationTypeBearer
+	authorizationTypeAPIKey = service.AuthorizationTypeAPIKey
+)
+
+type payloadKey struct{}
+
+type userKey struct{}
+
+// methodAccess is what a method asks of the caller, the counterpart of the
+// middlewares guarding the HTTP routes.
+type methodAccess struct {
+	// public methods are served without credentials.
+	public bool
+	// session methods are out of reach of API keys and OAuth clients.
+	session bool
+	// scope has to be granted to restricted callers.
+	scope string
+	// group is the rate limit group the method draws from, the one of the
+	// HTTP routes doing the same.
+	group string
+}
+
+// methodsAccess lists every method served. A method missing from it is
+// refused to everybody.
+var methodsAccess = map[string]methodAccess{
+	pb.UserService_CreateUser_FullMethodName:         {public: true, group: ratelimit.GroupPublic},
+	pb.UserService_LoginUser_FullMethodName:          {public: true, group: ratelimit.GroupPublic},
+	pb.UserService_LoginUserTwoFactor_FullMethodName: {public: true, group: ratelimit.GroupPublic},
+	pb.UserService_GetCurrentUser_FullMethodName:     {session: true, group: ratelimit.GroupUsers},
+	pb.AccountService_CreateAccount_FullMethodName:   {scope: tokens.ScopeAccountsWrite, group: ratelimit.GroupAccounts},
+	pb.AccountService_GetAccount_FullMethodName:      {scope: tokens.ScopeAccountsRead, group: ratelimit.GroupAccounts},
+	pb.AccountService_ListAccounts_FullMethodName:    {scope: tokens.ScopeAccountsRead, group: ratelimit.GroupAccounts},
+	pb.TransferService_CreateTransfer_FullMethodName: {scope: tokens.ScopeTransfersWrite, group: ratelimit.GroupTransfers},
+}
+
+// authInterceptor accepts the credentials authMiddleware of the HTTP API
+// does, a bearer access token or an API key in the authorization metadata.
+// The payload and the user it belongs to end up in the context.
+func authInterceptor(bank *service.Service) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		access, ok := methodsAccess[info.FullMethod]
+		if !ok {
+			return nil, errForbidden.withMessage("method is not available")
+		}
+		if access.public {
+			return handler(ctx, req)
+		}
+
+		payload, user, err := authenticate(ctx, bank)
+		if err != nil {
+			return nil, err
+		}
+
+		if access.session && payload.Audience != tokens.AudienceAccess {
+			return nil, errInsufficientScope.withMessage("only available with an access token")
+		}
+		if access.scope != "" && !payload.HasScope(access.scope) {
+			return nil, errInsufficientScope.withMessage("%s scope is required", access.scope)
+		}
+
+		ctx = context.WithValue(ctx, payloadKey{}, payload)
+		ctx = context.WithValue(ctx, userKey{}, user)
+		return handler(ctx, req)
+	}
+}
+
+func authenticate(ctx context.Context, bank *service.Service) (*tokens.Payload, db.User, error) {
+	values := metadata.ValueFromIncomingContext(ctx, authorizationMetadata)
+	if len(values) == 0 {
+		return nil, db.User{}, errUnauthorized.withMessage("authorization metadata is missing")
+	}
+
+	fields := strings.Fields(values[0])
+	if len(fields) < 2 {
+		return nil, db.User{}, errUnauthorized.withMessage("authorization metadata is invalid")
+	}
+
+	return bank.Authenticate(ctx, fields[0], fields[1])
+}
+
+func getPayload(ctx context.Context) *tokens.Payload {
+	return ctx.Value(payloadKey{}).(*tokens.Payload)
+}
+
+func getUser(ctx context.Context) db.User {
+	return ctx.Value(userKey{}).(db.User)
+}
@@ -0,0 +1,165 @@
+package gapi
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/pb"
+	"simple-bank/internal/tokens"
+	"testing"
+	"time"
+)
+
+func TestAuthInterceptor(t *testing.T) {
+	user, _ := randomUser(t)
+	account := randomAccount(user.Username)
+	readKey, readAPIKey := randomAPIKey(t, user.Username, tokens.ScopeAccountsRead)
+	writeKey, writeAPIKey := randomAPIKey(t, user.Username, tokens.ScopeAccountsWrite)
+
+	testCases := []struct {
+		name       string
+		buildStubs func(store *mockdb.MockStore)
+		call       func(t *testing.T, server *testServer) error
+		code       codes.Code
+		reason     string
+	}{
+		{
+			name: "access_token",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(account, nil)
+			},
+			call: func(t *testing.T, server *testServer) error {
+				_, err := server.accounts.GetAccount(server.withAccessToken(t, user.Username), &pb.GetAccountRequest{Id: account.ID})
+				return err
+			},
+			code: codes.OK,
+		},
+		{
+			name: "api_key",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), readAPIKey.Prefix).Times(1).Return(readAPIKey, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), readAPIKey.ID).Times(1).Return(nil)
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account.ID).Times(1).Return(account, nil)
+			},
+			call: func(t *testing.T, server *testServer) error {
+				_, err := server.accounts.GetAccount(withAPIKey(readKey), &pb.GetAccountRequest{Id: account.ID})
+				return err
+			},
+			code: codes.OK,
+		},
+		{
+			name:       "missing_credentials",
+			buildStubs: func(store *mockdb.MockStore) {},
+			call: func(t *testing.T, server *testServer) error {
+				_, err := server.accounts.GetAccount(context.Background(), &pb.GetAccountRequest{Id: account.ID})
+				return err
+			},
+			code:   codes.Unauthenticated,
+			reason: "unauthorized",
+		},
+		{
+			name:       "unsupported_type",
+			buildStubs: func(store *mockdb.MockStore) {},
+			call: func(t *testing.T, server *testServer) error {
+				ctx := metadata.AppendToOutgoingContext(context.Background(), authorizationMetadata, "Basic dXNlcjpwYXNz")
+				_, err := server.accounts.GetAccount(ctx, &pb.GetAccountRequest{Id: account.ID})
+				return err
+			},
+			code:   codes.Unauthenticated,
+			reason: "unauthorized",
+		},
+		{
+			name:       "invalid_token",
+			buildStubs: func(store *mockdb.MockStore) {},
+			call: func(t *testing.T, server *testServer) error {
+				ctx := metadata.AppendToOutgoingContext(context.Background(), authorizationMetadata, "Bearer invalid")
+				_, err := server.accounts.GetAccount(ctx, &pb.GetAccountRequest{Id: account.ID})
+				return err
+			},
+			code:   codes.Unauthenticated,
+			reason: "token_invalid",
+		},
+		{
+			name: "revoked_by_password_change",
+			buildStubs: func(store *mockdb.MockStore) {
+				changed := user
+				changed.PasswordChangedAt = time.Now().Add(time.Minute)
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(changed, nil)
+			},
+			call: func(t *testing.T, server *testServer) error {
+				_, err := server.accounts.GetAccount(server.withAccessToken(t, user.Username), &pb.GetAccountRequest{Id: account.ID})
+				return err
+			},
+			code:   codes.Unauthenticated,
+			reason: "token_revoked",
+		},
+		{
+			name: "missing_scope",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), writeAPIKey.Prefix).Times(1).Return(writeAPIKey, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), writeAPIKey.ID).Times(1).Return(nil)
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			call: func(t *testing.T, server *testServer) error {
+				_, err := server.accounts.GetAccount(withAPIKey(writeKey), &pb.GetAccountRequest{Id: account.ID})
+				return err
+			},
+			code:   codes.PermissionDenied,
+			reason: "insufficient_scope",
+		},
+		{
+			name: "session_only",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAPIKeyByPrefix(gomock.Any(), readAPIKey.Prefix).Times(1).Return(readAPIKey, nil)
+				store.EXPECT().TouchAPIKey(gomock.Any(), readAPIKey.ID).Times(1).Return(nil)
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+			},
+			call: func(t *testing.T, server *testServer) error {
+				_, err := server.users.GetCurrentUser(withAPIKey(readKey), &pb.GetCurrentUserRequest{})
+				return err
+			},
+			code:   codes.PermissionDenied,
+			reason: "insufficient_scope",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			err := tc.call(t, newTestServer(t, store))
+			if tc.code == codes.OK {
+				require.NoError(t, err)
+				return
+			}
+			requireRPCError(t, err, tc.code, tc.reason)
+		})
+	}
+}
+
+func TestAuthInterceptor_everyMethodHasAccess(t *testing.T) {
+	for _, service := range []string{
+		pb.UserService_ServiceDesc.ServiceName,
+		pb.AccountService_ServiceDesc.ServiceName,
+		pb.TransferService_ServiceDesc.ServiceName,
+	} {
+		server := newTestServer(t, nil)
+		info, ok := server.grpcServer.GetServiceInfo()[service]
+		require.True(t, ok, service)
+
+		for _, method := range info.Methods {
+			_, ok := methodsAccess["/"+service+"/"+method.Name]
+			require.True(t, ok, "%s/%s has no access rule", service, method.Name)
+		}
+	}
+}
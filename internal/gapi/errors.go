@@ -0,0 +1,169 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"log/slog"
+	"simple-bank/internal/security"
+	"simple-bank/internal/service"
+	"simple-bank/internal/tokens"
+)
+
+// errorDomain is the domain of the ErrorInfo detail every error carries.
+const errorDomain = "simple-bank"
+
+// rpcError is the gRPC side of the HTTP error catalog. Reason is the same
+// stable code the HTTP API returns, clients find it in an ErrorInfo detail.
+type rpcError struct {
+	Code    codes.Code
+	Reason  string
+	Message string
+	details []protoadapt.MessageV1
+}
+
+func (e *rpcError) Error() string {
+	return e.Reason + ": " + e.Message
+}
+
+func (e *rpcError) Is(target error) bool {
+	var t *rpcError
+	return errors.As(target, &t) && t.Reason == e.Reason
+}
+
+func (e *rpcError) withMessage(format string, args ...any) *rpcError {
+	cp := *e
+	cp.Message = fmt.Sprintf(format, args...)
+	return &cp
+}
+
+func (e *rpcError) withDetails(details ...protoadapt.MessageV1) *rpcError {
+	cp := *e
+	cp.details = append(append([]protoadapt.MessageV1{}, e.details...), details...)
+	return &cp
+}
+
+// GRPCStatus is what the grpc package sends for a returned rpcError.
+func (e *rpcError) GRPCStatus() *status.Status {
+	st := status.New(e.Code, e.Message)
+	details := append([]protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: e.Reason, Domain: errorDomain}}, e.details...)
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		return withDetails
+	}
+	return st
+}
+
+var (
+	errValidationFailed     = &rpcError{Code: codes.InvalidArgument, Reason: "validation_failed", Message: "Request validation failed"}
+	errWeakPassword         = &rpcError{Code: codes.InvalidArgument, Reason: "weak_password", Message: "Password does not meet the password policy"}
+	errCurrencyMismatch     = &rpcError{Code: codes.FailedPrecondition, Reason: "currency_mismatch", Message: "Account currency does not match"}
+	errAccountFrozen        = &rpcError{Code: codes.FailedPrecondition, Reason: "account_frozen", Message: "Account is frozen"}
+	errUnauthorized         = &rpcError{Code: codes.Unauthenticated, Reason: "unauthorized", Message: "Authentication is required"}
+	errTokenInvalid         = &rpcError{Code: codes.Unauthenticated, Reason: "token_invalid", Message: "Access token is invalid"}
+	errTokenExpired         = &rpcError{Code: codes.Unauthenticated, Reason: "token_expired", Message: "Access token has expired"}
+	errTokenRevoked         = &rpcError{Code: codes.Unauthenticated, Reason: "token_revoked", Message: "Access token has been revoked"}
+	errTokenNotValidYet     = &rpcError{Code: codes.Unauthenticated, Reason: "token_not_valid_yet", Message: "Access token is not valid yet"}
+	errInvalidCredentials   = &rpcError{Code: codes.Unauthenticated, Reason: "invalid_credentials", Message: "Invalid credentials"}
+	errEmailNotVerified     = &rpcError{Code: codes.PermissionDenied, Reason: "email_not_verified", Message: "Email address is not verified"}
+	errTwoFactorRequired    = &rpcError{Code: codes.PermissionDenied, Reason: "two_factor_required", Message: "Two-factor verification is required"}
+	errTwoFactorCodeInvalid = &rpcError{Code: codes.PermissionDenied, Reason: "two_factor_code_invalid", Message: "Two-factor code is invalid"}
+	errInsufficientScope    = &rpcError{Code: codes.PermissionDenied, Reason: "insufficient_scope", Message: "Credentials lack the required scope"}
+	errForbidden            = &rpcError{Code: codes.PermissionDenied, Reason: "forbidden", Message: "Access to the resource is forbidden"}
+	errAlreadyExists        = &rpcError{Code: codes.AlreadyExists, Reason: "already_exists", Message: "Resource already exists"}
+	errReferenceViolation   = &rpcError{Code: codes.FailedPrecondition, Reason: "reference_violation", Message: "Referenced resource does not exist"}
+	errNotFound             = &rpcError{Code: codes.NotFound, Reason: "not_found", Message: "Resource not found"}
+	errRateLimited          = &rpcError{Code: codes.ResourceExhausted, Reason: "rate_limited", Message: "Too many requests"}
+	errInternal             = &rpcError{Code: codes.Internal, Reason: "internal_error", Message: "Internal server error"}
+)
+
+func toRPCError(err error) *rpcError {
+	var rpcErr *rpcError
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+
+	if rpcErr := serviceError(err); rpcErr != nil {
+		return rpcErr
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgerrcode.UniqueViolation:
+			return errAlreadyExists
+		case pgerrcode.ForeignKeyViolation:
+			return errReferenceViolation
+		}
+		return errInternal
+	}
+
+	switch {
+	case errors.Is(err, tokens.ErrTokenExpired):
+		return errTokenExpired
+	case errors.Is(err, tokens.ErrTokenNotValidYet):
+		return errTokenNotValidYet
+	case errors.Is(err, tokens.ErrTokenInvalid):
+		return errTokenInvalid
+	case errors.Is(err, security.ErrPasswordNotMatched):
+		return errInvalidCredentials
+	}
+
+	return errInternal
+}
+
+// serviceErrors maps the refusals of the service package to the catalog.
+var serviceErrors = map[error]*rpcError{
+	service.ErrUnauthorized:         errUnauthorized,
+	service.ErrTokenRevoked:         errTokenRevoked,
+	service.ErrWeakPassword:         errWeakPassword,
+	service.ErrInvalidCredentials:   errInvalidCredentials,
+	service.ErrEmailNotVerified:     errEmailNotVerified,
+	service.ErrTwoFactorRequired:    errTwoFactorRequired,
+	service.ErrTwoFactorCodeInvalid: errTwoFactorCodeInvalid,
+	service.ErrForbidden:            errForbidden,
+	service.ErrCurrencyMismatch:     errCurrencyMismatch,
+	service.ErrAccountFrozen:        errAccountFrozen,
+}
+
+// serviceError converts a refusal of the service package, keeping its
+// detail. It returns nil for any other error.
+func serviceError(err error) *rpcError {
+	for serviceErr, rpcErr := range serviceErrors {
+		if !errors.Is(err, serviceErr) {
+			continue
+		}
+		var refusal *service.Error
+		if errors.As(err, &refusal) && refusal.Detail != "" {
+			return rpcErr.withMessage("%s", refusal.Detail)
+		}
+		return rpcErr
+	}
+	return nil
+}
+
+// errorInterceptor converts whatever a handler returns to an error of the
+// catalog. Errors that are not part of it are logged and never exposed.
+func errorInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+
+	rpcErr := toRPCError(err)
+	if rpcErr.Code == codes.Internal {
+		slog.Error("request failed", "method", info.FullMethod, "err", err)
+	}
+	return nil, rpcErr
+}
@@ -0,0 +1,202 @@
+package gapi
+
+import (
+	"context"
+	"crypto/sha1"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/config"
+	"simple-bank/internal/db"
+	"simple-bank/internal/mail"
+	"simple-bank/internal/pb"
+	"simple-bank/internal/random"
+	"simple-bank/internal/ratelimit"
+	"simple-bank/internal/security"
+	"simple-bank/internal/tokens"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer is a Server behind an in-memory listener with a client of each
+// service connected to it.
+type testServer struct {
+	*Server
+	users     pb.UserServiceClient
+	accounts  pb.AccountServiceClient
+	transfers pb.TransferServiceClient
+}
+
+func newTestServer(t *testing.T, store db.Store) *testServer {
+	return newConfiguredTestServer(t, store, func(*config.Config) {})
+}
+
+// newConfiguredTestServer is newTestServer with configure applied to the
+// configuration of the tests.
+func newConfiguredTestServer(t *testing.T, store db.Store, configure func(cfg *config.Config)) *testServer {
+	cfg := &config.Config{
+		TokenPrivateKey:            random.String(32),
+		RecoveryCodeKey:            random.String(32),
+		AccessTokenDuration:        time.Minute * 15,
+		TwoFactorChallengeDuration: 5 * time.Minute,
+		LoginMaxAttempts:           5,
+		LoginLockoutDuration:       time.Minute,
+		TransferStepUpThreshold:    1000,
+		AppBaseURL:                 "http://localhost:8080",
+		EmailVerificationDuration:  time.Hour,
+		PasswordMinLength:          6,
+	}
+	configure(cfg)
+
+	tokensManager, err := tokens.NewPasetoManager(cfg.TokenPrivateKey)
+	require.NoError(t, err)
+
+	mailer, err := mail.NewFileMailer(t.TempDir(), "test@simple-bank.local")
+	require.NoError(t, err)
+
+	passwordPolicy := security.PasswordPolicy{
+		MinLength: cfg.PasswordMinLength,
+		Breached: security.BreachedPasswords{
+			sha1.Sum([]byte(testBreachedPassword)): {},
+		},
+	}
+
+	server, err := NewServer(config.Fixed(cfg), store, tokensManager, ratelimit.NewMemoryStorage(), mailer, passwordPolicy, &testAuditRecorder{})
+	require.NoError(t, err)
+
+	listener := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return &testServer{
+		Server:    server,
+		users:     pb.NewUserServiceClient(conn),
+		accounts:  pb.NewAccountServiceClient(conn),
+		transfers: pb.NewTransferServiceClient(conn),
+	}
+}
+
+// testBreachedPassword is the only entry of the breached list in tests.
+const testBreachedPassword = "password123"
+
+// withAccessToken authenticates the calls made with the returned context as
+// username.
+func (s *testServer) withAccessToken(t *testing.T, username string) context.Context {
+	token, err := s.tokensManager.CreateToken(tokens.PayloadCreationParams{
+		Subject:   username,
+		Audience:  tokens.AudienceAccess,
+		Issuer:    tokens.Issuer,
+		NotBefore: time.Now(),
+		Duration:  time.Minute,
+	})
+	require.NoError(t, err)
+
+	return metadata.AppendToOutgoingContext(context.Background(), authorizationMetadata, "Bearer "+token)
+}
+
+func withAPIKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), authorizationMetadata, "ApiKey "+key)
+}
+
+func (s *testServer) auditEvents() []audit.Event {
+	recorder := s.auditRecorder.(*testAuditRecorder)
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return append([]audit.Event(nil), recorder.events...)
+}
+
+func (s *testServer) sentMessages(t *testing.T) []mail.Message {
+	messages, err := s.mailer.(*mail.FileMailer).Messages()
+	require.NoError(t, err)
+	return messages
+}
+
+type testAuditRecorder struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (r *testAuditRecorder) Record(_ context.Context, event audit.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+// requireRPCError checks the code of err and the reason of its ErrorInfo,
+// it returns the status for the other details.
+func requireRPCError(t *testing.T, err error, code codes.Code, reason string) *status.Status {
+	st, ok := status.FromError(err)
+	require.True(t, ok, "not a status error: %v", err)
+	require.Equal(t, code, st.Code(), st.Message())
+
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			require.Equal(t, reason, info.Reason)
+			require.Equal(t, errorDomain, info.Domain)
+			return st
+		}
+	}
+	require.Fail(t, "error has no ErrorInfo detail")
+	return st
+}
+
+func randomUser(t *testing.T) (db.User, string) {
+	password := random.String(8)
+
+	hashedPassword, err := security.HashPassword(password, security.DefaultPasswordParams)
+	require.NoError(t, err)
+
+	return db.User{
+		Username:          random.String(6),
+		FullName:          random.String(8),
+		Email:             random.UserEmail(),
+		HashedPassword:    hashedPassword,
+		EmailVerifiedAt:   time.Now().Add(-time.Hour),
+		PasswordChangedAt: time.Now().Add(-time.Hour),
+	}, password
+}
+
+func randomAccount(owner string) db.Account {
+	return db.Account{
+		ID:       random.Int64(1, 1000),
+		Owner:    owner,
+		Balance:  random.AccountBalance(),
+		Currency: random.AccountCurrency(),
+	}
+}
+
+func randomAPIKey(t *testing.T, username string, scopes ...string) (string, db.ApiKey) {
+	key, prefix, err := security.GenerateAPIKey()
+	require.NoError(t, err)
+
+	return key, db.ApiKey{
+		Username:     username,
+		Name:         "test",
+		Prefix:       prefix,
+		HashedSecret: security.HashOpaqueToken(key),
+		Scopes:       scopes,
+		CreatedAt:    time.Now().Add(-time.Hour),
+	}
+}
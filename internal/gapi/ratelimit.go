@@ -0,0 +1,61 @@
+package gapi
+
+import (
+	"context"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"log/slog"
+	"math"
+	"simple-bank/internal/ratelimit"
+	"simple-bank/internal/tokens"
+	"strconv"
+	"time"
+)
+
+const (
+	retryAfterMetadata         = "retry-after"
+	rateLimitRemainingMetadata = "x-ratelimit-remaining"
+)
+
+// rateLimitInterceptor throttles methods by the route group of
+// methodsAccess with the buckets rateLimitMiddleware of the HTTP API uses,
+// a caller gets one budget over both transports. Authenticated callers are
+// keyed by their subject, everybody else by the peer address, so it must run
+// after authInterceptor. The limits are read on every call, they follow
+// configuration reloads.
+func rateLimitInterceptor(storage ratelimit.Storage, currentLimits func() ratelimit.RouteLimits) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		group := methodsAccess[info.FullMethod].group
+		limit := currentLimits().Group(group)
+		if limit.IsUnlimited() {
+			return handler(ctx, req)
+		}
+
+		result, err := storage.Take(ctx, rateLimitKey(ctx, group), limit)
+		if err != nil {
+			// an unavailable storage must not take the whole API down
+			slog.Error("rate limit storage failed", "err", err)
+			return handler(ctx, req)
+		}
+
+		header := metadata.Pairs(rateLimitRemainingMetadata, strconv.Itoa(result.Remaining))
+		if !result.Allowed {
+			retryAfter := max(time.Duration(math.Ceil(result.RetryAfter.Seconds()))*time.Second, time.Second)
+			header.Set(retryAfterMetadata, strconv.Itoa(int(retryAfter.Seconds())))
+			_ = grpc.SetHeader(ctx, header)
+			return nil, errRateLimited.withDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+		}
+		_ = grpc.SetHeader(ctx, header)
+
+		return handler(ctx, req)
+	}
+}
+
+func rateLimitKey(ctx context.Context, group string) string {
+	if payload, ok := ctx.Value(payloadKey{}).(*tokens.Payload); ok {
+		return group + ":user:" + payload.Subject
+	}
+	return group + ":ip:" + clientIP(ctx)
+}
@@ -0,0 +1,90 @@
+package gapi
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"simple-bank/internal/config"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/pb"
+	"testing"
+	"time"
+)
+
+func TestRateLimitInterceptor(t *testing.T) {
+	user, _ := randomUser(t)
+	other, _ := randomUser(t)
+	account := randomAccount(user.Username)
+
+	t.Run("public", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := mockdb.NewMockStore(ctrl)
+		store.EXPECT().GetUser(gomock.Any(), user.Username).Times(2).Return(db.User{}, pgx.ErrNoRows)
+		store.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).AnyTimes()
+
+		server := newConfiguredTestServer(t, store, func(cfg *config.Config) {
+			cfg.RateLimitPublic = "2/1m"
+		})
+
+		for range 2 {
+			_, err := server.users.LoginUser(context.Background(), &pb.LoginUserRequest{Username: user.Username, Password: "wrong-password"})
+			requireRPCError(t, err, codes.Unauthenticated, "invalid_credentials")
+		}
+
+		var header metadata.MD
+		_, err := server.users.LoginUser(context.Background(), &pb.LoginUserRequest{Username: user.Username, Password: "wrong-password"}, grpc.Header(&header))
+		st := requireRPCError(t, err, codes.ResourceExhausted, "rate_limited")
+		require.Equal(t, []string{"0"}, header.Get(rateLimitRemainingMetadata))
+		require.NotEmpty(t, header.Get(retryAfterMetadata))
+
+		var retryInfo *errdetails.RetryInfo
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.RetryInfo); ok {
+				retryInfo = info
+			}
+		}
+		require.NotNil(t, retryInfo)
+		require.GreaterOrEqual(t, retryInfo.GetRetryDelay().AsDuration(), time.Second)
+	})
+
+	t.Run("per_user", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := mockdb.NewMockStore(ctrl)
+		store.EXPECT().GetUser(gomock.Any(), user.Username).AnyTimes().Return(user, nil)
+		store.EXPECT().GetUser(gomock.Any(), other.Username).AnyTimes().Return(other, nil)
+		store.EXPECT().GetAccount(gomock.Any(), account.ID).Times(2).Return(account, nil)
+
+		server := newConfiguredTestServer(t, store, func(cfg *config.Config) {
+			cfg.RateLimitAccounts = "1/1m"
+		})
+
+		_, err := server.accounts.GetAccount(server.withAccessToken(t, user.Username), &pb.GetAccountRequest{Id: account.ID})
+		require.NoError(t, err)
+
+		_, err = server.accounts.GetAccount(server.withAccessToken(t, user.Username), &pb.GetAccountRequest{Id: account.ID})
+		requireRPCError(t, err, codes.ResourceExhausted, "rate_limited")
+
+		// the bucket of another user is untouched
+		_, err = server.accounts.GetAccount(server.withAccessToken(t, other.Username), &pb.GetAccountRequest{Id: account.ID})
+		requireRPCError(t, err, codes.PermissionDenied, "forbidden")
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := mockdb.NewMockStore(ctrl)
+		store.EXPECT().GetUser(gomock.Any(), user.Username).AnyTimes().Return(user, nil)
+		store.EXPECT().GetAccount(gomock.Any(), account.ID).Times(3).Return(account, nil)
+
+		server := newTestServer(t, store)
+		for range 3 {
+			_, err := server.accounts.GetAccount(server.withAccessToken(t, user.Username), &pb.GetAccountRequest{Id: account.ID})
+			require.NoError(t, err)
+		}
+	})
+}
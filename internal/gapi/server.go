@@ -0,0 +1,103 @@
+package gapi
+
+import (
+	"google.golang.org/grpc"
+	"log/slog"
+	"net"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/config"
+	"simple-bank/internal/db"
+	"simple-bank/internal/mail"
+	"simple-bank/internal/pb"
+	"simple-bank/internal/ratelimit"
+	"simple-bank/internal/security"
+	"simple-bank/internal/service"
+	"simple-bank/internal/tokens"
+	"sync/atomic"
+)
+
+// Server serves the gRPC API. It runs on its own port next to the HTTP API
+// and shares its store, tokens and policies.
+type Server struct {
+	pb.UnimplementedUserServiceServer
+	pb.UnimplementedAccountServiceServer
+	pb.UnimplementedTransferServiceServer
+
+	configs          *config.Watcher
+	store            db.Store
+	bank             *service.Service
+	tokensManager    tokens.Manager
+	rateLimitStorage ratelimit.Storage
+	limits           atomic.Pointer[ratelimit.RouteLimits]
+	mailer           mail.Mailer
+	auditRecorder    audit.Recorder
+	grpcServer       *grpc.Server
+}
+
+func NewServer(configs *config.Watcher, store db.Store, tokensManager tokens.Manager, rateLimitStorage ratelimit.Storage, mailer mail.Mailer, passwordPolicy security.PasswordPolicy, auditRecorder audit.Recorder) (*Server, error) {
+	server := &Server{
+		configs:          configs,
+		store:            store,
+		bank:             service.New(configs, store, tokensManager, mailer, passwordPolicy),
+		tokensManager:    tokensManager,
+		rateLimitStorage: rateLimitStorage,
+		mailer:           mailer,
+		auditRecorder:    auditRecorder,
+	}
+
+	limits, err := parseRouteLimits(configs.Current())
+	if err != nil {
+		return nil, err
+	}
+	server.limits.Store(&limits)
+	configs.Subscribe(func(cfg *config.Config) {
+		limits, err := parseRouteLimits(cfg)
+		if err != nil {
+			slog.Error("rate limits not reloaded", "err", err)
+			return
+		}
+		server.limits.Store(&limits)
+	})
+
+	server.grpcServer = grpc.NewServer(grpc.ChainUnaryInterceptor(
+		errorInterceptor,
+		authInterceptor(server.bank),
+		rateLimitInterceptor(rateLimitStorage, server.routeLimits),
+	))
+	pb.RegisterUserServiceServer(server.grpcServer, server)
+	pb.RegisterAccountServiceServer(server.grpcServer, server)
+	pb.RegisterTransferServiceServer(server.grpcServer, server)
+
+	return server, nil
+}
+
+func parseRouteLimits(cfg *config.Config) (ratelimit.RouteLimits, error) {
+	return ratelimit.ParseRouteLimits(cfg.RateLimitPublic, cfg.RateLimitAccounts, cfg.RateLimitTransfers)
+}
+
+func (s *Server) routeLimits() ratelimit.RouteLimits {
+	return *s.limits.Load()
+}
+
+// config is the current configuration, a reload publishes a new one.
+func (s *Server) config() *config.Config {
+	return s.configs.Current()
+}
+
+// Serve handles the connections accepted by listener until Stop is called.
+func (s *Server) Serve(listener net.Listener) error {
+	return s.grpcServer.Serve(listener)
+}
+
+func (s *Server) Start(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Stop lets the calls in progress finish.
+func (s *Server) Stop() {
+	s.grpcServer.GracefulStop()
+}
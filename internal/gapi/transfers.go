@@ -0,0 +1,83 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/codes"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/pb"
+	"simple-bank/internal/service"
+	"strconv"
+)
+
+func (s *Server) CreateTransfer(ctx context.Context, req *pb.CreateTransferRequest) (*pb.CreateTransferResponse, error) {
+	err := validateFields(
+		field{"from_account_id", req.GetFromAccountId(), "required,min=1"},
+		field{"to_account_id", req.GetToAccountId(), "required,min=1"},
+		field{"amount", req.GetAmount(), "required,gt=0"},
+		field{"currency", req.GetCurrency(), "required,currency"},
+		field{"totp_code", req.GetTotpCode(), "omitempty,len=6,numeric"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.bank.Transfer(ctx, service.TransferParams{
+		User:          getUser(ctx),
+		FromAccountID: req.GetFromAccountId(),
+		ToAccountID:   req.GetToAccountId(),
+		Amount:        req.GetAmount(),
+		Currency:      req.GetCurrency(),
+		TOTPCode:      req.GetTotpCode(),
+	})
+	if err != nil {
+		var rejected *service.RejectedTransferError
+		if errors.As(err, &rejected) {
+			return nil, s.rejectTransfer(ctx, req, rejected.Err)
+		}
+		return nil, err
+	}
+
+	s.recordTransfer(ctx, req, strconv.FormatInt(result.Transfer.ID, 10), audit.OutcomeSuccess, "")
+
+	return &pb.CreateTransferResponse{
+		Transfer:    convertTransfer(result.Transfer),
+		FromAccount: convertAccount(result.FromAccount),
+		ToAccount:   convertAccount(result.ToAccount),
+		FromEntry:   convertEntry(result.FromEntry),
+		ToEntry:     convertEntry(result.ToEntry),
+	}, nil
+}
+
+// rejectTransfer records a transfer that passed request validation but was
+// refused (denied) or failed, and returns the error to answer with.
+func (s *Server) rejectTransfer(ctx context.Context, req *pb.CreateTransferRequest, err error) error {
+	rpcErr := toRPCError(err)
+	outcome := audit.OutcomeFailure
+	if rpcErr.Code == codes.PermissionDenied {
+		outcome = audit.OutcomeDenied
+	}
+	s.recordTransfer(ctx, req, "", outcome, rpcErr.Reason)
+
+	return err
+}
+
+func (s *Server) recordTransfer(ctx context.Context, req *pb.CreateTransferRequest, transferID string, outcome audit.Outcome, reason string) {
+	diff := map[string]any{
+		"from_account_id": req.GetFromAccountId(),
+		"to_account_id":   req.GetToAccountId(),
+		"amount":          req.GetAmount(),
+		"currency":        req.GetCurrency(),
+	}
+	if reason != "" {
+		diff["reason"] = reason
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Action:     auditActionTransferCreate,
+		TargetType: auditTargetTransfer,
+		TargetID:   transferID,
+		Outcome:    outcome,
+		Diff:       diff,
+	})
+}
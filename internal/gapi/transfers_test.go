@@ -0,0 +1,166 @@
+package gapi
+
+import (
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/pb"
+	"simple-bank/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestServer_CreateTransfer(t *testing.T) {
+	user, _ := randomUser(t)
+	unverified := user
+	unverified.EmailVerifiedAt = time.Time{}
+
+	account1 := randomAccount(user.Username)
+	account1.Currency = utils.CurrencyUSD
+	account2 := randomAccount("other")
+	account2.Currency = utils.CurrencyUSD
+	account3 := randomAccount("other")
+	account3.Currency = utils.CurrencyEUR
+	frozen := randomAccount("other")
+	frozen.Currency = utils.CurrencyUSD
+	frozen.FrozenAt = time.Now()
+
+	const amount = int64(10)
+
+	testCases := []struct {
+		name       string
+		user       db.User
+		request    *pb.CreateTransferRequest
+		buildStubs func(store *mockdb.MockStore)
+		code       codes.Code
+		reason     string
+		outcome    audit.Outcome
+	}{
+		{
+			name:    "ok",
+			user:    user,
+			request: &pb.CreateTransferRequest{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: amount, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account2.ID).Times(1).Return(account2, nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), db.TransferTxParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: amount}).
+					Times(1).
+					Return(db.TransferTxResult{
+						Transfer:    db.Transfer{ID: 1, FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: amount},
+						FromAccount: account1,
+						ToAccount:   account2,
+						FromEntry:   db.Entry{ID: 1, AccountID: account1.ID, Amount: -amount},
+						ToEntry:     db.Entry{ID: 2, AccountID: account2.ID, Amount: amount},
+					}, nil)
+			},
+			code:    codes.OK,
+			outcome: audit.OutcomeSuccess,
+		},
+		{
+			name:    "not_owner",
+			user:    user,
+			request: &pb.CreateTransferRequest{FromAccountId: account2.ID, ToAccountId: account1.ID, Amount: amount, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account2.ID).Times(1).Return(account2, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code:    codes.PermissionDenied,
+			reason:  "forbidden",
+			outcome: audit.OutcomeDenied,
+		},
+		{
+			name:    "currency_mismatch",
+			user:    user,
+			request: &pb.CreateTransferRequest{FromAccountId: account1.ID, ToAccountId: account3.ID, Amount: amount, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account3.ID).Times(1).Return(account3, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code:   codes.FailedPrecondition,
+			reason: "currency_mismatch",
+		},
+		{
+			name:    "account_frozen",
+			user:    user,
+			request: &pb.CreateTransferRequest{FromAccountId: account1.ID, ToAccountId: frozen.ID, Amount: amount, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), frozen.ID).Times(1).Return(frozen, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code:   codes.FailedPrecondition,
+			reason: "account_frozen",
+		},
+		{
+			name:    "email_not_verified",
+			user:    unverified,
+			request: &pb.CreateTransferRequest{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: amount, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account2.ID).Times(1).Return(account2, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code:    codes.PermissionDenied,
+			reason:  "email_not_verified",
+			outcome: audit.OutcomeDenied,
+		},
+		{
+			name:    "step_up_required",
+			user:    user,
+			request: &pb.CreateTransferRequest{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: 1000, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account2.ID).Times(1).Return(account2, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.Username).Times(1).Return(db.UserTotp{ConfirmedAt: time.Now()}, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code:    codes.PermissionDenied,
+			reason:  "two_factor_required",
+			outcome: audit.OutcomeDenied,
+		},
+		{
+			name:    "invalid_amount",
+			user:    user,
+			request: &pb.CreateTransferRequest{FromAccountId: account1.ID, ToAccountId: account2.ID, Amount: -1, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+			},
+			code:   codes.InvalidArgument,
+			reason: "validation_failed",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(tc.user, nil)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			response, err := server.transfers.CreateTransfer(server.withAccessToken(t, user.Username), tc.request)
+			if tc.code == codes.OK {
+				require.NoError(t, err)
+				require.Equal(t, int64(1), response.GetTransfer().GetId())
+				require.Equal(t, -amount, response.GetFromEntry().GetAmount())
+				require.Equal(t, amount, response.GetToEntry().GetAmount())
+			} else {
+				requireRPCError(t, err, tc.code, tc.reason)
+			}
+
+			events := server.auditEvents()
+			if tc.outcome == "" {
+				require.Empty(t, events)
+				return
+			}
+			require.Len(t, events, 1)
+			require.Equal(t, auditActionTransferCreate, events[0].Action)
+			require.Equal(t, tc.outcome, events[0].Outcome)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	"simple-bank/internal/pb"
+	"simple-bank/internal/service"
+)
+
+func (s *Server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	err := validateFields(
+		field{"username", req.GetUsername(), "required,alphanum"},
+		field{"password", req.GetPassword(), "required,min=6"},
+		field{"full_name", req.GetFullName(), "required,min=6"},
+		field{"email", req.GetEmail(), "required,email"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.bank.CreateUser(ctx, service.CreateUserParams{
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+		FullName: req.GetFullName(),
+		Email:    req.GetEmail(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Actor:      user.Username,
+		Action:     auditActionUserCreate,
+		TargetType: auditTargetUser,
+		TargetID:   user.Username,
+		Outcome:    audit.OutcomeSuccess,
+		Diff: map[string]any{
+			"full_name": user.FullName,
+			"email":     user.Email,
+		},
+	})
+
+	return &pb.CreateUserResponse{User: convertUser(user)}, nil
+}
+
+// LoginUser goes through the login of the HTTP API, lockout included. Users
+// with two-factor authentication enabled are answered with a challenge that
+// LoginUserTwoFactor completes.
+func (s *Server) LoginUser(ctx context.Context, req *pb.LoginUserRequest) (*pb.LoginUserResponse, error) {
+	err := validateFields(
+		field{"username", req.GetUsername(), "required,alphanum"},
+		field{"password", req.GetPassword(), "required,min=6"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.bank.Login(ctx, service.LoginParams{
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+		ClientIP: clientIP(ctx),
+	})
+	if err != nil {
+		return nil, s.failLogin(ctx, req.GetUsername(), err)
+	}
+
+	if result.TwoFactorRequired {
+		challengeToken, expiresAt, err := s.bank.IssueLoginChallenge(result.User)
+		if err != nil {
+			return nil, err
+		}
+		return &pb.LoginUserResponse{
+			Challenge: &pb.LoginChallenge{
+				ChallengeToken: challengeToken,
+				ExpiresAt:      timestamppb.New(expiresAt),
+			},
+		}, nil
+	}
+
+	return s.completeLogin(ctx, result.User)
+}
+
+// LoginUserTwoFactor completes a login challenge like the HTTP API does,
+// wrong codes feed the same lockout.
+func (s *Server) LoginUserTwoFactor(ctx context.Context, req *pb.LoginUserTwoFactorRequest) (*pb.LoginUserResponse, error) {
+	codeRules := "omitempty,len=6,numeric"
+	if req.GetRecoveryCode() == "" {
+		codeRules = "required,len=6,numeric"
+	}
+	err := validateFields(
+		field{"challenge_token", req.GetChallengeToken(), "required"},
+		field{"code", req.GetCode(), codeRules},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.bank.VerifyLoginChallenge(ctx, req.GetChallengeToken())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.bank.LoginSecondFactor(ctx, service.LoginSecondFactorParams{
+		User:         user,
+		Code:         req.GetCode(),
+		RecoveryCode: req.GetRecoveryCode(),
+		ClientIP:     clientIP(ctx),
+	})
+	if err != nil {
+		return nil, s.failLogin(ctx, user.Username, err)
+	}
+
+	return s.completeLogin(ctx, user)
+}
+
+// completeLogin is the last step of a login, once every factor has been
+// verified.
+func (s *Server) completeLogin(ctx context.Context, user db.User) (*pb.LoginUserResponse, error) {
+	if err := s.bank.CompleteLogin(ctx, user, clientIP(ctx)); err != nil {
+		return nil, err
+	}
+
+	s.recordAudit(ctx, audit.Event{
+		Actor:      user.Username,
+		Action:     auditActionUserLogin,
+		TargetType: auditTargetUser,
+		TargetID:   user.Username,
+		Outcome:    audit.OutcomeSuccess,
+	})
+
+	accessToken, err := s.bank.CreateAccessToken(user.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.LoginUserResponse{
+		AccessToken: accessToken,
+		User:        convertUser(user),
+	}, nil
+}
+
+func (s *Server) GetCurrentUser(ctx context.Context, _ *pb.GetCurrentUserRequest) (*pb.GetCurrentUserResponse, error) {
+	return &pb.GetCurrentUserResponse{User: convertUser(getUser(ctx))}, nil
+}
+
+// failLogin returns the error to answer a rejected login with. Wrong
+// credentials, wrong second factors and locked users end up in the audit
+// log, the attempt itself is already recorded by the service. A locked user
+// is answered like a wrong password.
+func (s *Server) failLogin(ctx context.Context, username string, err error) error {
+	rpcErr := toRPCError(err)
+	if !rpcErr.Is(errInvalidCredentials) && !rpcErr.Is(errTwoFactorCodeInvalid) {
+		return err
+	}
+
+	outcome, reason := audit.OutcomeFailure, rpcErr.Reason
+	if errors.Is(err, service.ErrAccountLocked) {
+		outcome, reason = audit.OutcomeDenied, auditReasonAccountLocked
+	}
+	s.recordAudit(ctx, audit.Event{
+		Actor:      username,
+		Action:     auditActionUserLogin,
+		TargetType: auditTargetUser,
+		TargetID:   username,
+		Outcome:    outcome,
+		Diff:       map[string]any{"reason": reason},
+	})
+	return rpcErr
+}
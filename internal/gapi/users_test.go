@@ -0,0 +1,349 @@
+package gapi
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"simple-bank/internal/audit"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/pb"
+	"simple-bank/internal/security"
+	"simple-bank/internal/tokens"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_CreateUser(t *testing.T) {
+	user, password := randomUser(t)
+
+	t.Run("ok", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := mockdb.NewMockStore(ctrl)
+
+		store.EXPECT().
+			CreateUser(gomock.Any(), gomock.Cond[db.CreateUserParams](func(x db.CreateUserParams) bool {
+				_, err := security.ComparePasswordAndHash(password, x.HashedPassword, security.DefaultPasswordParams)
+				return x.Username == user.Username && x.Email == user.Email && err == nil
+			})).
+			Times(1).
+			Return(user, nil)
+		store.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+		store.EXPECT().CreateUserToken(gomock.Any(), gomock.Any()).Times(1).Return(db.UserToken{}, nil)
+
+		server := newTestServer(t, store)
+		response, err := server.users.CreateUser(context.Background(), &pb.CreateUserRequest{
+			Username: user.Username,
+			Password: password,
+			FullName: user.FullName,
+			Email:    user.Email,
+		})
+		require.NoError(t, err)
+		require.Equal(t, user.Username, response.GetUser().GetUsername())
+		require.Equal(t, user.Email, response.GetUser().GetEmail())
+
+		messages := server.sentMessages(t)
+		require.Len(t, messages, 1)
+		require.Equal(t, user.Email, messages[0].To)
+
+		events := server.auditEvents()
+		require.Len(t, events, 1)
+		require.Equal(t, auditActionUserCreate, events[0].Action)
+	})
+
+	t.Run("validation_failed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := mockdb.NewMockStore(ctrl)
+		store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
+
+		server := newTestServer(t, store)
+		_, err := server.users.CreateUser(context.Background(), &pb.CreateUserRequest{
+			Username: "not valid",
+			Password: password,
+			FullName: user.FullName,
+		})
+		st := requireRPCError(t, err, codes.InvalidArgument, "validation_failed")
+
+		var fields []string
+		for _, detail := range st.Details() {
+			if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+				for _, violation := range badRequest.GetFieldViolations() {
+					fields = append(fields, violation.GetField())
+				}
+			}
+		}
+		require.ElementsMatch(t, []string{"username", "email"}, fields)
+	})
+
+	t.Run("weak_password", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := mockdb.NewMockStore(ctrl)
+		store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
+
+		_, err := newTestServer(t, store).users.CreateUser(context.Background(), &pb.CreateUserRequest{
+			Username: user.Username,
+			Password: testBreachedPassword,
+			FullName: user.FullName,
+			Email:    user.Email,
+		})
+		requireRPCError(t, err, codes.InvalidArgument, "weak_password")
+	})
+
+	t.Run("already_exists", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		store := mockdb.NewMockStore(ctrl)
+		store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, &pgconn.PgError{Code: "23505"})
+
+		_, err := newTestServer(t, store).users.CreateUser(context.Background(), &pb.CreateUserRequest{
+			Username: user.Username,
+			Password: password,
+			FullName: user.FullName,
+			Email:    user.Email,
+		})
+		requireRPCError(t, err, codes.AlreadyExists, "already_exists")
+	})
+}
+
+func TestServer_LoginUser(t *testing.T) {
+	user, password := randomUser(t)
+
+	testCases := []struct {
+		name       string
+		password   string
+		buildStubs func(store *mockdb.MockStore)
+		check      func(t *testing.T, server *testServer, response *pb.LoginUserResponse, err error)
+	}{
+		{
+			name:     "ok",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.Username).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), gomock.Cond[db.CreateLoginAttemptParams](func(x db.CreateLoginAttemptParams) bool {
+						return x.Username == user.Username && x.Succeeded
+					})).
+					Times(1)
+			},
+			check: func(t *testing.T, server *testServer, response *pb.LoginUserResponse, err error) {
+				require.NoError(t, err)
+				require.Equal(t, user.Username, response.GetUser().GetUsername())
+
+				payload, err := server.tokensManager.VerifyToken(response.GetAccessToken())
+				require.NoError(t, err)
+				require.Equal(t, user.Username, payload.Subject)
+
+				events := server.auditEvents()
+				require.Len(t, events, 1)
+				require.Equal(t, audit.OutcomeSuccess, events[0].Outcome)
+			},
+		},
+		{
+			name:     "wrong_password",
+			password: "wrong-password",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+				store.EXPECT().RegisterFailedLogin(gomock.Any(), user.Username).Times(1).Return(db.User{FailedLoginAttempts: 1}, nil)
+				store.EXPECT().LockUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).Times(1)
+			},
+			check: func(t *testing.T, server *testServer, _ *pb.LoginUserResponse, err error) {
+				requireRPCError(t, err, codes.Unauthenticated, "invalid_credentials")
+				require.Equal(t, audit.OutcomeFailure, server.auditEvents()[0].Outcome)
+			},
+		},
+		{
+			name:     "unknown_user",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).Times(1)
+			},
+			check: func(t *testing.T, _ *testServer, _ *pb.LoginUserResponse, err error) {
+				requireRPCError(t, err, codes.Unauthenticated, "invalid_credentials")
+			},
+		},
+		{
+			name:     "locked",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				locked := user
+				locked.LockedUntil = time.Now().Add(time.Minute)
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(locked, nil)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).Times(1)
+			},
+			check: func(t *testing.T, server *testServer, _ *pb.LoginUserResponse, err error) {
+				st := requireRPCError(t, err, codes.Unauthenticated, "invalid_credentials")
+				for _, detail := range st.Details() {
+					_, retry := detail.(*errdetails.RetryInfo)
+					require.False(t, retry, "the lock isn't told")
+				}
+				require.Equal(t, audit.OutcomeDenied, server.auditEvents()[0].Outcome)
+			},
+		},
+		{
+			name:     "two_factor_enabled",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.Username).Times(1).Return(db.UserTotp{ConfirmedAt: time.Now()}, nil)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, server *testServer, response *pb.LoginUserResponse, err error) {
+				require.NoError(t, err)
+				require.Empty(t, response.GetAccessToken())
+				require.Nil(t, response.GetUser())
+				require.WithinDuration(t, time.Now().Add(server.config().TwoFactorChallengeDuration), response.GetChallenge().GetExpiresAt().AsTime(), time.Second)
+
+				payload, err := server.tokensManager.VerifyToken(response.GetChallenge().GetChallengeToken())
+				require.NoError(t, err)
+				require.Equal(t, tokens.AudienceTwoFactor, payload.Audience)
+				require.Empty(t, server.auditEvents())
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			response, err := server.users.LoginUser(context.Background(), &pb.LoginUserRequest{
+				Username: user.Username,
+				Password: tc.password,
+			})
+			tc.check(t, server, response, err)
+		})
+	}
+}
+
+func TestServer_LoginUserTwoFactor(t *testing.T) {
+	user, _ := randomUser(t)
+	key, err := security.GenerateTOTP("Simple Bank", user.Username)
+	require.NoError(t, err)
+	userTOTP := db.UserTotp{Username: user.Username, Secret: key.Secret, ConfirmedAt: time.Now()}
+
+	code, err := totp.GenerateCode(userTOTP.Secret, time.Now())
+	require.NoError(t, err)
+	oldCode, err := totp.GenerateCode(userTOTP.Secret, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	if oldCode == code {
+		t.Skip("codes collided")
+	}
+
+	testCases := []struct {
+		name       string
+		request    func(server *testServer) *pb.LoginUserTwoFactorRequest
+		buildStubs func(store *mockdb.MockStore)
+		check      func(t *testing.T, server *testServer, response *pb.LoginUserResponse, err error)
+	}{
+		{
+			name: "ok",
+			request: func(server *testServer) *pb.LoginUserTwoFactorRequest {
+				return &pb.LoginUserTwoFactorRequest{ChallengeToken: challengeToken(t, server, user), Code: code}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.Username).Times(1).Return(userTOTP, nil)
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(int64(1), nil)
+				store.EXPECT().
+					CreateLoginAttempt(gomock.Any(), gomock.Cond[db.CreateLoginAttemptParams](func(x db.CreateLoginAttemptParams) bool {
+						return x.Username == user.Username && x.Succeeded
+					})).
+					Times(1)
+			},
+			check: func(t *testing.T, server *testServer, response *pb.LoginUserResponse, err error) {
+				require.NoError(t, err)
+				require.Equal(t, user.Username, response.GetUser().GetUsername())
+
+				payload, err := server.tokensManager.VerifyToken(response.GetAccessToken())
+				require.NoError(t, err)
+				require.Equal(t, tokens.AudienceAccess, payload.Audience)
+				require.Equal(t, audit.OutcomeSuccess, server.auditEvents()[0].Outcome)
+			},
+		},
+		{
+			name: "wrong_code",
+			request: func(server *testServer) *pb.LoginUserTwoFactorRequest {
+				return &pb.LoginUserTwoFactorRequest{ChallengeToken: challengeToken(t, server, user), Code: oldCode}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.Username).Times(1).Return(userTOTP, nil)
+				store.EXPECT().RegisterFailedLogin(gomock.Any(), user.Username).Times(1).Return(db.User{FailedLoginAttempts: 1}, nil)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).Times(1)
+			},
+			check: func(t *testing.T, server *testServer, _ *pb.LoginUserResponse, err error) {
+				requireRPCError(t, err, codes.PermissionDenied, "two_factor_code_invalid")
+				require.Equal(t, audit.OutcomeFailure, server.auditEvents()[0].Outcome)
+			},
+		},
+		{
+			name: "access_token_as_challenge",
+			request: func(server *testServer) *pb.LoginUserTwoFactorRequest {
+				md, _ := metadata.FromOutgoingContext(server.withAccessToken(t, user.Username))
+				accessToken := strings.TrimPrefix(md.Get(authorizationMetadata)[0], "Bearer ")
+				return &pb.LoginUserTwoFactorRequest{ChallengeToken: accessToken, Code: code}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, _ *testServer, _ *pb.LoginUserResponse, err error) {
+				requireRPCError(t, err, codes.Unauthenticated, "token_invalid")
+			},
+		},
+		{
+			name: "missing_code",
+			request: func(server *testServer) *pb.LoginUserTwoFactorRequest {
+				return &pb.LoginUserTwoFactorRequest{ChallengeToken: challengeToken(t, server, user)}
+			},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, _ *testServer, _ *pb.LoginUserResponse, err error) {
+				requireRPCError(t, err, codes.InvalidArgument, "validation_failed")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			response, err := server.users.LoginUserTwoFactor(context.Background(), tc.request(server))
+			tc.check(t, server, response, err)
+		})
+	}
+}
+
+func challengeToken(t *testing.T, server *testServer, user db.User) string {
+	token, _, err := server.bank.IssueLoginChallenge(user)
+	require.NoError(t, err)
+	return token
+}
+
+func TestServer_GetCurrentUser(t *testing.T) {
+	user, _ := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+
+	server := newTestServer(t, store)
+	response, err := server.users.GetCurrentUser(server.withAccessToken(t, user.Username), &pb.GetCurrentUserRequest{})
+	require.NoError(t, err)
+	require.Equal(t, user.Username, response.GetUser().GetUsername())
+	require.True(t, response.GetUser().GetEmailVerified())
+}
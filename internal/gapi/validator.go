@@ -0,0 +1,79 @@
+package gapi
+
+import (
+	"fmt"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"simple-bank/internal/utils"
+)
+
+// validate checks request fields against the same rules the HTTP API binds
+// its requests with.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	_ = v.RegisterValidation("currency", func(fl validator.FieldLevel) bool {
+		return utils.IsSupportedCurrency(fl.Field().String())
+	})
+	return v
+}
+
+type field struct {
+	name  string
+	value any
+	rules string
+}
+
+// validateFields reports every field breaking its rules at once, as the
+// field violations of a BadRequest detail.
+func validateFields(fields ...field) error {
+	var violations []*errdetails.BadRequest_FieldViolation
+	for _, f := range fields {
+		err := validate.Var(f.value, f.rules)
+		if err == nil {
+			continue
+		}
+
+		validationErrs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return err
+		}
+		for _, fe := range validationErrs {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.name,
+				Description: violationDescription(fe),
+				Reason:      fe.Tag(),
+			})
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+	return errValidationFailed.withDetails(&errdetails.BadRequest{FieldViolations: violations})
+}
+
+func violationDescription(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "len":
+		return fmt.Sprintf("must be %s characters long", fe.Param())
+	case "email":
+		return "must be a valid email address"
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "numeric":
+		return "must contain only digits"
+	case "currency":
+		return "must be a supported currency"
+	}
+	return fmt.Sprintf("failed on the %q rule", fe.Tag())
+}
@@ -0,0 +1,94 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"mime"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const outboxFilePattern = "*.eml"
+
+// FileMailer drops every message into an outbox directory instead of
+// delivering it. It is meant for local development and tests.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	file, err := os.CreateTemp(m.dir, now.UTC().Format("20060102T150405.000000000-")+outboxFilePattern)
+	if err != nil {
+		return err
+	}
+
+	if _, err = file.Write(msg.encode(m.from, now)); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Messages reads the outbox back, oldest message first.
+func (m *FileMailer) Messages() ([]Message, error) {
+	paths, err := filepath.Glob(filepath.Join(m.dir, outboxFilePattern))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	messages := make([]Message, 0, len(paths))
+	for _, path := range paths {
+		msg, err := readMessage(path)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func readMessage(path string) (Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Message{}, err
+	}
+	defer file.Close()
+
+	parsed, err := netmail.ReadMessage(file)
+	if err != nil {
+		return Message{}, err
+	}
+
+	body, err := io.ReadAll(parsed.Body)
+	if err != nil {
+		return Message{}, err
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      parsed.Header.Get("To"),
+		Subject: subject,
+		Body:    string(body),
+	}, nil
+}
@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	mailer, err := NewFileMailer(t.TempDir(), "bank@example.com")
+	require.NoError(t, err)
+
+	messages, err := mailer.Messages()
+	require.NoError(t, err)
+	require.Empty(t, messages)
+
+	first := Message{To: "alice@example.com", Subject: "Vérifiez votre email", Body: "first"}
+	second := Message{To: "bob@example.com", Subject: "Reset", Body: "second\nline"}
+	require.NoError(t, mailer.Send(context.Background(), first))
+	require.NoError(t, mailer.Send(context.Background(), second))
+
+	messages, err = mailer.Messages()
+	require.NoError(t, err)
+	require.Equal(t, []Message{first, second}, messages)
+}
+
+func TestFileMailer_CanceledContext(t *testing.T) {
+	mailer, err := NewFileMailer(t.TempDir(), "bank@example.com")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, mailer.Send(ctx, Message{To: "alice@example.com"}), context.Canceled)
+
+	messages, err := mailer.Messages()
+	require.NoError(t, err)
+	require.Empty(t, messages)
+}
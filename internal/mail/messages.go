@@ -0,0 +1,37 @@
+package mail
+
+import (
+	"fmt"
+	"time"
+)
+
+// EmailVerification asks name to confirm their address by opening link.
+func EmailVerification(to, name, link string, expiresIn time.Duration) Message {
+	return Message{
+		To:      to,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nconfirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			name, link, expiresIn),
+	}
+}
+
+// PasswordReset sends name the link to set a new password.
+func PasswordReset(to, name, link string, expiresIn time.Duration) Message {
+	return Message{
+		To:      to,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nset a new password by opening the link below:\n\n%s\n\nThe link expires in %s. If you did not ask for it, ignore this email.\n",
+			name, link, expiresIn),
+	}
+}
+
+// AccountLocked warns name that repeated failed logins locked their account
+// until the given time.
+func AccountLocked(to, name string, until time.Time) Message {
+	return Message{
+		To:      to,
+		Subject: "Your account is temporarily locked",
+		Body: fmt.Sprintf("Hello %s,\n\nafter too many failed sign-in attempts your account is locked until %s.\n\nIf it wasn't you, change your password once the lock is over.\n",
+			name, until.UTC().Format(time.RFC1123)),
+	}
+}
@@ -0,0 +1,300 @@
+// Package migrate applies the schema migrations of db/migration. It keeps
+// the bookkeeping of golang-migrate, a single row in schema_migrations, so
+// databases migrated by the migrate CLI carry on where it left off.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+var (
+	// ErrDirty is returned when a previous migration failed halfway. The
+	// schema has to be repaired by hand, then the version forced.
+	ErrDirty = errors.New("database is dirty")
+	// ErrSchemaBehind is returned by Check when migrations are pending.
+	ErrSchemaBehind = errors.New("database schema is behind")
+)
+
+// advisoryLockID serializes migrations between instances starting at the
+// same time.
+var advisoryLockID = int64(crc32.ChecksumIEEE([]byte("simple-bank:schema_migrations")))
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Load reads the migrations of fsys, every version needs both an up and a
+// down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint]*Migration)
+	for _, file := range files {
+		match := migrationFile.FindStringSubmatch(file)
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name is not VERSION_NAME.(up|down).sql", file)
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("migration %s: invalid version", file)
+		}
+		content, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[uint(version)]
+		if !ok {
+			migration = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %s: version %d is also %s", file, version, migration.Name)
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int {
+		return int(a.Version) - int(b.Version)
+	})
+	return migrations, nil
+}
+
+type Migrator struct {
+	db           *sql.DB
+	migrations   []Migration
+	advisoryLock bool
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, advisoryLock: true}, nil
+}
+
+// NewSQLite is New for a SQLite database. It has no advisory locks, a
+// migration only waits for the write lock of the transaction it runs in.
+func NewSQLite(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	m, err := New(db, fsys)
+	if err != nil {
+		return nil, err
+	}
+	m.advisoryLock = false
+	return m, nil
+}
+
+// Latest is the version the migrations bring the schema to.
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the version of the schema, 0 before the first migration.
+func (m *Migrator) Version(ctx context.Context) (version uint, dirty bool, err error) {
+	err = m.withConn(ctx, false, func(conn *sql.Conn) error {
+		version, dirty, err = readVersion(ctx, conn)
+		return err
+	})
+	return
+}
+
+// Check fails unless every migration has been applied. A schema ahead of
+// the migrations is accepted, it is what an older instance sees during a
+// rolling deploy.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w at version %d", ErrDirty, version)
+	}
+	if version < m.Latest() {
+		return fmt.Errorf("%w: version %d, the binary needs %d", ErrSchemaBehind, version, m.Latest())
+	}
+	return nil
+}
+
+// Up applies the pending migrations and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+
+	err := m.withConn(ctx, true, func(conn *sql.Conn) error {
+		version, err := cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+			if err = run(ctx, conn, migration.Up, migration.Version); err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps migrations and returns them, latest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+
+	err := m.withConn(ctx, true, func(conn *sql.Conn) error {
+		version, err := cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		i := slices.IndexFunc(m.migrations, func(migration Migration) bool { return migration.Version == version })
+		if version != 0 && i < 0 {
+			return fmt.Errorf("version %d has no migration", version)
+		}
+		for ; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			var previous uint
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			if err = run(ctx, conn, migration.Down, previous); err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Force records version as applied and clean without running anything, it
+// is how a dirty database is released once repaired.
+func (m *Migrator) Force(ctx context.Context, version uint) error {
+	if version != 0 && !slices.ContainsFunc(m.migrations, func(migration Migration) bool { return migration.Version == version }) {
+		return fmt.Errorf("version %d has no migration", version)
+	}
+
+	return m.withConn(ctx, true, func(conn *sql.Conn) error {
+		return setVersion(ctx, conn, version, false)
+	})
+}
+
+// withConn runs fn on a connection of its own, advisory locks belong to a
+// session.
+func (m *Migrator) withConn(ctx context.Context, lock bool, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if lock && m.advisoryLock {
+		if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID); err != nil {
+			return fmt.Errorf("lock migrations: %w", err)
+		}
+		defer func() {
+			// a context canceled while migrating must still release the lock
+			_, unlockErr := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", advisoryLockID)
+			err = errors.Join(err, unlockErr)
+		}()
+	}
+
+	if _, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint NOT NULL PRIMARY KEY,
+		dirty boolean NOT NULL
+	)`); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func readVersion(ctx context.Context, q execQuerier) (uint, bool, error) {
+	var version int64
+	var dirty bool
+	err := q.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	// golang-migrate writes -1 for no version
+	return uint(max(version, 0)), dirty, err
+}
+
+func cleanVersion(ctx context.Context, q execQuerier) (uint, error) {
+	version, dirty, err := readVersion(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("%w at version %d, repair it then force the version", ErrDirty, version)
+	}
+	return version, nil
+}
+
+func setVersion(ctx context.Context, q execQuerier, version uint, dirty bool) error {
+	if _, err := q.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return err
+	}
+	if version == 0 {
+		return nil
+	}
+	_, err := q.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)", int64(version), dirty)
+	return err
+}
+
+// run moves the schema to version with query. The version is marked dirty
+// first, so a failure that outlives the transaction is not mistaken for a
+// clean schema.
+func run(ctx context.Context, conn *sql.Conn, query string, version uint) error {
+	if err := setVersion(ctx, conn, version, true); err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, query); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	if err = setVersion(ctx, tx, version, false); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
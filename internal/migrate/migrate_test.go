@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"simple-bank/db/migration"
+	sqlitemigration "simple-bank/db/sqlite/migration"
+	sqlitedb "simple-bank/internal/db/sqlite"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_add_users.up.sql":     {Data: []byte("CREATE TABLE users ();")},
+		"000002_add_users.down.sql":   {Data: []byte("DROP TABLE users;")},
+		"000001_init_schema.up.sql":   {Data: []byte("CREATE TABLE accounts ();")},
+		"000001_init_schema.down.sql": {Data: []byte("DROP TABLE accounts;")},
+	}
+
+	migrations, err := Load(fsys)
+	require.NoError(t, err)
+	require.Equal(t, []Migration{
+		{Version: 1, Name: "init_schema", Up: "CREATE TABLE accounts ();", Down: "DROP TABLE accounts;"},
+		{Version: 2, Name: "add_users", Up: "CREATE TABLE users ();", Down: "DROP TABLE users;"},
+	}, migrations)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	testCases := []struct {
+		name  string
+		fsys  fstest.MapFS
+		error string
+	}{
+		{
+			name: "missing_down",
+			fsys: fstest.MapFS{
+				"000001_init.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			error: "needs both an up and a down file",
+		},
+		{
+			name: "bad_name",
+			fsys: fstest.MapFS{
+				"init.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			error: "name is not VERSION_NAME",
+		},
+		{
+			name: "version_reused",
+			fsys: fstest.MapFS{
+				"000001_init.up.sql":    {Data: []byte("SELECT 1;")},
+				"000001_init.down.sql":  {Data: []byte("SELECT 1;")},
+				"000001_other.down.sql": {Data: []byte("SELECT 1;")},
+			},
+			error: "version 1 is also",
+		},
+		{
+			name: "version_zero",
+			fsys: fstest.MapFS{
+				"000000_init.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			error: "invalid version",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Load(tc.fsys)
+			require.ErrorContains(t, err, tc.error)
+		})
+	}
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	migrations, err := Load(migration.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		require.Equal(t, uint(i+1), m.Version, "versions follow each other")
+	}
+
+	migrator, err := New(nil, migration.FS)
+	require.NoError(t, err)
+	require.Equal(t, migrations[len(migrations)-1].Version, migrator.Latest())
+}
+
+func TestMigrator_SQLite(t *testing.T) {
+	ctx := context.Background()
+	sqlDB, err := sqlitedb.Open(ctx, filepath.Join(t.TempDir(), "bank.db"))
+	require.NoError(t, err)
+	defer sqlDB.Close()
+
+	migrator, err := NewSQLite(sqlDB, sqlitemigration.FS)
+	require.NoError(t, err)
+	require.ErrorIs(t, migrator.Check(ctx), ErrSchemaBehind)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, len(migrator.migrations))
+	require.NoError(t, migrator.Check(ctx))
+
+	reverted, err := migrator.Down(ctx, len(applied))
+	require.NoError(t, err)
+	require.Len(t, reverted, len(applied))
+
+	version, dirty, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	require.Zero(t, version)
+	require.False(t, dirty)
+}
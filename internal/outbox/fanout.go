@@ -0,0 +1,21 @@
+package outbox
+
+import "context"
+
+// Fanout publishes every event to each of its sinks in turn. A failure stops
+// the fan-out and the event is relayed again later, to all of them: every
+// sink has to cope with redeliveries anyway.
+type Fanout []Sink
+
+func NewFanout(sinks ...Sink) Fanout {
+	return sinks
+}
+
+func (f Fanout) Publish(ctx context.Context, event Event) error {
+	for _, sink := range f {
+		if err := sink.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package outbox
+
+import (
+	"context"
+	"log"
+)
+
+// LogSink only writes events to a logger. It is meant for local development.
+type LogSink struct {
+	logger *log.Logger
+}
+
+func NewLogSink(logger *log.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+func (s *LogSink) Publish(ctx context.Context, event Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.logger.Printf("outbox event %d %s %s/%s: %s", event.ID, event.Type, event.AggregateType, event.AggregateID, event.Payload)
+	return nil
+}
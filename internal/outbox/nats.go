@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/nats-io/nats.go"
+	"strconv"
+)
+
+// NATSSink publishes every event on "<subject prefix>.<event type>", e.g.
+// "bank.events.transfer.completed". Nats-Msg-Id is set to the event ID, so
+// a JetStream stream on those subjects drops redeliveries within its
+// duplicate window.
+type NATSSink struct {
+	conn          *nats.Conn
+	subjectPrefix string
+}
+
+func NewNATSSink(url, subjectPrefix string) (*NATSSink, error) {
+	conn, err := nats.Connect(url, nats.Name("simple-bank outbox relay"))
+	if err != nil {
+		return nil, err
+	}
+	return &NATSSink{conn: conn, subjectPrefix: subjectPrefix}, nil
+}
+
+// Publish flushes after every message: a plain publish only reaches the
+// client buffer, the flush round trip tells whether the server got it.
+func (s *NATSSink) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(s.subjectPrefix + "." + event.Type)
+	msg.Header.Set(nats.MsgIdHdr, strconv.FormatInt(event.ID, 10))
+	msg.Data = data
+
+	if err = s.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+	return s.conn.FlushWithContext(ctx)
+}
+
+func (s *NATSSink) Close() {
+	s.conn.Close()
+}
@@ -0,0 +1,34 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"simple-bank/internal/db"
+	"time"
+)
+
+// Event is a domain event as it is handed to a Sink. Delivery is at least
+// once, consumers deduplicate on ID.
+type Event struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type Sink interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+func newEvent(row db.Outbox) Event {
+	return Event{
+		ID:            row.ID,
+		AggregateType: row.AggregateType,
+		AggregateID:   row.AggregateID,
+		Type:          row.EventType,
+		Payload:       row.Payload,
+		CreatedAt:     row.CreatedAt,
+	}
+}
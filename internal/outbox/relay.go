@@ -0,0 +1,132 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"simple-bank/internal/db"
+	"time"
+)
+
+const (
+	// claimLease is how long a claimed event is hidden from other relays. An
+	// event whose relay dies mid-delivery is picked up again after it.
+	claimLease = time.Minute
+
+	minRetryBackoff = time.Second
+	maxRetryBackoff = 10 * time.Minute
+
+	cleanupInterval    = time.Hour
+	publishedRetention = 7 * 24 * time.Hour
+
+	maxErrorLength = 1024
+)
+
+// Relay delivers outbox events to a Sink. Only the oldest pending event of an
+// aggregate can be claimed, so the events of an aggregate are delivered one
+// at a time and in order, while a failing aggregate doesn't hold back the
+// others. An event is marked published only after the sink accepted it:
+// delivery is at least once.
+type Relay struct {
+	store        db.Store
+	sink         Sink
+	pollInterval time.Duration
+	batchSize    int32
+	lastCleanup  time.Time
+}
+
+func NewRelay(store db.Store, sink Sink, pollInterval time.Duration, batchSize int32) *Relay {
+	return &Relay{
+		store:        store,
+		sink:         sink,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		lastCleanup:  time.Now(),
+	}
+}
+
+// Run relays events until ctx is done. A batch that delivered anything is
+// followed by the next one right away, the next event of those aggregates
+// may be waiting.
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		claimed, err := r.RelayBatch(ctx)
+		if err != nil {
+			slog.Error("outbox relay failed", "err", err)
+		}
+		r.cleanupIfDue(ctx)
+
+		if claimed > 0 && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// RelayBatch claims one batch of events and delivers it. It returns how many
+// events were claimed, failed deliveries included.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	events, err := r.store.ClaimOutboxEvents(ctx, db.ClaimOutboxEventsParams{
+		LeaseUntil: time.Now().Add(claimLease),
+		RowLimit:   r.batchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		if err = r.deliver(ctx, event); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+// deliver only returns an error when the outcome couldn't be saved. The
+// lease then expires and the event is delivered again.
+func (r *Relay) deliver(ctx context.Context, event db.Outbox) error {
+	publishErr := r.sink.Publish(ctx, newEvent(event))
+	if publishErr == nil {
+		return r.store.MarkOutboxEventPublished(ctx, event.ID)
+	}
+
+	message := publishErr.Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+
+	return r.store.MarkOutboxEventFailed(ctx, db.MarkOutboxEventFailedParams{
+		ID:            event.ID,
+		LastError:     message,
+		NextAttemptAt: time.Now().Add(retryBackoff(event.Attempts)),
+	})
+}
+
+// retryBackoff doubles with every attempt, attempts counts the one that
+// just failed.
+func retryBackoff(attempts int32) time.Duration {
+	backoff := minRetryBackoff
+	for i := int32(1); i < attempts; i++ {
+		backoff *= 2
+		if backoff >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return backoff
+}
+
+func (r *Relay) cleanupIfDue(ctx context.Context) {
+	now := time.Now()
+	if now.Sub(r.lastCleanup) < cleanupInterval {
+		return
+	}
+	r.lastCleanup = now
+
+	if err := r.store.DeletePublishedOutboxEvents(ctx, now.Add(-publishedRetention)); err != nil {
+		slog.Error("outbox cleanup failed", "err", err)
+	}
+}
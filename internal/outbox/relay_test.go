@@ -0,0 +1,175 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/random"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testSink struct {
+	published []Event
+	fail      map[int64]error
+}
+
+func (s *testSink) Publish(_ context.Context, event Event) error {
+	if err := s.fail[event.ID]; err != nil {
+		return err
+	}
+	s.published = append(s.published, event)
+	return nil
+}
+
+func randomOutboxEvent(attempts int32) db.Outbox {
+	return db.Outbox{
+		ID:            random.Int64(1, 1000),
+		AggregateType: db.OutboxAggregateAccount,
+		AggregateID:   random.String(6),
+		EventType:     db.EventAccountCreated,
+		Payload:       json.RawMessage(`{"id":1}`),
+		Attempts:      attempts,
+		CreatedAt:     time.Now(),
+	}
+}
+
+func TestRelay_RelayBatch(t *testing.T) {
+	delivered := randomOutboxEvent(1)
+	failing := randomOutboxEvent(3)
+	failing.ID = delivered.ID + 1
+
+	testCases := []struct {
+		name       string
+		fail       map[int64]error
+		buildStubs func(store *mockdb.MockStore)
+		check      func(t *testing.T, sink *testSink, claimed int, err error)
+	}{
+		{
+			name: "published",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ClaimOutboxEvents(gomock.Any(), gomock.Cond[db.ClaimOutboxEventsParams](func(x db.ClaimOutboxEventsParams) bool {
+						return x.RowLimit == 10 && x.LeaseUntil.After(time.Now())
+					})).
+					Times(1).
+					Return([]db.Outbox{delivered}, nil)
+				store.EXPECT().
+					MarkOutboxEventPublished(gomock.Any(), gomock.Eq(delivered.ID)).
+					Times(1)
+			},
+			check: func(t *testing.T, sink *testSink, claimed int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 1, claimed)
+				require.Len(t, sink.published, 1)
+				require.Equal(t, newEvent(delivered), sink.published[0])
+			},
+		},
+		{
+			name: "failed delivery is retried later",
+			fail: map[int64]error{failing.ID: errors.New("connection refused")},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ClaimOutboxEvents(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.Outbox{delivered, failing}, nil)
+				store.EXPECT().
+					MarkOutboxEventPublished(gomock.Any(), gomock.Eq(delivered.ID)).
+					Times(1)
+				store.EXPECT().
+					MarkOutboxEventFailed(gomock.Any(), gomock.Cond[db.MarkOutboxEventFailedParams](func(x db.MarkOutboxEventFailedParams) bool {
+						retryIn := time.Until(x.NextAttemptAt)
+						return x.ID == failing.ID &&
+							x.LastError == "connection refused" &&
+							retryIn > 3*time.Second && retryIn <= 4*time.Second
+					})).
+					Times(1)
+			},
+			check: func(t *testing.T, sink *testSink, claimed int, err error) {
+				require.NoError(t, err)
+				require.Equal(t, 2, claimed)
+				require.Len(t, sink.published, 1)
+			},
+		},
+		{
+			name: "nothing pending",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ClaimOutboxEvents(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.Outbox{}, nil)
+			},
+			check: func(t *testing.T, sink *testSink, claimed int, err error) {
+				require.NoError(t, err)
+				require.Zero(t, claimed)
+				require.Empty(t, sink.published)
+			},
+		},
+		{
+			name: "outcome not saved",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ClaimOutboxEvents(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.Outbox{delivered, failing}, nil)
+				store.EXPECT().
+					MarkOutboxEventPublished(gomock.Any(), gomock.Eq(delivered.ID)).
+					Times(1).
+					Return(errors.New("connection reset"))
+			},
+			check: func(t *testing.T, sink *testSink, claimed int, err error) {
+				require.Error(t, err)
+				require.Equal(t, 2, claimed)
+				require.Len(t, sink.published, 1)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			sink := &testSink{fail: tc.fail}
+			claimed, err := NewRelay(store, sink, time.Second, 10).RelayBatch(context.Background())
+			tc.check(t, sink, claimed, err)
+		})
+	}
+}
+
+func TestRelay_TruncatesLastError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	event := randomOutboxEvent(1)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ClaimOutboxEvents(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return([]db.Outbox{event}, nil)
+	store.EXPECT().
+		MarkOutboxEventFailed(gomock.Any(), gomock.Cond[db.MarkOutboxEventFailedParams](func(x db.MarkOutboxEventFailedParams) bool {
+			return len(x.LastError) == maxErrorLength
+		})).
+		Times(1)
+
+	sink := &testSink{fail: map[int64]error{event.ID: errors.New(strings.Repeat("x", 2*maxErrorLength))}}
+	_, err := NewRelay(store, sink, time.Second, 10).RelayBatch(context.Background())
+	require.NoError(t, err)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	require.Equal(t, time.Second, retryBackoff(1))
+	require.Equal(t, 2*time.Second, retryBackoff(2))
+	require.Equal(t, 8*time.Second, retryBackoff(4))
+	require.Equal(t, maxRetryBackoff, retryBackoff(20))
+	require.Equal(t, maxRetryBackoff, retryBackoff(1000))
+}
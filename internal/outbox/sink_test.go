@@ -0,0 +1,73 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestHTTPSink_Publish(t *testing.T) {
+	event := newEvent(randomOutboxEvent(1))
+
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Equal(t, strconv.FormatInt(event.ID, 10), r.Header.Get("Idempotency-Key"))
+		require.Equal(t, event.Type, r.Header.Get("X-Event-Type"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(body, &received))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	require.NoError(t, NewHTTPSink(server.URL).Publish(context.Background(), event))
+	require.Equal(t, event.ID, received.ID)
+	require.Equal(t, event.Type, received.Type)
+	require.JSONEq(t, string(event.Payload), string(received.Payload))
+}
+
+func TestHTTPSink_PublishRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := NewHTTPSink(server.URL).Publish(context.Background(), newEvent(randomOutboxEvent(1)))
+	require.ErrorContains(t, err, "503")
+}
+
+func TestLogSink_Publish(t *testing.T) {
+	var buf bytes.Buffer
+	event := newEvent(randomOutboxEvent(1))
+
+	require.NoError(t, NewLogSink(log.New(&buf, "", 0)).Publish(context.Background(), event))
+	require.Contains(t, buf.String(), event.Type)
+	require.Contains(t, buf.String(), string(event.Payload))
+}
+
+func TestFanout_Publish(t *testing.T) {
+	event := newEvent(randomOutboxEvent(1))
+	first := &testSink{}
+	failing := &testSink{fail: map[int64]error{event.ID: errors.New("unavailable")}}
+	last := &testSink{}
+
+	require.NoError(t, NewFanout(first, last).Publish(context.Background(), event))
+	require.Len(t, first.published, 1)
+	require.Len(t, last.published, 1)
+
+	// the event is relayed again to every sink, the failing one included
+	require.Error(t, NewFanout(first, failing, last).Publish(context.Background(), event))
+	require.Len(t, first.published, 2)
+	require.Len(t, last.published, 1)
+}
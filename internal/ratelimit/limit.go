@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidLimit = errors.New("invalid rate limit")
+)
+
+const (
+	// staleAfter is how long a bucket can stay untouched before it is
+	// dropped. A bucket refills completely within its period, so dropping
+	// it doesn't change any outcome as long as periods are shorter.
+	staleAfter      = time.Hour
+	cleanupInterval = 10 * time.Minute
+	maxPeriod       = staleAfter
+)
+
+// Limit describes a token bucket: it holds at most Burst tokens and
+// is refilled with Rate tokens per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Unlimited is returned by ParseLimit for an empty value.
+var Unlimited = Limit{}
+
+func (l Limit) IsUnlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+// ParseLimit parses limits written as "<requests>/<period>", e.g. "5/1m".
+// The bucket size equals the number of requests.
+func ParseLimit(value string) (Limit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Unlimited, nil
+	}
+
+	requestsRaw, periodRaw, found := strings.Cut(value, "/")
+	if !found {
+		return Limit{}, fmt.Errorf("%w: %q, expected <requests>/<period>", ErrInvalidLimit, value)
+	}
+
+	requests, err := strconv.Atoi(requestsRaw)
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q, requests must be a positive number", ErrInvalidLimit, value)
+	}
+
+	period, err := time.ParseDuration(periodRaw)
+	if err != nil || period <= 0 || period > maxPeriod {
+		return Limit{}, fmt.Errorf("%w: %q, period must be a positive duration up to %s", ErrInvalidLimit, value, maxPeriod)
+	}
+
+	return Limit{
+		Rate:  float64(requests) / period.Seconds(),
+		Burst: requests,
+	}, nil
+}
+
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Storage keeps buckets by key. Implementations must be safe for
+// concurrent use.
+type Storage interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// newResult builds the outcome of a take from the amount of tokens left in
+// the bucket after refill and, when allowed, after consumption.
+func newResult(limit Limit, tokens float64, allowed bool) Result {
+	if allowed {
+		return Result{Allowed: true, Remaining: int(math.Floor(tokens))}
+	}
+
+	wait := math.Max(1-tokens, 0) / limit.Rate
+	return Result{
+		Allowed:    false,
+		Remaining:  0,
+		RetryAfter: time.Duration(wait * float64(time.Second)),
+	}
+}
+
+// Route groups throttled by both the HTTP and the gRPC API. A caller draws
+// from the same bucket of a group over either transport.
+const (
+	GroupPublic    = "public"
+	GroupUsers     = "users"
+	GroupAccounts  = "accounts"
+	GroupTransfers = "transfers"
+	GroupWebhooks  = "webhooks"
+)
+
+// RouteLimits are the configured limits of the route groups. Users share
+// the public limit, webhooks the accounts one.
+type RouteLimits struct {
+	Public    Limit
+	Accounts  Limit
+	Transfers Limit
+}
+
+// ParseRouteLimits parses the limits of the route groups, see ParseLimit.
+func ParseRouteLimits(public, accounts, transfers string) (RouteLimits, error) {
+	var limits RouteLimits
+	var err error
+	if limits.Public, err = ParseLimit(public); err != nil {
+		return limits, err
+	}
+	if limits.Accounts, err = ParseLimit(accounts); err != nil {
+		return limits, err
+	}
+	limits.Transfers, err = ParseLimit(transfers)
+	return limits, err
+}
+
+// Group returns the limit of a route group, unknown groups are unlimited.
+func (l RouteLimits) Group(group string) Limit {
+	switch group {
+	case GroupPublic, GroupUsers:
+		return l.Public
+	case GroupAccounts, GroupWebhooks:
+		return l.Accounts
+	case GroupTransfers:
+		return l.Transfers
+	default:
+		return Unlimited
+	}
+}
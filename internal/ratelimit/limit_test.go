@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected Limit
+		hasError bool
+	}{
+		{name: "empty", value: "", expected: Unlimited},
+		{name: "per_minute", value: "6/1m", expected: Limit{Rate: 0.1, Burst: 6}},
+		{name: "per_second", value: " 10/1s ", expected: Limit{Rate: 10, Burst: 10}},
+		{name: "no_period", value: "10", hasError: true},
+		{name: "zero_requests", value: "0/1m", hasError: true},
+		{name: "invalid_requests", value: "ten/1m", hasError: true},
+		{name: "invalid_period", value: "10/minute", hasError: true},
+		{name: "too_long_period", value: "10/2h", hasError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			limit, err := ParseLimit(tc.value)
+			if tc.hasError {
+				require.ErrorIs(t, err, ErrInvalidLimit)
+				return
+			}
+			require.NoError(t, err)
+			require.InDelta(t, tc.expected.Rate, limit.Rate, 1e-9)
+			require.Equal(t, tc.expected.Burst, limit.Burst)
+		})
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	limit := Limit{Rate: 0.5, Burst: 2}
+
+	result := newResult(limit, 1.7, true)
+	require.True(t, result.Allowed)
+	require.Equal(t, 1, result.Remaining)
+
+	result = newResult(limit, 0.5, false)
+	require.False(t, result.Allowed)
+	require.Equal(t, time.Second, result.RetryAfter)
+
+	result = newResult(limit, 1.5, false)
+	require.False(t, result.Allowed)
+	require.Zero(t, result.RetryAfter)
+}
+
+func TestRouteLimits(t *testing.T) {
+	limits, err := ParseRouteLimits("10/1m", "", "1/1s")
+	require.NoError(t, err)
+
+	require.Equal(t, limits.Public, limits.Group(GroupPublic))
+	require.Equal(t, limits.Public, limits.Group(GroupUsers))
+	require.True(t, limits.Group(GroupAccounts).IsUnlimited())
+	require.True(t, limits.Group(GroupWebhooks).IsUnlimited())
+	require.Equal(t, Limit{Rate: 1, Burst: 1}, limits.Group(GroupTransfers))
+	require.True(t, limits.Group("unknown").IsUnlimited())
+
+	_, err = ParseRouteLimits("10/1m", "nope", "")
+	require.ErrorIs(t, err, ErrInvalidLimit)
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// MemoryStorage keeps buckets in the process memory, so limits are not
+// shared between instances.
+type MemoryStorage struct {
+	mu          sync.Mutex
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		buckets:     make(map[string]*bucket),
+		lastCleanup: time.Now(),
+		now:         time.Now,
+	}
+}
+
+func (s *MemoryStorage) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastCleanup) > cleanupInterval {
+		s.cleanup(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	elapsed := math.Max(now.Sub(b.updatedAt).Seconds(), 0)
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return newResult(limit, b.tokens, false), nil
+	}
+
+	b.tokens--
+	return newResult(limit, b.tokens, true), nil
+}
+
+func (s *MemoryStorage) cleanup(now time.Time) {
+	deadline := now.Add(-staleAfter)
+	for key, b := range s.buckets {
+		if b.updatedAt.Before(deadline) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastCleanup = now
+}
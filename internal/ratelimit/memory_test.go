@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMemoryStorage_Take(t *testing.T) {
+	now := time.Now()
+	storage := NewMemoryStorage()
+	storage.now = func() time.Time { return now }
+
+	limit, err := ParseLimit("3/1m")
+	require.NoError(t, err)
+
+	for i := 2; i >= 0; i-- {
+		result, err := storage.Take(context.Background(), "key", limit)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, i, result.Remaining)
+	}
+
+	result, err := storage.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, 20*time.Second, result.RetryAfter)
+
+	result, err = storage.Take(context.Background(), "other_key", limit)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	now = now.Add(20 * time.Second)
+	result, err = storage.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+
+	now = now.Add(time.Hour)
+	result, err = storage.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 2, result.Remaining)
+}
+
+func TestMemoryStorage_Cleanup(t *testing.T) {
+	now := time.Now()
+	storage := NewMemoryStorage()
+	storage.now = func() time.Time { return now }
+
+	limit, err := ParseLimit("3/1m")
+	require.NoError(t, err)
+
+	_, err = storage.Take(context.Background(), "stale", limit)
+	require.NoError(t, err)
+
+	now = now.Add(staleAfter + cleanupInterval)
+	_, err = storage.Take(context.Background(), "fresh", limit)
+	require.NoError(t, err)
+
+	require.NotContains(t, storage.buckets, "stale")
+	require.Contains(t, storage.buckets, "fresh")
+}
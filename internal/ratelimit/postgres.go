@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"log/slog"
+	"simple-bank/internal/db"
+	"sync"
+	"time"
+)
+
+// PostgresStorage shares buckets between instances through the
+// rate_limit_buckets table. Refill and consumption are two separate atomic
+// statements: refill only depends on the time elapsed since the previous
+// update, so interleaving them with other instances is harmless.
+type PostgresStorage struct {
+	store       db.Store
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+func NewPostgresStorage(store db.Store) *PostgresStorage {
+	return &PostgresStorage{
+		store:       store,
+		lastCleanup: time.Now(),
+	}
+}
+
+func (s *PostgresStorage) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.cleanupIfDue(ctx)
+
+	tokens, err := s.store.RefillRateLimitBucket(ctx, db.RefillRateLimitBucketParams{
+		Key:   key,
+		Burst: float64(limit.Burst),
+		Rate:  limit.Rate,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	left, err := s.store.TakeRateLimitToken(ctx, key)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return newResult(limit, tokens, false), nil
+		}
+		return Result{}, err
+	}
+
+	return newResult(limit, left, true), nil
+}
+
+func (s *PostgresStorage) cleanupIfDue(ctx context.Context) {
+	s.mu.Lock()
+	now := time.Now()
+	due := now.Sub(s.lastCleanup) > cleanupInterval
+	if due {
+		s.lastCleanup = now
+	}
+	s.mu.Unlock()
+
+	if !due {
+		return
+	}
+
+	if err := s.store.DeleteStaleRateLimitBuckets(ctx, now.Add(-staleAfter)); err != nil {
+		slog.Error("rate limit cleanup failed", "err", err)
+	}
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"testing"
+	"time"
+)
+
+func TestPostgresStorage_Take(t *testing.T) {
+	limit, err := ParseLimit("3/1m")
+	require.NoError(t, err)
+
+	refillParams := db.RefillRateLimitBucketParams{
+		Key:   "key",
+		Burst: 3,
+		Rate:  limit.Rate,
+	}
+
+	testCases := []struct {
+		name        string
+		buildStubs  func(store *mockdb.MockStore)
+		checkResult func(t *testing.T, result Result, err error)
+	}{
+		{
+			name: "allowed",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RefillRateLimitBucket(gomock.Any(), gomock.Eq(refillParams)).
+					Times(1).
+					Return(float64(3), nil)
+				store.EXPECT().
+					TakeRateLimitToken(gomock.Any(), gomock.Eq("key")).
+					Times(1).
+					Return(float64(2), nil)
+			},
+			checkResult: func(t *testing.T, result Result, err error) {
+				require.NoError(t, err)
+				require.True(t, result.Allowed)
+				require.Equal(t, 2, result.Remaining)
+			},
+		},
+		{
+			name: "denied",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RefillRateLimitBucket(gomock.Any(), gomock.Eq(refillParams)).
+					Times(1).
+					Return(0.5, nil)
+				store.EXPECT().
+					TakeRateLimitToken(gomock.Any(), gomock.Eq("key")).
+					Times(1).
+					Return(float64(0), pgx.ErrNoRows)
+			},
+			checkResult: func(t *testing.T, result Result, err error) {
+				require.NoError(t, err)
+				require.False(t, result.Allowed)
+				require.Equal(t, 10*time.Second, result.RetryAfter)
+			},
+		},
+		{
+			name: "refill_error",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					RefillRateLimitBucket(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(float64(0), sql.ErrConnDone)
+				store.EXPECT().
+					TakeRateLimitToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResult: func(t *testing.T, result Result, err error) {
+				require.ErrorIs(t, err, sql.ErrConnDone)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			result, err := NewPostgresStorage(store).Take(context.Background(), "key", limit)
+			tc.checkResult(t, result, err)
+		})
+	}
+}
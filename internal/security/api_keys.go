@@ -0,0 +1,49 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	apiKeyTag         = "sbk"
+	apiKeyPrefixBytes = 6
+)
+
+// GenerateAPIKey returns a key of the form sbk_<prefix>_<secret>. The prefix
+// is not secret, it identifies the key in listings and lookups. Only the
+// HashOpaqueToken digest of the whole key should be stored.
+func GenerateAPIKey() (key, prefix string, err error) {
+	buf := make([]byte, apiKeyPrefixBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+
+	secret, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	prefix = apiKeyTag + "_" + hex.EncodeToString(buf)
+	return prefix + "_" + secret, prefix, nil
+}
+
+// ParseAPIKey extracts the prefix of a key made by GenerateAPIKey.
+func ParseAPIKey(key string) (prefix string, ok bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyTag || parts[2] == "" {
+		return "", false
+	}
+	if len(parts[1]) != hex.EncodedLen(apiKeyPrefixBytes) {
+		return "", false
+	}
+	return parts[0] + "_" + parts[1], true
+}
+
+// CompareOpaqueToken checks token against a HashOpaqueToken digest in
+// constant time.
+func CompareOpaqueToken(token, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashOpaqueToken(token)), []byte(hash)) == 1
+}
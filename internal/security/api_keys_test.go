@@ -0,0 +1,48 @@
+package security
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	key, prefix, err := GenerateAPIKey()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(key, prefix+"_"))
+	require.Len(t, prefix, len("sbk_")+12)
+
+	parsed, ok := ParseAPIKey(key)
+	require.True(t, ok)
+	require.Equal(t, prefix, parsed)
+
+	otherKey, otherPrefix, err := GenerateAPIKey()
+	require.NoError(t, err)
+	require.NotEqual(t, key, otherKey)
+	require.NotEqual(t, prefix, otherPrefix)
+}
+
+func TestParseAPIKey(t *testing.T) {
+	// the secret is base64url and may contain underscores itself
+	prefix, ok := ParseAPIKey("sbk_0123456789ab_sec_ret")
+	require.True(t, ok)
+	require.Equal(t, "sbk_0123456789ab", prefix)
+
+	for _, key := range []string{
+		"",
+		"sbk_0123456789ab",
+		"sbk_0123456789ab_",
+		"abc_0123456789ab_secret",
+		"sbk_short_secret",
+	} {
+		_, ok := ParseAPIKey(key)
+		require.False(t, ok, key)
+	}
+}
+
+func TestCompareOpaqueToken(t *testing.T) {
+	hash := HashOpaqueToken("test")
+	require.True(t, CompareOpaqueToken("test", hash))
+	require.False(t, CompareOpaqueToken("Test", hash))
+	require.False(t, CompareOpaqueToken("test", ""))
+}
@@ -0,0 +1,36 @@
+package security
+
+import (
+	"time"
+)
+
+const (
+	maxLockoutDuration = 24 * time.Hour
+)
+
+type LockoutPolicy struct {
+	MaxAttempts  int
+	BaseDuration time.Duration
+}
+
+func (p LockoutPolicy) Enabled() bool {
+	return p.MaxAttempts > 0 && p.BaseDuration > 0
+}
+
+// LockoutDuration returns for how long an account has to be locked after
+// the given amount of consecutive failed attempts. The duration doubles
+// with every failure past the threshold and is capped at one day.
+func (p LockoutPolicy) LockoutDuration(failedAttempts int) time.Duration {
+	if !p.Enabled() || failedAttempts < p.MaxAttempts {
+		return 0
+	}
+
+	duration := p.BaseDuration
+	for i := p.MaxAttempts; i < failedAttempts; i++ {
+		duration *= 2
+		if duration >= maxLockoutDuration {
+			return maxLockoutDuration
+		}
+	}
+	return duration
+}
@@ -0,0 +1,25 @@
+package security
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLockoutPolicy_LockoutDuration(t *testing.T) {
+	policy := LockoutPolicy{MaxAttempts: 3, BaseDuration: time.Minute}
+
+	require.Zero(t, policy.LockoutDuration(0))
+	require.Zero(t, policy.LockoutDuration(2))
+	require.Equal(t, time.Minute, policy.LockoutDuration(3))
+	require.Equal(t, 2*time.Minute, policy.LockoutDuration(4))
+	require.Equal(t, 4*time.Minute, policy.LockoutDuration(5))
+	require.Equal(t, maxLockoutDuration, policy.LockoutDuration(100))
+}
+
+func TestLockoutPolicy_Disabled(t *testing.T) {
+	policy := LockoutPolicy{}
+
+	require.False(t, policy.Enabled())
+	require.Zero(t, policy.LockoutDuration(100))
+}
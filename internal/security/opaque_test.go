@@ -0,0 +1,23 @@
+package security
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGenerateOpaqueToken(t *testing.T) {
+	token1, err := GenerateOpaqueToken()
+	require.NoError(t, err)
+	token2, err := GenerateOpaqueToken()
+	require.NoError(t, err)
+
+	require.Len(t, token1, 43)
+	require.NotEqual(t, token1, token2)
+}
+
+func TestHashOpaqueToken(t *testing.T) {
+	require.Equal(t,
+		"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		HashOpaqueToken("test"))
+	require.NotEqual(t, HashOpaqueToken("test"), HashOpaqueToken("Test"))
+}
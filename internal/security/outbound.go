@@ -0,0 +1,65 @@
+package security
+
+import (
+	"errors"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// ErrAddressNotAllowed is returned when dialing an address that isn't
+// public on behalf of a customer.
+var ErrAddressNotAllowed = errors.New("address is not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, cloud
+// providers use it for internal networks too.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// PublicAddress reports whether a request configured by a customer may reach
+// addr. Loopback, private, shared (CGNAT), link-local, unspecified and
+// multicast addresses are internal to the deployment.
+func PublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr) &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified()
+}
+
+// PublicDialControl is a net.Dialer Control hook refusing every address
+// PublicAddress rejects. It sees the address a name resolved to right before
+// connecting, a DNS answer changed after validation is refused as well.
+func PublicDialControl(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !PublicAddress(addrPort.Addr()) {
+		return ErrAddressNotAllowed
+	}
+	return nil
+}
+
+// ValidOutboundURL reports whether raw is an https URL a customer may point
+// requests at. Hosts given as an IP address must be public; names are only
+// checked when dialing, PublicDialControl has the final word.
+func ValidOutboundURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return PublicAddress(addr)
+	}
+	return true
+}
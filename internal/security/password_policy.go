@@ -0,0 +1,88 @@
+package security
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrPasswordTooShort = errors.New("password is too short")
+	ErrPasswordBreached = errors.New("password appears in a list of breached passwords")
+)
+
+type PasswordPolicy struct {
+	MinLength int
+	Breached  BreachedPasswords
+}
+
+// Check returns nil when password is acceptable for a new credential. The
+// length is counted in characters, not bytes.
+func (p PasswordPolicy) Check(password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("%w: at least %d characters are required", ErrPasswordTooShort, p.MinLength)
+	}
+	if p.Breached.Contains(password) {
+		return ErrPasswordBreached
+	}
+	return nil
+}
+
+// BreachedPasswords is a set of SHA-1 digests, the format breach corpora are
+// usually distributed in. A nil set contains nothing.
+type BreachedPasswords map[[sha1.Size]byte]struct{}
+
+func (b BreachedPasswords) Contains(password string) bool {
+	_, ok := b[sha1.Sum([]byte(password))]
+	return ok
+}
+
+// LoadBreachedPasswords reads a file with one entry per line. An entry is
+// either a plain password or an upper or lower case SHA-1 digest, optionally
+// followed by ":count" as in the Have I Been Pwned downloads. Empty lines
+// and lines starting with # are skipped.
+func LoadBreachedPasswords(path string) (BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	breached := make(BreachedPasswords)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		digest, ok := parseSHA1Entry(line)
+		if !ok {
+			digest = sha1.Sum([]byte(line))
+		}
+		breached[digest] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	return breached, nil
+}
+
+func parseSHA1Entry(line string) ([sha1.Size]byte, bool) {
+	var digest [sha1.Size]byte
+
+	hexDigest, _, _ := strings.Cut(line, ":")
+	if len(hexDigest) != hex.EncodedLen(sha1.Size) {
+		return digest, false
+	}
+	if _, err := hex.Decode(digest[:], []byte(hexDigest)); err != nil {
+		return digest, false
+	}
+	return digest, true
+}
@@ -0,0 +1,57 @@
+package security
+
+import (
+	"crypto/sha1"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPasswordPolicy_Check(t *testing.T) {
+	policy := PasswordPolicy{
+		MinLength: 8,
+		Breached:  BreachedPasswords{},
+	}
+	policy.Breached[sha1.Sum([]byte("password123"))] = struct{}{}
+
+	require.NoError(t, policy.Check("correct horse"))
+	require.NoError(t, policy.Check("пароль-ok"))
+	require.ErrorIs(t, policy.Check("short"), ErrPasswordTooShort)
+	// 12 bytes, but only 6 characters
+	require.ErrorIs(t, policy.Check("пароль"), ErrPasswordTooShort)
+	require.ErrorIs(t, policy.Check("password123"), ErrPasswordBreached)
+}
+
+func TestPasswordPolicy_Empty(t *testing.T) {
+	require.NoError(t, PasswordPolicy{}.Check(""))
+}
+
+func TestLoadBreachedPasswords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# comment\n" +
+		"qwerty\n" +
+		"\n" +
+		"  letmein  \n" +
+		// "password" in the Have I Been Pwned format
+		"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n" +
+		// "123456"
+		"7c4a8d09ca3762af61e59520943dc26494f8941b\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	breached, err := LoadBreachedPasswords(path)
+	require.NoError(t, err)
+	require.Len(t, breached, 4)
+
+	require.True(t, breached.Contains("qwerty"))
+	require.True(t, breached.Contains("letmein"))
+	require.True(t, breached.Contains("password"))
+	require.True(t, breached.Contains("123456"))
+	require.False(t, breached.Contains("# comment"))
+	require.False(t, breached.Contains("Password"))
+}
+
+func TestLoadBreachedPasswords_Missing(t *testing.T) {
+	_, err := LoadBreachedPasswords(filepath.Join(t.TempDir(), "missing.txt"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
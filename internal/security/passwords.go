@@ -1,25 +1,101 @@
 package security
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"github.com/alexedwards/argon2id"
+	"sync"
+)
+
+const (
+	passwordSaltLength = 16
+	passwordKeyLength  = 32
 )
 
 var (
 	ErrPasswordNotMatched = errors.New("password does not match")
 )
 
-func HashPassword(password string) (string, error) {
-	return argon2id.CreateHash(password, argon2id.DefaultParams)
+// PasswordParams are the argon2id cost parameters new hashes are created
+// with. Zero fields fall back to DefaultPasswordParams.
+type PasswordParams struct {
+	// Memory is in KiB.
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultPasswordParams pins the parallelism instead of deriving it from the
+// CPU count, otherwise hashes made on different machines would never agree
+// on being up to date.
+var DefaultPasswordParams = PasswordParams{
+	Memory:      64 * 1024,
+	Iterations:  1,
+	Parallelism: 2,
+}
+
+func (p PasswordParams) argon2() *argon2id.Params {
+	params := &argon2id.Params{
+		Memory:      p.Memory,
+		Iterations:  p.Iterations,
+		Parallelism: p.Parallelism,
+		SaltLength:  passwordSaltLength,
+		KeyLength:   passwordKeyLength,
+	}
+	if params.Memory == 0 {
+		params.Memory = DefaultPasswordParams.Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = DefaultPasswordParams.Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = DefaultPasswordParams.Parallelism
+	}
+	return params
+}
+
+func HashPassword(password string, params PasswordParams) (string, error) {
+	return argon2id.CreateHash(password, params.argon2())
 }
 
-func ComparePasswordAndHash(password, hash string) error {
-	matches, err := argon2id.ComparePasswordAndHash(password, hash)
+// ComparePasswordAndHash checks password against hash. On a match it also
+// reports whether hash was made with other parameters than params, in which
+// case the caller should store a fresh hash while it has the password.
+func ComparePasswordAndHash(password, hash string, params PasswordParams) (outdated bool, err error) {
+	matches, hashParams, err := argon2id.CheckHash(password, hash)
 	if err != nil {
-		return err
+		return false, err
 	}
 	if !matches {
-		return ErrPasswordNotMatched
+		return false, ErrPasswordNotMatched
 	}
-	return nil
+
+	want := params.argon2()
+	outdated = hashParams.Memory != want.Memory ||
+		hashParams.Iterations != want.Iterations ||
+		hashParams.Parallelism != want.Parallelism ||
+		hashParams.SaltLength != want.SaltLength ||
+		hashParams.KeyLength != want.KeyLength
+	return outdated, nil
+}
+
+// dummyHashes holds one hash per PasswordParams, a comparison against it
+// has to cost as much as one against a real hash.
+var dummyHashes sync.Map
+
+// CompareWithDummyHash spends as much time as ComparePasswordAndHash does
+// for an existing user, so that unknown usernames can't be told apart by
+// response time. It always returns ErrPasswordNotMatched.
+func CompareWithDummyHash(password string, params PasswordParams) error {
+	hash, ok := dummyHashes.Load(params)
+	if !ok {
+		secret := make([]byte, 16)
+		_, _ = rand.Read(secret)
+		newHash, _ := HashPassword(hex.EncodeToString(secret), params)
+		hash, _ = dummyHashes.LoadOrStore(params, newHash)
+	}
+
+	_, _ = argon2id.ComparePasswordAndHash(password, hash.(string))
+	return ErrPasswordNotMatched
 }
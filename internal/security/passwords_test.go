@@ -1,7 +1,6 @@
 package security
 
 import (
-	"fmt"
 	"github.com/stretchr/testify/require"
 	"simple-bank/internal/random"
 	"testing"
@@ -14,16 +13,58 @@ func TestComparePasswordAndHash(t *testing.T) {
 	invalidHashFormat := "notevenahashlol"
 	someOtherHash := "$argon2id$v=19$m=65536,t=1,p=12$wrxuS7K3poi+ooHcSt$argon2id$v=19$m=65536,t=1,p=12$wrxuS7K3poi+ooHcStNPUQ$AXTsuykut/CQecmvxXBcW57IvxyZslsWK99Y+a0iTWMNPUQ$AXTsuykut/CQecmvxXBcW57IvxyZslsWK99Y+a0iTWM"
 
-	require.NoError(t, ComparePasswordAndHash(rawPassword, hashedPassword))
-	require.Error(t, ComparePasswordAndHash(rawPassword, invalidHashFormat))
-	require.Error(t, ComparePasswordAndHash(rawPassword, someOtherHash))
+	_, err := ComparePasswordAndHash(rawPassword, hashedPassword, DefaultPasswordParams)
+	require.NoError(t, err)
+	_, err = ComparePasswordAndHash("wrong", hashedPassword, DefaultPasswordParams)
+	require.ErrorIs(t, err, ErrPasswordNotMatched)
+	_, err = ComparePasswordAndHash(rawPassword, invalidHashFormat, DefaultPasswordParams)
+	require.Error(t, err)
+	_, err = ComparePasswordAndHash(rawPassword, someOtherHash, DefaultPasswordParams)
+	require.Error(t, err)
+}
+
+func TestComparePasswordAndHash_Outdated(t *testing.T) {
+	rawPassword := random.String(6)
+	current := PasswordParams{Memory: 8 * 1024, Iterations: 2, Parallelism: 1}
+
+	hashedPassword, err := HashPassword(rawPassword, current)
+	require.NoError(t, err)
+
+	outdated, err := ComparePasswordAndHash(rawPassword, hashedPassword, current)
+	require.NoError(t, err)
+	require.False(t, outdated)
+
+	stronger := current
+	stronger.Iterations++
+	outdated, err = ComparePasswordAndHash(rawPassword, hashedPassword, stronger)
+	require.NoError(t, err)
+	require.True(t, outdated)
+
+	// a wrong password never asks for a rehash
+	outdated, err = ComparePasswordAndHash("wrong", hashedPassword, stronger)
+	require.ErrorIs(t, err, ErrPasswordNotMatched)
+	require.False(t, outdated)
 }
 
 func TestHashPassword(t *testing.T) {
 	rawPassword := random.String(6)
-	hashedPassword, err := HashPassword(rawPassword)
-	fmt.Println(hashedPassword)
+	hashedPassword, err := HashPassword(rawPassword, DefaultPasswordParams)
 	require.NoError(t, err)
 	require.NotEmpty(t, hashedPassword)
-	require.NoError(t, ComparePasswordAndHash(rawPassword, hashedPassword))
+	require.Contains(t, hashedPassword, "$m=65536,t=1,p=2$")
+
+	outdated, err := ComparePasswordAndHash(rawPassword, hashedPassword, DefaultPasswordParams)
+	require.NoError(t, err)
+	require.False(t, outdated)
+}
+
+func TestHashPassword_ZeroParams(t *testing.T) {
+	hashedPassword, err := HashPassword(random.String(6), PasswordParams{Iterations: 2})
+	require.NoError(t, err)
+	require.Contains(t, hashedPassword, "$m=65536,t=2,p=2$")
+}
+
+func TestCompareWithDummyHash(t *testing.T) {
+	require.ErrorIs(t, CompareWithDummyHash(random.String(6), DefaultPasswordParams), ErrPasswordNotMatched)
+	require.ErrorIs(t, CompareWithDummyHash("", DefaultPasswordParams), ErrPasswordNotMatched)
 }
@@ -0,0 +1,17 @@
+package security
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// example from RFC 7636, appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	require.Equal(t, challenge, PKCEChallenge(verifier))
+	require.True(t, VerifyPKCE(verifier, challenge))
+	require.False(t, VerifyPKCE(verifier+"x", challenge))
+	require.False(t, VerifyPKCE(verifier, verifier))
+}
@@ -0,0 +1,120 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod = 30
+	totpSkew   = 1
+
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryCodeLength   = 10
+
+	// RecoveryCodeKeySize is the minimum length of the RecoveryCodeLookup key.
+	RecoveryCodeKeySize = 32
+)
+
+var (
+	ErrTOTPCodeInvalid = errors.New("totp code is invalid")
+)
+
+type TOTPKey struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+func GenerateTOTP(issuer, accountName string) (TOTPKey, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: accountName,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return TOTPKey{}, err
+	}
+
+	return TOTPKey{
+		Secret:          key.Secret(),
+		ProvisioningURI: key.URL(),
+	}, nil
+}
+
+// ValidateTOTP checks code against secret allowing one period of clock
+// drift. It returns the time step the code belongs to; steps up to and
+// including lastUsedStep are rejected, so a code can't be replayed.
+func ValidateTOTP(code, secret string, now time.Time, lastUsedStep int64) (int64, error) {
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+
+		expected, err := hotp.GenerateCodeCustom(secret, uint64(step), hotp.ValidateOpts{
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, nil
+		}
+	}
+
+	return 0, ErrTOTPCodeInvalid
+}
+
+// GenerateRecoveryCodes returns n random single-use codes formatted as
+// "xxxxx-xxxxx". They are meant to be shown once and stored hashed.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	buf := make([]byte, recoveryCodeLength)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+
+		var code strings.Builder
+		for j, b := range buf {
+			if j == recoveryCodeLength/2 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+		}
+		codes[i] = code.String()
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode makes recovery codes case and dash insensitive.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	if len(code) != recoveryCodeLength {
+		return code
+	}
+	return code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+}
+
+// RecoveryCodeLookup returns the keyed digest a recovery code is stored and
+// found under, so verifying one costs a single password hash comparison.
+// Without the key a leaked digest does not help guessing the code.
+func RecoveryCodeLookup(key, code string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(NormalizeRecoveryCode(code)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,72 @@
+package security
+
+import (
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	key, err := GenerateTOTP("simple-bank", "user")
+	require.NoError(t, err)
+	require.NotEmpty(t, key.Secret)
+	require.True(t, strings.HasPrefix(key.ProvisioningURI, "otpauth://totp/"))
+
+	now := time.Now()
+	code, err := totp.GenerateCode(key.Secret, now)
+	require.NoError(t, err)
+
+	step, err := ValidateTOTP(code, key.Secret, now, 0)
+	require.NoError(t, err)
+	require.Equal(t, now.Unix()/totpPeriod, step)
+
+	// one period of drift is tolerated in both directions
+	_, err = ValidateTOTP(code, key.Secret, now.Add(totpPeriod*time.Second), 0)
+	require.NoError(t, err)
+	_, err = ValidateTOTP(code, key.Secret, now.Add(-totpPeriod*time.Second), 0)
+	require.NoError(t, err)
+
+	_, err = ValidateTOTP(code, key.Secret, now.Add(3*totpPeriod*time.Second), 0)
+	require.ErrorIs(t, err, ErrTOTPCodeInvalid)
+
+	// replay of an already used step
+	_, err = ValidateTOTP(code, key.Secret, now, step)
+	require.ErrorIs(t, err, ErrTOTPCodeInvalid)
+
+	_, err = ValidateTOTP("000000", key.Secret, now, 0)
+	if code != "000000" {
+		require.ErrorIs(t, err, ErrTOTPCodeInvalid)
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		require.Len(t, code, recoveryCodeLength+1)
+		require.Equal(t, code, NormalizeRecoveryCode(code))
+		require.False(t, seen[code])
+		seen[code] = true
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	require.Equal(t, "abcde-fghjk", NormalizeRecoveryCode(" ABCDE-FGHJK "))
+	require.Equal(t, "abcde-fghjk", NormalizeRecoveryCode("abcdefghjk"))
+	require.Equal(t, "abc", NormalizeRecoveryCode("abc"))
+}
+
+func TestRecoveryCodeLookup(t *testing.T) {
+	key := "0123456789abcdef0123456789abcdef"
+
+	lookup := RecoveryCodeLookup(key, "abcde-fghjk")
+	require.Len(t, lookup, 64)
+	require.Equal(t, lookup, RecoveryCodeLookup(key, " ABCDEFGHJK "))
+	require.NotEqual(t, lookup, RecoveryCodeLookup(key, "abcde-fghjm"))
+	require.NotEqual(t, lookup, RecoveryCodeLookup(key[1:]+"0", "abcde-fghjk"))
+}
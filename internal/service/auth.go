@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"log/slog"
+	"simple-bank/internal/db"
+	"simple-bank/internal/security"
+	"simple-bank/internal/tokens"
+	"strings"
+	"time"
+)
+
+// Authorization types both APIs accept, matched case-insensitively.
+const (
+	AuthorizationTypeBearer = "bearer"
+	AuthorizationTypeAPIKey = "apikey"
+)
+
+// Authenticate verifies a bearer access token or an API key and loads the
+// user it belongs to. Token failures come back as the errors of the tokens
+// package or ErrTokenRevoked.
+func (s *Service) Authenticate(ctx context.Context, authorizationType, credentials string) (*tokens.Payload, db.User, error) {
+	var payload *tokens.Payload
+	var err error
+	switch authorizationType = strings.ToLower(authorizationType); authorizationType {
+	case AuthorizationTypeBearer:
+		payload, err = s.verifyAccessToken(credentials)
+	case AuthorizationTypeAPIKey:
+		payload, err = s.verifyAPIKey(ctx, credentials)
+	default:
+		err = refuse(ErrUnauthorized, "unsupported authorization type: %s", authorizationType)
+	}
+	if err != nil {
+		return nil, db.User{}, err
+	}
+
+	user, err := s.store.GetUser(ctx, payload.Subject)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, db.User{}, tokens.ErrTokenInvalid
+		}
+		return nil, db.User{}, err
+	}
+
+	if TokenRevoked(payload, user) {
+		return nil, db.User{}, ErrTokenRevoked
+	}
+
+	return payload, user, nil
+}
+
+func (s *Service) verifyAccessToken(accessToken string) (*tokens.Payload, error) {
+	payload, err := s.tokensManager.VerifyToken(accessToken)
+	if err != nil {
+		return nil, TokenError(err)
+	}
+
+	// a login challenge only proves the password, it grants no access
+	if payload.Audience == tokens.AudienceTwoFactor {
+		return nil, tokens.ErrTokenInvalid
+	}
+
+	return payload, nil
+}
+
+// verifyAPIKey turns an API key into the payload an access token of its
+// owner would carry, restricted to the scopes of the key.
+func (s *Service) verifyAPIKey(ctx context.Context, key string) (*tokens.Payload, error) {
+	prefix, ok := security.ParseAPIKey(key)
+	if !ok {
+		return nil, tokens.ErrTokenInvalid
+	}
+
+	apiKey, err := s.store.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, tokens.ErrTokenInvalid
+		}
+		return nil, err
+	}
+
+	switch {
+	case !security.CompareOpaqueToken(key, apiKey.HashedSecret):
+		return nil, tokens.ErrTokenInvalid
+	case !apiKey.RevokedAt.IsZero():
+		return nil, ErrTokenRevoked
+	case !apiKey.ExpiresAt.IsZero() && time.Now().After(apiKey.ExpiresAt):
+		return nil, tokens.ErrTokenExpired
+	}
+
+	if err := s.store.TouchAPIKey(ctx, apiKey.ID); err != nil {
+		slog.Warn("touch api key", "prefix", apiKey.Prefix, "err", err)
+	}
+
+	return &tokens.Payload{
+		ID:        apiKey.ID,
+		Subject:   apiKey.Username,
+		Audience:  tokens.AudienceAPIKey,
+		Issuer:    tokens.Issuer,
+		NotBefore: apiKey.CreatedAt,
+		IssuedAt:  apiKey.CreatedAt,
+		ExpiredAt: apiKey.ExpiresAt,
+		Scopes:    apiKey.Scopes,
+	}, nil
+}
+
+// TokenRevoked reports whether a password change signed out the session the
+// payload belongs to. API keys are revoked one by one instead.
+func TokenRevoked(payload *tokens.Payload, user db.User) bool {
+	return payload.Audience != tokens.AudienceAPIKey && payload.IssuedAt.Before(user.PasswordChangedAt)
+}
+
+// TokenError narrows any verification failure down to the errors of the
+// tokens package, so that library-specific messages never reach the client.
+func TokenError(err error) error {
+	switch {
+	case errors.Is(err, tokens.ErrTokenExpired):
+		return tokens.ErrTokenExpired
+	case errors.Is(err, tokens.ErrTokenNotValidYet):
+		return tokens.ErrTokenNotValidYet
+	default:
+		return tokens.ErrTokenInvalid
+	}
+}
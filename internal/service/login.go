@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"log/slog"
+	"simple-bank/internal/db"
+	"simple-bank/internal/mail"
+	"simple-bank/internal/security"
+	"simple-bank/internal/tokens"
+	"time"
+)
+
+type LoginParams struct {
+	Username string
+	Password string
+	ClientIP string
+}
+
+type LoginResult struct {
+	User db.User
+	// TwoFactorRequired is set for users with two-factor authentication
+	// enabled, the login is only completed by LoginSecondFactor.
+	TwoFactorRequired bool
+}
+
+// Login checks the password of a user under the lockout policy, a failure
+// is recorded as a login attempt. A successful login is finished with
+// CompleteLogin once every factor has been verified.
+func (s *Service) Login(ctx context.Context, arg LoginParams) (LoginResult, error) {
+	user, err := s.store.GetUser(ctx, arg.Username)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return LoginResult{}, err
+		}
+
+		_ = security.CompareWithDummyHash(arg.Password, s.PasswordParams())
+		return LoginResult{}, s.failLogin(ctx, arg.Username, arg.ClientIP, ErrInvalidCredentials)
+	}
+
+	if err := s.CheckPassword(ctx, user, arg.Password, arg.ClientIP); err != nil {
+		return LoginResult{}, err
+	}
+
+	twoFactor, err := s.TwoFactorEnabled(ctx, user.Username)
+	if err != nil {
+		return LoginResult{}, err
+	}
+
+	return LoginResult{User: user, TwoFactorRequired: twoFactor}, nil
+}
+
+// CheckPassword verifies the password of user. Wrong passwords feed the
+// lockout whatever the operation, a password change included; a locked user
+// is refused like a wrong password, after as much hashing. A hash made with
+// outdated parameters is replaced.
+func (s *Service) CheckPassword(ctx context.Context, user db.User, password, clientIP string) error {
+	if s.locked(user) {
+		_ = security.CompareWithDummyHash(password, s.PasswordParams())
+		return s.failLogin(ctx, user.Username, clientIP, ErrAccountLocked)
+	}
+
+	outdated, err := security.ComparePasswordAndHash(password, user.HashedPassword, s.PasswordParams())
+	if err != nil {
+		if !errors.Is(err, security.ErrPasswordNotMatched) {
+			return err
+		}
+
+		if err := s.registerFailedLogin(ctx, user.Username); err != nil {
+			return err
+		}
+		return s.failLogin(ctx, user.Username, clientIP, ErrInvalidCredentials)
+	}
+
+	if outdated {
+		s.upgradePasswordHash(ctx, user, password)
+	}
+	return nil
+}
+
+type LoginSecondFactorParams struct {
+	User         db.User
+	Code         string
+	RecoveryCode string
+	ClientIP     string
+}
+
+// LoginSecondFactor verifies the TOTP code, or else the recovery code, of a
+// login whose password checked out. Wrong codes feed the lockout.
+func (s *Service) LoginSecondFactor(ctx context.Context, arg LoginSecondFactorParams) error {
+	if s.locked(arg.User) {
+		return s.failLogin(ctx, arg.User.Username, arg.ClientIP, ErrAccountLocked)
+	}
+
+	var err error
+	if arg.Code != "" {
+		err = s.verifyTOTPCode(ctx, arg.User.Username, arg.Code)
+	} else {
+		err = s.verifyRecoveryCode(ctx, arg.User.Username, arg.RecoveryCode)
+	}
+	if err != nil {
+		if !errors.Is(err, ErrTwoFactorCodeInvalid) {
+			return err
+		}
+
+		if err := s.registerFailedLogin(ctx, arg.User.Username); err != nil {
+			return err
+		}
+		return s.failLogin(ctx, arg.User.Username, arg.ClientIP, ErrTwoFactorCodeInvalid)
+	}
+	return nil
+}
+
+// IssueLoginChallenge returns the token a user with two-factor
+// authentication finishes the login with, and when it expires. It only
+// proves the password, Authenticate refuses it.
+func (s *Service) IssueLoginChallenge(user db.User) (string, time.Time, error) {
+	duration := s.config().TwoFactorChallengeDuration
+	challengeToken, err := s.tokensManager.CreateToken(tokens.PayloadCreationParams{
+		Subject:   user.Username,
+		Audience:  tokens.AudienceTwoFactor,
+		Issuer:    tokens.Issuer,
+		NotBefore: time.Now(),
+		Duration:  duration,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return challengeToken, time.Now().Add(duration), nil
+}
+
+// VerifyLoginChallenge loads the user a challenge token was issued to.
+// Token failures come back as the errors of the tokens package.
+func (s *Service) VerifyLoginChallenge(ctx context.Context, challengeToken string) (db.User, error) {
+	payload, err := s.tokensManager.VerifyToken(challengeToken)
+	if err != nil {
+		return db.User{}, TokenError(err)
+	}
+	if payload.Audience != tokens.AudienceTwoFactor {
+		return db.User{}, tokens.ErrTokenInvalid
+	}
+
+	return s.store.GetUser(ctx, payload.Subject)
+}
+
+// CompleteLogin is the last step of a login, once every factor has been
+// verified: the failed attempts are forgotten.
+func (s *Service) CompleteLogin(ctx context.Context, user db.User, clientIP string) error {
+	if user.FailedLoginAttempts > 0 {
+		if err := s.store.ResetFailedLogins(ctx, user.Username); err != nil {
+			return err
+		}
+	}
+
+	return s.recordLoginAttempt(ctx, user.Username, clientIP, true)
+}
+
+// CreateAccessToken issues the access token a completed login is answered
+// with.
+func (s *Service) CreateAccessToken(username string) (string, error) {
+	return s.tokensManager.CreateToken(tokens.PayloadCreationParams{
+		Subject:   username,
+		Audience:  tokens.AudienceAccess,
+		Issuer:    tokens.Issuer,
+		NotBefore: time.Now(),
+		Duration:  s.config().AccessTokenDuration,
+	})
+}
+
+// TwoFactorEnabled reports whether username confirmed a TOTP enrollment.
+func (s *Service) TwoFactorEnabled(ctx context.Context, username string) (bool, error) {
+	userTOTP, err := s.store.GetUserTOTP(ctx, username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return !userTOTP.ConfirmedAt.IsZero(), nil
+}
+
+func (s *Service) locked(user db.User) bool {
+	return time.Now().Before(user.LockedUntil)
+}
+
+func (s *Service) lockoutPolicy() security.LockoutPolicy {
+	cfg := s.config()
+	return security.LockoutPolicy{
+		MaxAttempts:  cfg.LoginMaxAttempts,
+		BaseDuration: cfg.LoginLockoutDuration,
+	}
+}
+
+// registerFailedLogin counts a wrong credential and locks the user once the
+// policy threshold is reached. Logins over both APIs feed the same counter.
+func (s *Service) registerFailedLogin(ctx context.Context, username string) error {
+	user, err := s.store.RegisterFailedLogin(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	lockFor := s.lockoutPolicy().LockoutDuration(int(user.FailedLoginAttempts))
+	if lockFor == 0 {
+		return nil
+	}
+
+	lockedUntil := time.Now().Add(lockFor)
+	if err := s.store.LockUser(ctx, db.LockUserParams{
+		Username:    username,
+		LockedUntil: lockedUntil,
+	}); err != nil {
+		return err
+	}
+
+	// the login answer doesn't tell about the lock, the owner learns it
+	// from their mailbox
+	if err := s.mailer.Send(ctx, mail.AccountLocked(user.Email, user.FullName, lockedUntil)); err != nil {
+		slog.Error("send account locked email", "username", username, "err", err)
+	}
+	return nil
+}
+
+// failLogin records a rejected login attempt and returns refusal.
+func (s *Service) failLogin(ctx context.Context, username, clientIP string, refusal error) error {
+	if err := s.recordLoginAttempt(ctx, username, clientIP, false); err != nil {
+		return err
+	}
+	return refusal
+}
+
+func (s *Service) recordLoginAttempt(ctx context.Context, username, clientIP string, succeeded bool) error {
+	_, err := s.store.CreateLoginAttempt(ctx, db.CreateLoginAttemptParams{
+		Username:  username,
+		ClientIp:  clientIP,
+		Succeeded: succeeded,
+	})
+	return err
+}
+
+// upgradePasswordHash replaces a hash made with outdated parameters. It is
+// best effort, the login goes on with the old hash if it fails, and it
+// leaves password_changed_at alone so no session gets revoked.
+func (s *Service) upgradePasswordHash(ctx context.Context, user db.User, password string) {
+	hashedPassword, err := security.HashPassword(password, s.PasswordParams())
+	if err == nil {
+		err = s.store.UpgradeUserPasswordHash(ctx, db.UpgradeUserPasswordHashParams{
+			NewHashedPassword: hashedPassword,
+			Username:          user.Username,
+			OldHashedPassword: user.HashedPassword,
+		})
+	}
+	if err != nil {
+		slog.Error("upgrade password hash", "username", user.Username, "err", err)
+	}
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"testing"
+	"time"
+)
+
+func TestService_Login(t *testing.T) {
+	user, password := randomUser(t)
+	locked := user
+	locked.LockedUntil = time.Now().Add(time.Minute)
+
+	failedAttempt := gomock.Cond[db.CreateLoginAttemptParams](func(x db.CreateLoginAttemptParams) bool {
+		return x.Username == user.Username && x.ClientIp == "10.0.0.1" && !x.Succeeded
+	})
+
+	testCases := []struct {
+		name       string
+		password   string
+		buildStubs func(store *mockdb.MockStore)
+		check      func(t *testing.T, bank *Service, result LoginResult, err error)
+	}{
+		{
+			name:     "ok",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.Username).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, _ *Service, result LoginResult, err error) {
+				require.NoError(t, err)
+				require.Equal(t, user.Username, result.User.Username)
+				require.False(t, result.TwoFactorRequired)
+			},
+		},
+		{
+			name:     "two_factor",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.Username).Times(1).Return(db.UserTotp{ConfirmedAt: time.Now()}, nil)
+			},
+			check: func(t *testing.T, _ *Service, result LoginResult, err error) {
+				require.NoError(t, err)
+				require.True(t, result.TwoFactorRequired)
+			},
+		},
+		{
+			name:     "unknown_user",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), failedAttempt).Times(1)
+			},
+			check: func(t *testing.T, _ *Service, _ LoginResult, err error) {
+				require.ErrorIs(t, err, ErrInvalidCredentials)
+			},
+		},
+		{
+			name:     "wrong_password",
+			password: "wrong-password",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+				store.EXPECT().RegisterFailedLogin(gomock.Any(), user.Username).Times(1).Return(db.User{FailedLoginAttempts: 1}, nil)
+				store.EXPECT().LockUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), failedAttempt).Times(1)
+			},
+			check: func(t *testing.T, _ *Service, _ LoginResult, err error) {
+				require.ErrorIs(t, err, ErrInvalidCredentials)
+			},
+		},
+		{
+			name:     "lockout_threshold",
+			password: "wrong-password",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(user, nil)
+				store.EXPECT().RegisterFailedLogin(gomock.Any(), user.Username).Times(1).Return(db.User{Email: user.Email, FailedLoginAttempts: 5}, nil)
+				store.EXPECT().
+					LockUser(gomock.Any(), gomock.Cond[db.LockUserParams](func(x db.LockUserParams) bool {
+						return x.Username == user.Username && x.LockedUntil.After(time.Now())
+					})).
+					Times(1)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), failedAttempt).Times(1)
+			},
+			check: func(t *testing.T, bank *Service, _ LoginResult, err error) {
+				require.ErrorIs(t, err, ErrInvalidCredentials)
+
+				// the owner is told about the lock by email only
+				mails := sentMails(t, bank)
+				require.Len(t, mails, 1)
+				require.Equal(t, user.Email, mails[0].To)
+			},
+		},
+		{
+			name:     "locked",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), user.Username).Times(1).Return(locked, nil)
+				store.EXPECT().RegisterFailedLogin(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), failedAttempt).Times(1)
+			},
+			check: func(t *testing.T, bank *Service, _ LoginResult, err error) {
+				require.ErrorIs(t, err, ErrAccountLocked)
+				require.ErrorIs(t, err, ErrInvalidCredentials, "a locked user is answered like a wrong password")
+				require.Empty(t, sentMails(t, bank))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			bank := newTestService(t, store)
+			result, err := bank.Login(context.Background(), LoginParams{
+				Username: user.Username,
+				Password: tc.password,
+				ClientIP: "10.0.0.1",
+			})
+			tc.check(t, bank, result, err)
+		})
+	}
+}
+
+func TestService_CompleteLogin(t *testing.T) {
+	user, _ := randomUser(t)
+	user.FailedLoginAttempts = 2
+
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().ResetFailedLogins(gomock.Any(), user.Username).Times(1)
+	store.EXPECT().
+		CreateLoginAttempt(gomock.Any(), db.CreateLoginAttemptParams{Username: user.Username, ClientIp: "10.0.0.1", Succeeded: true}).
+		Times(1)
+
+	require.NoError(t, newTestService(t, store).CompleteLogin(context.Background(), user, "10.0.0.1"))
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"github.com/stretchr/testify/require"
+	"simple-bank/internal/config"
+	"simple-bank/internal/db"
+	"simple-bank/internal/mail"
+	"simple-bank/internal/random"
+	"simple-bank/internal/security"
+	"simple-bank/internal/tokens"
+	"testing"
+	"time"
+)
+
+func newTestService(t *testing.T, store db.Store) *Service {
+	cfg := &config.Config{
+		TokenPrivateKey:         random.String(32),
+		RecoveryCodeKey:         random.String(32),
+		LoginMaxAttempts:        5,
+		LoginLockoutDuration:    time.Minute,
+		TransferStepUpThreshold: 1000,
+		PasswordMinLength:       8,
+		PasswordHashMemory:      security.DefaultPasswordParams.Memory,
+		PasswordHashIterations:  security.DefaultPasswordParams.Iterations,
+		PasswordHashParallelism: security.DefaultPasswordParams.Parallelism,
+	}
+
+	tokensManager, err := tokens.NewPasetoManager(cfg.TokenPrivateKey)
+	require.NoError(t, err)
+
+	mailer, err := mail.NewFileMailer(t.TempDir(), "test@simple-bank.local")
+	require.NoError(t, err)
+
+	passwordPolicy := security.PasswordPolicy{MinLength: cfg.PasswordMinLength}
+
+	return New(config.Fixed(cfg), store, tokensManager, mailer, passwordPolicy)
+}
+
+// sentMails reads back the emails a test service has sent.
+func sentMails(t *testing.T, bank *Service) []mail.Message {
+	messages, err := bank.mailer.(*mail.FileMailer).Messages()
+	require.NoError(t, err)
+	return messages
+}
+
+func randomUser(t *testing.T) (db.User, string) {
+	password := random.String(8)
+
+	hashedPassword, err := security.HashPassword(password, security.DefaultPasswordParams)
+	require.NoError(t, err)
+
+	return db.User{
+		Username:          random.String(6),
+		FullName:          random.String(8),
+		Email:             random.UserEmail(),
+		HashedPassword:    hashedPassword,
+		EmailVerifiedAt:   time.Now().Add(-time.Hour),
+		PasswordChangedAt: time.Now().Add(-time.Hour),
+	}, password
+}
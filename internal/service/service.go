@@ -0,0 +1,85 @@
+// Package service holds the rules the HTTP and the gRPC API share: sign-up
+// and the emails it sends, authentication, the login lockout and the checks a
+// transfer goes through.
+// The APIs map requests and errors, the decisions are taken here.
+package service
+
+import (
+	"errors"
+	"fmt"
+	"simple-bank/internal/config"
+	"simple-bank/internal/db"
+	"simple-bank/internal/mail"
+	"simple-bank/internal/security"
+	"simple-bank/internal/tokens"
+)
+
+// Refusals of the service. The APIs map each of them to an entry of their
+// error catalog, the detail of an Error wrapping one is safe to show.
+// ErrAccountLocked is an ErrInvalidCredentials: a locked user is answered
+// like a wrong password, only the audit log tells them apart.
+var (
+	ErrUnauthorized         = errors.New("unauthorized")
+	ErrTokenRevoked         = errors.New("token has been revoked")
+	ErrWeakPassword         = errors.New("password does not meet the password policy")
+	ErrInvalidCredentials   = errors.New("invalid credentials")
+	ErrAccountLocked        = fmt.Errorf("account is temporarily locked: %w", ErrInvalidCredentials)
+	ErrEmailNotVerified     = errors.New("email address is not verified")
+	ErrTwoFactorRequired    = errors.New("two-factor verification is required")
+	ErrTwoFactorCodeInvalid = errors.New("two-factor code is invalid")
+	ErrForbidden            = errors.New("forbidden")
+	ErrCurrencyMismatch     = errors.New("account currency does not match")
+	ErrAccountFrozen        = errors.New("account is frozen")
+)
+
+// Error is a refusal with a detail for the client.
+type Error struct {
+	Err    error
+	Detail string
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error() + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func refuse(err error, format string, args ...any) *Error {
+	return &Error{Err: err, Detail: fmt.Sprintf(format, args...)}
+}
+
+type Service struct {
+	configs        *config.Watcher
+	store          db.Store
+	tokensManager  tokens.Manager
+	mailer         mail.Mailer
+	passwordPolicy security.PasswordPolicy
+}
+
+func New(configs *config.Watcher, store db.Store, tokensManager tokens.Manager, mailer mail.Mailer, passwordPolicy security.PasswordPolicy) *Service {
+	return &Service{
+		configs:        configs,
+		store:          store,
+		tokensManager:  tokensManager,
+		mailer:         mailer,
+		passwordPolicy: passwordPolicy,
+	}
+}
+
+// config is the current configuration, read it once per use: a reload may
+// publish another one in between.
+func (s *Service) config() *config.Config {
+	return s.configs.Current()
+}
+
+// PasswordParams are the argon2 parameters new hashes are made with.
+func (s *Service) PasswordParams() security.PasswordParams {
+	cfg := s.config()
+	return security.PasswordParams{
+		Memory:      cfg.PasswordHashMemory,
+		Iterations:  cfg.PasswordHashIterations,
+		Parallelism: cfg.PasswordHashParallelism,
+	}
+}
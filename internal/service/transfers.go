@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"simple-bank/internal/db"
+)
+
+type TransferParams struct {
+	// User is the authenticated caller, it has to own the source account.
+	User          db.User
+	FromAccountID int64
+	ToAccountID   int64
+	Amount        int64
+	Currency      string
+	TOTPCode      string
+}
+
+// RejectedTransferError wraps the refusals and failures of a transfer whose
+// accounts checked out, the APIs keep a trace of those in the audit log.
+type RejectedTransferError struct {
+	Err error
+}
+
+func (e *RejectedTransferError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RejectedTransferError) Unwrap() error {
+	return e.Err
+}
+
+// Transfer moves money between two accounts of the same currency. The
+// caller has to own the source account and have a verified email address,
+// transfers at or above the step-up threshold need a TOTP code.
+func (s *Service) Transfer(ctx context.Context, arg TransferParams) (db.TransferTxResult, error) {
+	fromAccount, err := s.validateAccount(ctx, arg.FromAccountID, arg.Currency)
+	if err != nil {
+		return db.TransferTxResult{}, err
+	}
+
+	if arg.User.Username != fromAccount.Owner {
+		return db.TransferTxResult{}, reject(refuse(ErrForbidden, "you do not own account %d", arg.FromAccountID))
+	}
+
+	if _, err := s.validateAccount(ctx, arg.ToAccountID, arg.Currency); err != nil {
+		return db.TransferTxResult{}, err
+	}
+
+	if arg.User.EmailVerifiedAt.IsZero() {
+		return db.TransferTxResult{}, reject(refuse(ErrEmailNotVerified, "verify your email address before making transfers"))
+	}
+
+	if threshold := s.config().TransferStepUpThreshold; threshold > 0 && arg.Amount >= threshold {
+		if err := s.stepUpTransfer(ctx, arg.User, arg.TOTPCode, threshold); err != nil {
+			return db.TransferTxResult{}, reject(err)
+		}
+	}
+
+	result, err := s.store.TransferTx(ctx, db.TransferTxParams{
+		FromAccountID: arg.FromAccountID,
+		ToAccountID:   arg.ToAccountID,
+		Amount:        arg.Amount,
+	})
+	if err != nil {
+		// frozen since validateAccount, refused the same way
+		var frozen *db.AccountFrozenError
+		if errors.As(err, &frozen) {
+			return db.TransferTxResult{}, refuse(ErrAccountFrozen, "account [%d] is frozen", frozen.AccountID)
+		}
+		return db.TransferTxResult{}, reject(err)
+	}
+	return result, nil
+}
+
+// stepUpTransfer asks a TOTP code of a transfer at or above threshold. Users
+// without two-factor authentication are refused until they enable it, and
+// wrong codes feed the login lockout so the code can't be guessed here.
+func (s *Service) stepUpTransfer(ctx context.Context, user db.User, code string, threshold int64) error {
+	userTOTP, err := s.store.GetUserTOTP(ctx, user.Username)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+	if userTOTP.ConfirmedAt.IsZero() {
+		return refuse(ErrTwoFactorRequired, "transfers of %d or more require two-factor authentication, enable it first", threshold)
+	}
+	if code == "" {
+		return refuse(ErrTwoFactorRequired, "transfers of %d or more require a TOTP code", threshold)
+	}
+
+	// a locked user is refused like a wrong code, the code is not checked
+	if s.locked(user) {
+		return ErrTwoFactorCodeInvalid
+	}
+	if err := s.useTOTPCode(ctx, userTOTP, code); err != nil {
+		if !errors.Is(err, ErrTwoFactorCodeInvalid) {
+			return err
+		}
+		if err := s.registerFailedLogin(ctx, user.Username); err != nil {
+			return err
+		}
+		return ErrTwoFactorCodeInvalid
+	}
+	return nil
+}
+
+func reject(err error) error {
+	return &RejectedTransferError{Err: err}
+}
+
+func (s *Service) validateAccount(ctx context.Context, accountID int64, currency string) (db.Account, error) {
+	account, err := s.store.GetAccount(ctx, accountID)
+	if err != nil {
+		return account, err
+	}
+
+	if account.Currency != currency {
+		return account, refuse(ErrCurrencyMismatch, "account [%d] currency mismatched. expected: %s, actual: %s", accountID, currency, account.Currency)
+	}
+
+	if !account.FrozenAt.IsZero() {
+		return account, refuse(ErrAccountFrozen, "account [%d] is frozen", accountID)
+	}
+
+	return account, nil
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/security"
+	"simple-bank/internal/utils"
+	"testing"
+	"time"
+)
+
+func TestService_Transfer(t *testing.T) {
+	user, _ := randomUser(t)
+	unverified := user
+	unverified.EmailVerifiedAt = time.Time{}
+	locked := user
+	locked.LockedUntil = time.Now().Add(time.Minute)
+	userTOTP := confirmedUserTOTP(t, user.Username)
+
+	account1 := db.Account{ID: 1, Owner: user.Username, Balance: 100, Currency: utils.CurrencyUSD}
+	account2 := db.Account{ID: 2, Owner: "other", Balance: 100, Currency: utils.CurrencyUSD}
+	account3 := db.Account{ID: 3, Owner: "other", Balance: 100, Currency: utils.CurrencyEUR}
+	frozen := db.Account{ID: 4, Owner: "other", Balance: 100, Currency: utils.CurrencyUSD, FrozenAt: time.Now()}
+
+	testCases := []struct {
+		name       string
+		user       db.User
+		arg        TransferParams
+		buildStubs func(store *mockdb.MockStore)
+		err        error
+		rejected   bool
+	}{
+		{
+			name: "ok",
+			user: user,
+			arg:  TransferParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 10, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account2.ID).Times(1).Return(account2, nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), db.TransferTxParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 10}).
+					Times(1)
+			},
+		},
+		{
+			name: "not_owner",
+			user: user,
+			arg:  TransferParams{FromAccountID: account2.ID, ToAccountID: account1.ID, Amount: 10, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account2.ID).Times(1).Return(account2, nil)
+			},
+			err:      ErrForbidden,
+			rejected: true,
+		},
+		{
+			name: "currency_mismatch",
+			user: user,
+			arg:  TransferParams{FromAccountID: account1.ID, ToAccountID: account3.ID, Amount: 10, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account3.ID).Times(1).Return(account3, nil)
+			},
+			err: ErrCurrencyMismatch,
+		},
+		{
+			name: "account_frozen",
+			user: user,
+			arg:  TransferParams{FromAccountID: account1.ID, ToAccountID: frozen.ID, Amount: 10, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), frozen.ID).Times(1).Return(frozen, nil)
+			},
+			err: ErrAccountFrozen,
+		},
+		{
+			name: "frozen_during_transfer",
+			user: user,
+			arg:  TransferParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 10, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account2.ID).Times(1).Return(account2, nil)
+				store.EXPECT().
+					TransferTx(gomock.Any(), db.TransferTxParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 10}).
+					Times(1).
+					Return(db.TransferTxResult{}, &db.AccountFrozenError{AccountID: account2.ID})
+			},
+			err: ErrAccountFrozen,
+		},
+		{
+			name: "email_not_verified",
+			user: unverified,
+			arg:  TransferParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 10, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account2.ID).Times(1).Return(account2, nil)
+			},
+			err:      ErrEmailNotVerified,
+			rejected: true,
+		},
+		{
+			name: "step_up_required",
+			user: user,
+			arg:  TransferParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 1000, Currency: utils.CurrencyUSD},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account2.ID).Times(1).Return(account2, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.Username).Times(1).Return(db.UserTotp{ConfirmedAt: time.Now()}, nil)
+			},
+			err:      ErrTwoFactorRequired,
+			rejected: true,
+		},
+		{
+			name: "step_up_not_enrolled",
+			user: user,
+			arg:  TransferParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 1000, Currency: utils.CurrencyUSD, TOTPCode: "123456"},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account2.ID).Times(1).Return(account2, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.Username).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+			},
+			err:      ErrTwoFactorRequired,
+			rejected: true,
+		},
+		{
+			name: "step_up_invalid_code",
+			user: user,
+			arg:  TransferParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 1000, Currency: utils.CurrencyUSD, TOTPCode: otherTOTPCode(t, userTOTP.Secret)},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account2.ID).Times(1).Return(account2, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.Username).Times(1).Return(userTOTP, nil)
+				store.EXPECT().RegisterFailedLogin(gomock.Any(), user.Username).Times(1).Return(user, nil)
+			},
+			err:      ErrTwoFactorCodeInvalid,
+			rejected: true,
+		},
+		{
+			name: "step_up_locked",
+			user: locked,
+			arg:  TransferParams{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: 1000, Currency: utils.CurrencyUSD, TOTPCode: currentTOTPCode(t, userTOTP.Secret)},
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), account1.ID).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), account2.ID).Times(1).Return(account2, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), user.Username).Times(1).Return(userTOTP, nil)
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(0)
+			},
+			err:      ErrTwoFactorCodeInvalid,
+			rejected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+
+			tc.arg.User = tc.user
+			_, err := newTestService(t, store).Transfer(context.Background(), tc.arg)
+			if tc.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tc.err)
+
+			var rejected *RejectedTransferError
+			require.Equal(t, tc.rejected, errors.As(err, &rejected))
+		})
+	}
+}
+
+func confirmedUserTOTP(t *testing.T, username string) db.UserTotp {
+	key, err := security.GenerateTOTP("Simple Bank", username)
+	require.NoError(t, err)
+
+	return db.UserTotp{Username: username, Secret: key.Secret, ConfirmedAt: time.Now()}
+}
+
+func currentTOTPCode(t *testing.T, secret string) string {
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	return code
+}
+
+// otherTOTPCode returns a well-formed code that is outside the accepted
+// window.
+func otherTOTPCode(t *testing.T, secret string) string {
+	for past := time.Hour; ; past += time.Hour {
+		code, err := totp.GenerateCode(secret, time.Now().Add(-past))
+		require.NoError(t, err)
+		if code != currentTOTPCode(t, secret) {
+			return code
+		}
+	}
+}
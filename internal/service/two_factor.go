@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"simple-bank/internal/db"
+	"simple-bank/internal/security"
+	"time"
+)
+
+// verifyTOTPCode accepts a code only once: the time step it belongs to is
+// stored, and the conditional update fails for a concurrent replay.
+func (s *Service) verifyTOTPCode(ctx context.Context, username, code string) error {
+	userTOTP, err := s.store.GetUserTOTP(ctx, username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrTwoFactorRequired
+		}
+		return err
+	}
+	if userTOTP.ConfirmedAt.IsZero() {
+		return ErrTwoFactorRequired
+	}
+	return s.useTOTPCode(ctx, userTOTP, code)
+}
+
+// useTOTPCode checks code against a confirmed enrollment and spends its step.
+func (s *Service) useTOTPCode(ctx context.Context, userTOTP db.UserTotp, code string) error {
+	step, err := security.ValidateTOTP(code, userTOTP.Secret, time.Now(), userTOTP.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, security.ErrTOTPCodeInvalid) {
+			return ErrTwoFactorCodeInvalid
+		}
+		return err
+	}
+
+	updated, err := s.store.UseTOTPStep(ctx, db.UseTOTPStepParams{
+		Step:     step,
+		Username: userTOTP.Username,
+	})
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrTwoFactorCodeInvalid
+	}
+	return nil
+}
+
+// HashRecoveryCodes prepares freshly generated recovery codes for storage:
+// each is hashed like a password and indexed by its keyed lookup.
+func (s *Service) HashRecoveryCodes(codes []string) ([]db.RecoveryCodeHash, error) {
+	key := s.config().RecoveryCodeKey
+	hashed := make([]db.RecoveryCodeHash, len(codes))
+	for i, code := range codes {
+		hashedCode, err := security.HashPassword(code, s.PasswordParams())
+		if err != nil {
+			return nil, err
+		}
+		hashed[i] = db.RecoveryCodeHash{
+			HashedCode: hashedCode,
+			Lookup:     security.RecoveryCodeLookup(key, code),
+		}
+	}
+	return hashed, nil
+}
+
+// verifyRecoveryCode finds the code by its keyed lookup, so a guess costs at
+// most one password hash comparison instead of one per unused code.
+func (s *Service) verifyRecoveryCode(ctx context.Context, username, code string) error {
+	code = security.NormalizeRecoveryCode(code)
+	recoveryCode, err := s.store.GetUnusedRecoveryCode(ctx, db.GetUnusedRecoveryCodeParams{
+		Username: username,
+		Lookup:   security.RecoveryCodeLookup(s.config().RecoveryCodeKey, code),
+	})
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		recoveryCode, err = s.findLegacyRecoveryCode(ctx, username, code)
+		if err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if _, err := security.ComparePasswordAndHash(code, recoveryCode.HashedCode, s.PasswordParams()); err != nil {
+			return ErrTwoFactorCodeInvalid
+		}
+	}
+
+	used, err := s.store.UseRecoveryCode(ctx, recoveryCode.ID)
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return ErrTwoFactorCodeInvalid
+	}
+	return nil
+}
+
+// findLegacyRecoveryCode checks the codes issued before they had a lookup one
+// by one. Users who enrolled since have none and pay nothing for a miss.
+func (s *Service) findLegacyRecoveryCode(ctx context.Context, username, code string) (db.RecoveryCode, error) {
+	codes, err := s.store.ListUnusedRecoveryCodes(ctx, username)
+	if err != nil {
+		return db.RecoveryCode{}, err
+	}
+
+	for _, recoveryCode := range codes {
+		if recoveryCode.Lookup != "" {
+			continue
+		}
+		if _, err := security.ComparePasswordAndHash(code, recoveryCode.HashedCode, s.PasswordParams()); err == nil {
+			return recoveryCode, nil
+		}
+	}
+	return db.RecoveryCode{}, ErrTwoFactorCodeInvalid
+}
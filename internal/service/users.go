@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"simple-bank/internal/db"
+	"simple-bank/internal/mail"
+	"simple-bank/internal/security"
+	"strings"
+	"time"
+)
+
+type CreateUserParams struct {
+	Username string
+	Password string
+	FullName string
+	Email    string
+}
+
+// CreateUser signs a user up and sends the verification email.
+func (s *Service) CreateUser(ctx context.Context, arg CreateUserParams) (db.User, error) {
+	if err := s.CheckPasswordPolicy(arg.Password); err != nil {
+		return db.User{}, err
+	}
+
+	hashedPassword, err := security.HashPassword(arg.Password, s.PasswordParams())
+	if err != nil {
+		return db.User{}, err
+	}
+
+	user, err := s.store.CreateUser(ctx, db.CreateUserParams{
+		Username:       arg.Username,
+		HashedPassword: hashedPassword,
+		FullName:       arg.FullName,
+		Email:          arg.Email,
+	})
+	if err != nil {
+		return db.User{}, err
+	}
+
+	s.NotifyEmailVerification(ctx, user)
+	return user, nil
+}
+
+// CheckPasswordPolicy is applied to every password a user picks, existing
+// passwords keep working until they are changed.
+func (s *Service) CheckPasswordPolicy(password string) error {
+	if err := s.passwordPolicy.Check(password); err != nil {
+		return refuse(ErrWeakPassword, "%s", err)
+	}
+	return nil
+}
+
+// SendEmailVerification mails a link confirming the email address of user.
+func (s *Service) SendEmailVerification(ctx context.Context, user db.User) error {
+	duration := s.config().EmailVerificationDuration
+	token, err := s.issueUserToken(ctx, user.Username, db.UserTokenPurposeEmailVerification, duration)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.EmailVerification(user.Email, user.FullName,
+		s.userTokenLink("/verify-email", token), duration))
+}
+
+// NotifyEmailVerification is best effort: the change it follows has been
+// saved either way and the verification email can be requested again.
+func (s *Service) NotifyEmailVerification(ctx context.Context, user db.User) {
+	if err := s.SendEmailVerification(ctx, user); err != nil {
+		slog.Error("send email verification", "username", user.Username, "err", err)
+	}
+}
+
+// SendPasswordReset mails a link to pick a new password to user.
+func (s *Service) SendPasswordReset(ctx context.Context, user db.User) error {
+	duration := s.config().PasswordResetDuration
+	token, err := s.issueUserToken(ctx, user.Username, db.UserTokenPurposePasswordReset, duration)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mail.PasswordReset(user.Email, user.FullName,
+		s.userTokenLink("/reset-password", token), duration))
+}
+
+// issueUserToken revokes the outstanding tokens of the same purpose, so only
+// the most recent link sent to the user works.
+func (s *Service) issueUserToken(ctx context.Context, username, purpose string, duration time.Duration) (string, error) {
+	token, err := security.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.store.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+		Username: username,
+		Purpose:  purpose,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.store.CreateUserToken(ctx, db.CreateUserTokenParams{
+		Username:    username,
+		Purpose:     purpose,
+		HashedToken: security.HashOpaqueToken(token),
+		ExpiresAt:   time.Now().Add(duration),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *Service) userTokenLink(path, token string) string {
+	return strings.TrimSuffix(s.config().AppBaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
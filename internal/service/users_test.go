@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/security"
+	"testing"
+)
+
+func TestService_CreateUser(t *testing.T) {
+	user, password := randomUser(t)
+	arg := CreateUserParams{
+		Username: user.Username,
+		Password: password,
+		FullName: user.FullName,
+		Email:    user.Email,
+	}
+
+	testCases := []struct {
+		name       string
+		password   string
+		buildStubs func(store *mockdb.MockStore)
+		check      func(t *testing.T, bank *Service, created db.User, err error)
+	}{
+		{
+			name:     "ok",
+			password: password,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					CreateUser(gomock.Any(), gomock.Cond[db.CreateUserParams](func(x db.CreateUserParams) bool {
+						_, err := security.ComparePasswordAndHash(password, x.HashedPassword, security.DefaultPasswordParams)
+						return x.Username == user.Username && x.Email == user.Email && err == nil
+					})).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RevokeUserTokens(gomock.Any(), db.RevokeUserTokensParams{Username: user.Username, Purpose: db.UserTokenPurposeEmailVerification}).
+					Times(1)
+				store.EXPECT().CreateUserToken(gomock.Any(), gomock.Any()).Times(1)
+			},
+			check: func(t *testing.T, bank *Service, created db.User, err error) {
+				require.NoError(t, err)
+				require.Equal(t, user, created)
+
+				mails := sentMails(t, bank)
+				require.Len(t, mails, 1)
+				require.Equal(t, user.Email, mails[0].To)
+				require.Contains(t, mails[0].Body, "/verify-email?token=")
+			},
+		},
+		{
+			name:     "weak_password",
+			password: "short",
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			check: func(t *testing.T, bank *Service, _ db.User, err error) {
+				require.ErrorIs(t, err, ErrWeakPassword)
+				require.Empty(t, sentMails(t, bank))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			bank := newTestService(t, store)
+			arg := arg
+			arg.Password = tc.password
+			created, err := bank.CreateUser(context.Background(), arg)
+			tc.check(t, bank, created, err)
+		})
+	}
+}
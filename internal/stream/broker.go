@@ -0,0 +1,65 @@
+package stream
+
+import "sync"
+
+// Broker wakes up the streams of a user when there is something new for
+// them. A wake-up carries no data: streams read what they missed from the
+// store, so a wake-up that is dropped because one is already pending loses
+// nothing.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: make(map[string]map[chan struct{}]struct{})}
+}
+
+// Subscribe registers a stream of owner. The returned function unregisters
+// it and must be called once the stream ends.
+func (b *Broker) Subscribe(owner string) (<-chan struct{}, func()) {
+	wake := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers[owner] == nil {
+		b.subscribers[owner] = make(map[chan struct{}]struct{})
+	}
+	b.subscribers[owner][wake] = struct{}{}
+
+	return wake, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[owner], wake)
+		if len(b.subscribers[owner]) == 0 {
+			delete(b.subscribers, owner)
+		}
+	}
+}
+
+// Notify wakes up every stream of owner.
+func (b *Broker) Notify(owner string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for wake := range b.subscribers[owner] {
+		signal(wake)
+	}
+}
+
+// NotifyAll wakes up every stream, after notifications may have been lost.
+func (b *Broker) NotifyAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, subscribers := range b.subscribers {
+		for wake := range subscribers {
+			signal(wake)
+		}
+	}
+}
+
+func signal(wake chan struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,43 @@
+package stream
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func requireWoken(t *testing.T, wake <-chan struct{}, woken bool) {
+	select {
+	case <-wake:
+		require.True(t, woken, "unexpected wake-up")
+	default:
+		require.False(t, woken, "no wake-up")
+	}
+}
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker()
+
+	alice1, unsubscribe := broker.Subscribe("alice")
+	alice2, _ := broker.Subscribe("alice")
+	bob, _ := broker.Subscribe("bob")
+
+	broker.Notify("alice")
+	// pending wake-ups are coalesced
+	broker.Notify("alice")
+	requireWoken(t, alice1, true)
+	requireWoken(t, alice1, false)
+	requireWoken(t, alice2, true)
+	requireWoken(t, bob, false)
+
+	unsubscribe()
+	broker.Notify("alice")
+	requireWoken(t, alice1, false)
+	requireWoken(t, alice2, true)
+
+	broker.NotifyAll()
+	requireWoken(t, alice2, true)
+	requireWoken(t, bob, true)
+
+	// nobody listens
+	broker.Notify("carol")
+}
@@ -0,0 +1,72 @@
+package stream
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"log/slog"
+	"simple-bank/internal/db"
+	"time"
+)
+
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+)
+
+// PostgresListener feeds a Broker from the account events channel. It holds
+// a connection of its own, outside of the pool.
+type PostgresListener struct {
+	dataSource string
+	broker     *Broker
+}
+
+func NewPostgresListener(dataSource string, broker *Broker) *PostgresListener {
+	return &PostgresListener{dataSource: dataSource, broker: broker}
+}
+
+// Run listens until ctx is done, a lost connection is reopened with a
+// backoff that doubles up to maxReconnectInterval.
+func (l *PostgresListener) Run(ctx context.Context) error {
+	backoff := minReconnectInterval
+	for {
+		listened, err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if listened {
+			backoff = minReconnectInterval
+		}
+		slog.Error("account events listener", "err", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(2*backoff, maxReconnectInterval)
+	}
+}
+
+// listen forwards notifications until the connection fails, listened tells
+// whether LISTEN went through first.
+func (l *PostgresListener) listen(ctx context.Context) (listened bool, err error) {
+	conn, err := pgx.Connect(ctx, l.dataSource)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{db.AccountEventsChannel}.Sanitize()); err != nil {
+		return false, err
+	}
+	// notifications sent while not listening are lost
+	l.broker.NotifyAll()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		l.broker.Notify(notification.Payload)
+	}
+}
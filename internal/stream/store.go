@@ -0,0 +1,33 @@
+package stream
+
+import "context"
+
+// Listener feeds a Broker until ctx is done.
+type Listener interface {
+	Run(ctx context.Context) error
+}
+
+// AccountEventsSource is a store that reports account events itself
+// instead of through LISTEN/NOTIFY.
+type AccountEventsSource interface {
+	OnAccountEvents(listener func(owner string))
+}
+
+// StoreListener feeds a Broker from an AccountEventsSource, the SQLite
+// store has no NOTIFY to listen to.
+type StoreListener struct {
+	source AccountEventsSource
+	broker *Broker
+}
+
+func NewStoreListener(source AccountEventsSource, broker *Broker) *StoreListener {
+	return &StoreListener{source: source, broker: broker}
+}
+
+func (l *StoreListener) Run(ctx context.Context) error {
+	l.source.OnAccountEvents(l.broker.Notify)
+	defer l.source.OnAccountEvents(nil)
+
+	<-ctx.Done()
+	return ctx.Err()
+}
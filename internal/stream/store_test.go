@@ -0,0 +1,37 @@
+package stream
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	memdb "simple-bank/internal/db/memory"
+	"testing"
+	"time"
+)
+
+func TestStoreListener(t *testing.T) {
+	store := memdb.NewStore()
+	broker := NewBroker()
+	wake, _ := broker.Subscribe("alice")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- NewStoreListener(store, broker).Run(ctx)
+	}()
+
+	// the listener registers itself once it runs
+	require.Eventually(t, func() bool {
+		require.NoError(t, store.NotifyAccountEvents(ctx, "alice"))
+		select {
+		case <-wake:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+	require.NoError(t, store.NotifyAccountEvents(context.Background(), "alice"))
+	requireWoken(t, wake, false)
+}
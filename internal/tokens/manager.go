@@ -8,6 +8,17 @@ var (
 	ErrTokenExpired     = errors.New("token expired")
 	ErrTokenInvalid     = errors.New("token invalid")
 	ErrTokenNotValidYet = errors.New("token not valid yet")
+	ErrNoScopes         = errors.New("restricted token needs at least one scope")
+)
+
+// Issuer and the audiences are shared by every API accepting the tokens, a
+// token issued over HTTP is valid over gRPC as well.
+const (
+	Issuer            = "bank-service"
+	AudienceAccess    = "bank-service"
+	AudienceTwoFactor = "bank-service-2fa"
+	AudienceAPIKey    = "bank-service-api-key"
+	AudienceOAuth     = "bank-service-oauth"
 )
 
 type Manager interface {
@@ -3,9 +3,18 @@ package tokens
 import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"slices"
 	"time"
 )
 
+// Scopes a restricted payload can carry.
+const (
+	ScopeAccountsRead   = "accounts:read"
+	ScopeAccountsWrite  = "accounts:write"
+	ScopeTransfersWrite = "transfers:write"
+	ScopeWebhooksManage = "webhooks:manage"
+)
+
 type Payload struct {
 	ID        uuid.UUID `json:"id"`
 	Subject   string    `json:"username"`
@@ -14,6 +23,17 @@ type Payload struct {
 	NotBefore time.Time `json:"not_before"`
 	IssuedAt  time.Time `json:"issued_at"`
 	ExpiredAt time.Time `json:"expired_at"`
+	// Scopes limits what the bearer may do. Nil means no restriction, which
+	// is what first-party sessions get, so a restricted payload always
+	// carries at least one scope.
+	Scopes []string `json:"scopes,omitempty"`
+	// ClientID is set on tokens issued to an OAuth client.
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// HasScope reports whether the payload grants scope.
+func (p Payload) HasScope(scope string) bool {
+	return p.Scopes == nil || slices.Contains(p.Scopes, scope)
 }
 
 func (p Payload) GetExpirationTime() (*jwt.NumericDate, error) {
@@ -46,9 +66,15 @@ type PayloadCreationParams struct {
 	Issuer    string
 	NotBefore time.Time
 	Duration  time.Duration
+	Scopes    []string
+	ClientID  string
 }
 
 func NewPayload(params PayloadCreationParams) (*Payload, error) {
+	if params.Scopes != nil && len(params.Scopes) == 0 {
+		return nil, ErrNoScopes
+	}
+
 	tokenId, err := uuid.NewV7()
 	if err != nil {
 		return nil, err
@@ -61,5 +87,7 @@ func NewPayload(params PayloadCreationParams) (*Payload, error) {
 		NotBefore: params.NotBefore,
 		IssuedAt:  time.Now(),
 		ExpiredAt: time.Now().Add(params.Duration),
+		Scopes:    params.Scopes,
+		ClientID:  params.ClientID,
 	}, nil
 }
@@ -0,0 +1,44 @@
+package tokens
+
+import (
+	"github.com/stretchr/testify/require"
+	random2 "simple-bank/internal/random"
+	"testing"
+	"time"
+)
+
+func TestPayload_HasScope(t *testing.T) {
+	require.True(t, Payload{}.HasScope("accounts:read"))
+
+	payload := Payload{Scopes: []string{"accounts:read"}}
+	require.True(t, payload.HasScope("accounts:read"))
+	require.False(t, payload.HasScope("accounts:write"))
+}
+
+func TestNewPayload_EmptyScopes(t *testing.T) {
+	_, err := NewPayload(PayloadCreationParams{
+		Subject:  random2.Username(),
+		Duration: time.Minute,
+		Scopes:   []string{},
+	})
+	require.ErrorIs(t, err, ErrNoScopes)
+}
+
+func TestPasetoManager_Scopes(t *testing.T) {
+	manager, err := NewPasetoManager(random2.String(32))
+	require.NoError(t, err)
+
+	token, err := manager.CreateToken(PayloadCreationParams{
+		Subject:   random2.Username(),
+		Audience:  "bank-service",
+		Issuer:    "test",
+		NotBefore: time.Now(),
+		Duration:  time.Minute,
+		Scopes:    []string{"accounts:read", "transfers:write"},
+	})
+	require.NoError(t, err)
+
+	payload, err := manager.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, []string{"accounts:read", "transfers:write"}, payload.Scopes)
+}
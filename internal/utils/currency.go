@@ -1,16 +1,16 @@
 package utils
 
+import "slices"
+
 const (
 	CurrencyUSD = "USD"
 	CurrencyEUR = "EUR"
 	CurrencyCAD = "CAD"
 )
 
+// SupportedCurrencies are the currencies accounts can be opened in.
+var SupportedCurrencies = []string{CurrencyUSD, CurrencyEUR, CurrencyCAD}
+
 func IsSupportedCurrency(currency string) bool {
-	switch currency {
-	case CurrencyUSD, CurrencyEUR, CurrencyCAD:
-		return true
-	default:
-		return false
-	}
+	return slices.Contains(SupportedCurrencies, currency)
 }
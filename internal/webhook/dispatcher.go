@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"simple-bank/internal/db"
+	"simple-bank/internal/outbox"
+	"slices"
+	"time"
+)
+
+// EventTypes are the outbox events customers can subscribe to.
+var EventTypes = []string{
+	db.EventAccountCreated,
+	db.EventAccountBalanceChanged,
+	db.EventTransferCompleted,
+}
+
+// Payload is the body of every webhook request.
+type Payload struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Renderer renders the data of an event for every user it is about, keyed
+// by username. A user must only get what is theirs, the receiver of a
+// transfer doesn't see the sender's balance.
+type Renderer func(eventType string, payload []byte) (map[string]any, error)
+
+// Dispatcher is the outbox sink of webhooks: it queues a delivery for every
+// subscription interested in an event, the Sender makes the requests.
+// Queueing is idempotent, an event relayed twice is delivered once.
+type Dispatcher struct {
+	store  db.Store
+	render Renderer
+}
+
+func NewDispatcher(store db.Store, render Renderer) *Dispatcher {
+	return &Dispatcher{store: store, render: render}
+}
+
+func (d *Dispatcher) Publish(ctx context.Context, event outbox.Event) error {
+	data, err := d.render(event.Type, event.Payload)
+	if err != nil || len(data) == 0 {
+		return err
+	}
+
+	subscriptions, err := d.store.ListWebhookSubscriptionsForEvent(ctx, db.ListWebhookSubscriptionsForEventParams{
+		Owners:    slices.Sorted(maps.Keys(data)),
+		EventType: event.Type,
+	})
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+
+	bodies := make(map[string][]byte, len(data))
+	for _, subscription := range subscriptions {
+		body, ok := bodies[subscription.Owner]
+		if !ok {
+			if body, err = d.body(event, data[subscription.Owner]); err != nil {
+				return err
+			}
+			bodies[subscription.Owner] = body
+		}
+
+		err = d.store.CreateWebhookDelivery(ctx, db.CreateWebhookDeliveryParams{
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        body,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) body(event outbox.Event, data any) ([]byte, error) {
+	rendered, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Payload{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		Data:      rendered,
+	})
+}
@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"simple-bank/internal/api"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"simple-bank/internal/outbox"
+	"testing"
+	"time"
+)
+
+func newOutboxEvent(t *testing.T, eventType string, payload any) outbox.Event {
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	return outbox.Event{ID: 42, Type: eventType, Payload: data, CreatedAt: time.Now()}
+}
+
+func TestDispatcher_Publish(t *testing.T) {
+	transfer := db.TransferTxResult{
+		Transfer:    db.Transfer{ID: 5, FromAccountID: 1, ToAccountID: 2, Amount: 10},
+		FromAccount: db.Account{ID: 1, Owner: "bob", Balance: 90},
+		ToAccount:   db.Account{ID: 2, Owner: "alice", Balance: 1000},
+		FromEntry:   db.Entry{ID: 11, AccountID: 1, Amount: -10},
+		ToEntry:     db.Entry{ID: 12, AccountID: 2, Amount: 10},
+	}
+	internalTransfer := db.TransferTxResult{
+		FromAccount: db.Account{ID: 1, Owner: "alice"},
+		ToAccount:   db.Account{ID: 3, Owner: "alice"},
+	}
+
+	testCases := []struct {
+		name       string
+		event      outbox.Event
+		buildStubs func(store *mockdb.MockStore)
+	}{
+		{
+			name:  "account created",
+			event: newOutboxEvent(t, db.EventAccountCreated, db.Account{ID: 1, Owner: "alice"}),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListWebhookSubscriptionsForEvent(gomock.Any(), gomock.Eq(db.ListWebhookSubscriptionsForEventParams{
+						Owners:    []string{"alice"},
+						EventType: db.EventAccountCreated,
+					})).
+					Times(1).
+					Return([]db.WebhookSubscription{{ID: 7}, {ID: 8}}, nil)
+				store.EXPECT().
+					CreateWebhookDelivery(gomock.Any(), gomock.Cond[db.CreateWebhookDeliveryParams](func(x db.CreateWebhookDeliveryParams) bool {
+						var payload Payload
+						return (x.SubscriptionID == 7 || x.SubscriptionID == 8) &&
+							x.EventID == 42 &&
+							json.Unmarshal(x.Payload, &payload) == nil &&
+							payload.ID == 42 && payload.Type == db.EventAccountCreated
+					})).
+					Times(2)
+			},
+		},
+		{
+			name:  "transfer notifies both owners",
+			event: newOutboxEvent(t, db.EventTransferCompleted, transfer),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListWebhookSubscriptionsForEvent(gomock.Any(), gomock.Eq(db.ListWebhookSubscriptionsForEventParams{
+						Owners:    []string{"alice", "bob"},
+						EventType: db.EventTransferCompleted,
+					})).
+					Times(1).
+					Return([]db.WebhookSubscription{}, nil)
+				store.EXPECT().CreateWebhookDelivery(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			name:  "transfer payload holds the owner's side only",
+			event: newOutboxEvent(t, db.EventTransferCompleted, transfer),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListWebhookSubscriptionsForEvent(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.WebhookSubscription{{ID: 7, Owner: "bob"}, {ID: 8, Owner: "alice"}}, nil)
+				store.EXPECT().
+					CreateWebhookDelivery(gomock.Any(), gomock.Cond[db.CreateWebhookDeliveryParams](func(x db.CreateWebhookDeliveryParams) bool {
+						var payload struct {
+							Data struct {
+								FromAccount *db.Account `json:"from_account"`
+								ToAccount   *db.Account `json:"to_account"`
+							} `json:"data"`
+						}
+						if json.Unmarshal(x.Payload, &payload) != nil {
+							return false
+						}
+						from, to := payload.Data.FromAccount, payload.Data.ToAccount
+						switch x.SubscriptionID {
+						case 7:
+							return from != nil && from.Balance == 90 && to == nil
+						case 8:
+							return to != nil && to.Balance == 1000 && from == nil
+						}
+						return false
+					})).
+					Times(2)
+			},
+		},
+		{
+			name:  "transfer between own accounts",
+			event: newOutboxEvent(t, db.EventTransferCompleted, internalTransfer),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					ListWebhookSubscriptionsForEvent(gomock.Any(), gomock.Eq(db.ListWebhookSubscriptionsForEventParams{
+						Owners:    []string{"alice"},
+						EventType: db.EventTransferCompleted,
+					})).
+					Times(1)
+			},
+		},
+		{
+			name:  "not exposed",
+			event: newOutboxEvent(t, "user.created", map[string]string{}),
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().ListWebhookSubscriptionsForEvent(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			require.NoError(t, NewDispatcher(store, api.EventData).Publish(context.Background(), tc.event))
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"simple-bank/internal/db"
+	"simple-bank/internal/security"
+	"strconv"
+	"time"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+
+	// claimLease is how long a claimed delivery is hidden from other senders.
+	// It must outlast requestTimeout.
+	claimLease = time.Minute
+
+	minRetryBackoff = 30 * time.Second
+	maxRetryBackoff = 6 * time.Hour
+
+	maxErrorLength = 1024
+)
+
+// The errors recorded on a delivery are shown to the subscriber, they never
+// carry what the network or the endpoint said.
+var (
+	errInsecureURL           = errors.New("url must use https")
+	errDestinationNotAllowed = errors.New("destination address is not allowed")
+	errRequestTimeout        = errors.New("request timed out")
+	errRequestFailed         = errors.New("request failed")
+)
+
+// Sender makes the requests of queued deliveries. A delivery is retried with
+// exponential backoff until it succeeds or runs out of attempts. Every failed
+// attempt counts against its subscription, which is disabled once it fails
+// disableAfter times in a row; deliveries of a disabled subscription wait
+// until it is enabled again.
+type Sender struct {
+	store        db.Store
+	client       *http.Client
+	pollInterval time.Duration
+	batchSize    int32
+	maxAttempts  int32
+	disableAfter int32
+}
+
+func NewSender(store db.Store, pollInterval time.Duration, batchSize, maxAttempts, disableAfter int32) *Sender {
+	dialer := &net.Dialer{
+		Timeout: requestTimeout,
+		Control: security.PublicDialControl,
+	}
+	return &Sender{
+		store: store,
+		client: &http.Client{
+			Timeout: requestTimeout,
+			// no proxy, the dialer must see the address of the endpoint
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: requestTimeout,
+			},
+			// a redirect is a misconfigured endpoint, not a delivery
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		maxAttempts:  maxAttempts,
+		disableAfter: disableAfter,
+	}
+}
+
+// Run sends deliveries until ctx is done.
+func (s *Sender) Run(ctx context.Context) error {
+	for {
+		claimed, err := s.SendBatch(ctx)
+		if err != nil {
+			slog.Error("webhook sender failed", "err", err)
+		}
+
+		if claimed > 0 && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+// SendBatch claims one batch of due deliveries and sends it. It returns how
+// many deliveries were claimed.
+func (s *Sender) SendBatch(ctx context.Context) (int, error) {
+	deliveries, err := s.store.ClaimWebhookDeliveries(ctx, db.ClaimWebhookDeliveriesParams{
+		LeaseUntil: time.Now().Add(claimLease),
+		RowLimit:   s.batchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		if err = s.send(ctx, delivery); err != nil {
+			return len(deliveries), err
+		}
+	}
+	return len(deliveries), nil
+}
+
+func (s *Sender) send(ctx context.Context, delivery db.ClaimWebhookDeliveriesRow) error {
+	status, sendErr := s.post(ctx, delivery)
+	if sendErr == nil {
+		err := s.store.MarkWebhookDeliverySucceeded(ctx, db.MarkWebhookDeliverySucceededParams{
+			ID:             delivery.ID,
+			ResponseStatus: int32(status),
+		})
+		if err != nil {
+			return err
+		}
+		return s.store.ResetWebhookSubscriptionFailures(ctx, delivery.SubscriptionID)
+	}
+
+	nextStatus := db.WebhookDeliveryPending
+	if delivery.Attempts >= s.maxAttempts {
+		nextStatus = db.WebhookDeliveryFailed
+	}
+
+	message := sendErr.Error()
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+
+	err := s.store.MarkWebhookDeliveryFailed(ctx, db.MarkWebhookDeliveryFailedParams{
+		ID:             delivery.ID,
+		Status:         nextStatus,
+		ResponseStatus: int32(status),
+		LastError:      message,
+		NextAttemptAt:  time.Now().Add(retryBackoff(delivery.Attempts)),
+	})
+	if err != nil {
+		return err
+	}
+
+	subscription, err := s.store.RecordWebhookSubscriptionFailure(ctx, db.RecordWebhookSubscriptionFailureParams{
+		ID:           delivery.SubscriptionID,
+		DisableAfter: s.disableAfter,
+	})
+	if err != nil {
+		return err
+	}
+	if subscription.ConsecutiveFailures == s.disableAfter {
+		slog.Warn("webhook subscription disabled after repeated failures", "subscription_id", subscription.ID, "url", subscription.Url)
+	}
+	return nil
+}
+
+// post returns the response status, 0 when there was no response. Its
+// error is one of the errors above, what actually went wrong is logged.
+func (s *Sender) post(ctx context.Context, delivery db.ClaimWebhookDeliveriesRow) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		slog.Warn("webhook delivery failed", "delivery_id", delivery.ID, "err", err)
+		return 0, errRequestFailed
+	}
+	// subscriptions created before https was required
+	if req.URL.Scheme != "https" {
+		return 0, errInsecureURL
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "simple-bank-webhooks")
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(EventTypeHeader, delivery.EventType)
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, time.Now(), delivery.Payload))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		slog.Warn("webhook delivery failed", "delivery_id", delivery.ID, "err", err)
+		return 0, requestError(err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+func requestError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, security.ErrAddressNotAllowed):
+		return errDestinationNotAllowed
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return errRequestTimeout
+	default:
+		return errRequestFailed
+	}
+}
+
+// retryBackoff doubles with every attempt, attempts counts the one that
+// just failed.
+func retryBackoff(attempts int32) time.Duration {
+	backoff := minRetryBackoff
+	for i := int32(1); i < attempts; i++ {
+		backoff *= 2
+		if backoff >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return backoff
+}
@@ -0,0 +1,265 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"simple-bank/internal/db"
+	mockdb "simple-bank/internal/db/mock"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testMaxAttempts  = 3
+	testDisableAfter = 5
+)
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver stands in for a customer endpoint, it answers every request
+// with status.
+func newReceiver(t *testing.T, status int) (*httptest.Server, chan receivedRequest) {
+	received := make(chan receivedRequest, 10)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		received <- receivedRequest{header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+// newTestSender trusts the certificate of receiver and may reach it on the
+// loopback address.
+func newTestSender(store db.Store, receiver *httptest.Server) *Sender {
+	sender := NewSender(store, time.Second, 10, testMaxAttempts, testDisableAfter)
+	transport := sender.client.Transport.(*http.Transport)
+	transport.DialContext = (&net.Dialer{Timeout: requestTimeout}).DialContext
+	transport.TLSClientConfig = receiver.Client().Transport.(*http.Transport).TLSClientConfig
+	return sender
+}
+
+func randomDelivery(url string, attempts int32) db.ClaimWebhookDeliveriesRow {
+	payload, _ := json.Marshal(Payload{ID: 42, Type: db.EventAccountCreated, Data: json.RawMessage(`{"id":1}`)})
+	return db.ClaimWebhookDeliveriesRow{
+		ID:             11,
+		SubscriptionID: 7,
+		EventID:        42,
+		EventType:      db.EventAccountCreated,
+		Payload:        payload,
+		Status:         db.WebhookDeliveryPending,
+		Attempts:       attempts,
+		Url:            url,
+		Secret:         "whsec_test",
+	}
+}
+
+func TestSender_SendBatch(t *testing.T) {
+	testCases := []struct {
+		name       string
+		status     int
+		attempts   int32
+		buildStubs func(store *mockdb.MockStore)
+	}{
+		{
+			name:     "delivered",
+			status:   http.StatusNoContent,
+			attempts: 1,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					MarkWebhookDeliverySucceeded(gomock.Any(), gomock.Eq(db.MarkWebhookDeliverySucceededParams{
+						ID:             11,
+						ResponseStatus: http.StatusNoContent,
+					})).
+					Times(1)
+				store.EXPECT().
+					ResetWebhookSubscriptionFailures(gomock.Any(), gomock.Eq(int64(7))).
+					Times(1)
+			},
+		},
+		{
+			name:     "retried with backoff",
+			status:   http.StatusInternalServerError,
+			attempts: 2,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					MarkWebhookDeliveryFailed(gomock.Any(), gomock.Cond[db.MarkWebhookDeliveryFailedParams](func(x db.MarkWebhookDeliveryFailedParams) bool {
+						retryIn := time.Until(x.NextAttemptAt)
+						return x.ID == 11 &&
+							x.Status == db.WebhookDeliveryPending &&
+							x.ResponseStatus == http.StatusInternalServerError &&
+							x.LastError == "unexpected status 500" &&
+							retryIn > 59*time.Second && retryIn <= time.Minute
+					})).
+					Times(1)
+				store.EXPECT().
+					RecordWebhookSubscriptionFailure(gomock.Any(), gomock.Eq(db.RecordWebhookSubscriptionFailureParams{
+						ID:           7,
+						DisableAfter: testDisableAfter,
+					})).
+					Times(1).
+					Return(db.WebhookSubscription{ID: 7, ConsecutiveFailures: 1}, nil)
+			},
+		},
+		{
+			name:     "out of attempts",
+			status:   http.StatusGone,
+			attempts: testMaxAttempts,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					MarkWebhookDeliveryFailed(gomock.Any(), gomock.Cond[db.MarkWebhookDeliveryFailedParams](func(x db.MarkWebhookDeliveryFailedParams) bool {
+						return x.Status == db.WebhookDeliveryFailed && x.ResponseStatus == http.StatusGone
+					})).
+					Times(1)
+				store.EXPECT().
+					RecordWebhookSubscriptionFailure(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.WebhookSubscription{ID: 7, ConsecutiveFailures: testDisableAfter, DisabledAt: time.Now()}, nil)
+			},
+		},
+		{
+			name:     "redirect is a failure",
+			status:   http.StatusFound,
+			attempts: 1,
+			buildStubs: func(store *mockdb.MockStore) {
+				store.EXPECT().
+					MarkWebhookDeliveryFailed(gomock.Any(), gomock.Cond[db.MarkWebhookDeliveryFailedParams](func(x db.MarkWebhookDeliveryFailedParams) bool {
+						return x.ResponseStatus == http.StatusFound
+					})).
+					Times(1)
+				store.EXPECT().
+					RecordWebhookSubscriptionFailure(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			receiver, received := newReceiver(t, tc.status)
+			delivery := randomDelivery(receiver.URL, tc.attempts)
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				ClaimWebhookDeliveries(gomock.Any(), gomock.Cond[db.ClaimWebhookDeliveriesParams](func(x db.ClaimWebhookDeliveriesParams) bool {
+					return x.RowLimit == 10 && x.LeaseUntil.After(time.Now())
+				})).
+				Times(1).
+				Return([]db.ClaimWebhookDeliveriesRow{delivery}, nil)
+			tc.buildStubs(store)
+
+			claimed, err := newTestSender(store, receiver).SendBatch(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, 1, claimed)
+
+			request := <-received
+			require.Equal(t, delivery.Payload, json.RawMessage(request.body))
+			require.Equal(t, "application/json", request.header.Get("Content-Type"))
+			require.Equal(t, strconv.FormatInt(delivery.ID, 10), request.header.Get(DeliveryHeader))
+			require.Equal(t, delivery.EventType, request.header.Get(EventTypeHeader))
+			require.NoError(t, Verify(delivery.Secret, request.header.Get(SignatureHeader), request.body, time.Now(), time.Minute))
+		})
+	}
+}
+
+func TestSender_Unreachable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	receiver, _ := newReceiver(t, http.StatusOK)
+	receiver.Close()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().
+		ClaimWebhookDeliveries(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return([]db.ClaimWebhookDeliveriesRow{randomDelivery(receiver.URL, 1)}, nil)
+	store.EXPECT().
+		MarkWebhookDeliveryFailed(gomock.Any(), gomock.Cond[db.MarkWebhookDeliveryFailedParams](func(x db.MarkWebhookDeliveryFailedParams) bool {
+			return x.ResponseStatus == 0 && x.LastError == errRequestFailed.Error()
+		})).
+		Times(1)
+	store.EXPECT().
+		RecordWebhookSubscriptionFailure(gomock.Any(), gomock.Any()).
+		Times(1)
+
+	_, err := newTestSender(store, receiver).SendBatch(context.Background())
+	require.NoError(t, err)
+}
+
+func TestSender_Refused(t *testing.T) {
+	receiver, received := newReceiver(t, http.StatusOK)
+
+	testCases := []struct {
+		name     string
+		url      string
+		expected error
+	}{
+		{
+			name:     "loopback",
+			url:      receiver.URL,
+			expected: errDestinationNotAllowed,
+		},
+		{
+			name:     "name of a loopback address",
+			url:      strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1),
+			expected: errDestinationNotAllowed,
+		},
+		{
+			name:     "metadata service",
+			url:      "https://169.254.169.254/latest/meta-data",
+			expected: errDestinationNotAllowed,
+		},
+		{
+			name:     "plain http",
+			url:      "http://example.com/hooks",
+			expected: errInsecureURL,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().
+				ClaimWebhookDeliveries(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return([]db.ClaimWebhookDeliveriesRow{randomDelivery(tc.url, 1)}, nil)
+			store.EXPECT().
+				MarkWebhookDeliveryFailed(gomock.Any(), gomock.Cond[db.MarkWebhookDeliveryFailedParams](func(x db.MarkWebhookDeliveryFailedParams) bool {
+					return x.ResponseStatus == 0 && x.LastError == tc.expected.Error()
+				})).
+				Times(1)
+			store.EXPECT().
+				RecordWebhookSubscriptionFailure(gomock.Any(), gomock.Any()).
+				Times(1)
+
+			_, err := NewSender(store, time.Second, 10, testMaxAttempts, testDisableAfter).SendBatch(context.Background())
+			require.NoError(t, err)
+			require.Empty(t, received)
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	require.Equal(t, 30*time.Second, retryBackoff(1))
+	require.Equal(t, time.Minute, retryBackoff(2))
+	require.Equal(t, maxRetryBackoff, retryBackoff(30))
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	EventTypeHeader = "X-Event-Type"
+
+	signatureVersion = "v1"
+)
+
+var (
+	ErrSignatureMalformed = errors.New("webhook signature is malformed")
+	ErrSignatureMismatch  = errors.New("webhook signature does not match")
+	ErrSignatureExpired   = errors.New("webhook signature is too old")
+)
+
+// Sign returns the value of the signature header for body sent at
+// timestamp: "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+// Signing the timestamp along with the body lets receivers reject replays.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,%s=%s", t, signatureVersion, hex.EncodeToString(mac(secret, t, body)))
+}
+
+// Verify checks a signature header produced by Sign. Signatures older than
+// tolerance are refused even when they match.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var t, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return ErrSignatureMalformed
+		}
+		switch key {
+		case "t":
+			t = value
+		case signatureVersion:
+			signature = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || signature == "" {
+		return ErrSignatureMalformed
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrSignatureMalformed
+	}
+
+	if !hmac.Equal(expected, mac(secret, t, body)) {
+		return ErrSignatureMismatch
+	}
+	if now.Sub(time.Unix(unix, 0)).Abs() > tolerance {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+func mac(secret, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,39 @@
+package webhook
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"id":1}`)
+	sentAt := time.Unix(1700000000, 0)
+	header := Sign(secret, sentAt, body)
+	require.Regexp(t, `^t=1700000000,v1=[0-9a-f]{64}$`, header)
+
+	testCases := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+		now    time.Time
+		err    error
+	}{
+		{name: "valid", secret: secret, header: header, body: body, now: sentAt.Add(time.Minute)},
+		{name: "other secret", secret: "whsec_other", header: header, body: body, now: sentAt, err: ErrSignatureMismatch},
+		{name: "tampered body", secret: secret, header: header, body: []byte(`{"id":2}`), now: sentAt, err: ErrSignatureMismatch},
+		{name: "too old", secret: secret, header: header, body: body, now: sentAt.Add(10 * time.Minute), err: ErrSignatureExpired},
+		{name: "missing signature", secret: secret, header: "t=1700000000", body: body, now: sentAt, err: ErrSignatureMalformed},
+		{name: "garbage", secret: secret, header: "garbage", body: body, now: sentAt, err: ErrSignatureMalformed},
+		{name: "not hex", secret: secret, header: "t=1700000000,v1=zz", body: body, now: sentAt, err: ErrSignatureMalformed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Verify(tc.secret, tc.header, tc.body, tc.now, 5*time.Minute)
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
@@ -1,10 +0,0 @@
-#!/bin/sh
-
-set -e
-
-echo "running the migration"
-source /app.env
-/migrate -path /migration -database "$DB_SOURCE" -verbose up
-
-echo "start the app"
-exec "$@"
\ No newline at end of file